@@ -2,6 +2,10 @@ package cke
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net"
 	"strings"
 	"time"
@@ -13,6 +17,7 @@ import (
 const (
 	defaultDialTimeout = 30 * time.Second
 	defaultKeepAlive   = 5 * time.Second
+	defaultPingTimeout = 5 * time.Second
 
 	// DefaultRunTimeout is the timeout value for Agent.Run().
 	DefaultRunTimeout = 10 * time.Minute
@@ -44,17 +49,25 @@ type Agent interface {
 	// RunWithTimeout run command with given timeout.
 	// If timeout is 0, the command will run indefinitely.
 	RunWithTimeout(command, input string, timeout time.Duration) (stdout, stderr []byte, err error)
+
+	// RunWithReader run command with stdin read from input.
+	// This is for large inputs that should not be kept in memory.
+	// If timeout is 0, the command will run indefinitely.
+	RunWithReader(command string, input io.Reader, timeout time.Duration) (stdout, stderr []byte, err error)
 }
 
 type sshAgent struct {
-	node   *Node
-	client *ssh.Client
-	conn   net.Conn
+	node    *Node
+	client  *ssh.Client
+	conn    net.Conn
+	hostKey ssh.PublicKey
 }
 
 // SSHAgent creates an Agent that communicates over SSH.
-// It returns non-nil error when connection could not be established.
-func SSHAgent(node *Node, privkey string) (Agent, error) {
+// The host key of the node is verified by hostKeys.
+// It returns non-nil error when connection could not be established
+// or the container engine is not available on the node.
+func SSHAgent(ctx context.Context, node *Node, privkey, engine string, hostKeys *HostKeyVerifier) (Agent, error) {
 	conn, err := agentDialer.Dial("tcp", node.Address+":22")
 	if err != nil {
 		log.Error("failed to dial: ", map[string]interface{}{
@@ -68,12 +81,18 @@ func SSHAgent(node *Node, privkey string) (Agent, error) {
 		return nil, err
 	}
 
+	var hostKey ssh.PublicKey
+	verify := hostKeys.Callback(ctx, node)
 	config := &ssh.ClientConfig{
 		User: node.User,
 		Auth: []ssh.AuthMethod{
 			ssh.PublicKeys(signer),
 		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return verify(hostname, remote, key)
+		},
+		HostKeyAlgorithms: hostKeys.Algorithms(node),
 	}
 
 	err = conn.SetDeadline(time.Now().Add(defaultDialTimeout))
@@ -94,11 +113,12 @@ func SSHAgent(node *Node, privkey string) (Agent, error) {
 	}
 
 	a := &sshAgent{
-		node:   node,
-		client: ssh.NewClient(clientConn, channelCh, reqCh),
-		conn:   conn,
+		node:    node,
+		client:  ssh.NewClient(clientConn, channelCh, reqCh),
+		conn:    conn,
+		hostKey: hostKey,
 	}
-	_, _, err = a.Run("docker version")
+	_, _, err = a.Run(engineCheckCommand(engine))
 	if err != nil {
 		a.Close()
 		return nil, err
@@ -113,6 +133,28 @@ func (a *sshAgent) Close() error {
 	return err
 }
 
+// ping checks if the connection is still alive.
+func (a *sshAgent) ping() error {
+	if a.client == nil {
+		return errors.New("agent is closed")
+	}
+
+	// Other sessions may be running on the connection, so this does not
+	// use conn.SetDeadline.  The caller closes the agent on errors.
+	ch := make(chan error, 1)
+	go func() {
+		_, _, err := a.client.SendRequest("keepalive@openssh.com", true, nil)
+		ch <- err
+	}()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(defaultPingTimeout):
+		return errors.New("ping timed out")
+	}
+}
+
 func (a *sshAgent) Run(command string) ([]byte, []byte, error) {
 	return a.RunWithTimeout(command, "", DefaultRunTimeout)
 }
@@ -123,15 +165,14 @@ func (a *sshAgent) RunWithInput(command, input string) error {
 }
 
 func (a *sshAgent) RunWithTimeout(command, input string, timeout time.Duration) ([]byte, []byte, error) {
-	if timeout > 0 {
-		err := a.conn.SetDeadline(time.Now().Add(timeout))
-		if err != nil {
-			return nil, nil, err
-		}
-
-		defer a.conn.SetDeadline(time.Time{})
+	var r io.Reader
+	if len(input) > 0 {
+		r = strings.NewReader(input)
 	}
+	return a.RunWithReader(command, r, timeout)
+}
 
+func (a *sshAgent) RunWithReader(command string, input io.Reader, timeout time.Duration) ([]byte, []byte, error) {
 	session, err := a.client.NewSession()
 	if err != nil {
 		log.Error("failed to create session: ", map[string]interface{}{
@@ -141,8 +182,27 @@ func (a *sshAgent) RunWithTimeout(command, input string, timeout time.Duration)
 	}
 	defer session.Close()
 
-	if len(input) > 0 {
-		session.Stdin = strings.NewReader(input)
+	// Sessions share the connection, so the timeout is enforced by
+	// closing this session rather than setting a deadline on conn.
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				session.Close()
+			case <-done:
+			}
+		}()
+	}
+
+	if input != nil {
+		session.Stdin = input
 	}
 
 	var stdoutBuff bytes.Buffer
@@ -152,6 +212,9 @@ func (a *sshAgent) RunWithTimeout(command, input string, timeout time.Duration)
 	err = session.Run(command)
 	stdout := stdoutBuff.Bytes()
 	stderr := stderrBuff.Bytes()
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("command timed out after %s: %w", timeout, err)
+	}
 	if err != nil {
 		log.Error("failed to run command: ", map[string]interface{}{
 			log.FnError: err,
@@ -0,0 +1,128 @@
+package cke
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/cybozu-go/log"
+)
+
+// DefaultSSHConnectConcurrency is the default number of SSH connections
+// established concurrently.
+const DefaultSSHConnectConcurrency = 32
+
+// AgentPool keeps SSH agents across infrastructure instances so that
+// connections to nodes need not be re-established in every operation loop.
+//
+// A pooled agent is re-created when its connection is dead, when the
+// parameters to login the node have changed, or when the host key of the
+// node is no longer trusted.
+type AgentPool struct {
+	mu     sync.Mutex
+	agents map[string]*pooledAgent
+}
+
+type pooledAgent struct {
+	agent    Agent
+	identity string
+}
+
+// NewAgentPool creates an empty AgentPool.
+func NewAgentPool() *AgentPool {
+	return &AgentPool{
+		agents: make(map[string]*pooledAgent),
+	}
+}
+
+// agentIdentity summarizes the parameters to login the node.
+func agentIdentity(node *Node, privkey, engine string) string {
+	h := sha256.New()
+	for _, s := range []string{node.User, engine, privkey} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns a live agent for the node.  If there is no reusable agent,
+// this connects the node and adds a new agent to the pool.
+func (p *AgentPool) get(ctx context.Context, node *Node, privkey, engine string, hostKeys *HostKeyVerifier) (Agent, error) {
+	identity := agentIdentity(node, privkey, engine)
+
+	p.mu.Lock()
+	pa := p.agents[node.Address]
+	delete(p.agents, node.Address)
+	p.mu.Unlock()
+
+	if pa != nil {
+		if p.reusable(pa, node, identity, hostKeys) {
+			p.mu.Lock()
+			p.agents[node.Address] = pa
+			p.mu.Unlock()
+			return pa.agent, nil
+		}
+		pa.agent.Close()
+	}
+
+	a, err := SSHAgent(ctx, node, privkey, engine, hostKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.agents[node.Address] = &pooledAgent{agent: a, identity: identity}
+	p.mu.Unlock()
+	return a, nil
+}
+
+func (p *AgentPool) reusable(pa *pooledAgent, node *Node, identity string, hostKeys *HostKeyVerifier) bool {
+	if pa.identity != identity {
+		return false
+	}
+
+	sa, ok := pa.agent.(*sshAgent)
+	if !ok {
+		return true
+	}
+	if sa.hostKey != nil && !hostKeys.accepts(node, sa.hostKey) {
+		return false
+	}
+	if err := sa.ping(); err != nil {
+		log.Info("reconnecting to the node", map[string]interface{}{
+			log.FnError: err,
+			"address":   node.Address,
+		})
+		return false
+	}
+	return true
+}
+
+// retain closes and removes agents for nodes not in the list.
+func (p *AgentPool) retain(nodes []*Node) {
+	addresses := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		addresses[n.Address] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, pa := range p.agents {
+		if addresses[addr] {
+			continue
+		}
+		pa.agent.Close()
+		delete(p.agents, addr)
+	}
+}
+
+// Close closes all agents in the pool.
+func (p *AgentPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, pa := range p.agents {
+		pa.agent.Close()
+		delete(p.agents, addr)
+	}
+}
@@ -0,0 +1,48 @@
+package cke
+
+import "time"
+
+// PendingOperationStatus is the approval status of a pending operation.
+type PendingOperationStatus string
+
+// Approval statuses of pending operations.
+const (
+	PendingOperationWaiting  = PendingOperationStatus("pending")
+	PendingOperationApproved = PendingOperationStatus("approved")
+	PendingOperationRejected = PendingOperationStatus("rejected")
+)
+
+// PendingOperation is an operation that is not run until approved.
+type PendingOperation struct {
+	ID        string                 `json:"id"`
+	Operation string                 `json:"operation"`
+	Targets   []string               `json:"targets,omitempty"`
+	Phase     OperationPhase         `json:"phase"`
+	Status    PendingOperationStatus `json:"status"`
+	Requested time.Time              `json:"requested"`
+	// Decided is the time when the operation was approved or rejected.
+	Decided time.Time `json:"decided"`
+}
+
+// NewPendingOperation creates a PendingOperation for op in phase.
+func NewPendingOperation(op Operator, phase OperationPhase, now time.Time) *PendingOperation {
+	return &PendingOperation{
+		ID:        OperationID(op),
+		Operation: op.Name(),
+		Targets:   op.Targets(),
+		Phase:     phase,
+		Status:    PendingOperationWaiting,
+		Requested: now,
+	}
+}
+
+// Expired returns true if the entry is older than expiry at now.
+// Unapproved entries expire after expiry since requested, and approved
+// or rejected entries expire after expiry since decided.
+func (p *PendingOperation) Expired(expiry time.Duration, now time.Time) bool {
+	since := p.Requested
+	if p.Status != PendingOperationWaiting {
+		since = p.Decided
+	}
+	return now.Sub(since) >= expiry
+}
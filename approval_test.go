@@ -0,0 +1,56 @@
+package cke
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/utils/pointer"
+)
+
+func TestApproval(t *testing.T) {
+	t.Parallel()
+
+	if (Approval{}).NeedsApproval("remove-node") {
+		t.Error("disabled approval should not hold operations")
+	}
+	if !(Approval{Enabled: true}).NeedsApproval("remove-node") {
+		t.Error("remove-node should need approval by default")
+	}
+	if (Approval{Enabled: true}).NeedsApproval("kubelet-restart") {
+		t.Error("kubelet-restart should not need approval by default")
+	}
+
+	a := Approval{Enabled: true, Operations: []string{"kubelet-restart"}}
+	if !a.NeedsApproval("kubelet-restart") || a.NeedsApproval("remove-node") {
+		t.Error("operations should replace the default")
+	}
+
+	if (Approval{}).Expiry() != DefaultApprovalExpiry {
+		t.Error("unexpected default expiry")
+	}
+	if (Approval{ExpirySeconds: pointer.Int(60)}).Expiry() != time.Minute {
+		t.Error("unexpected expiry")
+	}
+}
+
+func TestPendingOperationExpired(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	p := NewPendingOperation(testOperator{"remove-node", []string{"node1"}}, PhaseK8sMaintain, now)
+	if p.Expired(time.Hour, now.Add(59*time.Minute)) {
+		t.Error("pending operation should not expire yet")
+	}
+	if !p.Expired(time.Hour, now.Add(time.Hour)) {
+		t.Error("pending operation should expire")
+	}
+
+	p.Status = PendingOperationApproved
+	p.Decided = now.Add(30 * time.Minute)
+	if p.Expired(time.Hour, now.Add(time.Hour)) {
+		t.Error("approved operation should expire after the approval")
+	}
+	if !p.Expired(time.Hour, now.Add(90*time.Minute)) {
+		t.Error("approved operation should expire")
+	}
+}
@@ -0,0 +1,148 @@
+package cke
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// Backend types.
+const (
+	BackendVault   = "vault"
+	BackendBuiltin = "builtin"
+)
+
+// Names of secrets kept in Backend.
+const (
+	// SecretSSH holds SSH private keys to login to nodes.
+	// Keys are node addresses.  Empty key holds the default key.
+	SecretSSH = "ssh"
+	// SecretK8s holds encryption keys for Kubernetes Secrets.
+	SecretK8s = "k8s"
+)
+
+// DefaultBuiltinKeyFile is the default path of the key file for the built-in backend.
+const DefaultBuiltinKeyFile = "/etc/cke/builtin.key"
+
+// BackendConfig selects the backend to issue certificates and keep secrets.
+type BackendConfig struct {
+	// Type is either "vault" or "builtin".
+	Type string `json:"type"`
+}
+
+// Validate validates the backend configuration.
+func (c *BackendConfig) Validate() error {
+	switch c.Type {
+	case BackendVault, BackendBuiltin:
+		return nil
+	}
+	return errors.New("unknown backend type: " + c.Type)
+}
+
+// CertificateRequest is a request to issue a certificate.
+type CertificateRequest struct {
+	// Role is the name of the role to issue the certificate.
+	// Vault restricts certificates by roles.
+	Role string
+	// Onetime is true if the role should not be kept after issuing.
+	Onetime bool
+
+	CommonName   string
+	Organization string
+	AltNames     []string
+	IPAddresses  []string
+
+	ServerAuth bool
+	ClientAuth bool
+	// Signing is true if the key is used to sign other data, e.g. service account tokens.
+	Signing bool
+
+	// TTL is the lifetime of the certificate.  Zero means MaxTTL.
+	TTL time.Duration
+	// MaxTTL is the maximum lifetime of certificates issued by the role.
+	MaxTTL time.Duration
+}
+
+// Backend is the interface to issue certificates and keep secrets.
+type Backend interface {
+	// IssueCertificate issues a certificate and its private key from the CA.
+	// They are returned in PEM format.
+	IssueCertificate(ctx context.Context, ca string, req CertificateRequest) (crt, key string, err error)
+
+	// DefaultIssuer returns the ID of the issuer to sign certificates of the CA.
+	DefaultIssuer(ctx context.Context, ca string) (string, error)
+	// GenerateIssuer generates a new root certificate of the CA without making it
+	// the default issuer.  This returns the ID of the new issuer and the PEM encoded certificate.
+	GenerateIssuer(ctx context.Context, ca, commonName string, ttl time.Duration) (id, cert string, err error)
+	// SetDefaultIssuer makes the issuer the default one of the CA.
+	SetDefaultIssuer(ctx context.Context, ca, id string) error
+	// DeleteIssuer deletes the issuer of the CA.
+	DeleteIssuer(ctx context.Context, ca, id string) error
+
+	// ReadSecret reads a secret.  If the secret does not exist, this returns ErrNotFound.
+	ReadSecret(ctx context.Context, name string) (map[string]string, error)
+	// WriteSecret writes a secret.
+	WriteSecret(ctx context.Context, name string, data map[string]string) error
+}
+
+// OpenBackend returns the Backend configured in etcd.
+// If not configured, Vault is used as the backend.
+// vc is called only if the backend is Vault.
+func OpenBackend(ctx context.Context, s Storage, vc func() (*vault.Client, error)) (Backend, error) {
+	cfg, err := s.GetBackendConfig(ctx)
+	switch err {
+	case nil:
+	case ErrNotFound:
+		cfg = &BackendConfig{Type: BackendVault}
+	default:
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case BackendVault:
+		client, err := vc()
+		if err != nil {
+			return nil, err
+		}
+		return VaultBackend{client}, nil
+	case BackendBuiltin:
+		v := builtinKey.Load()
+		if v == nil {
+			return nil, errors.New("key for the built-in backend is not loaded")
+		}
+		return NewBuiltinBackend(s, v.([]byte))
+	}
+	return nil, errors.New("unknown backend type: " + cfg.Type)
+}
+
+var builtinKey atomic.Value
+
+// LoadBuiltinKey loads the key to encrypt data of the built-in backend from a file.
+// The file should contain a base64 encoded 32-byte key.
+// If the file does not exist, this does nothing.
+func LoadBuiltinKey(p string) error {
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("invalid key in %s: %w", p, err)
+	}
+	if len(key) != builtinKeySize {
+		return fmt.Errorf("key in %s must be %d bytes", p, builtinKeySize)
+	}
+	builtinKey.Store(key)
+	return nil
+}
@@ -0,0 +1,391 @@
+package cke
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	builtinKeySize = 32
+	builtinRSABits = 2048
+
+	// builtinBackdate is the duration to backdate NotBefore of certificates
+	// to tolerate clock skew.
+	builtinBackdate = 30 * time.Second
+)
+
+// BuiltinBackend is a Backend that keeps CA keys and secrets in etcd.
+// They are encrypted by AES-GCM with a key given to CKE and ckecli.
+// Certificates are signed in-process.
+type BuiltinBackend struct {
+	storage Storage
+	aead    cipher.AEAD
+}
+
+var _ Backend = &BuiltinBackend{}
+
+// builtinCA is the data of a CA stored in etcd.
+type builtinCA struct {
+	Default string          `json:"default"`
+	Issuers []builtinIssuer `json:"issuers"`
+}
+
+type builtinIssuer struct {
+	ID          string `json:"id"`
+	Certificate string `json:"certificate"`
+	// Key is the encrypted private key in PKCS #1 DER form.
+	Key []byte `json:"key"`
+}
+
+func (c *builtinCA) issuer(id string) *builtinIssuer {
+	for i := range c.Issuers {
+		if c.Issuers[i].ID == id {
+			return &c.Issuers[i]
+		}
+	}
+	return nil
+}
+
+// NewBuiltinBackend creates a BuiltinBackend with key to encrypt data.
+func NewBuiltinBackend(s Storage, key []byte) (*BuiltinBackend, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &BuiltinBackend{storage: s, aead: aead}, nil
+}
+
+// GenerateBuiltinKey generates a key for the built-in backend.
+func GenerateBuiltinKey() ([]byte, error) {
+	key := make([]byte, builtinKeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// encrypt encrypts data.  The etcd key is used as additional data
+// so that the ciphertext cannot be moved to other keys.
+func (b *BuiltinBackend) encrypt(etcdKey string, data []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, data, []byte(etcdKey)), nil
+}
+
+func (b *BuiltinBackend) decrypt(etcdKey string, data []byte) ([]byte, error) {
+	size := b.aead.NonceSize()
+	if len(data) < size {
+		return nil, errors.New("too short ciphertext")
+	}
+	return b.aead.Open(nil, data[:size], data[size:], []byte(etcdKey))
+}
+
+func (b *BuiltinBackend) getCA(ctx context.Context, ca string) (*builtinCA, int64, error) {
+	resp, err := b.storage.Get(ctx, KeyBuiltinCAPrefix+ca)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, ErrNotFound
+	}
+
+	data := new(builtinCA)
+	err = json.Unmarshal(resp.Kvs[0].Value, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, resp.Kvs[0].ModRevision, nil
+}
+
+// updateCA updates the data of a CA by f.  If the data has been updated
+// concurrently, this retries.
+func (b *BuiltinBackend) updateCA(ctx context.Context, ca string, f func(data *builtinCA) error) error {
+	key := KeyBuiltinCAPrefix + ca
+	for {
+		data, rev, err := b.getCA(ctx, ca)
+		switch err {
+		case nil:
+		case ErrNotFound:
+			data = new(builtinCA)
+		default:
+			return err
+		}
+
+		err = f(data)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+
+		resp, err := b.storage.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+			Then(clientv3.OpPut(key, string(value))).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			return nil
+		}
+	}
+}
+
+// signer returns the certificate and the private key of the default issuer.
+func (b *BuiltinBackend) signer(ctx context.Context, ca string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	data, _, err := b.getCA(ctx, ca)
+	if err != nil {
+		return nil, nil, err
+	}
+	iss := data.issuer(data.Default)
+	if iss == nil {
+		return nil, nil, fmt.Errorf("no default issuer for %s", ca)
+	}
+
+	block, _ := pem.Decode([]byte(iss.Certificate))
+	if block == nil {
+		return nil, nil, fmt.Errorf("invalid certificate of %s", ca)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := b.decrypt(KeyBuiltinCAPrefix+ca, iss.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt the key of %s: %w", ca, err)
+	}
+	key, err := x509.ParsePKCS1PrivateKey(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func encodeCertificate(der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// IssueCertificate implements Backend.
+func (b *BuiltinBackend) IssueCertificate(ctx context.Context, ca string, req CertificateRequest) (crt, key string, err error) {
+	caCert, caKey, err := b.signer(ctx, ca)
+	if err != nil {
+		return "", "", err
+	}
+
+	ttl := req.TTL
+	if ttl <= 0 || ttl > req.MaxTTL {
+		ttl = req.MaxTTL
+	}
+	now := time.Now()
+	notAfter := now.Add(ttl)
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return "", "", err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: req.CommonName},
+		NotBefore:             now.Add(-builtinBackdate),
+		NotAfter:              notAfter,
+		DNSNames:              req.AltNames,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+	}
+	if req.Organization != "" {
+		tmpl.Subject.Organization = []string{req.Organization}
+	}
+	for _, a := range req.IPAddresses {
+		ip := net.ParseIP(a)
+		if ip == nil {
+			return "", "", fmt.Errorf("invalid IP address: %s", a)
+		}
+		tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+	}
+	if req.ServerAuth {
+		tmpl.ExtKeyUsage = append(tmpl.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
+	}
+	if req.ClientAuth {
+		tmpl.ExtKeyUsage = append(tmpl.ExtKeyUsage, x509.ExtKeyUsageClientAuth)
+	}
+	if req.Signing {
+		tmpl.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign
+	}
+
+	priv, err := rsa.GenerateKey(rand.Reader, builtinRSABits)
+	if err != nil {
+		return "", "", err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &priv.PublicKey, caKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	key = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}))
+	return encodeCertificate(der), key, nil
+}
+
+// DefaultIssuer implements Backend.
+func (b *BuiltinBackend) DefaultIssuer(ctx context.Context, ca string) (string, error) {
+	data, _, err := b.getCA(ctx, ca)
+	if err != nil {
+		return "", err
+	}
+	if data.Default == "" {
+		return "", fmt.Errorf("no default issuer for %s", ca)
+	}
+	return data.Default, nil
+}
+
+// GenerateIssuer implements Backend.
+// If the CA has no issuers, the new issuer becomes the default one.
+func (b *BuiltinBackend) GenerateIssuer(ctx context.Context, ca, commonName string, ttl time.Duration) (id, cert string, err error) {
+	idBytes := make([]byte, 16)
+	_, err = rand.Read(idBytes)
+	if err != nil {
+		return "", "", err
+	}
+	id = hex.EncodeToString(idBytes)
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-builtinBackdate),
+		NotAfter:              now.Add(ttl),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	priv, err := rsa.GenerateKey(rand.Reader, builtinRSABits)
+	if err != nil {
+		return "", "", err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		return "", "", err
+	}
+	encKey, err := b.encrypt(KeyBuiltinCAPrefix+ca, x509.MarshalPKCS1PrivateKey(priv))
+	if err != nil {
+		return "", "", err
+	}
+
+	cert = encodeCertificate(der)
+	err = b.updateCA(ctx, ca, func(data *builtinCA) error {
+		data.Issuers = append(data.Issuers, builtinIssuer{
+			ID:          id,
+			Certificate: cert,
+			Key:         encKey,
+		})
+		if data.Default == "" {
+			data.Default = id
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return id, cert, nil
+}
+
+// SetDefaultIssuer implements Backend.
+func (b *BuiltinBackend) SetDefaultIssuer(ctx context.Context, ca, id string) error {
+	return b.updateCA(ctx, ca, func(data *builtinCA) error {
+		if data.issuer(id) == nil {
+			return fmt.Errorf("no such issuer for %s: %s", ca, id)
+		}
+		data.Default = id
+		return nil
+	})
+}
+
+// DeleteIssuer implements Backend.
+func (b *BuiltinBackend) DeleteIssuer(ctx context.Context, ca, id string) error {
+	return b.updateCA(ctx, ca, func(data *builtinCA) error {
+		if data.Default == id {
+			return fmt.Errorf("cannot delete the default issuer of %s", ca)
+		}
+		issuers := data.Issuers[:0]
+		for _, iss := range data.Issuers {
+			if iss.ID != id {
+				issuers = append(issuers, iss)
+			}
+		}
+		data.Issuers = issuers
+		return nil
+	})
+}
+
+// ReadSecret implements Backend.
+func (b *BuiltinBackend) ReadSecret(ctx context.Context, name string) (map[string]string, error) {
+	key := KeyBuiltinSecretsPrefix + name
+	resp, err := b.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	plain, err := b.decrypt(key, resp.Kvs[0].Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret %s: %w", name, err)
+	}
+	var data map[string]string
+	err = json.Unmarshal(plain, &data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// WriteSecret implements Backend.
+func (b *BuiltinBackend) WriteSecret(ctx context.Context, name string, data map[string]string) error {
+	key := KeyBuiltinSecretsPrefix + name
+	plain, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	value, err := b.encrypt(key, plain)
+	if err != nil {
+		return err
+	}
+	_, err = b.storage.Put(ctx, key, string(value))
+	return err
+}
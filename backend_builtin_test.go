@@ -0,0 +1,259 @@
+package cke
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseTestCertificate(t *testing.T, data string) *x509.Certificate {
+	t.Helper()
+
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		t.Fatal("no PEM block")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func testBuiltinBackendConfig(t *testing.T) {
+	t.Parallel()
+
+	client := newEtcdClient(t)
+	defer client.Close()
+	storage := Storage{client}
+	ctx := context.Background()
+
+	_, err := storage.GetBackendConfig(ctx)
+	if err != ErrNotFound {
+		t.Fatal("backend config found.")
+	}
+
+	cfg := &BackendConfig{Type: BackendBuiltin}
+	err = storage.PutBackendConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := storage.GetBackendConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, got) {
+		t.Errorf("unexpected backend config: %v", got)
+	}
+
+	key, err := GenerateBuiltinKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	builtinKey.Store(key)
+	b, err := OpenBackend(ctx, storage, getVaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.(*BuiltinBackend); !ok {
+		t.Errorf("unexpected backend: %T", b)
+	}
+}
+
+func testBuiltinBackendSecret(t *testing.T) {
+	t.Parallel()
+
+	client := newEtcdClient(t)
+	defer client.Close()
+	storage := Storage{client}
+	ctx := context.Background()
+
+	key, err := GenerateBuiltinKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewBuiltinBackend(storage, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = b.ReadSecret(ctx, SecretSSH)
+	if err != ErrNotFound {
+		t.Fatal("secret found.")
+	}
+
+	data := map[string]string{"": "default key", "10.0.0.1": "node key"}
+	err = b.WriteSecret(ctx, SecretSSH, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.ReadSecret(ctx, SecretSSH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, got) {
+		t.Errorf("unexpected secret: %v", got)
+	}
+
+	resp, err := storage.Get(ctx, KeyBuiltinSecretsPrefix+SecretSSH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(resp.Kvs[0].Value), "node key") {
+		t.Error("secret is not encrypted")
+	}
+
+	otherKey, err := GenerateBuiltinKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewBuiltinBackend(storage, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = other.ReadSecret(ctx, SecretSSH)
+	if err == nil {
+		t.Error("secret should not be decrypted with another key")
+	}
+}
+
+func testBuiltinBackendCertificate(t *testing.T) {
+	t.Parallel()
+
+	client := newEtcdClient(t)
+	defer client.Close()
+	storage := Storage{client}
+	ctx := context.Background()
+
+	key, err := GenerateBuiltinKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewBuiltinBackend(storage, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = b.DefaultIssuer(ctx, CAKubernetes)
+	if err != ErrNotFound {
+		t.Fatal("issuer found.")
+	}
+
+	oldID, oldCA, err := b.GenerateIssuer(ctx, CAKubernetes, "kubernetes CA", 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := b.DefaultIssuer(ctx, CAKubernetes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != oldID {
+		t.Errorf("the first issuer should be the default: %s", id)
+	}
+
+	req := CertificateRequest{
+		Role:         RoleKubelet,
+		CommonName:   "system:node:node1",
+		Organization: "system:nodes",
+		AltNames:     []string{"localhost", "node1"},
+		IPAddresses:  []string{"127.0.0.1", "10.0.0.1"},
+		ServerAuth:   true,
+		ClientAuth:   true,
+		TTL:          time.Hour,
+		MaxTTL:       maxCertificateTTL,
+	}
+	crt, _, err := b.IssueCertificate(ctx, CAKubernetes, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := parseTestCertificate(t, crt)
+	if cert.Subject.CommonName != req.CommonName {
+		t.Errorf("unexpected common name: %s", cert.Subject.CommonName)
+	}
+	if !reflect.DeepEqual(cert.Subject.Organization, []string{req.Organization}) {
+		t.Errorf("unexpected organization: %v", cert.Subject.Organization)
+	}
+	if !reflect.DeepEqual(cert.DNSNames, req.AltNames) {
+		t.Errorf("unexpected DNS names: %v", cert.DNSNames)
+	}
+	if len(cert.IPAddresses) != 2 || !cert.IPAddresses[1].Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("unexpected IP addresses: %v", cert.IPAddresses)
+	}
+	if cert.NotAfter.After(time.Now().Add(time.Hour)) {
+		t.Errorf("certificate lives too long: %v", cert.NotAfter)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM([]byte(oldCA))
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// TTL is capped by the lifetime of the CA.
+	req.TTL = 48 * time.Hour
+	crt, _, err = b.IssueCertificate(ctx, CAKubernetes, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parseTestCertificate(t, crt).NotAfter.After(parseTestCertificate(t, oldCA).NotAfter) {
+		t.Error("certificate outlives the CA")
+	}
+
+	newID, newCA, err := b.GenerateIssuer(ctx, CAKubernetes, "kubernetes CA", 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err = b.DefaultIssuer(ctx, CAKubernetes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != oldID {
+		t.Errorf("the default issuer should not be changed: %s", id)
+	}
+
+	err = b.SetDefaultIssuer(ctx, CAKubernetes, newID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.DeleteIssuer(ctx, CAKubernetes, newID)
+	if err == nil {
+		t.Error("the default issuer should not be deleted")
+	}
+	err = b.DeleteIssuer(ctx, CAKubernetes, oldID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.SetDefaultIssuer(ctx, CAKubernetes, oldID)
+	if err == nil {
+		t.Error("deleted issuer should not be the default")
+	}
+
+	crt, _, err = b.IssueCertificate(ctx, CAKubernetes, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool = x509.NewCertPool()
+	pool.AppendCertsFromPEM([]byte(newCA))
+	_, err = parseTestCertificate(t, crt).Verify(x509.VerifyOptions{
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBuiltinBackend(t *testing.T) {
+	t.Run("Config", testBuiltinBackendConfig)
+	t.Run("Secret", testBuiltinBackendSecret)
+	t.Run("Certificate", testBuiltinBackendCertificate)
+}
@@ -0,0 +1,206 @@
+package cke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybozu-go/log"
+	vault "github.com/hashicorp/vault/api"
+)
+
+// VaultBackend is a Backend using PKI and KV secrets engines of Vault.
+type VaultBackend struct {
+	Client *vault.Client
+}
+
+var _ Backend = VaultBackend{}
+
+var roleLock sync.Mutex
+
+// addRole adds a role to CA if not exists.
+func addRole(client *vault.Client, ca, role string, data map[string]interface{}) error {
+	roleLock.Lock()
+	defer roleLock.Unlock()
+
+	l := client.Logical()
+	rpath := path.Join(ca, "roles", role)
+	secret, err := l.Read(rpath)
+	if err != nil {
+		return err
+	}
+	if secret != nil {
+		// already exists
+		return nil
+	}
+
+	_, err = l.Write(rpath, data)
+	if err != nil {
+		log.Error("failed to create vault role", map[string]interface{}{
+			log.FnError: err,
+			"ca":        ca,
+			"role":      role,
+		})
+	}
+	return err
+}
+
+// deleteRole deletes a role of CA.
+func deleteRole(client *vault.Client, ca, role string) error {
+	roleLock.Lock()
+	defer roleLock.Unlock()
+
+	l := client.Logical()
+	rpath := path.Join(ca, "roles", role)
+	l.Delete(rpath)
+	_, err := l.Read(rpath)
+	if err != nil {
+		return err
+	}
+	return err
+}
+
+// vaultTTL formats d for Vault.
+func vaultTTL(d time.Duration) string {
+	return fmt.Sprintf("%ds", int64(d.Seconds()))
+}
+
+// IssueCertificate implements Backend.
+func (b VaultBackend) IssueCertificate(ctx context.Context, ca string, req CertificateRequest) (crt, key string, err error) {
+	pkiKey := VaultPKIKey(ca)
+
+	roleOpts := map[string]interface{}{
+		"ttl":               vaultTTL(req.MaxTTL),
+		"max_ttl":           vaultTTL(req.MaxTTL),
+		"enforce_hostnames": "false",
+		"allow_any_name":    "true",
+		"server_flag":       strconv.FormatBool(req.ServerAuth),
+		"client_flag":       strconv.FormatBool(req.ClientAuth),
+	}
+	if req.Organization != "" {
+		roleOpts["organization"] = req.Organization
+	}
+	if req.Signing {
+		roleOpts["key_usage"] = "DigitalSignature,CertSign"
+		roleOpts["no_store"] = "true"
+	}
+
+	certOpts := map[string]interface{}{
+		"common_name":          req.CommonName,
+		"exclude_cn_from_sans": "true",
+	}
+	if len(req.AltNames) > 0 {
+		certOpts["alt_names"] = strings.Join(req.AltNames, ",")
+	}
+	if len(req.IPAddresses) > 0 {
+		certOpts["ip_sans"] = strings.Join(req.IPAddresses, ",")
+	}
+	if req.TTL > 0 {
+		certOpts["ttl"] = vaultTTL(req.TTL)
+	}
+
+	err = addRole(b.Client, pkiKey, req.Role, roleOpts)
+	if err != nil {
+		return "", "", err
+	}
+
+	secret, err := b.Client.Logical().Write(path.Join(pkiKey, "issue", req.Role), certOpts)
+	if err != nil {
+		return "", "", err
+	}
+	crt = secret.Data["certificate"].(string)
+	if req.Onetime {
+		if err := deleteRole(b.Client, pkiKey, req.Role); err != nil {
+			return "", "", err
+		}
+	}
+	key = secret.Data["private_key"].(string)
+	return crt, key, err
+}
+
+// DefaultIssuer implements Backend.
+func (b VaultBackend) DefaultIssuer(ctx context.Context, ca string) (string, error) {
+	secret, err := b.Client.Logical().Read(path.Join(VaultPKIKey(ca), "config/issuers"))
+	if err != nil {
+		return "", err
+	}
+	if secret == nil || secret.Data == nil {
+		return "", fmt.Errorf("no issuers config for %s", ca)
+	}
+	id, ok := secret.Data["default"].(string)
+	if !ok || id == "" {
+		return "", fmt.Errorf("no default issuer for %s", ca)
+	}
+	return id, nil
+}
+
+// GenerateIssuer implements Backend.
+func (b VaultBackend) GenerateIssuer(ctx context.Context, ca, commonName string, ttl time.Duration) (id, cert string, err error) {
+	secret, err := b.Client.Logical().Write(path.Join(VaultPKIKey(ca), "root/rotate/internal"), map[string]interface{}{
+		"common_name": commonName,
+		"ttl":         vaultTTL(ttl),
+		"format":      "pem",
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if secret == nil || secret.Data == nil {
+		return "", "", fmt.Errorf("failed to generate a new issuer for %s", ca)
+	}
+	id, ok1 := secret.Data["issuer_id"].(string)
+	cert, ok2 := secret.Data["certificate"].(string)
+	if !ok1 || !ok2 {
+		return "", "", errors.New("no issuer_id or certificate in the response")
+	}
+	return id, cert, nil
+}
+
+// SetDefaultIssuer implements Backend.
+func (b VaultBackend) SetDefaultIssuer(ctx context.Context, ca, id string) error {
+	_, err := b.Client.Logical().Write(path.Join(VaultPKIKey(ca), "config/issuers"), map[string]interface{}{
+		"default": id,
+	})
+	return err
+}
+
+// DeleteIssuer implements Backend.
+func (b VaultBackend) DeleteIssuer(ctx context.Context, ca, id string) error {
+	_, err := b.Client.Logical().Delete(path.Join(VaultPKIKey(ca), "issuer", id))
+	return err
+}
+
+// ReadSecret implements Backend.
+func (b VaultBackend) ReadSecret(ctx context.Context, name string) (map[string]string, error) {
+	secret, err := b.Client.Logical().Read(path.Join(CKESecret, name))
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, ErrNotFound
+	}
+
+	data := make(map[string]string)
+	for k, v := range secret.Data {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("non-string value in %s: %s", name, k)
+		}
+		data[k] = s
+	}
+	return data, nil
+}
+
+// WriteSecret implements Backend.
+func (b VaultBackend) WriteSecret(ctx context.Context, name string, data map[string]string) error {
+	d := make(map[string]interface{})
+	for k, v := range data {
+		d[k] = v
+	}
+	_, err := b.Client.Logical().Write(path.Join(CKESecret, name), d)
+	return err
+}
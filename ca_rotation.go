@@ -0,0 +1,61 @@
+package cke
+
+import (
+	"strings"
+	"time"
+)
+
+// CARotationStep is a step of the CA rotation.
+type CARotationStep string
+
+// Steps of the CA rotation.
+const (
+	// CARotationBundle publishes the bundle of the old and new CA certificates
+	// so that all components trust both of them.
+	CARotationBundle = CARotationStep("bundle")
+	// CARotationReissue makes the new CA the issuer and reissues leaf certificates.
+	CARotationReissue = CARotationStep("reissue")
+	// CARotationPrune removes the old CA certificate from the bundle.
+	CARotationPrune = CARotationStep("prune")
+)
+
+// CARotation represents the progress of rotating a CA.
+type CARotation struct {
+	// CA is the name of the CA such as "kubernetes".
+	CA string `json:"ca"`
+	// OldIssuer and NewIssuer are the issuer IDs in the backend.
+	OldIssuer string `json:"old_issuer"`
+	NewIssuer string `json:"new_issuer"`
+	// OldCertificate and NewCertificate are the PEM encoded CA certificates.
+	OldCertificate string         `json:"old_certificate"`
+	NewCertificate string         `json:"new_certificate"`
+	Step           CARotationStep `json:"step"`
+	Started        time.Time      `json:"started"`
+}
+
+// NextStep returns the step following the current one.
+// This returns an empty string if the current step is the last one.
+func (r *CARotation) NextStep() CARotationStep {
+	switch r.Step {
+	case CARotationBundle:
+		return CARotationReissue
+	case CARotationReissue:
+		return CARotationPrune
+	}
+	return ""
+}
+
+// Bundle returns the concatenation of the old and new CA certificates.
+func (r *CARotation) Bundle() string {
+	return strings.TrimRight(r.OldCertificate, "\n") + "\n" + r.NewCertificate
+}
+
+// IsValidCAKey returns true if key is one of CAKeys.
+func IsValidCAKey(key string) bool {
+	for _, k := range CAKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package cke
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testCARotationNextStep(t *testing.T) {
+	r := &CARotation{Step: CARotationBundle}
+	var steps []CARotationStep
+	for r.Step != "" {
+		steps = append(steps, r.Step)
+		r.Step = r.NextStep()
+	}
+	expected := []CARotationStep{
+		CARotationBundle,
+		CARotationReissue,
+		CARotationPrune,
+	}
+	if !cmp.Equal(expected, steps) {
+		t.Error("unexpected steps:", cmp.Diff(expected, steps))
+	}
+}
+
+func testCARotationBundle(t *testing.T) {
+	r := &CARotation{
+		OldCertificate: "-----BEGIN CERTIFICATE-----\nold\n-----END CERTIFICATE-----\n",
+		NewCertificate: "-----BEGIN CERTIFICATE-----\nnew\n-----END CERTIFICATE-----",
+	}
+	expected := "-----BEGIN CERTIFICATE-----\nold\n-----END CERTIFICATE-----\n-----BEGIN CERTIFICATE-----\nnew\n-----END CERTIFICATE-----"
+	if r.Bundle() != expected {
+		t.Error("unexpected bundle:", r.Bundle())
+	}
+}
+
+func TestCARotation(t *testing.T) {
+	t.Run("NextStep", testCARotationNextStep)
+	t.Run("Bundle", testCARotationBundle)
+}
@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/url"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/containernetworking/cni/libcni"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apivalidation "k8s.io/apimachinery/pkg/api/validation"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -111,9 +114,35 @@ type EtcdParams struct {
 // APIServerParams is a set of extra parameters for kube-apiserver.
 type APIServerParams struct {
 	ServiceParams   `json:",inline"`
-	AuditLogEnabled bool   `json:"audit_log_enabled"`
-	AuditLogPolicy  string `json:"audit_log_policy"`
-	AuditLogPath    string `json:"audit_log_path"`
+	AuditLogEnabled bool       `json:"audit_log_enabled"`
+	AuditLogPolicy  string     `json:"audit_log_policy"`
+	AuditLogPath    string     `json:"audit_log_path"`
+	KMS             *KMSParams `json:"kms,omitempty"`
+}
+
+// KMS API versions of the KMS encryption provider.
+const (
+	KMSAPIVersionV1 = "v1"
+	KMSAPIVersionV2 = "v2"
+)
+
+// KMSParams is a set of parameters to encrypt Secrets with a KMS plugin.
+//
+// The KMS plugin runs as a system container on control plane nodes.
+// ServiceParams are extra parameters for the plugin container.
+type KMSParams struct {
+	ServiceParams  `json:",inline"`
+	Image          string `json:"image"`
+	APIVersion     string `json:"api_version,omitempty"`
+	Name           string `json:"name"`
+	Endpoint       string `json:"endpoint"`
+	CacheSize      *int32 `json:"cache_size,omitempty"`
+	TimeoutSeconds *int   `json:"timeout_seconds,omitempty"`
+}
+
+// SocketDir returns the directory of the UNIX domain socket of the KMS plugin.
+func (p KMSParams) SocketDir() string {
+	return filepath.Dir(strings.TrimPrefix(p.Endpoint, "unix://"))
 }
 
 // CNIConfFile is a config file for CNI plugin deployed on worker nodes by CKE.
@@ -165,6 +194,7 @@ type ProxyParams struct {
 	ServiceParams `json:",inline"`
 	Disable       bool                       `json:"disable,omitempty"`
 	Config        *unstructured.Unstructured `json:"config,omitempty"`
+	RollingUpdate RollingUpdate              `json:"rolling_update,omitempty"`
 }
 
 // GetMode returns the proxy mode.
@@ -234,6 +264,7 @@ type KubeletParams struct {
 	CNIConfFile   CNIConfFile                `json:"cni_conf_file"`
 	Config        *unstructured.Unstructured `json:"config,omitempty"`
 	CRIEndpoint   string                     `json:"cri_endpoint"`
+	RollingUpdate RollingUpdate              `json:"rolling_update,omitempty"`
 }
 
 // MergeConfig merges the input struct with `base`.
@@ -268,16 +299,425 @@ func (p KubeletParams) MergeConfig(base *kubeletv1beta1.KubeletConfiguration) (*
 	return &cfg, nil
 }
 
+// RollingUpdate is a set of parameters to restart a component on many nodes
+// in batches rather than all at once.
+type RollingUpdate struct {
+	MaxBatchSize  int `json:"max_batch_size,omitempty"`
+	MaxPercentage int `json:"max_percentage,omitempty"`
+	PauseSeconds  int `json:"pause_seconds,omitempty"`
+}
+
+// BatchSize returns the number of nodes to be restarted at once
+// in a cluster consisting of total nodes.
+// Zero values of MaxBatchSize and MaxPercentage mean no limits.
+func (r RollingUpdate) BatchSize(total int) int {
+	size := total
+	if r.MaxBatchSize > 0 && r.MaxBatchSize < size {
+		size = r.MaxBatchSize
+	}
+	if r.MaxPercentage > 0 {
+		n := total * r.MaxPercentage / 100
+		if n < 1 {
+			n = 1
+		}
+		if n < size {
+			size = n
+		}
+	}
+	return size
+}
+
+func validateRollingUpdate(r RollingUpdate, fldPath *field.Path) error {
+	if r.MaxBatchSize < 0 {
+		return field.Invalid(fldPath.Child("max_batch_size"), r.MaxBatchSize, "must not be negative")
+	}
+	if r.MaxPercentage < 0 || r.MaxPercentage > 100 {
+		return field.Invalid(fldPath.Child("max_percentage"), r.MaxPercentage, "must be between 0 and 100")
+	}
+	if r.PauseSeconds < 0 {
+		return field.Invalid(fldPath.Child("pause_seconds"), r.PauseSeconds, "must not be negative")
+	}
+	return nil
+}
+
+func validateKMS(p KMSParams, fldPath *field.Path) error {
+	if len(p.Name) == 0 {
+		return field.Required(fldPath.Child("name"), "")
+	}
+	if len(p.Image) == 0 {
+		return field.Required(fldPath.Child("image"), "")
+	}
+	switch p.APIVersion {
+	case "", KMSAPIVersionV1:
+	case KMSAPIVersionV2:
+		if p.CacheSize != nil {
+			return field.Invalid(fldPath.Child("cache_size"), *p.CacheSize, "cannot be set for KMS v2")
+		}
+	default:
+		return field.NotSupported(fldPath.Child("api_version"), p.APIVersion, []string{KMSAPIVersionV1, KMSAPIVersionV2})
+	}
+	if !strings.HasPrefix(p.Endpoint, "unix://") || !filepath.IsAbs(strings.TrimPrefix(p.Endpoint, "unix://")) {
+		return field.Invalid(fldPath.Child("endpoint"), p.Endpoint, "must be unix:// followed by an absolute path")
+	}
+	if p.SocketDir() == "/" {
+		return field.Invalid(fldPath.Child("endpoint"), p.Endpoint, "must not be placed in the root directory")
+	}
+	if p.TimeoutSeconds != nil && *p.TimeoutSeconds <= 0 {
+		return field.Invalid(fldPath.Child("timeout_seconds"), *p.TimeoutSeconds, "must be positive")
+	}
+	return nil
+}
+
 // Reboot is a set of configurations for reboot.
 type Reboot struct {
 	Command                []string              `json:"command"`
 	EvictionTimeoutSeconds *int                  `json:"eviction_timeout_seconds,omitempty"`
 	CommandTimeoutSeconds  *int                  `json:"command_timeout_seconds,omitempty"`
 	ProtectedNamespaces    *metav1.LabelSelector `json:"protected_namespaces,omitempty"`
+
+	// MaxConcurrentNodes enables processing multiple reboot queue entries
+	// at once up to this number of nodes.  If nil, entries are processed
+	// one by one.
+	MaxConcurrentNodes *int `json:"max_concurrent_nodes,omitempty"`
+	// MaxConcurrentRacks limits the number of racks rebooted at once.
+	// Racks are identified by RackLabel of nodes.
+	MaxConcurrentRacks *int `json:"max_concurrent_racks,omitempty"`
+	// MaxNodesPerRolePercent limits the share of nodes of each role
+	// rebooted at once.  Roles are identified by RoleLabel of nodes.
+	MaxNodesPerRolePercent *int `json:"max_nodes_per_role_percent,omitempty"`
+
+	// VerificationTimeoutSeconds is the time limit for rebooted nodes
+	// to come back.  If nil, DefaultRebootVerificationTimeout is used.
+	VerificationTimeoutSeconds *int `json:"verification_timeout_seconds,omitempty"`
+
+	// Drain configures how Pods are removed from nodes before reboot.
+	Drain DrainPolicy `json:"drain"`
+
+	// Hooks are run for each node before draining, before rebooting,
+	// and after verifying the node.
+	Hooks RebootHooks `json:"hooks"`
+}
+
+// DrainMode determines what to do with Pods that cannot be evicted.
+type DrainMode string
+
+// Drain modes.
+const (
+	// DrainEvictOrDelete deletes Pods not in protected namespaces if
+	// they cannot be evicted.  This is the default.
+	DrainEvictOrDelete = DrainMode("evict-or-delete")
+	// DrainEvictOnly retries eviction of Pods until the eviction timeout.
+	// Pods are never deleted without eviction.
+	DrainEvictOnly = DrainMode("evict-only")
+)
+
+// EmptyDirPolicy determines how Pods with emptyDir volumes are drained.
+type EmptyDirPolicy string
+
+// EmptyDir policies.
+const (
+	// EmptyDirEvict evicts Pods with emptyDir volumes.  This is the default.
+	EmptyDirEvict = EmptyDirPolicy("evict")
+	// EmptyDirSkip leaves Pods with emptyDir volumes on the nodes.
+	EmptyDirSkip = EmptyDirPolicy("skip")
+	// EmptyDirBlock aborts draining if there are Pods with emptyDir volumes.
+	EmptyDirBlock = EmptyDirPolicy("block")
+)
+
+// DrainPolicy is a set of configurations for draining nodes.
+// Pods managed by DaemonSets and mirror Pods are never drained.
+type DrainPolicy struct {
+	Mode     DrainMode      `json:"mode,omitempty"`
+	EmptyDir EmptyDirPolicy `json:"empty_dir,omitempty"`
+	// SkipPods selects Pods that are left on the nodes.
+	SkipPods *metav1.LabelSelector `json:"skip_pods,omitempty"`
+}
+
+// RebootHookStage is the stage of reboot at which hooks are run.
+type RebootHookStage string
+
+// Reboot hook stages.
+const (
+	RebootHookPreDrain   = RebootHookStage("pre-drain")
+	RebootHookPreReboot  = RebootHookStage("pre-reboot")
+	RebootHookPostReboot = RebootHookStage("post-reboot")
+)
+
+// RebootHooks is a set of hooks for each reboot stage.
+type RebootHooks struct {
+	PreDrain   []RebootHook `json:"pre_drain,omitempty"`
+	PreReboot  []RebootHook `json:"pre_reboot,omitempty"`
+	PostReboot []RebootHook `json:"post_reboot,omitempty"`
+}
+
+// HookFailurePolicy determines what to do when a hook fails.
+type HookFailurePolicy string
+
+// Hook failure policies.
+const (
+	// HookFailureAbort aborts the operation.
+	HookFailureAbort = HookFailurePolicy("abort")
+	// HookFailureSkip ignores the failure.
+	HookFailureSkip = HookFailurePolicy("skip")
+	// HookFailureRetry retries the hook up to MaxRetries times, and then
+	// aborts the operation.
+	HookFailureRetry = HookFailurePolicy("retry")
+)
+
+// RebootHook is a hook run for each node being rebooted.
+// Exactly one of Webhook, Command, or Job must be specified.
+type RebootHook struct {
+	Name    string             `json:"name"`
+	Webhook *RebootHookWebhook `json:"webhook,omitempty"`
+	// Command is a shell command run on the node.
+	Command string         `json:"command,omitempty"`
+	Job     *RebootHookJob `json:"job,omitempty"`
+
+	TimeoutSeconds *int              `json:"timeout_seconds,omitempty"`
+	FailurePolicy  HookFailurePolicy `json:"failure_policy,omitempty"`
+	MaxRetries     *int              `json:"max_retries,omitempty"`
+}
+
+// RebootHookWebhook is a hook that sends an HTTP POST request.
+type RebootHookWebhook struct {
+	URL string `json:"url"`
+}
+
+// RebootHookJob is a hook that runs a Kubernetes Job and waits for its completion.
+type RebootHookJob struct {
+	Namespace string          `json:"namespace"`
+	Spec      batchv1.JobSpec `json:"spec"`
+}
+
+// Defaults of reboot hooks.
+const (
+	DefaultRebootHookTimeout    = 5 * time.Minute
+	DefaultRebootHookMaxRetries = 3
+)
+
+// Hooks returns the hooks for stage.
+func (h RebootHooks) Hooks(stage RebootHookStage) []RebootHook {
+	switch stage {
+	case RebootHookPreDrain:
+		return h.PreDrain
+	case RebootHookPreReboot:
+		return h.PreReboot
+	case RebootHookPostReboot:
+		return h.PostReboot
+	}
+	return nil
+}
+
+// Timeout returns the time limit of each run of the hook.
+func (h RebootHook) Timeout() time.Duration {
+	if h.TimeoutSeconds == nil {
+		return DefaultRebootHookTimeout
+	}
+	return time.Duration(*h.TimeoutSeconds) * time.Second
+}
+
+// Policy returns the failure policy of the hook.
+func (h RebootHook) Policy() HookFailurePolicy {
+	if h.FailurePolicy == "" {
+		return HookFailureAbort
+	}
+	return h.FailurePolicy
+}
+
+// Attempts returns the maximum number of runs of the hook.
+func (h RebootHook) Attempts() int {
+	if h.Policy() != HookFailureRetry {
+		return 1
+	}
+	if h.MaxRetries == nil {
+		return 1 + DefaultRebootHookMaxRetries
+	}
+	return 1 + *h.MaxRetries
+}
+
+// Type returns the type of the hook; one of "webhook", "command", or "job".
+func (h RebootHook) Type() string {
+	switch {
+	case h.Webhook != nil:
+		return "webhook"
+	case h.Command != "":
+		return "command"
+	case h.Job != nil:
+		return "job"
+	}
+	return ""
+}
+
+// DefaultRebootVerificationTimeout is the default time limit for rebooted nodes to come back.
+const DefaultRebootVerificationTimeout = 30 * time.Minute
+
+// VerificationTimeout returns the time limit for rebooted nodes to come back.
+func (r Reboot) VerificationTimeout() time.Duration {
+	if r.VerificationTimeoutSeconds == nil {
+		return DefaultRebootVerificationTimeout
+	}
+	return time.Duration(*r.VerificationTimeoutSeconds) * time.Second
+}
+
+// Labels of nodes to limit concurrent reboots.
+const (
+	RackLabel = "cke.cybozu.com/rack"
+	RoleLabel = "cke.cybozu.com/role"
+)
+
+// EtcdBackup is a set of configurations for scheduled backups of etcd
+// managed by CKE.  Exactly one of Local, S3, or HTTP must be specified
+// when Enabled is true.
+type EtcdBackup struct {
+	Enabled         bool             `json:"enabled"`
+	IntervalSeconds *int             `json:"interval_seconds,omitempty"`
+	Rotate          *int             `json:"rotate,omitempty"`
+	Local           *EtcdBackupLocal `json:"local,omitempty"`
+	S3              *EtcdBackupS3    `json:"s3,omitempty"`
+	HTTP            *EtcdBackupHTTP  `json:"http,omitempty"`
+}
+
+// EtcdBackupLocal is a backup target in a directory of the host running CKE.
+type EtcdBackupLocal struct {
+	Dir string `json:"dir"`
+}
+
+// EtcdBackupS3 is a backup target in S3-compatible object storage.
+type EtcdBackupS3 struct {
+	Endpoint string `json:"endpoint"`
+	Region   string `json:"region,omitempty"`
+	Bucket   string `json:"bucket"`
+	Prefix   string `json:"prefix,omitempty"`
+}
+
+// EtcdBackupHTTP is a backup target that accepts snapshots with HTTP PUT.
+type EtcdBackupHTTP struct {
+	URL string `json:"url"`
+}
+
+// Certificates is a set of configurations for TLS certificates of etcd and
+// Kubernetes components issued by CKE.
+type Certificates struct {
+	TTLSeconds         *int `json:"ttl_seconds,omitempty"`
+	RenewBeforeSeconds *int `json:"renew_before_seconds,omitempty"`
+}
+
+// DefaultCertificateTTL is the default lifetime of component certificates.
+// This is also the maximum lifetime.
+const DefaultCertificateTTL = 87600 * time.Hour
+
+// TTL returns the lifetime of component certificates.
+func (c Certificates) TTL() time.Duration {
+	if c.TTLSeconds == nil {
+		return DefaultCertificateTTL
+	}
+	return time.Duration(*c.TTLSeconds) * time.Second
+}
+
+// RenewBefore returns the duration before expiry when CKE renews certificates.
+// The default is one third of TTL.
+func (c Certificates) RenewBefore() time.Duration {
+	if c.RenewBeforeSeconds == nil {
+		return c.TTL() / 3
+	}
+	return time.Duration(*c.RenewBeforeSeconds) * time.Second
+}
+
+// Approval is a set of configurations for manual approval of disruptive operations.
+// When Enabled is true, operations listed in Operations are not run until
+// they are approved by "ckecli ops approve".
+type Approval struct {
+	Enabled bool `json:"enabled"`
+	// Operations are the names of operations that need approval.
+	// Empty means DefaultApprovalOperations.
+	Operations    []string `json:"operations,omitempty"`
+	ExpirySeconds *int     `json:"expiry_seconds,omitempty"`
+}
+
+// DefaultApprovalOperations are the names of operations that need approval by default.
+var DefaultApprovalOperations = []string{
+	"etcd-destroy-member",
+	"etcd-remove-member",
+	"remove-node",
+	"stop-etcd",
+	"stop-etcd-rivers",
+	"stop-kms-plugin",
+	"stop-kube-apiserver",
+	"stop-kube-controller-manager",
+	"stop-kube-proxy",
+	"stop-kube-scheduler",
+}
+
+// DefaultApprovalExpiry is the default lifetime of pending operations.
+const DefaultApprovalExpiry = 24 * time.Hour
+
+// NeedsApproval returns true if the operation named name needs approval.
+func (a Approval) NeedsApproval(name string) bool {
+	if !a.Enabled {
+		return false
+	}
+	ops := a.Operations
+	if len(ops) == 0 {
+		ops = DefaultApprovalOperations
+	}
+	for _, o := range ops {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Expiry returns the duration after which unapproved operations expire.
+// Approved operations that have not completed also expire after this
+// duration since the approval.
+func (a Approval) Expiry() time.Duration {
+	if a.ExpirySeconds == nil {
+		return DefaultApprovalExpiry
+	}
+	return time.Duration(*a.ExpirySeconds) * time.Second
+}
+
+// Images is a set of container images of etcd and Kubernetes to run.
+// Empty fields mean the images built in CKE.
+type Images struct {
+	Etcd       Image `json:"etcd,omitempty"`
+	Kubernetes Image `json:"kubernetes,omitempty"`
+}
+
+// EtcdImage returns the etcd image to run.
+func (i Images) EtcdImage() Image {
+	if len(i.Etcd) == 0 {
+		return EtcdImage
+	}
+	return i.Etcd
+}
+
+// KubernetesImage returns the Kubernetes image to run.
+func (i Images) KubernetesImage() Image {
+	if len(i.Kubernetes) == 0 {
+		return KubernetesImage
+	}
+	return i.Kubernetes
+}
+
+func validateImages(i Images) error {
+	if len(i.Etcd) > 0 {
+		if _, _, err := i.Etcd.Version(); err != nil {
+			return fmt.Errorf("invalid images.etcd: %w", err)
+		}
+	}
+	if len(i.Kubernetes) > 0 {
+		if _, _, err := i.Kubernetes.Version(); err != nil {
+			return fmt.Errorf("invalid images.kubernetes: %w", err)
+		}
+	}
+	return nil
 }
 
 // Options is a set of optional parameters for k8s components.
 type Options struct {
+	ContainerEngine   string          `json:"container-engine,omitempty"`
 	Etcd              EtcdParams      `json:"etcd"`
 	Rivers            ServiceParams   `json:"rivers"`
 	EtcdRivers        ServiceParams   `json:"etcd-rivers"`
@@ -290,14 +730,19 @@ type Options struct {
 
 // Cluster is a set of configurations for a etcd/Kubernetes cluster.
 type Cluster struct {
-	Name          string   `json:"name"`
-	Nodes         []*Node  `json:"nodes"`
-	TaintCP       bool     `json:"taint_control_plane"`
-	ServiceSubnet string   `json:"service_subnet"`
-	DNSServers    []string `json:"dns_servers"`
-	DNSService    string   `json:"dns_service"`
-	Reboot        Reboot   `json:"reboot"`
-	Options       Options  `json:"options"`
+	Name             string       `json:"name"`
+	Nodes            []*Node      `json:"nodes"`
+	TaintCP          bool         `json:"taint_control_plane"`
+	ServiceSubnet    string       `json:"service_subnet"`
+	DNSServers       []string     `json:"dns_servers"`
+	DNSService       string       `json:"dns_service"`
+	SSHHostKeyPolicy string       `json:"ssh_host_key_policy,omitempty"`
+	Reboot           Reboot       `json:"reboot"`
+	EtcdBackup       EtcdBackup   `json:"etcd_backup"`
+	Certificates     Certificates `json:"certificates"`
+	Approval         Approval     `json:"approval"`
+	Images           Images       `json:"images"`
+	Options          Options      `json:"options"`
 }
 
 // Validate validates the cluster definition.
@@ -339,11 +784,36 @@ func (c *Cluster) Validate(isTmpl bool) error {
 		}
 	}
 
+	switch c.SSHHostKeyPolicy {
+	case "", SSHHostKeyPolicyTOFU, SSHHostKeyPolicyStrict:
+	default:
+		return errors.New("unknown ssh_host_key_policy: " + c.SSHHostKeyPolicy)
+	}
+
 	err = validateReboot(c.Reboot)
 	if err != nil {
 		return err
 	}
 
+	err = validateEtcdBackup(c.EtcdBackup)
+	if err != nil {
+		return err
+	}
+
+	err = validateCertificates(c.Certificates)
+	if err != nil {
+		return err
+	}
+
+	if c.Approval.ExpirySeconds != nil && *c.Approval.ExpirySeconds <= 0 {
+		return errors.New("approval.expiry_seconds must be positive")
+	}
+
+	err = validateImages(c.Images)
+	if err != nil {
+		return err
+	}
+
 	err = validateOptions(c.Options)
 	if err != nil {
 		return err
@@ -464,6 +934,33 @@ func validateReboot(reboot Reboot) error {
 	if reboot.CommandTimeoutSeconds != nil && *reboot.CommandTimeoutSeconds < 0 {
 		return errors.New("command_timeout_seconds must not be negative")
 	}
+	if reboot.MaxConcurrentNodes != nil && *reboot.MaxConcurrentNodes <= 0 {
+		return errors.New("max_concurrent_nodes must be positive")
+	}
+	if reboot.MaxConcurrentRacks != nil && *reboot.MaxConcurrentRacks <= 0 {
+		return errors.New("max_concurrent_racks must be positive")
+	}
+	if p := reboot.MaxNodesPerRolePercent; p != nil && (*p <= 0 || *p > 100) {
+		return errors.New("max_nodes_per_role_percent must be between 1 and 100")
+	}
+	if reboot.VerificationTimeoutSeconds != nil && *reboot.VerificationTimeoutSeconds <= 0 {
+		return errors.New("verification_timeout_seconds must be positive")
+	}
+	if err := validateDrainPolicy(reboot.Drain); err != nil {
+		return err
+	}
+	for _, stage := range []RebootHookStage{RebootHookPreDrain, RebootHookPreReboot, RebootHookPostReboot} {
+		names := make(map[string]bool)
+		for _, h := range reboot.Hooks.Hooks(stage) {
+			if names[h.Name] {
+				return fmt.Errorf("duplicate %s hook: %s", stage, h.Name)
+			}
+			names[h.Name] = true
+			if err := validateRebootHook(h); err != nil {
+				return fmt.Errorf("invalid %s hook %q: %w", stage, h.Name, err)
+			}
+		}
+	}
 	// nil is safe for LabelSelectorAsSelector
 	_, err := metav1.LabelSelectorAsSelector(reboot.ProtectedNamespaces)
 	if err != nil {
@@ -472,6 +969,138 @@ func validateReboot(reboot Reboot) error {
 	return nil
 }
 
+func validateDrainPolicy(d DrainPolicy) error {
+	switch d.Mode {
+	case "", DrainEvictOrDelete, DrainEvictOnly:
+	default:
+		return errors.New("unknown drain.mode: " + string(d.Mode))
+	}
+	switch d.EmptyDir {
+	case "", EmptyDirEvict, EmptyDirSkip, EmptyDirBlock:
+	default:
+		return errors.New("unknown drain.empty_dir: " + string(d.EmptyDir))
+	}
+	// nil is safe for LabelSelectorAsSelector
+	if _, err := metav1.LabelSelectorAsSelector(d.SkipPods); err != nil {
+		return fmt.Errorf("invalid drain.skip_pods: %w", err)
+	}
+	return nil
+}
+
+func validateRebootHook(h RebootHook) error {
+	if errs := validation.IsDNS1123Label(h.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid name: %s", strings.Join(errs, "; "))
+	}
+
+	types := 0
+	if h.Webhook != nil {
+		types++
+		u, err := url.Parse(h.Webhook.URL)
+		if err != nil {
+			return fmt.Errorf("invalid webhook.url: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return errors.New("webhook.url must be an http or https URL: " + h.Webhook.URL)
+		}
+	}
+	if h.Command != "" {
+		types++
+	}
+	if h.Job != nil {
+		types++
+		if errs := validation.IsDNS1123Label(h.Job.Namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid job.namespace: %s", strings.Join(errs, "; "))
+		}
+		if len(h.Job.Spec.Template.Spec.Containers) == 0 {
+			return errors.New("job.spec.template has no containers")
+		}
+	}
+	if types != 1 {
+		return errors.New("exactly one of webhook, command, or job is required")
+	}
+
+	if h.TimeoutSeconds != nil && *h.TimeoutSeconds <= 0 {
+		return errors.New("timeout_seconds must be positive")
+	}
+	switch h.FailurePolicy {
+	case "", HookFailureAbort, HookFailureSkip, HookFailureRetry:
+	default:
+		return errors.New("unknown failure_policy: " + string(h.FailurePolicy))
+	}
+	if h.MaxRetries != nil && *h.MaxRetries < 0 {
+		return errors.New("max_retries must not be negative")
+	}
+	return nil
+}
+
+func validateCertificates(c Certificates) error {
+	if c.TTLSeconds != nil {
+		if *c.TTLSeconds <= 0 {
+			return errors.New("certificates.ttl_seconds must be positive")
+		}
+		if c.TTL() > DefaultCertificateTTL {
+			return fmt.Errorf("certificates.ttl_seconds must not exceed %d", int(DefaultCertificateTTL.Seconds()))
+		}
+	}
+	if c.RenewBeforeSeconds != nil && *c.RenewBeforeSeconds <= 0 {
+		return errors.New("certificates.renew_before_seconds must be positive")
+	}
+	if c.RenewBefore() >= c.TTL() {
+		return errors.New("certificates.renew_before_seconds must be less than ttl_seconds")
+	}
+	return nil
+}
+
+func validateEtcdBackup(eb EtcdBackup) error {
+	if !eb.Enabled {
+		return nil
+	}
+	if eb.IntervalSeconds != nil && *eb.IntervalSeconds <= 0 {
+		return errors.New("etcd_backup.interval_seconds must be positive")
+	}
+	if eb.Rotate != nil && *eb.Rotate <= 0 {
+		return errors.New("etcd_backup.rotate must be positive")
+	}
+
+	targets := 0
+	if eb.Local != nil {
+		targets++
+		if !filepath.IsAbs(eb.Local.Dir) {
+			return errors.New("etcd_backup.local.dir must be absolute: " + eb.Local.Dir)
+		}
+	}
+	if eb.S3 != nil {
+		targets++
+		u, err := url.Parse(eb.S3.Endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid etcd_backup.s3.endpoint: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return errors.New("etcd_backup.s3.endpoint must be an http or https URL: " + eb.S3.Endpoint)
+		}
+		if len(eb.S3.Bucket) == 0 {
+			return errors.New("etcd_backup.s3.bucket is empty")
+		}
+	}
+	if eb.HTTP != nil {
+		targets++
+		u, err := url.Parse(eb.HTTP.URL)
+		if err != nil {
+			return fmt.Errorf("invalid etcd_backup.http.url: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return errors.New("etcd_backup.http.url must be an http or https URL: " + eb.HTTP.URL)
+		}
+		if eb.Rotate != nil {
+			return errors.New("etcd_backup.rotate cannot be applied to etcd_backup.http")
+		}
+	}
+	if targets != 1 {
+		return errors.New("etcd_backup requires exactly one of local, s3, or http")
+	}
+	return nil
+}
+
 func validateOptions(opts Options) error {
 	v := func(binds []Mount) error {
 		for _, m := range binds {
@@ -485,6 +1114,12 @@ func validateOptions(opts Options) error {
 		return nil
 	}
 
+	switch opts.ContainerEngine {
+	case "", EngineDocker, EngineContainerd:
+	default:
+		return errors.New("unknown container engine: " + opts.ContainerEngine)
+	}
+
 	err := v(opts.Etcd.ExtraBinds)
 	if err != nil {
 		return err
@@ -524,6 +1159,14 @@ func validateOptions(opts Options) error {
 				kubeletConfig.ClusterDomain, strings.Join(msgs, ";"))
 		}
 	}
+	err = validateRollingUpdate(opts.Kubelet.RollingUpdate, fldPath.Child("rolling_update"))
+	if err != nil {
+		return err
+	}
+	err = validateRollingUpdate(opts.Proxy.RollingUpdate, field.NewPath("options", "kube-proxy", "rolling_update"))
+	if err != nil {
+		return err
+	}
 	if len(opts.Kubelet.CRIEndpoint) == 0 {
 		return errors.New("kubelet.cri_endpoint should not be empty")
 	}
@@ -572,6 +1215,16 @@ func validateOptions(opts Options) error {
 		}
 	}
 
+	if opts.APIServer.KMS != nil {
+		if err := validateKMS(*opts.APIServer.KMS, field.NewPath("options", "kube-api", "kms")); err != nil {
+			return err
+		}
+		err = v(opts.APIServer.KMS.ExtraBinds)
+		if err != nil {
+			return err
+		}
+	}
+
 	if _, err := opts.Scheduler.MergeConfig(&schedulerv1beta1.KubeSchedulerConfiguration{}); err != nil {
 		return err
 	}
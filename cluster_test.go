@@ -6,11 +6,13 @@ import (
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	proxyv1alpha1 "k8s.io/kube-proxy/config/v1alpha1"
 	schedulerv1beta1 "k8s.io/kube-scheduler/config/v1beta1"
 	kubeletv1beta1 "k8s.io/kubelet/config/v1beta1"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/yaml"
 )
 
@@ -217,6 +219,7 @@ rules:
 func testClusterValidate(t *testing.T) {
 	t.Parallel()
 
+	var cacheSize int32 = 1000
 	tests := []struct {
 		name    string
 		cluster Cluster
@@ -276,6 +279,356 @@ func testClusterValidate(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"invalid ssh host key policy",
+			Cluster{
+				Name:             "testcluster",
+				ServiceSubnet:    "10.0.0.0/14",
+				SSHHostKeyPolicy: "accept-all",
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"strict ssh host key policy",
+			Cluster{
+				Name:             "testcluster",
+				ServiceSubnet:    "10.0.0.0/14",
+				SSHHostKeyPolicy: SSHHostKeyPolicyStrict,
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			false,
+		},
+		{
+			"valid certificates",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Certificates: Certificates{
+					TTLSeconds:         pointer.Int(86400 * 365),
+					RenewBeforeSeconds: pointer.Int(86400 * 30),
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			false,
+		},
+		{
+			"zero certificate ttl",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Certificates: Certificates{
+					TTLSeconds: pointer.Int(0),
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"too long certificate ttl",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Certificates: Certificates{
+					TTLSeconds: pointer.Int(86400 * 3651),
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"zero renew_before",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Certificates: Certificates{
+					RenewBeforeSeconds: pointer.Int(0),
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"renew_before not less than ttl",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Certificates: Certificates{
+					TTLSeconds:         pointer.Int(86400),
+					RenewBeforeSeconds: pointer.Int(86400),
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"valid reboot concurrency",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Reboot: Reboot{
+					MaxConcurrentNodes:     pointer.Int(10),
+					MaxConcurrentRacks:     pointer.Int(1),
+					MaxNodesPerRolePercent: pointer.Int(20),
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			false,
+		},
+		{
+			"too large max_nodes_per_role_percent",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Reboot: Reboot{
+					MaxNodesPerRolePercent: pointer.Int(101),
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"zero verification_timeout_seconds",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Reboot: Reboot{
+					VerificationTimeoutSeconds: pointer.Int(0),
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"valid drain policy",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Reboot: Reboot{
+					Drain: DrainPolicy{Mode: DrainEvictOnly, EmptyDir: EmptyDirSkip, SkipPods: &metav1.LabelSelector{MatchLabels: map[string]string{"drain": "skip"}}},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			false,
+		},
+		{
+			"unknown drain mode",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Reboot: Reboot{
+					Drain: DrainPolicy{Mode: "delete-only"},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"unknown drain empty_dir",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Reboot: Reboot{
+					Drain: DrainPolicy{EmptyDir: "keep"},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"valid reboot hooks",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Reboot: Reboot{
+					Hooks: RebootHooks{
+						PreDrain: []RebootHook{
+							{Name: "ceph", Command: "ceph health", FailurePolicy: HookFailureRetry, MaxRetries: pointer.Int(5)},
+							{Name: "lb", Webhook: &RebootHookWebhook{URL: "https://lb.example.com/drain"}, FailurePolicy: HookFailureSkip},
+						},
+						PostReboot: []RebootHook{
+							{Name: "lb", Webhook: &RebootHookWebhook{URL: "https://lb.example.com/undrain"}, TimeoutSeconds: pointer.Int(10)},
+						},
+					},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			false,
+		},
+		{
+			"reboot hook without action",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Reboot: Reboot{
+					Hooks: RebootHooks{
+						PreReboot: []RebootHook{
+							{Name: "empty"},
+						},
+					},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"reboot hook with multiple actions",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Reboot: Reboot{
+					Hooks: RebootHooks{
+						PreReboot: []RebootHook{
+							{Name: "both", Command: "true", Webhook: &RebootHookWebhook{URL: "http://example.com"}},
+						},
+					},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"duplicate reboot hooks",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Reboot: Reboot{
+					Hooks: RebootHooks{
+						PreReboot: []RebootHook{
+							{Name: "dup", Command: "true"},
+							{Name: "dup", Command: "false"},
+						},
+					},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"unknown reboot hook failure policy",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Reboot: Reboot{
+					Hooks: RebootHooks{
+						PreReboot: []RebootHook{
+							{Name: "hook", Command: "true", FailurePolicy: "ignore"},
+						},
+					},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"reboot hook job without containers",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Reboot: Reboot{
+					Hooks: RebootHooks{
+						PreReboot: []RebootHook{
+							{Name: "job", Job: &RebootHookJob{Namespace: "default"}},
+						},
+					},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"zero approval expiry",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Approval: Approval{
+					Enabled:       true,
+					ExpirySeconds: pointer.Int(0),
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
 		{
 			"empty policy",
 			Cluster{
@@ -283,8 +636,106 @@ func testClusterValidate(t *testing.T) {
 				ServiceSubnet: "10.0.0.0/14",
 				Options: Options{
 					APIServer: APIServerParams{
-						AuditLogEnabled: true,
-						AuditLogPolicy:  "",
+						AuditLogEnabled: true,
+						AuditLogPolicy:  "",
+					},
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"invalid policy",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Options: Options{
+					APIServer: APIServerParams{
+						AuditLogEnabled: true,
+						AuditLogPolicy:  "test",
+					},
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"valid policy",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Options: Options{
+					APIServer: APIServerParams{
+						AuditLogEnabled: true,
+						AuditLogPolicy: `apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+- level: Metadata
+`,
+					},
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			false,
+		},
+		{
+			"valid kms",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Options: Options{
+					APIServer: APIServerParams{
+						KMS: &KMSParams{
+							Image:    "example.com/kms-plugin:1.0.0",
+							Name:     "kms",
+							Endpoint: "unix:///run/kms/kms.sock",
+						},
+					},
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			false,
+		},
+		{
+			"valid kms v2",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Options: Options{
+					APIServer: APIServerParams{
+						KMS: &KMSParams{
+							Image:      "example.com/kms-plugin:1.0.0",
+							APIVersion: "v2",
+							Name:       "kms",
+							Endpoint:   "unix:///run/kms/kms.sock",
+						},
+					},
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			false,
+		},
+		{
+			"kms without name",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Options: Options{
+					APIServer: APIServerParams{
+						KMS: &KMSParams{
+							Image:    "example.com/kms-plugin:1.0.0",
+							Endpoint: "unix:///run/kms/kms.sock",
+						},
 					},
 					Kubelet: KubeletParams{
 						CRIEndpoint: "/var/run/k8s-containerd.sock",
@@ -294,14 +745,16 @@ func testClusterValidate(t *testing.T) {
 			true,
 		},
 		{
-			"invalid policy",
+			"kms without image",
 			Cluster{
 				Name:          "testcluster",
 				ServiceSubnet: "10.0.0.0/14",
 				Options: Options{
 					APIServer: APIServerParams{
-						AuditLogEnabled: true,
-						AuditLogPolicy:  "test",
+						KMS: &KMSParams{
+							Name:     "kms",
+							Endpoint: "unix:///run/kms/kms.sock",
+						},
 					},
 					Kubelet: KubeletParams{
 						CRIEndpoint: "/var/run/k8s-containerd.sock",
@@ -311,25 +764,67 @@ func testClusterValidate(t *testing.T) {
 			true,
 		},
 		{
-			"valid policy",
+			"kms with invalid endpoint",
 			Cluster{
 				Name:          "testcluster",
 				ServiceSubnet: "10.0.0.0/14",
 				Options: Options{
 					APIServer: APIServerParams{
-						AuditLogEnabled: true,
-						AuditLogPolicy: `apiVersion: audit.k8s.io/v1
-kind: Policy
-rules:
-- level: Metadata
-`,
+						KMS: &KMSParams{
+							Image:    "example.com/kms-plugin:1.0.0",
+							Name:     "kms",
+							Endpoint: "/run/kms/kms.sock",
+						},
 					},
 					Kubelet: KubeletParams{
 						CRIEndpoint: "/var/run/k8s-containerd.sock",
 					},
 				},
 			},
-			false,
+			true,
+		},
+		{
+			"kms with unknown api version",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Options: Options{
+					APIServer: APIServerParams{
+						KMS: &KMSParams{
+							Image:      "example.com/kms-plugin:1.0.0",
+							APIVersion: "v3",
+							Name:       "kms",
+							Endpoint:   "unix:///run/kms/kms.sock",
+						},
+					},
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"kms v2 with cache size",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Options: Options{
+					APIServer: APIServerParams{
+						KMS: &KMSParams{
+							Image:      "example.com/kms-plugin:1.0.0",
+							APIVersion: "v2",
+							Name:       "kms",
+							Endpoint:   "unix:///run/kms/kms.sock",
+							CacheSize:  &cacheSize,
+						},
+					},
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
 		},
 		{
 			"invalid proxy mode",
@@ -601,6 +1096,221 @@ rules:
 			},
 			false,
 		},
+		{
+			"valid container engine",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Options: Options{
+					ContainerEngine: EngineContainerd,
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			false,
+		},
+		{
+			"invalid container engine",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Options: Options{
+					ContainerEngine: "podman",
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"valid etcd backup",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				EtcdBackup: EtcdBackup{
+					Enabled: true,
+					S3: &EtcdBackupS3{
+						Endpoint: "https://s3.example.com",
+						Bucket:   "backups",
+					},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			false,
+		},
+		{
+			"etcd backup without target",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				EtcdBackup: EtcdBackup{
+					Enabled: true,
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"etcd backup with multiple targets",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				EtcdBackup: EtcdBackup{
+					Enabled: true,
+					Local:   &EtcdBackupLocal{Dir: "/var/cke/etcd-backups"},
+					HTTP:    &EtcdBackupHTTP{URL: "https://backup.example.com/cke"},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"etcd backup with relative directory",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				EtcdBackup: EtcdBackup{
+					Enabled: true,
+					Local:   &EtcdBackupLocal{Dir: "etcd-backups"},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"etcd backup with invalid rotate",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				EtcdBackup: EtcdBackup{
+					Enabled: true,
+					Rotate:  pointer.Int(0),
+					HTTP:    &EtcdBackupHTTP{URL: "https://backup.example.com/cke"},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"etcd backup with rotate for http",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				EtcdBackup: EtcdBackup{
+					Enabled: true,
+					Rotate:  pointer.Int(7),
+					HTTP:    &EtcdBackupHTTP{URL: "https://backup.example.com/cke"},
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"valid images",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Images: Images{
+					Etcd:       "quay.io/cybozu/etcd:3.5.1.1",
+					Kubernetes: "registry.example.com:5000/kubernetes:v1.23.1",
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			false,
+		},
+		{
+			"images without version tag",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Images: Images{
+					Kubernetes: "registry.example.com:5000/kubernetes",
+				},
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+				},
+			},
+			true,
+		},
+		{
+			"valid rolling update",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint:   "/var/run/k8s-containerd.sock",
+						RollingUpdate: RollingUpdate{MaxBatchSize: 10, MaxPercentage: 20, PauseSeconds: 30},
+					},
+					Proxy: ProxyParams{
+						RollingUpdate: RollingUpdate{MaxBatchSize: 5},
+					},
+				},
+			},
+			false,
+		},
+		{
+			"invalid kubelet rolling update percentage",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint:   "/var/run/k8s-containerd.sock",
+						RollingUpdate: RollingUpdate{MaxPercentage: 101},
+					},
+				},
+			},
+			true,
+		},
+		{
+			"invalid kube-proxy rolling update batch size",
+			Cluster{
+				Name:          "testcluster",
+				ServiceSubnet: "10.0.0.0/14",
+				Options: Options{
+					Kubelet: KubeletParams{
+						CRIEndpoint: "/var/run/k8s-containerd.sock",
+					},
+					Proxy: ProxyParams{
+						RollingUpdate: RollingUpdate{MaxBatchSize: -1},
+					},
+				},
+			},
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -772,9 +1482,33 @@ func testNodename(t *testing.T) {
 
 }
 
+func testRollingUpdateBatchSize(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		rolling RollingUpdate
+		total   int
+		size    int
+	}{
+		{RollingUpdate{}, 10, 10},
+		{RollingUpdate{MaxBatchSize: 3}, 10, 3},
+		{RollingUpdate{MaxBatchSize: 30}, 10, 10},
+		{RollingUpdate{MaxPercentage: 25}, 10, 2},
+		{RollingUpdate{MaxPercentage: 5}, 10, 1},
+		{RollingUpdate{MaxBatchSize: 3, MaxPercentage: 50}, 10, 3},
+		{RollingUpdate{MaxBatchSize: 6, MaxPercentage: 50}, 10, 5},
+	}
+	for _, c := range cases {
+		if size := c.rolling.BatchSize(c.total); size != c.size {
+			t.Errorf("%+v.BatchSize(%d) = %d, want %d", c.rolling, c.total, size, c.size)
+		}
+	}
+}
+
 func TestCluster(t *testing.T) {
 	t.Run("YAML", testClusterYAML)
 	t.Run("Validate", testClusterValidate)
 	t.Run("ValidateNode", testClusterValidateNode)
 	t.Run("Nodename", testNodename)
+	t.Run("RollingUpdateBatchSize", testRollingUpdateBatchSize)
 }
@@ -1,13 +1,191 @@
 package cke
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
 
 // Constraints is a set of conditions that a cluster must satisfy
 type Constraints struct {
-	ControlPlaneCount        int `json:"control-plane-count"`
-	MinimumWorkers           int `json:"minimum-workers"`
-	MaximumWorkers           int `json:"maximum-workers"`
-	RebootMaximumUnreachable int `json:"maximum-unreachable-nodes-for-reboot"`
+	ControlPlaneCount        int                 `json:"control-plane-count"`
+	MinimumWorkers           int                 `json:"minimum-workers"`
+	MaximumWorkers           int                 `json:"maximum-workers"`
+	RebootMaximumUnreachable int                 `json:"maximum-unreachable-nodes-for-reboot"`
+	MaintenanceWindows       []MaintenanceWindow `json:"maintenance-windows,omitempty"`
+	Blackouts                []Blackout          `json:"blackouts,omitempty"`
+}
+
+// MaintenanceWindow is a weekly recurring period in which disruptive operations are allowed.
+type MaintenanceWindow struct {
+	// Days is the list of days of week such as "Mon".  Empty means every day.
+	Days []string `json:"days,omitempty"`
+	// Start is the start time of the window in "HH:MM" format.
+	Start string `json:"start"`
+	// Duration is the length of the window such as "4h".  It must not exceed 24h.
+	Duration string `json:"duration"`
+	// Timezone is the IANA time zone name.  Empty means UTC.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// Blackout is a period in which disruptive operations are not allowed.
+type Blackout struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+type parsedWindow struct {
+	days     map[time.Weekday]bool
+	hour     int
+	minute   int
+	duration time.Duration
+	loc      *time.Location
+}
+
+func (w MaintenanceWindow) parse() (*parsedWindow, error) {
+	pw := &parsedWindow{days: make(map[time.Weekday]bool)}
+	for _, d := range w.Days {
+		wd, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return nil, errors.New("invalid day of week: " + d)
+		}
+		pw.days[wd] = true
+	}
+
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start time %q: %w", w.Start, err)
+	}
+	pw.hour = start.Hour()
+	pw.minute = start.Minute()
+
+	pw.duration, err = time.ParseDuration(w.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration %q: %w", w.Duration, err)
+	}
+	if pw.duration <= 0 || pw.duration > 24*time.Hour {
+		return nil, errors.New("duration must be positive and not exceed 24h: " + w.Duration)
+	}
+
+	pw.loc, err = time.LoadLocation(w.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", w.Timezone, err)
+	}
+	return pw, nil
+}
+
+// contains returns true if now is in the window.
+func (pw *parsedWindow) contains(now time.Time) bool {
+	t := now.In(pw.loc)
+	// a window started yesterday may continue until today.
+	for _, offset := range []int{-1, 0} {
+		start := time.Date(t.Year(), t.Month(), t.Day()+offset, pw.hour, pw.minute, 0, 0, pw.loc)
+		if len(pw.days) > 0 && !pw.days[start.Weekday()] {
+			continue
+		}
+		if !t.Before(start) && t.Before(start.Add(pw.duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate validates the maintenance windows and blackouts.
+func (c *Constraints) Validate() error {
+	for i, w := range c.MaintenanceWindows {
+		if _, err := w.parse(); err != nil {
+			return fmt.Errorf("maintenance-windows[%d]: %w", i, err)
+		}
+	}
+	for i, b := range c.Blackouts {
+		if !b.End.After(b.Start) {
+			return fmt.Errorf("blackouts[%d]: end must be after start", i)
+		}
+	}
+	return nil
+}
+
+// DisruptionAllowed returns true if disruptive operations such as
+// restarting etcd or rebooting nodes are allowed at now.
+//
+// They are allowed when now is not in any blackout, and is in one of
+// the maintenance windows.  If no window is defined, they are allowed
+// at any time except during blackouts.
+func (c *Constraints) DisruptionAllowed(now time.Time) bool {
+	for _, b := range c.Blackouts {
+		if !now.Before(b.Start) && now.Before(b.End) {
+			return false
+		}
+	}
+
+	if len(c.MaintenanceWindows) == 0 {
+		return true
+	}
+	for _, w := range c.MaintenanceWindows {
+		pw, err := w.parse()
+		if err != nil {
+			// invalid windows are rejected on storing constraints; just ignore.
+			continue
+		}
+		if pw.contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextDisruptionAllowed returns the earliest time at or after now when
+// disruptive operations are allowed.  This returns false if there is no
+// such time within a week after the last blackout ends.
+func (c *Constraints) NextDisruptionAllowed(now time.Time) (time.Time, bool) {
+	// disruption becomes allowed only when a blackout ends or a window starts.
+	candidates := []time.Time{now}
+	last := now
+	for _, b := range c.Blackouts {
+		if b.End.After(now) {
+			candidates = append(candidates, b.End)
+			if b.End.After(last) {
+				last = b.End
+			}
+		}
+	}
+	days := int(last.Sub(now)/(24*time.Hour)) + 8
+	for _, w := range c.MaintenanceWindows {
+		pw, err := w.parse()
+		if err != nil {
+			continue
+		}
+		t := now.In(pw.loc)
+		for d := 0; d <= days; d++ {
+			start := time.Date(t.Year(), t.Month(), t.Day()+d, pw.hour, pw.minute, 0, 0, pw.loc)
+			if start.After(now) {
+				candidates = append(candidates, start)
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Before(candidates[j])
+	})
+	for _, t := range candidates {
+		if c.DisruptionAllowed(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
 }
 
 // Check checks the cluster satisfies the constraints
@@ -1,6 +1,9 @@
 package cke
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func testConstraintsCheck(t *testing.T) {
 	nodes := []*Node{
@@ -57,6 +60,145 @@ func testConstraintsCheck(t *testing.T) {
 	}
 }
 
+func testConstraintsValidate(t *testing.T) {
+	now := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		constraints Constraints
+		wantErr     bool
+	}{
+		{
+			name: "valid case",
+			constraints: Constraints{
+				MaintenanceWindows: []MaintenanceWindow{{Days: []string{"Mon", "sat"}, Start: "22:00", Duration: "4h", Timezone: "Asia/Tokyo"}},
+				Blackouts:          []Blackout{{Start: now, End: now.Add(time.Hour)}},
+			},
+			wantErr: false,
+		},
+		{
+			name:        "invalid day",
+			constraints: Constraints{MaintenanceWindows: []MaintenanceWindow{{Days: []string{"Monday"}, Start: "22:00", Duration: "4h"}}},
+			wantErr:     true,
+		},
+		{
+			name:        "invalid start",
+			constraints: Constraints{MaintenanceWindows: []MaintenanceWindow{{Start: "25:00", Duration: "4h"}}},
+			wantErr:     true,
+		},
+		{
+			name:        "too long duration",
+			constraints: Constraints{MaintenanceWindows: []MaintenanceWindow{{Start: "22:00", Duration: "25h"}}},
+			wantErr:     true,
+		},
+		{
+			name:        "invalid timezone",
+			constraints: Constraints{MaintenanceWindows: []MaintenanceWindow{{Start: "22:00", Duration: "1h", Timezone: "Foo/Bar"}}},
+			wantErr:     true,
+		},
+		{
+			name:        "invalid blackout",
+			constraints: Constraints{Blackouts: []Blackout{{Start: now, End: now}}},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		c := tt.constraints
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Constraints.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func testConstraintsDisruptionAllowed(t *testing.T) {
+	// 2021-12-01 is Wednesday.
+	wed := func(hour, min int) time.Time {
+		return time.Date(2021, 12, 1, hour, min, 0, 0, time.UTC)
+	}
+	windows := []MaintenanceWindow{
+		{Days: []string{"Tue"}, Start: "22:00", Duration: "4h"},
+		{Days: []string{"Wed"}, Start: "12:00", Duration: "30m"},
+	}
+	blackouts := []Blackout{{Start: wed(1, 0), End: wed(1, 30)}}
+
+	tests := []struct {
+		name        string
+		constraints Constraints
+		now         time.Time
+		want        bool
+	}{
+		{"no windows", Constraints{}, wed(10, 0), true},
+		{"in blackout without windows", Constraints{Blackouts: blackouts}, wed(1, 0), false},
+		{"after blackout", Constraints{Blackouts: blackouts}, wed(1, 30), true},
+		{"window from yesterday", Constraints{MaintenanceWindows: windows}, wed(0, 30), true},
+		{"end of window from yesterday", Constraints{MaintenanceWindows: windows}, wed(2, 0), false},
+		{"start of window", Constraints{MaintenanceWindows: windows}, wed(12, 0), true},
+		{"outside windows", Constraints{MaintenanceWindows: windows}, wed(12, 30), false},
+		{"blackout in window", Constraints{MaintenanceWindows: windows, Blackouts: blackouts}, wed(1, 10), false},
+		{"other timezone", Constraints{MaintenanceWindows: []MaintenanceWindow{{Start: "09:00", Duration: "1h", Timezone: "Asia/Tokyo"}}}, wed(0, 30), true},
+	}
+	for _, tt := range tests {
+		c := tt.constraints
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.DisruptionAllowed(tt.now); got != tt.want {
+				t.Errorf("Constraints.DisruptionAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testConstraintsNextDisruptionAllowed(t *testing.T) {
+	// 2021-12-01 is Wednesday.
+	wed := func(hour, min int) time.Time {
+		return time.Date(2021, 12, 1, hour, min, 0, 0, time.UTC)
+	}
+	windows := []MaintenanceWindow{
+		{Days: []string{"Tue"}, Start: "22:00", Duration: "4h"},
+		{Days: []string{"Wed"}, Start: "12:00", Duration: "30m"},
+	}
+	blackouts := []Blackout{{Start: wed(1, 0), End: wed(1, 30)}}
+
+	tests := []struct {
+		name        string
+		constraints Constraints
+		now         time.Time
+		want        time.Time
+	}{
+		{"no windows", Constraints{}, wed(10, 0), wed(10, 0)},
+		{"in blackout without windows", Constraints{Blackouts: blackouts}, wed(1, 10), wed(1, 30)},
+		{"in window", Constraints{MaintenanceWindows: windows}, wed(0, 30), wed(0, 30)},
+		{"next window", Constraints{MaintenanceWindows: windows}, wed(2, 0), wed(12, 0)},
+		{"next week", Constraints{MaintenanceWindows: windows}, wed(13, 0), wed(22, 0).AddDate(0, 0, 6)},
+		{"blackout in window", Constraints{MaintenanceWindows: windows, Blackouts: blackouts}, wed(1, 10), wed(1, 30)},
+		{
+			"blackout covers window",
+			Constraints{MaintenanceWindows: windows, Blackouts: []Blackout{{Start: wed(11, 0), End: wed(13, 0)}}},
+			wed(2, 0),
+			wed(22, 0).AddDate(0, 0, 6),
+		},
+		{
+			"long blackout",
+			Constraints{Blackouts: []Blackout{{Start: wed(0, 0), End: wed(0, 0).AddDate(0, 1, 0)}}},
+			wed(2, 0),
+			wed(0, 0).AddDate(0, 1, 0),
+		},
+	}
+	for _, tt := range tests {
+		c := tt.constraints
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := c.NextDisruptionAllowed(tt.now)
+			if !ok || !got.Equal(tt.want) {
+				t.Errorf("Constraints.NextDisruptionAllowed() = %v, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
 func TestConstraints(t *testing.T) {
 	t.Run("Check", testConstraintsCheck)
+	t.Run("Validate", testConstraintsValidate)
+	t.Run("DisruptionAllowed", testConstraintsDisruptionAllowed)
+	t.Run("NextDisruptionAllowed", testConstraintsNextDisruptionAllowed)
 }
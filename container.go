@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"path/filepath"
 	"strings"
 )
@@ -16,6 +17,29 @@ const (
 	CKELabelName = "com.cybozu.cke"
 )
 
+// Container engines to run CKE-managed containers.
+const (
+	EngineDocker     = "docker"
+	EngineContainerd = "containerd"
+)
+
+// NewContainerEngine returns the named ContainerEngine that runs commands with agent.
+// If name is empty, Docker is returned.
+func NewContainerEngine(name string, agent Agent) ContainerEngine {
+	if name == EngineContainerd {
+		return Containerd(agent)
+	}
+	return Docker(agent)
+}
+
+// engineCheckCommand returns a command to check the named container engine is available.
+func engineCheckCommand(name string) string {
+	if name == EngineContainerd {
+		return nerdctlCommand + " version"
+	}
+	return "docker version"
+}
+
 // ContainerEngine defines interfaces for a container engine.
 type ContainerEngine interface {
 	// PullImage pulls an image.
@@ -24,6 +48,9 @@ type ContainerEngine interface {
 	Run(img Image, binds []Mount, command string, args ...string) error
 	// RunWithInput runs a container as a foreground process with stdin as a string.
 	RunWithInput(img Image, binds []Mount, command, input string, args ...string) error
+	// RunWithReader runs a container as a foreground process with stdin read from input.
+	// This has no timeout as the input may be large.
+	RunWithReader(img Image, binds []Mount, command string, input io.Reader, args ...string) error
 	/// RunWithOutput runs a container as a foreground process and get stdout and stderr.
 	RunWithOutput(img Image, binds []Mount, command string, args ...string) ([]byte, []byte, error)
 	// RunSystem runs the named container as a system service.
@@ -51,91 +78,20 @@ type ckeLabel struct {
 	ExtraParams   ServiceParams `json:"extra"`
 }
 
-// Docker is an implementation of ContainerEngine.
-func Docker(agent Agent) ContainerEngine {
-	return docker{agent}
-}
-
-type docker struct {
-	agent Agent
-}
-
-func (c docker) PullImage(img Image) error {
-	stdout, stderr, err := c.agent.Run("docker image list --format '{{.Repository}}:{{.Tag}}'")
-	if err != nil {
-		return fmt.Errorf("%w, stdout: %s, stderr: %s", err, stdout, stderr)
-	}
-
-	for _, i := range strings.Split(string(stdout), "\n") {
-		if img.Name() == i {
-			return nil
-		}
-	}
-
-	stdout, stderr, err = c.agent.Run("docker image pull " + img.Name())
-	if err != nil {
-		return fmt.Errorf("%w, stdout: %s, stderr: %s", err, stdout, stderr)
-	}
-	return nil
-}
-
-func (c docker) Run(img Image, binds []Mount, command string, args ...string) error {
-	runArgs := []string{
-		"docker",
-		"run",
-		"--log-driver=journald",
-		"--rm",
-		"--network=host",
-		"--uts=host",
-		"--read-only",
-	}
-	for _, m := range binds {
-		o := "rw"
-		if m.ReadOnly {
-			o = "ro"
-		}
-		runArgs = append(runArgs, fmt.Sprintf("--volume=%s:%s:%s", m.Source, m.Destination, o))
-	}
-	runArgs = append(runArgs, img.Name(), command)
-	runArgs = append(runArgs, args...)
-
-	_, _, err := c.agent.Run(strings.Join(runArgs, " "))
-	return err
-}
-
-func (c docker) RunWithInput(img Image, binds []Mount, command, input string, args ...string) error {
+// runArgs returns the command line to run a container as a foreground process.
+// cli is the command line prefix of the container engine such as "docker".
+func runArgs(cli string, img Image, binds []Mount, interactive bool, command string, args ...string) string {
 	runArgs := []string{
-		"docker",
+		cli,
 		"run",
 		"--log-driver=journald",
 		"--rm",
-		"-i",
 		"--network=host",
 		"--uts=host",
 		"--read-only",
 	}
-	for _, m := range binds {
-		o := "rw"
-		if m.ReadOnly {
-			o = "ro"
-		}
-		runArgs = append(runArgs, fmt.Sprintf("--volume=%s:%s:%s", m.Source, m.Destination, o))
-	}
-	runArgs = append(runArgs, img.Name(), command)
-	runArgs = append(runArgs, args...)
-
-	return c.agent.RunWithInput(strings.Join(runArgs, " "), input)
-}
-
-func (c docker) RunWithOutput(img Image, binds []Mount, command string, args ...string) ([]byte, []byte, error) {
-	runArgs := []string{
-		"docker",
-		"run",
-		"--log-driver=journald",
-		"--rm",
-		"--network=host",
-		"--uts=host",
-		"--read-only",
+	if interactive {
+		runArgs = append(runArgs, "-i")
 	}
 	for _, m := range binds {
 		o := "rw"
@@ -146,27 +102,23 @@ func (c docker) RunWithOutput(img Image, binds []Mount, command string, args ...
 	}
 	runArgs = append(runArgs, img.Name(), command)
 	runArgs = append(runArgs, args...)
-
-	stdout, stderr, err := c.agent.Run(strings.Join(runArgs, " "))
-
-	return stdout, stderr, err
+	return strings.Join(runArgs, " ")
 }
 
-func (c docker) RunSystem(name string, img Image, opts []string, params, extra ServiceParams) error {
-	id, err := c.getID(name)
-	if err != nil {
-		return err
-	}
-	if len(id) != 0 {
-		cmdline := "docker rm " + name
-		stdout, stderr, err := c.agent.Run(cmdline)
+// runSystem runs the named container as a system service with cli.
+// If exists is true, the stopped container of the same name is removed beforehand.
+// Both docker and nerdctl accept the same options for this.
+func runSystem(agent Agent, cli string, exists bool, name string, img Image, opts []string, params, extra ServiceParams) error {
+	if exists {
+		cmdline := cli + " rm " + name
+		stdout, stderr, err := agent.Run(cmdline)
 		if err != nil {
 			return fmt.Errorf("%w, cmdline: %s, stdout: %s, stderr: %s", err, cmdline, stdout, stderr)
 		}
 	}
 
 	args := []string{
-		"docker",
+		cli,
 		"run",
 		"--log-driver=journald",
 		"-d",
@@ -205,7 +157,7 @@ func (c docker) RunSystem(name string, img Image, opts []string, params, extra S
 	if err != nil {
 		return err
 	}
-	labelFile, err := c.putData(CKELabelName + "=" + string(data))
+	labelFile, err := putData(agent, CKELabelName+"="+string(data))
 	if err != nil {
 		return err
 	}
@@ -217,13 +169,67 @@ func (c docker) RunSystem(name string, img Image, opts []string, params, extra S
 	args = append(args, extra.ExtraArguments...)
 
 	cmdline := strings.Join(args, " ")
-	stdout, stderr, err := c.agent.Run(cmdline)
+	stdout, stderr, err := agent.Run(cmdline)
 	if err != nil {
 		return fmt.Errorf("%w, cmdline: %s, stdout: %s, stderr: %s", err, cmdline, stdout, stderr)
 	}
 	return nil
 }
 
+// Docker is an implementation of ContainerEngine.
+func Docker(agent Agent) ContainerEngine {
+	return docker{agent}
+}
+
+type docker struct {
+	agent Agent
+}
+
+func (c docker) PullImage(img Image) error {
+	stdout, stderr, err := c.agent.Run("docker image list --format '{{.Repository}}:{{.Tag}}'")
+	if err != nil {
+		return fmt.Errorf("%w, stdout: %s, stderr: %s", err, stdout, stderr)
+	}
+
+	for _, i := range strings.Split(string(stdout), "\n") {
+		if img.Name() == i {
+			return nil
+		}
+	}
+
+	stdout, stderr, err = c.agent.Run("docker image pull " + img.Name())
+	if err != nil {
+		return fmt.Errorf("%w, stdout: %s, stderr: %s", err, stdout, stderr)
+	}
+	return nil
+}
+
+func (c docker) Run(img Image, binds []Mount, command string, args ...string) error {
+	_, _, err := c.agent.Run(runArgs("docker", img, binds, false, command, args...))
+	return err
+}
+
+func (c docker) RunWithInput(img Image, binds []Mount, command, input string, args ...string) error {
+	return c.agent.RunWithInput(runArgs("docker", img, binds, true, command, args...), input)
+}
+
+func (c docker) RunWithReader(img Image, binds []Mount, command string, input io.Reader, args ...string) error {
+	_, _, err := c.agent.RunWithReader(runArgs("docker", img, binds, true, command, args...), input, 0)
+	return err
+}
+
+func (c docker) RunWithOutput(img Image, binds []Mount, command string, args ...string) ([]byte, []byte, error) {
+	return c.agent.Run(runArgs("docker", img, binds, false, command, args...))
+}
+
+func (c docker) RunSystem(name string, img Image, opts []string, params, extra ServiceParams) error {
+	id, err := c.getID(name)
+	if err != nil {
+		return err
+	}
+	return runSystem(c.agent, "docker", len(id) != 0, name, img, opts, params, extra)
+}
+
 func (c docker) Stop(name string) error {
 	cmdline := "docker container stop " + name
 	stdout, stderr, err := c.agent.Run(cmdline)
@@ -251,14 +257,14 @@ func (c docker) Remove(name string) error {
 	return nil
 }
 
-func (c docker) putData(data string) (string, error) {
+func putData(agent Agent, data string) (string, error) {
 	b := make([]byte, 16)
 	_, err := rand.Read(b)
 	if err != nil {
 		return "", err
 	}
 	fileName := filepath.Join("/tmp", hex.EncodeToString(b))
-	err = c.agent.RunWithInput("tee "+fileName, data)
+	err = agent.RunWithInput("tee "+fileName, data)
 	if err != nil {
 		return "", err
 	}
@@ -304,13 +310,15 @@ func (c docker) Exists(name string) (bool, error) {
 
 // this is a partial copy of ContainerJSON in github.com/moby/moby/api/types
 type containerJSON struct {
+	ID     string
 	Name   string
 	Config struct {
 		Image  string
 		Labels map[string]string
 	}
 	State struct {
-		Running bool
+		Running  bool
+		ExitCode int
 	}
 }
 
@@ -340,8 +348,13 @@ RETRY:
 		goto RETRY
 	}
 
+	return parseInspectOutput(stdout)
+}
+
+// parseInspectOutput parses the output of "docker container inspect".
+func parseInspectOutput(stdout []byte) (map[string]ServiceStatus, error) {
 	var djs []containerJSON
-	err = json.Unmarshal(stdout, &djs)
+	err := json.Unmarshal(stdout, &djs)
 	if err != nil {
 		return nil, err
 	}
@@ -362,6 +375,8 @@ RETRY:
 			Image:         dj.Config.Image,
 			BuiltInParams: params.BuiltInParams,
 			ExtraParams:   params.ExtraParams,
+			ContainerID:   dj.ID,
+			ExitCode:      dj.State.ExitCode,
 		}
 	}
 
@@ -0,0 +1,186 @@
+package cke
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// nerdctlCommand is the command line prefix to control containerd.
+// CKE-managed containers are placed in a dedicated namespace so that
+// they are not touched by kubelet through CRI.
+const nerdctlCommand = "nerdctl --namespace=cke"
+
+// Containerd is an implementation of ContainerEngine.
+// It controls containerd with nerdctl.
+func Containerd(agent Agent) ContainerEngine {
+	return containerd{agent}
+}
+
+type containerd struct {
+	agent Agent
+}
+
+func (c containerd) PullImage(img Image) error {
+	stdout, stderr, err := c.agent.Run(nerdctlCommand + " image ls --format '{{.Repository}}:{{.Tag}}'")
+	if err != nil {
+		return fmt.Errorf("%w, stdout: %s, stderr: %s", err, stdout, stderr)
+	}
+
+	for _, i := range strings.Split(string(stdout), "\n") {
+		if img.Name() == i {
+			return nil
+		}
+	}
+
+	stdout, stderr, err = c.agent.Run(nerdctlCommand + " image pull " + img.Name())
+	if err != nil {
+		return fmt.Errorf("%w, stdout: %s, stderr: %s", err, stdout, stderr)
+	}
+	return nil
+}
+
+func (c containerd) Run(img Image, binds []Mount, command string, args ...string) error {
+	_, _, err := c.agent.Run(runArgs(nerdctlCommand, img, binds, false, command, args...))
+	return err
+}
+
+func (c containerd) RunWithInput(img Image, binds []Mount, command, input string, args ...string) error {
+	return c.agent.RunWithInput(runArgs(nerdctlCommand, img, binds, true, command, args...), input)
+}
+
+func (c containerd) RunWithReader(img Image, binds []Mount, command string, input io.Reader, args ...string) error {
+	_, _, err := c.agent.RunWithReader(runArgs(nerdctlCommand, img, binds, true, command, args...), input, 0)
+	return err
+}
+
+func (c containerd) RunWithOutput(img Image, binds []Mount, command string, args ...string) ([]byte, []byte, error) {
+	return c.agent.Run(runArgs(nerdctlCommand, img, binds, false, command, args...))
+}
+
+func (c containerd) RunSystem(name string, img Image, opts []string, params, extra ServiceParams) error {
+	id, err := c.getID(name)
+	if err != nil {
+		return err
+	}
+	return runSystem(c.agent, nerdctlCommand, len(id) != 0, name, img, opts, params, extra)
+}
+
+func (c containerd) run(cmdline string) error {
+	stdout, stderr, err := c.agent.Run(cmdline)
+	if err != nil {
+		return fmt.Errorf("%w, cmdline: %s, stdout: %s, stderr: %s", err, cmdline, stdout, stderr)
+	}
+	return nil
+}
+
+func (c containerd) Stop(name string) error {
+	return c.run(nerdctlCommand + " container stop " + name)
+}
+
+func (c containerd) Kill(name string) error {
+	return c.run(nerdctlCommand + " container kill " + name)
+}
+
+func (c containerd) Remove(name string) error {
+	return c.run(nerdctlCommand + " container rm " + name)
+}
+
+func (c containerd) getID(name string) (string, error) {
+	ids, err := c.getIDs([]string{name})
+	if err != nil {
+		return "", err
+	}
+	return ids[name], nil
+}
+
+// getIDs returns container IDs for names.
+// Unlike docker, nerdctl does not support regular expressions in name filters,
+// so this lists all containers and picks up the exact names.
+func (c containerd) getIDs(names []string) (map[string]string, error) {
+	cmdline := nerdctlCommand + " ps -a --no-trunc --format {{.Names}}:{{.ID}}"
+	stdout, stderr, err := c.agent.Run(cmdline)
+	if err != nil {
+		return nil, fmt.Errorf("%w, cmdline: %s, stdout: %s, stderr: %s", err, cmdline, stdout, stderr)
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	ids := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(stdout))
+	for scanner.Scan() {
+		nameID := strings.Split(scanner.Text(), ":")
+		if len(nameID) != 2 || !wanted[nameID[0]] {
+			continue
+		}
+		ids[nameID[0]] = nameID[1]
+	}
+	return ids, nil
+}
+
+func (c containerd) Exists(name string) (bool, error) {
+	id, err := c.getID(name)
+	if err != nil {
+		return false, err
+	}
+	return len(id) != 0, nil
+}
+
+func (c containerd) Inspect(names []string) (map[string]ServiceStatus, error) {
+	retryCount := 0
+RETRY:
+	nameIds, err := c.getIDs(names)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, id := range nameIds {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	// nerdctl prints inspection results in the same format as docker.
+	cmdline := nerdctlCommand + " container inspect " + strings.Join(ids, " ")
+	stdout, stderr, err := c.agent.Run(cmdline)
+	if err != nil {
+		// a container may be removed after listing.
+		retryCount++
+		if retryCount >= 3 {
+			return nil, fmt.Errorf("%w, cmdline: %s, stdout: %s, stderr: %s", err, cmdline, stdout, stderr)
+		}
+		goto RETRY
+	}
+
+	return parseInspectOutput(stdout)
+}
+
+func (c containerd) VolumeCreate(name string) error {
+	return c.run(nerdctlCommand + " volume create " + name)
+}
+
+func (c containerd) VolumeRemove(name string) error {
+	return c.run(nerdctlCommand + " volume rm " + name)
+}
+
+func (c containerd) VolumeExists(name string) (bool, error) {
+	cmdline := nerdctlCommand + " volume ls -q"
+	stdout, stderr, err := c.agent.Run(cmdline)
+	if err != nil {
+		return false, fmt.Errorf("%w, cmdline: %s, stdout: %s, stderr: %s", err, cmdline, stdout, stderr)
+	}
+
+	for _, n := range strings.Split(string(stdout), "\n") {
+		if n == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,87 @@
+package cke
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeAgent struct {
+	outputs map[string]string
+}
+
+func (a fakeAgent) Close() error {
+	return nil
+}
+
+func (a fakeAgent) Run(command string) ([]byte, []byte, error) {
+	for prefix, out := range a.outputs {
+		if strings.HasPrefix(command, prefix) {
+			return []byte(out), nil, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+func (a fakeAgent) RunWithInput(command, input string) error {
+	return nil
+}
+
+func (a fakeAgent) RunWithTimeout(command, input string, timeout time.Duration) ([]byte, []byte, error) {
+	return a.Run(command)
+}
+
+func (a fakeAgent) RunWithReader(command string, input io.Reader, timeout time.Duration) ([]byte, []byte, error) {
+	return a.Run(command)
+}
+
+func TestContainerdInspect(t *testing.T) {
+	t.Parallel()
+
+	agent := fakeAgent{
+		outputs: map[string]string{
+			nerdctlCommand + " ps": "etcd:1234\netcd-rivers:5678\nfoo:9999\n",
+			nerdctlCommand + " container inspect": `[
+{"Name": "etcd", "Config": {"Image": "quay.io/cybozu/etcd:3.5", "Labels": {"com.cybozu.cke": "{\"builtin\":{\"extra_args\":[\"a\"]},\"extra\":{}}"}}, "State": {"Running": true}},
+{"Name": "etcd-rivers", "Config": {"Image": "quay.io/cybozu/cke-tools:1.0", "Labels": {"com.cybozu.cke": "{}"}}, "State": {"Running": false, "ExitCode": 1}}
+]`,
+		},
+	}
+	ce := NewContainerEngine(EngineContainerd, agent)
+
+	exists, err := ce.Exists("etcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("etcd should exist")
+	}
+	exists, err = ce.Exists("etc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("etc should not exist")
+	}
+
+	ss, err := ce.Inspect([]string{"etcd", "etcd-rivers", "kubelet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ss) != 2 {
+		t.Fatalf("unexpected statuses: %#v", ss)
+	}
+	if !ss["etcd"].Running || ss["etcd"].Image != "quay.io/cybozu/etcd:3.5" {
+		t.Errorf("unexpected etcd status: %#v", ss["etcd"])
+	}
+	if len(ss["etcd"].BuiltInParams.ExtraArguments) != 1 {
+		t.Errorf("unexpected etcd params: %#v", ss["etcd"].BuiltInParams)
+	}
+	if ss["etcd-rivers"].Running {
+		t.Error("etcd-rivers should not be running")
+	}
+	if ss["etcd-rivers"].ExitCode != 1 {
+		t.Errorf("unexpected exit code of etcd-rivers: %d", ss["etcd-rivers"].ExitCode)
+	}
+}
@@ -0,0 +1,215 @@
+package cke
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	apiserverv1 "k8s.io/apiserver/pkg/apis/config/v1"
+)
+
+// Providers to encrypt Kubernetes Secrets at rest.
+const (
+	EncryptionProviderAESCBC    = "aescbc"
+	EncryptionProviderAESGCM    = "aesgcm"
+	EncryptionProviderSecretbox = "secretbox"
+)
+
+// EncryptionProviders is the list of supported encryption providers.
+var EncryptionProviders = []string{
+	EncryptionProviderAESCBC,
+	EncryptionProviderAESGCM,
+	EncryptionProviderSecretbox,
+}
+
+// encryptionPrimaryKey is the name of the field in SecretK8s that
+// holds the provider used to encrypt Secrets.
+const encryptionPrimaryKey = "primary"
+
+// EncryptionKeys is the set of keys to encrypt Kubernetes Secrets.
+// The first key of the primary provider is used for encryption.
+// All keys are used for decryption.
+type EncryptionKeys struct {
+	Primary   string
+	Providers map[string][]apiserverv1.Key
+}
+
+// ReadEncryptionKeys reads encryption keys from the backend.
+func ReadEncryptionKeys(ctx context.Context, b Backend) (*EncryptionKeys, error) {
+	secret, err := b.ReadSecret(ctx, SecretK8s)
+	if err == ErrNotFound {
+		return nil, errors.New("no encryption secrets for API server")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := &EncryptionKeys{
+		Primary:   EncryptionProviderAESCBC,
+		Providers: make(map[string][]apiserverv1.Key),
+	}
+	if p := secret[encryptionPrimaryKey]; len(p) > 0 {
+		keys.Primary = p
+	}
+	for _, p := range EncryptionProviders {
+		data, ok := secret[p]
+		if !ok {
+			continue
+		}
+		// AESConfiguration and SecretboxConfiguration have the same schema.
+		cfg := new(apiserverv1.AESConfiguration)
+		if err := json.Unmarshal([]byte(data), cfg); err != nil {
+			return nil, err
+		}
+		if len(cfg.Keys) > 0 {
+			keys.Providers[p] = cfg.Keys
+		}
+	}
+	if len(keys.Providers[keys.Primary]) == 0 {
+		return nil, errors.New("no secret data for " + keys.Primary)
+	}
+	return keys, nil
+}
+
+// WriteEncryptionKeys writes encryption keys to the backend.
+func WriteEncryptionKeys(ctx context.Context, b Backend, keys *EncryptionKeys) error {
+	data := map[string]string{
+		encryptionPrimaryKey: keys.Primary,
+	}
+	for _, p := range EncryptionProviders {
+		if len(keys.Providers[p]) == 0 {
+			continue
+		}
+		cfg, err := json.Marshal(apiserverv1.AESConfiguration{Keys: keys.Providers[p]})
+		if err != nil {
+			return err
+		}
+		data[p] = string(cfg)
+	}
+
+	return b.WriteSecret(ctx, SecretK8s, data)
+}
+
+// HasKey returns true if the provider has a key of the name.
+func (k *EncryptionKeys) HasKey(provider, name string) bool {
+	for _, key := range k.Providers[provider] {
+		if key.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AddKey adds a key to the provider as a key only for decryption.
+func (k *EncryptionKeys) AddKey(provider string, key apiserverv1.Key) {
+	if k.HasKey(provider, key.Name) {
+		return
+	}
+	k.Providers[provider] = append(k.Providers[provider], key)
+}
+
+// Promote makes the key of the name the one for encryption.
+func (k *EncryptionKeys) Promote(provider, name string) error {
+	keys := k.Providers[provider]
+	for i, key := range keys {
+		if key.Name != name {
+			continue
+		}
+		promoted := []apiserverv1.Key{key}
+		promoted = append(promoted, keys[:i]...)
+		promoted = append(promoted, keys[i+1:]...)
+		k.Providers[provider] = promoted
+		k.Primary = provider
+		return nil
+	}
+	return errors.New("no such encryption key: " + provider + "/" + name)
+}
+
+// Prune removes keys other than the key of the name.
+func (k *EncryptionKeys) Prune(provider, name string) error {
+	for _, key := range k.Providers[provider] {
+		if key.Name != name {
+			continue
+		}
+		k.Providers = map[string][]apiserverv1.Key{
+			provider: {key},
+		}
+		return nil
+	}
+	return errors.New("no such encryption key: " + provider + "/" + name)
+}
+
+// Configuration returns EncryptionConfiguration for kube-apiserver.
+func (k *EncryptionKeys) Configuration() *apiserverv1.EncryptionConfiguration {
+	providers := []apiserverv1.ProviderConfiguration{
+		providerConfiguration(k.Primary, k.Providers[k.Primary]),
+	}
+	for _, p := range EncryptionProviders {
+		if p == k.Primary || len(k.Providers[p]) == 0 {
+			continue
+		}
+		providers = append(providers, providerConfiguration(p, k.Providers[p]))
+	}
+	providers = append(providers, apiserverv1.ProviderConfiguration{Identity: &apiserverv1.IdentityConfiguration{}})
+
+	return &apiserverv1.EncryptionConfiguration{
+		Resources: []apiserverv1.ResourceConfiguration{
+			{
+				Resources: []string{"secrets"},
+				Providers: providers,
+			},
+		},
+	}
+}
+
+func providerConfiguration(provider string, keys []apiserverv1.Key) apiserverv1.ProviderConfiguration {
+	switch provider {
+	case EncryptionProviderAESGCM:
+		return apiserverv1.ProviderConfiguration{AESGCM: &apiserverv1.AESConfiguration{Keys: keys}}
+	case EncryptionProviderSecretbox:
+		return apiserverv1.ProviderConfiguration{Secretbox: &apiserverv1.SecretboxConfiguration{Keys: keys}}
+	default:
+		return apiserverv1.ProviderConfiguration{AESCBC: &apiserverv1.AESConfiguration{Keys: keys}}
+	}
+}
+
+// EncryptionKeyRotationStep is a step of the encryption key rotation.
+type EncryptionKeyRotationStep string
+
+// Steps of the encryption key rotation.
+const (
+	// EncryptionKeyRotationAdd adds the new key for decryption to all API servers.
+	EncryptionKeyRotationAdd = EncryptionKeyRotationStep("add")
+	// EncryptionKeyRotationPromote makes the new key the one for encryption.
+	EncryptionKeyRotationPromote = EncryptionKeyRotationStep("promote")
+	// EncryptionKeyRotationRewrite rewrites all Secrets with the new key.
+	EncryptionKeyRotationRewrite = EncryptionKeyRotationStep("rewrite")
+	// EncryptionKeyRotationPrune removes the old keys from all API servers.
+	EncryptionKeyRotationPrune = EncryptionKeyRotationStep("prune")
+)
+
+// EncryptionKeyRotation represents the progress of rotating the encryption key
+// for Kubernetes Secrets.
+type EncryptionKeyRotation struct {
+	// Provider is the provider of the new key.
+	Provider string `json:"provider"`
+	// KeyName is the name of the new key stored in Vault.
+	KeyName string                    `json:"key_name"`
+	Step    EncryptionKeyRotationStep `json:"step"`
+	Started time.Time                 `json:"started"`
+}
+
+// NextStep returns the step following the current one.
+// This returns an empty string if the current step is the last one.
+func (r *EncryptionKeyRotation) NextStep() EncryptionKeyRotationStep {
+	switch r.Step {
+	case EncryptionKeyRotationAdd:
+		return EncryptionKeyRotationPromote
+	case EncryptionKeyRotationPromote:
+		return EncryptionKeyRotationRewrite
+	case EncryptionKeyRotationRewrite:
+		return EncryptionKeyRotationPrune
+	}
+	return ""
+}
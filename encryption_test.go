@@ -0,0 +1,86 @@
+package cke
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apiserverv1 "k8s.io/apiserver/pkg/apis/config/v1"
+)
+
+func testEncryptionKeysRotation(t *testing.T) {
+	oldKey := apiserverv1.Key{Name: "old", Secret: "b2xk"}
+	newKey := apiserverv1.Key{Name: "new", Secret: "bmV3"}
+	keys := &EncryptionKeys{
+		Primary: EncryptionProviderAESCBC,
+		Providers: map[string][]apiserverv1.Key{
+			EncryptionProviderAESCBC: {oldKey},
+		},
+	}
+	identity := apiserverv1.ProviderConfiguration{Identity: &apiserverv1.IdentityConfiguration{}}
+
+	keys.AddKey(EncryptionProviderAESGCM, newKey)
+	keys.AddKey(EncryptionProviderAESGCM, newKey)
+	expected := []apiserverv1.ProviderConfiguration{
+		{AESCBC: &apiserverv1.AESConfiguration{Keys: []apiserverv1.Key{oldKey}}},
+		{AESGCM: &apiserverv1.AESConfiguration{Keys: []apiserverv1.Key{newKey}}},
+		identity,
+	}
+	if diff := cmp.Diff(expected, keys.Configuration().Resources[0].Providers); diff != "" {
+		t.Error("unexpected providers after add:", diff)
+	}
+
+	err := keys.Promote(EncryptionProviderAESGCM, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []apiserverv1.ProviderConfiguration{
+		{AESGCM: &apiserverv1.AESConfiguration{Keys: []apiserverv1.Key{newKey}}},
+		{AESCBC: &apiserverv1.AESConfiguration{Keys: []apiserverv1.Key{oldKey}}},
+		identity,
+	}
+	if diff := cmp.Diff(expected, keys.Configuration().Resources[0].Providers); diff != "" {
+		t.Error("unexpected providers after promote:", diff)
+	}
+
+	err = keys.Prune(EncryptionProviderAESGCM, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []apiserverv1.ProviderConfiguration{
+		{AESGCM: &apiserverv1.AESConfiguration{Keys: []apiserverv1.Key{newKey}}},
+		identity,
+	}
+	if diff := cmp.Diff(expected, keys.Configuration().Resources[0].Providers); diff != "" {
+		t.Error("unexpected providers after prune:", diff)
+	}
+
+	if keys.Promote(EncryptionProviderSecretbox, "new") == nil {
+		t.Error("promoting a missing key should fail")
+	}
+	if keys.Prune(EncryptionProviderAESCBC, "old") == nil {
+		t.Error("pruning with a missing key should fail")
+	}
+}
+
+func testEncryptionKeyRotationNextStep(t *testing.T) {
+	r := &EncryptionKeyRotation{Step: EncryptionKeyRotationAdd}
+	var steps []EncryptionKeyRotationStep
+	for r.Step != "" {
+		steps = append(steps, r.Step)
+		r.Step = r.NextStep()
+	}
+	expected := []EncryptionKeyRotationStep{
+		EncryptionKeyRotationAdd,
+		EncryptionKeyRotationPromote,
+		EncryptionKeyRotationRewrite,
+		EncryptionKeyRotationPrune,
+	}
+	if !cmp.Equal(expected, steps) {
+		t.Error("unexpected steps:", cmp.Diff(expected, steps))
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	t.Run("KeysRotation", testEncryptionKeysRotation)
+	t.Run("NextStep", testEncryptionKeyRotationNextStep)
+}
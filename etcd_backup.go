@@ -0,0 +1,20 @@
+package cke
+
+import "time"
+
+// EtcdBackupStatus represents the last successful backup of etcd.
+type EtcdBackupStatus struct {
+	Name     string    `json:"name"`
+	Time     time.Time `json:"time"`
+	Size     int64     `json:"size"`
+	Checksum string    `json:"checksum"`
+}
+
+// EtcdRestoreRequest represents a request to restore etcd from a backup.
+type EtcdRestoreRequest struct {
+	// Name is the name of the backup in the configured backup target.
+	Name string `json:"name"`
+	// Token is the initial cluster token for the restored etcd cluster.
+	Token     string    `json:"token"`
+	Requested time.Time `json:"requested"`
+}
@@ -0,0 +1,155 @@
+package etcdbackup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/cybozu-go/cke"
+)
+
+const (
+	snapshotPrefix  = "etcd-"
+	snapshotSuffix  = ".backup"
+	checksumSuffix  = ".sha256"
+	snapshotTimeFmt = "20060102-150405"
+	defaultInterval = time.Hour
+	defaultRotate   = 14
+	defaultS3Region = "us-east-1"
+
+	maxChecksumFileSize = 4096
+)
+
+// ErrListNotSupported is returned from Backend.List when the backend
+// cannot enumerate stored snapshots.
+var ErrListNotSupported = errors.New("listing snapshots is not supported")
+
+// Backend is the interface of storage to keep etcd snapshots.
+//
+// Along with each snapshot, backends keep its SHA-256 checksum in a file
+// named after the snapshot with ".sha256" suffix in the format of sha256sum.
+type Backend interface {
+	// Put stores a snapshot of size bytes read from r as name.
+	// sum is the hex-encoded SHA-256 checksum of the snapshot.
+	// Backends should verify the checksum of the stored data if possible.
+	Put(ctx context.Context, name string, r io.Reader, size int64, sum string) error
+
+	// Get returns a reader of a stored snapshot or its checksum file.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// List returns the names of stored snapshots.
+	List(ctx context.Context) ([]string, error)
+
+	// Remove removes a stored snapshot and its checksum.
+	Remove(ctx context.Context, name string) error
+}
+
+// NewBackend creates a Backend from the configuration.
+func NewBackend(cfg *cke.EtcdBackup) (Backend, error) {
+	switch {
+	case cfg.Local != nil:
+		return NewLocalBackend(cfg.Local.Dir), nil
+	case cfg.S3 != nil:
+		return NewS3Backend(cfg.S3, S3CredentialsFromEnv())
+	case cfg.HTTP != nil:
+		return NewHTTPBackend(cfg.HTTP.URL), nil
+	}
+	return nil, errors.New("no backup target is configured")
+}
+
+// Fetch reads the snapshot name from b into w and verifies it with the
+// stored checksum.  It returns the hex-encoded SHA-256 checksum.
+// If this returns an error, data written to w should be discarded.
+func Fetch(ctx context.Context, b Backend, name string, w io.Writer) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+
+	sumFile, err := readAll(ctx, b, checksumName(name), maxChecksumFileSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to read checksum of %s: %w", name, err)
+	}
+	fields := strings.Fields(string(sumFile))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("invalid checksum file for %s", name)
+	}
+
+	r, err := b.Get(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), r); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if sum != fields[0] {
+		return "", fmt.Errorf("checksum mismatch for %s: expected %s, actual %s", name, fields[0], sum)
+	}
+	return sum, nil
+}
+
+func readAll(ctx context.Context, b Backend, name string, limit int64) ([]byte, error) {
+	r, err := b.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(io.LimitReader(r, limit))
+}
+
+// Interval returns the interval between backups.
+func Interval(cfg *cke.EtcdBackup) time.Duration {
+	if cfg.IntervalSeconds == nil {
+		return defaultInterval
+	}
+	return time.Duration(*cfg.IntervalSeconds) * time.Second
+}
+
+// Rotate returns the number of snapshots to keep.
+func Rotate(cfg *cke.EtcdBackup) int {
+	if cfg.Rotate == nil {
+		return defaultRotate
+	}
+	return *cfg.Rotate
+}
+
+// SnapshotName returns the name of a snapshot taken at t.
+func SnapshotName(t time.Time) string {
+	return snapshotPrefix + t.UTC().Format(snapshotTimeFmt) + snapshotSuffix
+}
+
+// ValidateName returns an error if name is not a name of a snapshot
+// returned from SnapshotName.
+func ValidateName(name string) error {
+	if !isSnapshotName(name) {
+		return fmt.Errorf("invalid snapshot name: %q", name)
+	}
+	return nil
+}
+
+func isSnapshotName(name string) bool {
+	if !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+		return false
+	}
+	ts := strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix)
+	_, err := time.Parse(snapshotTimeFmt, ts)
+	return err == nil
+}
+
+func checksumName(name string) string {
+	return name + checksumSuffix
+}
+
+// checksumFile returns the contents of a checksum file in the format of sha256sum.
+func checksumFile(name, sum string) string {
+	return fmt.Sprintf("%s  %s\n", sum, name)
+}
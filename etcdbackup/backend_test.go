@@ -0,0 +1,99 @@
+package etcdbackup
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestValidateName(t *testing.T) {
+	t.Parallel()
+
+	valid := []string{
+		SnapshotName(time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)),
+		"etcd-20211001-000000.backup",
+	}
+	for _, name := range valid {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("%s should be valid: %v", name, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"snapshot.db",
+		"etcd-20211001-000000.backup.sha256",
+		"etcd-latest.backup",
+		"etcd-../../etc/shadow-.backup",
+		"etcd-20211001-000000/../../x.backup",
+	}
+	for _, name := range invalid {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("%s should be invalid", name)
+		}
+	}
+}
+
+func TestFetchFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("meta"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("consistent_index"), []byte{0, 0, 0, 0, 0, 0, 0, 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	b := NewLocalBackend(filepath.Join(t.TempDir(), "backups"))
+	valid := "etcd-20211001-000000.backup"
+	if err := b.Put(ctx, valid, bytes.NewReader(data), int64(len(data)), sha256Hex(string(data))); err != nil {
+		t.Fatal(err)
+	}
+	broken := "etcd-20211001-010000.backup"
+	if err := b.Put(ctx, broken, strings.NewReader("broken"), 6, sha256Hex("broken")); err != nil {
+		t.Fatal(err)
+	}
+
+	fetched, sum, err := FetchFile(ctx, b, valid)
+	if err != nil {
+		t.Fatalf("valid snapshot is rejected: %v", err)
+	}
+	defer os.Remove(fetched)
+	if sum != sha256Hex(string(data)) {
+		t.Errorf("unexpected checksum: %s", sum)
+	}
+	fetchedData, err := os.ReadFile(fetched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fetchedData, data) {
+		t.Error("fetched snapshot differs")
+	}
+
+	if _, _, err := FetchFile(ctx, b, broken); err == nil {
+		t.Error("broken snapshot should be rejected")
+	}
+}
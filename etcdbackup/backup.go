@@ -0,0 +1,131 @@
+package etcdbackup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/log"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/etcdutl/v3/snapshot"
+)
+
+// Take takes a snapshot of etcd, verifies it, and stores it in b.
+//
+// The snapshot is once saved in a temporary file to check its integrity
+// and to calculate its checksum before it is passed to b.
+func Take(ctx context.Context, etcd *clientv3.Client, b Backend, now time.Time) (*cke.EtcdBackupStatus, error) {
+	r, err := etcd.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	f, err := os.CreateTemp("", "cke-etcd-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	if err := checkSnapshot(f.Name()); err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	name := SnapshotName(now)
+	if err := b.Put(ctx, name, f, size, sum); err != nil {
+		return nil, fmt.Errorf("failed to store %s: %w", name, err)
+	}
+
+	return &cke.EtcdBackupStatus{
+		Name:     name,
+		Time:     now.UTC(),
+		Size:     size,
+		Checksum: sum,
+	}, nil
+}
+
+// RemoveOld removes old snapshots in b so that at most keep snapshots remain.
+// If b cannot list snapshots, this does nothing.
+func RemoveOld(ctx context.Context, b Backend, keep int) error {
+	names, err := b.List(ctx)
+	if err == ErrListNotSupported {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	toRemove := len(names) - keep
+	for i := 0; i < toRemove; i++ {
+		if err := b.Remove(ctx, names[i]); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", names[i], err)
+		}
+		log.Info("removed old etcd backup", map[string]interface{}{
+			"name": names[i],
+		})
+	}
+	return nil
+}
+
+// checkSnapshot checks the integrity of the snapshot file at path.
+func checkSnapshot(path string) error {
+	ss := snapshot.NewV3(Logger())
+	if _, err := ss.Status(path); err != nil {
+		return fmt.Errorf("failed to check status of the snapshot: %w", err)
+	}
+	return nil
+}
+
+// FetchFile fetches the snapshot name from b into a temporary file like
+// Fetch, and checks its integrity.  It returns the path of the file and its
+// checksum.  The caller should remove the file.
+func FetchFile(ctx context.Context, b Backend, name string) (string, string, error) {
+	f, err := os.CreateTemp("", "cke-etcd-snapshot-")
+	if err != nil {
+		return "", "", err
+	}
+	path := f.Name()
+	sum, err := fetchFile(ctx, b, name, f)
+	if err != nil {
+		os.Remove(path)
+		return "", "", err
+	}
+	return path, sum, nil
+}
+
+func fetchFile(ctx context.Context, b Backend, name string, f *os.File) (string, error) {
+	defer f.Close()
+
+	sum, err := Fetch(ctx, b, name, f)
+	if err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := checkSnapshot(f.Name()); err != nil {
+		return "", fmt.Errorf("%s is not a valid snapshot: %w", name, err)
+	}
+	return sum, nil
+}
@@ -0,0 +1,4 @@
+// Package etcdbackup takes snapshots of etcd managed by CKE and keeps them
+// in storage backends such as a local directory, S3-compatible object storage,
+// or an HTTP server.
+package etcdbackup
@@ -0,0 +1,97 @@
+package etcdbackup
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type httpBackend struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPBackend returns a Backend that uploads snapshots to baseURL with HTTP PUT.
+// A snapshot is uploaded to baseURL + "/" + name.
+//
+// The backend sends the checksum of the snapshot in the "Digest" header
+// so that the server can verify the uploaded data.  As HTTP does not have
+// a standard way to list files, List returns ErrListNotSupported.
+func NewHTTPBackend(baseURL string) Backend {
+	return httpBackend{
+		url:    strings.TrimSuffix(baseURL, "/"),
+		client: &http.Client{},
+	}
+}
+
+func (b httpBackend) do(ctx context.Context, method, name string, body io.Reader, size int64, sum string) error {
+	req, err := http.NewRequestWithContext(ctx, method, b.url+"/"+name, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	if sum != "" {
+		raw, err := hex.DecodeString(sum)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(raw))
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 && !(method == http.MethodDelete && resp.StatusCode == http.StatusNotFound) {
+		return fmt.Errorf("%s %s: unexpected status %s", method, req.URL.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+func (b httpBackend) Put(ctx context.Context, name string, r io.Reader, size int64, sum string) error {
+	err := b.do(ctx, http.MethodPut, name, r, size, sum)
+	if err != nil {
+		return err
+	}
+
+	data := checksumFile(name, sum)
+	return b.do(ctx, http.MethodPut, checksumName(name), strings.NewReader(data), int64(len(data)), "")
+}
+
+func (b httpBackend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url+"/"+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: unexpected status %s", req.URL.Redacted(), resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (b httpBackend) List(ctx context.Context) ([]string, error) {
+	return nil, ErrListNotSupported
+}
+
+func (b httpBackend) Remove(ctx context.Context, name string) error {
+	err := b.do(ctx, http.MethodDelete, name, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	return b.do(ctx, http.MethodDelete, checksumName(name), nil, 0, "")
+}
@@ -0,0 +1,107 @@
+package etcdbackup
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHTTPBackend(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	files := make(map[string]string)
+	digests := make(map[string]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			files[r.URL.Path] = string(data)
+			digests[r.URL.Path] = r.Header.Get("Digest")
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			data, ok := files[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(data))
+		case http.MethodDelete:
+			if _, ok := files[r.URL.Path]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(files, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	b := NewHTTPBackend(ts.URL + "/backups/")
+
+	data := "snapshot"
+	sum := sha256Hex(data)
+	name := "etcd-20211001-000000.backup"
+	err := b.Put(ctx, name, strings.NewReader(data), int64(len(data)), sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files["/backups/"+name] != data {
+		t.Errorf("unexpected data: %s", files["/backups/"+name])
+	}
+	raw, _ := hex.DecodeString(sum)
+	if digests["/backups/"+name] != "SHA-256="+base64.StdEncoding.EncodeToString(raw) {
+		t.Errorf("unexpected digest: %s", digests["/backups/"+name])
+	}
+	if files["/backups/"+name+".sha256"] != sum+"  "+name+"\n" {
+		t.Errorf("unexpected checksum file: %s", files["/backups/"+name+".sha256"])
+	}
+
+	fetched := new(bytes.Buffer)
+	_, err = Fetch(ctx, b, name, fetched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched.String() != data {
+		t.Errorf("unexpected snapshot: %s", fetched)
+	}
+	_, err = Fetch(ctx, b, "etcd-20211001-010000.backup", io.Discard)
+	if err == nil {
+		t.Error("missing snapshot should be an error")
+	}
+
+	_, err = b.List(ctx)
+	if err != ErrListNotSupported {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = RemoveOld(ctx, b, 0)
+	if err != nil {
+		t.Errorf("RemoveOld should do nothing: %v", err)
+	}
+
+	err = b.Remove(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("files remain: %v", files)
+	}
+	err = b.Remove(ctx, name)
+	if err != nil {
+		t.Errorf("removing missing files should succeed: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package etcdbackup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type localBackend struct {
+	dir string
+}
+
+// NewLocalBackend returns a Backend that keeps snapshots in dir.
+func NewLocalBackend(dir string) Backend {
+	return localBackend{dir: dir}
+}
+
+func (b localBackend) Put(ctx context.Context, name string, r io.Reader, size int64, sum string) error {
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(b.dir, ".tmp-"+name)
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	defer func() {
+		f.Close()
+		os.Remove(tmpName)
+	}()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("size mismatch: expected %d, written %d", size, n)
+	}
+	if written := hex.EncodeToString(h.Sum(nil)); written != sum {
+		return fmt.Errorf("checksum mismatch: expected %s, written %s", sum, written)
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	err = writeFileSync(filepath.Join(b.dir, checksumName(name)), []byte(checksumFile(name, sum)))
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, filepath.Join(b.dir, name)); err != nil {
+		return err
+	}
+	return syncDir(b.dir)
+}
+
+func (b localBackend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	// name must not point outside of b.dir.
+	if err := ValidateName(strings.TrimSuffix(name, checksumSuffix)); err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(b.dir, name))
+}
+
+func (b localBackend) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if isSnapshotName(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (b localBackend) Remove(ctx context.Context, name string) error {
+	err := os.Remove(filepath.Join(b.dir, name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Remove(filepath.Join(b.dir, checksumName(name)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return syncDir(b.dir)
+}
+
+func writeFileSync(name string, data []byte) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,117 @@
+package etcdbackup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sha256Hex(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+func TestLocalBackend(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "backups")
+	b := NewLocalBackend(dir)
+
+	names, err := b.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("unexpected names: %v", names)
+	}
+
+	base := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		data := "snapshot" + string(rune('0'+i))
+		name := SnapshotName(base.Add(time.Duration(i) * time.Hour))
+		err := b.Put(ctx, name, strings.NewReader(data), int64(len(data)), sha256Hex(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = b.Put(ctx, SnapshotName(base.Add(time.Minute)), strings.NewReader("broken"), 6, sha256Hex("snapshot"))
+	if err == nil {
+		t.Error("checksum mismatch should be detected")
+	}
+
+	names, err = b.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"etcd-20211001-000000.backup",
+		"etcd-20211001-010000.backup",
+		"etcd-20211001-020000.backup",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("unexpected names: %v", names)
+	}
+
+	sum, err := os.ReadFile(filepath.Join(dir, "etcd-20211001-010000.backup.sha256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sum) != sha256Hex("snapshot1")+"  etcd-20211001-010000.backup\n" {
+		t.Errorf("unexpected checksum file: %s", sum)
+	}
+
+	data := new(bytes.Buffer)
+	_, err = Fetch(ctx, b, "etcd-20211001-010000.backup", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.String() != "snapshot1" {
+		t.Errorf("unexpected snapshot: %s", data)
+	}
+	err = os.WriteFile(filepath.Join(dir, "etcd-20211001-020000.backup"), []byte("broken"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Fetch(ctx, b, "etcd-20211001-020000.backup", io.Discard)
+	if err == nil {
+		t.Error("corrupted snapshot should be detected")
+	}
+
+	_, err = b.Get(ctx, "../backups/etcd-20211001-010000.backup")
+	if err == nil {
+		t.Error("names outside of the directory should be rejected")
+	}
+
+	err = RemoveOld(ctx, b, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err = b.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, expected[1:]) {
+		t.Errorf("unexpected names after rotation: %v", names)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "etcd-20211001-000000.backup.sha256")); !os.IsNotExist(err) {
+		t.Errorf("checksum file should be removed: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("unexpected files remain: %v", entries)
+	}
+}
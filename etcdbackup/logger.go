@@ -0,0 +1,61 @@
+package etcdbackup
+
+import (
+	"github.com/cybozu-go/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger returns a zap logger that writes to the default logger of
+// github.com/cybozu-go/log.  Pass this to etcd libraries that require
+// a zap logger so that their logs appear in the same format as CKE's.
+func Logger() *zap.Logger {
+	return zap.New(logCore{})
+}
+
+type logCore struct {
+	fields map[string]interface{}
+}
+
+func (c logCore) merge(fs []zapcore.Field) map[string]interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	for k, v := range c.fields {
+		enc.Fields[k] = v
+	}
+	for _, f := range fs {
+		f.AddTo(enc)
+	}
+	return enc.Fields
+}
+
+// Enabled implements zapcore.LevelEnabler.
+// The threshold is checked by the default logger.
+func (c logCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c logCore) With(fs []zapcore.Field) zapcore.Core {
+	return logCore{fields: c.merge(fs)}
+}
+
+func (c logCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(e, c)
+}
+
+func (c logCore) Write(e zapcore.Entry, fs []zapcore.Field) error {
+	fields := c.merge(fs)
+	switch {
+	case e.Level >= zapcore.ErrorLevel:
+		return log.Error(e.Message, fields)
+	case e.Level == zapcore.WarnLevel:
+		return log.Warn(e.Message, fields)
+	case e.Level == zapcore.InfoLevel:
+		return log.Info(e.Message, fields)
+	default:
+		return log.Debug(e.Message, fields)
+	}
+}
+
+func (c logCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,28 @@
+package etcdbackup
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cybozu-go/log"
+	"go.uber.org/zap"
+)
+
+func TestLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := log.DefaultLogger()
+	logger.SetOutput(buf)
+	logger.SetFormatter(log.Logfmt{})
+	defer logger.SetOutput(os.Stderr)
+
+	Logger().With(zap.String("path", "/tmp/snapshot.db")).Warn("snapshot is old", zap.Int("revision", 3))
+
+	out := buf.String()
+	for _, s := range []string{"severity=warning", `"snapshot is old"`, `path="/tmp/snapshot.db"`, "revision=3"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%q is not logged: %s", s, out)
+		}
+	}
+}
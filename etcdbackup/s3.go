@@ -0,0 +1,299 @@
+package etcdbackup
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cybozu-go/cke"
+)
+
+const (
+	amzDateFormat      = "20060102T150405Z"
+	emptyPayloadSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Credentials is a set of credentials to access S3-compatible object storage.
+type S3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// S3CredentialsFromEnv reads S3Credentials from the standard environment
+// variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN.
+func S3CredentialsFromEnv() S3Credentials {
+	return S3Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+type s3Backend struct {
+	endpoint *url.URL
+	region   string
+	bucket   string
+	prefix   string
+	cred     S3Credentials
+	client   *http.Client
+}
+
+// NewS3Backend returns a Backend that keeps snapshots in S3-compatible object storage.
+// Objects are accessed in the path-style, i.e. ENDPOINT/BUCKET/PREFIX/NAME, and
+// requests are signed with AWS Signature Version 4.
+func NewS3Backend(cfg *cke.EtcdBackupS3, cred S3Credentials) (Backend, error) {
+	if cred.AccessKeyID == "" || cred.SecretAccessKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set for S3 backups")
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	region := cfg.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	return s3Backend{
+		endpoint: u,
+		region:   region,
+		bucket:   cfg.Bucket,
+		prefix:   strings.Trim(cfg.Prefix, "/"),
+		cred:     cred,
+		client:   &http.Client{},
+	}, nil
+}
+
+func (b s3Backend) key(name string) string {
+	if b.prefix == "" {
+		return name
+	}
+	return b.prefix + "/" + name
+}
+
+func (b s3Backend) request(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	p := path.Join("/", b.endpoint.Path, b.bucket)
+	if key != "" {
+		p += "/" + key
+	}
+	u := *b.endpoint
+	u.Path = p
+	u.RawPath = uriEncode(p, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	signV4(req, b.cred, b.region, payloadHash, time.Now())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s %s: unexpected status %s: %s", method, u.Redacted(), resp.Status, string(msg))
+	}
+	return resp, nil
+}
+
+func (b s3Backend) put(ctx context.Context, key string, r io.Reader, size int64, sum string) error {
+	resp, err := b.request(ctx, http.MethodPut, key, nil, r, size, sum)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Put uploads a snapshot.  The checksum is sent as x-amz-content-sha256,
+// which S3 verifies against the uploaded data.
+func (b s3Backend) Put(ctx context.Context, name string, r io.Reader, size int64, sum string) error {
+	err := b.put(ctx, b.key(name), r, size, sum)
+	if err != nil {
+		return err
+	}
+
+	data := checksumFile(name, sum)
+	h := sha256.Sum256([]byte(data))
+	return b.put(ctx, b.key(checksumName(name)), strings.NewReader(data), int64(len(data)), hex.EncodeToString(h[:]))
+}
+
+func (b s3Backend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	resp, err := b.request(ctx, http.MethodGet, b.key(name), nil, nil, 0, emptyPayloadSHA256)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (b s3Backend) List(ctx context.Context) ([]string, error) {
+	prefix := b.key("")
+	var names []string
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := b.request(ctx, http.MethodGet, "", query, nil, 0, emptyPayloadSHA256)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			name := strings.TrimPrefix(c.Key, prefix)
+			if isSnapshotName(name) {
+				names = append(names, name)
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (b s3Backend) Remove(ctx context.Context, name string) error {
+	for _, key := range []string{b.key(name), b.key(checksumName(name))} {
+		resp, err := b.request(ctx, http.MethodDelete, key, nil, nil, 0, emptyPayloadSHA256)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// signV4 signs req with AWS Signature Version 4.
+// All headers in req.Header and Host are signed.
+func signV4(req *http.Request, cred S3Credentials, region, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format(amzDateFormat)
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if cred.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", cred.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	crh := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crh[:])
+
+	key := hmacSHA256([]byte("AWS4"+cred.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cred.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// uriEncode encodes s as specified for AWS Signature Version 4.
+func uriEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,152 @@
+package cke
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/cybozu-go/log"
+	"golang.org/x/crypto/ssh"
+)
+
+// Policies to verify SSH host keys of nodes.
+const (
+	// SSHHostKeyPolicyTOFU pins the host key at the first connection (trust on first use).
+	// This is the default.
+	SSHHostKeyPolicyTOFU = "tofu"
+	// SSHHostKeyPolicyStrict allows only connections to nodes whose host keys are registered beforehand.
+	SSHHostKeyPolicyStrict = "strict"
+)
+
+// HostKeyError is returned when the host key of a node cannot be trusted.
+type HostKeyError struct {
+	Address string
+	// Expected is the fingerprint of the pinned key.  Empty if no key is pinned.
+	Expected string
+	// Actual is the fingerprint of the key presented by the node.
+	Actual string
+}
+
+func (e *HostKeyError) Error() string {
+	if len(e.Expected) == 0 {
+		return fmt.Sprintf("ssh host key of %s is not registered: %s", e.Address, e.Actual)
+	}
+	return fmt.Sprintf("ssh host key of %s does not match: expected %s, got %s", e.Address, e.Expected, e.Actual)
+}
+
+// HostKeyVerifier verifies SSH host keys of nodes with the keys pinned in etcd.
+type HostKeyVerifier struct {
+	storage Storage
+	policy  string
+	pinned  map[string]ssh.PublicKey
+
+	// readOnly is true if new host keys must not be pinned.
+	readOnly bool
+}
+
+// NewHostKeyVerifier loads the pinned host keys and returns HostKeyVerifier.
+func NewHostKeyVerifier(ctx context.Context, s Storage, policy string) (*HostKeyVerifier, error) {
+	keys, err := s.GetSSHHostKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pinned := make(map[string]ssh.PublicKey, len(keys))
+	for addr, data := range keys {
+		key, err := ParseSSHHostKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ssh host key for %s: %w", addr, err)
+		}
+		pinned[addr] = key
+	}
+	return &HostKeyVerifier{storage: s, policy: policy, pinned: pinned}, nil
+}
+
+// NewReadOnlyHostKeyVerifier returns HostKeyVerifier that never pins host keys.
+// Host keys of nodes that are not pinned yet are rejected regardless of the policy.
+func NewReadOnlyHostKeyVerifier(ctx context.Context, s Storage, policy string) (*HostKeyVerifier, error) {
+	v, err := NewHostKeyVerifier(ctx, s, policy)
+	if err != nil {
+		return nil, err
+	}
+	v.readOnly = true
+	return v, nil
+}
+
+// ParseSSHHostKey parses a host key in the authorized_keys format.
+func ParseSSHHostKey(data string) (ssh.PublicKey, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(data))
+	return key, err
+}
+
+// MarshalSSHHostKey returns the host key in the authorized_keys format.
+func MarshalSSHHostKey(key ssh.PublicKey) string {
+	return string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(key)))
+}
+
+// Algorithms returns the host key algorithms acceptable for the node.
+// If the host key of the node is pinned, only its algorithm is acceptable.
+func (v *HostKeyVerifier) Algorithms(node *Node) []string {
+	key, ok := v.pinned[node.Address]
+	if !ok {
+		return nil
+	}
+	return []string{key.Type()}
+}
+
+// Callback returns ssh.HostKeyCallback to verify the host key of the node.
+func (v *HostKeyVerifier) Callback(ctx context.Context, node *Node) ssh.HostKeyCallback {
+	return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		if pinned, ok := v.pinned[node.Address]; ok {
+			return checkHostKey(node.Address, pinned, key)
+		}
+
+		if v.policy == SSHHostKeyPolicyStrict || v.readOnly {
+			return &HostKeyError{Address: node.Address, Actual: ssh.FingerprintSHA256(key)}
+		}
+
+		ok, err := v.storage.PutSSHHostKeyIfAbsent(ctx, node.Address, MarshalSSHHostKey(key))
+		if err != nil {
+			return err
+		}
+		if ok {
+			log.Info("pinned ssh host key", map[string]interface{}{
+				"address":     node.Address,
+				"fingerprint": ssh.FingerprintSHA256(key),
+			})
+			return nil
+		}
+
+		// another CKE instance has pinned the key concurrently.
+		data, err := v.storage.GetSSHHostKey(ctx, node.Address)
+		if err != nil {
+			return err
+		}
+		pinned, err := ParseSSHHostKey(data)
+		if err != nil {
+			return err
+		}
+		return checkHostKey(node.Address, pinned, key)
+	}
+}
+
+// accepts returns true if a connection to the node authenticated with
+// the host key is still acceptable.
+func (v *HostKeyVerifier) accepts(node *Node, key ssh.PublicKey) bool {
+	if pinned, ok := v.pinned[node.Address]; ok {
+		return bytes.Equal(pinned.Marshal(), key.Marshal())
+	}
+	return v.policy != SSHHostKeyPolicyStrict && !v.readOnly
+}
+
+func checkHostKey(address string, pinned, key ssh.PublicKey) error {
+	if bytes.Equal(pinned.Marshal(), key.Marshal()) {
+		return nil
+	}
+	return &HostKeyError{
+		Address:  address,
+		Expected: ssh.FingerprintSHA256(pinned),
+		Actual:   ssh.FingerprintSHA256(key),
+	}
+}
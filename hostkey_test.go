@@ -0,0 +1,107 @@
+package cke
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func testHostKeyParse(t *testing.T) {
+	key := newTestHostKey(t)
+
+	data := MarshalSSHHostKey(key) + " root@node1\n"
+	parsed, err := ParseSSHHostKey(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if MarshalSSHHostKey(parsed) != MarshalSSHHostKey(key) {
+		t.Errorf("unexpected key: %s", MarshalSSHHostKey(parsed))
+	}
+
+	_, err = ParseSSHHostKey("not a key")
+	if err == nil {
+		t.Error("invalid key should not be parsed")
+	}
+}
+
+func testHostKeyCallback(t *testing.T) {
+	ctx := context.Background()
+	pinnedKey := newTestHostKey(t)
+	otherKey := newTestHostKey(t)
+	node1 := &Node{Address: "10.0.0.1"}
+	node2 := &Node{Address: "10.0.0.2"}
+
+	v := &HostKeyVerifier{
+		policy: SSHHostKeyPolicyStrict,
+		pinned: map[string]ssh.PublicKey{node1.Address: pinnedKey},
+	}
+
+	algos := v.Algorithms(node1)
+	if len(algos) != 1 || algos[0] != ssh.KeyAlgoED25519 {
+		t.Errorf("unexpected algorithms: %v", algos)
+	}
+	if algos := v.Algorithms(node2); algos != nil {
+		t.Errorf("unexpected algorithms for unpinned node: %v", algos)
+	}
+
+	err := v.Callback(ctx, node1)("", nil, pinnedKey)
+	if err != nil {
+		t.Error("pinned key should be accepted:", err)
+	}
+
+	err = v.Callback(ctx, node1)("", nil, otherKey)
+	var hkErr *HostKeyError
+	if !errors.As(err, &hkErr) {
+		t.Fatal("mismatched key should be rejected:", err)
+	}
+	if hkErr.Expected != ssh.FingerprintSHA256(pinnedKey) || hkErr.Actual != ssh.FingerprintSHA256(otherKey) {
+		t.Errorf("unexpected error: %v", hkErr)
+	}
+
+	err = v.Callback(ctx, node2)("", nil, otherKey)
+	if !errors.As(err, &hkErr) {
+		t.Fatal("unregistered key should be rejected in strict policy:", err)
+	}
+	if hkErr.Expected != "" {
+		t.Errorf("unexpected error: %v", hkErr)
+	}
+
+	// read-only verifiers never pin keys; storage is not set here.
+	ro := &HostKeyVerifier{
+		policy:   SSHHostKeyPolicyTOFU,
+		pinned:   map[string]ssh.PublicKey{node1.Address: pinnedKey},
+		readOnly: true,
+	}
+	err = ro.Callback(ctx, node1)("", nil, pinnedKey)
+	if err != nil {
+		t.Error("pinned key should be accepted:", err)
+	}
+	err = ro.Callback(ctx, node2)("", nil, otherKey)
+	if !errors.As(err, &hkErr) {
+		t.Fatal("unregistered key should be rejected in read-only mode:", err)
+	}
+	if ro.accepts(node2, otherKey) {
+		t.Error("unregistered key should not be accepted in read-only mode")
+	}
+}
+
+func TestHostKey(t *testing.T) {
+	t.Run("Parse", testHostKeyParse)
+	t.Run("Callback", testHostKeyCallback)
+}
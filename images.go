@@ -1,5 +1,11 @@
 package cke
 
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
 // Image is the type of container images.
 type Image string
 
@@ -8,6 +14,30 @@ func (i Image) Name() string {
 	return string(i)
 }
 
+// Version returns the major and minor versions in the tag of the image.
+// The tag must begin with MAJOR.MINOR as in "1.22.5.1".
+func (i Image) Version() (major, minor int, err error) {
+	name := string(i)
+	idx := strings.LastIndex(name, ":")
+	if idx < 0 || strings.Contains(name[idx:], "/") {
+		return 0, 0, errors.New("image has no tag: " + name)
+	}
+
+	fields := strings.SplitN(strings.TrimPrefix(name[idx+1:], "v"), ".", 3)
+	if len(fields) < 2 {
+		return 0, 0, errors.New("image tag is not a version: " + name)
+	}
+	major, err = strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, errors.New("image tag is not a version: " + name)
+	}
+	minor, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, errors.New("image tag is not a version: " + name)
+	}
+	return major, minor, nil
+}
+
 // Container image definitions
 const (
 	EtcdImage       = Image("quay.io/cybozu/etcd:3.5.1.1")
@@ -98,7 +98,7 @@ func (k *KubeHTTP) CACert() string {
 // GetCert retrieves cached TLS client certificate to access kube-apiserver.
 func (k *KubeHTTP) GetCert(ctx context.Context, inf Infrastructure) (cert, key []byte, err error) {
 	issue := func() (cert, key []byte, err error) {
-		c, k, e := KubernetesCA{}.IssueUserCert(ctx, inf, RoleAdmin, AdminGroup, "25h")
+		c, k, e := KubernetesCA{}.IssueUserCert(ctx, inf, RoleAdmin, AdminGroup, 25*time.Hour)
 		if e != nil {
 			return nil, nil, e
 		}
@@ -130,8 +130,12 @@ type Infrastructure interface {
 
 	// Agent returns the agent corresponding to addr and returns nil if addr is not connected.
 	Agent(addr string) Agent
+	// AgentError returns the reason why addr is not connected, or nil.
+	AgentError(addr string) error
 	Engine(addr string) ContainerEngine
 	Vault() (*vault.Client, error)
+	// Backend returns the backend to issue certificates and keep secrets.
+	Backend() (Backend, error)
 	Storage() Storage
 
 	NewEtcdClient(ctx context.Context, endpoints []string) (*clientv3.Client, error)
@@ -144,8 +148,13 @@ type Infrastructure interface {
 var kubeHTTP KubeHTTP
 
 type ckeInfrastructure struct {
-	agents  map[string]Agent
-	storage Storage
+	agents      map[string]Agent
+	agentErrors map[string]error
+	engine      string
+	storage     Storage
+	vault       func() (*vault.Client, error)
+	backend     Backend
+	pooled      bool // true if agents are owned by AgentPool
 
 	etcdOnce sync.Once
 	etcdErr  error
@@ -179,27 +188,72 @@ func (i *ckeInfrastructure) init(ctx context.Context) error {
 	return i.initErr
 }
 
-// NewInfrastructure creates a new Infrastructure instance
+// NewInfrastructure creates a new Infrastructure instance.
+// SSH connections to nodes are closed by Close().
 func NewInfrastructure(ctx context.Context, c *Cluster, s Storage) (Infrastructure, error) {
-	vc, err := getVaultClient()
+	return newInfrastructure(ctx, c, s, nil, getVaultClient, false)
+}
+
+// NewReadOnlyInfrastructure creates a new Infrastructure instance that
+// does not pin SSH host keys of nodes.  Nodes whose host keys are not
+// pinned yet are treated as unreachable.  This is for planning operations.
+//
+// vc is called only if the backend is Vault.  If vc is nil, the client
+// connected by ConnectVault is used.
+func NewReadOnlyInfrastructure(ctx context.Context, c *Cluster, s Storage, vc func() (*vault.Client, error)) (Infrastructure, error) {
+	if vc == nil {
+		vc = getVaultClient
+	}
+	return newInfrastructure(ctx, c, s, nil, vc, true)
+}
+
+// NewPooledInfrastructure creates a new Infrastructure instance that
+// reuses SSH connections kept in pool.  Close() does not close the
+// connections; they are kept in pool for the next instance.
+// Connections to nodes that are no longer in c are closed.
+func NewPooledInfrastructure(ctx context.Context, c *Cluster, s Storage, pool *AgentPool) (Infrastructure, error) {
+	inf, err := newInfrastructure(ctx, c, s, pool, getVaultClient, false)
+	if err != nil {
+		return nil, err
+	}
+	pool.retain(c.Nodes)
+	return inf, nil
+}
+
+func newInfrastructure(ctx context.Context, c *Cluster, s Storage, pool *AgentPool, vc func() (*vault.Client, error), readOnly bool) (Infrastructure, error) {
+	b, err := OpenBackend(ctx, s, vc)
 	if err != nil {
 		return nil, err
 	}
 
-	secret, err := vc.Logical().Read(SSHSecret)
+	privkeys, err := b.ReadSecret(ctx, SecretSSH)
+	if err != nil {
+		return nil, err
+	}
+
+	var hostKeys *HostKeyVerifier
+	if readOnly {
+		hostKeys, err = NewReadOnlyHostKeyVerifier(ctx, s, c.SSHHostKeyPolicy)
+	} else {
+		hostKeys, err = NewHostKeyVerifier(ctx, s, c.SSHHostKeyPolicy)
+	}
 	if err != nil {
 		return nil, err
 	}
-	privkeys := secret.Data
 
 	agents := make(map[string]Agent)
+	agentErrors := make(map[string]error)
 	defer func() {
+		if pool != nil {
+			return
+		}
 		for _, a := range agents {
 			a.Close()
 		}
 	}()
 
 	mu := new(sync.Mutex)
+	sem := make(chan struct{}, DefaultSSHConnectConcurrency)
 
 	env := well.NewEnvironment(ctx)
 	for _, n := range c.Nodes {
@@ -207,16 +261,32 @@ func NewInfrastructure(ctx context.Context, c *Cluster, s Storage) (Infrastructu
 		env.Go(func(ctx context.Context) error {
 			mykey, ok := privkeys[node.Address]
 			if !ok {
-				mykey = privkeys[""]
+				mykey, ok = privkeys[""]
 			}
-			if mykey == nil {
+			if !ok {
 				return errors.New("no ssh private key for " + node.Address)
 			}
-			a, err := SSHAgent(node, mykey.(string))
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			var a Agent
+			var err error
+			if pool != nil {
+				a, err = pool.get(ctx, node, mykey, c.Options.ContainerEngine, hostKeys)
+			} else {
+				a, err = SSHAgent(ctx, node, mykey, c.Options.ContainerEngine, hostKeys)
+			}
+			<-sem
 			if err != nil {
 				log.Warn("failed to create SSHAgent for "+node.Address, map[string]interface{}{
 					log.FnError: err,
 				})
+				mu.Lock()
+				agentErrors[node.Address] = err
+				mu.Unlock()
 				// lint:ignore nilerr  Just skip adding my agent to agents.
 				return nil
 			}
@@ -235,7 +305,7 @@ func NewInfrastructure(ctx context.Context, c *Cluster, s Storage) (Infrastructu
 	}
 
 	// This assignment of the `agent` must be placed last.
-	inf := &ckeInfrastructure{agents: agents, storage: s}
+	inf := &ckeInfrastructure{agents: agents, agentErrors: agentErrors, engine: c.Options.ContainerEngine, storage: s, vault: vc, backend: b, pooled: pool != nil}
 	agents = nil
 	return inf, nil
 }
@@ -244,12 +314,20 @@ func (i *ckeInfrastructure) Agent(addr string) Agent {
 	return i.agents[addr]
 }
 
+func (i *ckeInfrastructure) AgentError(addr string) error {
+	return i.agentErrors[addr]
+}
+
 func (i *ckeInfrastructure) Engine(addr string) ContainerEngine {
-	return Docker(i.agents[addr])
+	return NewContainerEngine(i.engine, i.agents[addr])
 }
 
 func (i *ckeInfrastructure) Vault() (*vault.Client, error) {
-	return getVaultClient()
+	return i.vault()
+}
+
+func (i *ckeInfrastructure) Backend() (Backend, error) {
+	return i.backend, nil
 }
 
 func (i *ckeInfrastructure) Storage() Storage {
@@ -257,6 +335,10 @@ func (i *ckeInfrastructure) Storage() Storage {
 }
 
 func (i *ckeInfrastructure) Close() {
+	if i.pooled {
+		i.agents = nil
+		return
+	}
 	for _, a := range i.agents {
 		a.Close()
 	}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
@@ -32,23 +31,17 @@ var dialer = &net.Dialer{
 	Timeout: 5 * time.Second,
 }
 
-func isRunning(name string) (bool, string, error) {
-	stdout, err := exec.Command("docker", "ps", "--format={{.Names}} {{.Image}}").Output()
+func isRunning(ce cke.ContainerEngine, name string) (bool, string, error) {
+	ss, err := ce.Inspect([]string{name})
 	if err != nil {
-		return false, "", fmt.Errorf("failed to run docker ps: %w", err)
+		return false, "", fmt.Errorf("failed to inspect %s: %w", name, err)
 	}
 
-	for _, line := range strings.Split(string(stdout), "\n") {
-		fields := strings.Fields(line)
-		if len(fields) != 2 {
-			continue
-		}
-		if fields[0] != name {
-			continue
-		}
-		return true, fields[1], nil
+	st := ss[name]
+	if !st.Running {
+		return false, "", nil
 	}
-	return false, "", nil
+	return true, st.Image, nil
 }
 
 func getStatus(ctx context.Context, inf cke.Infrastructure) (*status, error) {
@@ -78,7 +71,7 @@ func getStatus(ctx context.Context, inf cke.Infrastructure) (*status, error) {
 		return nil, errors.New("no kube-apiserver is available")
 	}
 
-	proxyRunning, proxyImage, err := isRunning("kube-proxy")
+	proxyRunning, proxyImage, err := isRunning(inf.Engine(localNode.Address), "kube-proxy")
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +117,7 @@ func getStatus(ctx context.Context, inf cke.Infrastructure) (*status, error) {
 
 	unboundConfigMap := nodedns.ConfigMap(clusterDNS.Spec.ClusterIP, domain, dnsServers)
 
-	unboundRunning, unboundImage, err := isRunning("cke-unbound")
+	unboundRunning, unboundImage, err := isRunning(inf.Engine(localNode.Address), unboundContainerName)
 	if err != nil {
 		return nil, err
 	}
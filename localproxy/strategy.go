@@ -22,12 +22,13 @@ func decideOps(c *cke.Cluster, currentAP string, st *status) (newAP string, ops
 	}
 
 	apURL := fmt.Sprintf("https://%s:6443", newAP)
+	img := c.Images.KubernetesImage()
 
 	if !st.proxyRunning {
-		ops = append(ops, k8s.KubeProxyBootOp(ckeNodes, c.Name, apURL, c.Options.Proxy))
+		ops = append(ops, k8s.KubeProxyBootOp(ckeNodes, c.Name, apURL, c.Options.Proxy, c.Certificates.TTL(), img))
 	} else {
-		if newAP != currentAP || st.proxyImage != cke.KubernetesImage.Name() {
-			ops = append(ops, k8s.KubeProxyRestartOp(ckeNodes, c.Name, apURL, c.Options.Proxy))
+		if newAP != currentAP || st.proxyImage != img.Name() {
+			ops = append(ops, k8s.KubeProxyRestartOp(ckeNodes, c.Name, apURL, c.Options.Proxy, 0, c.Certificates.TTL(), img))
 		}
 	}
 
@@ -54,6 +54,30 @@ func NewCollector(client *v3.Client) prometheus.Collector {
 				collectors:  []prometheus.Collector{operationPhase, operationPhaseTimestampSeconds},
 				isAvailable: isOperationPhaseAvailable,
 			},
+			"operation_retries": {
+				collectors:  []prometheus.Collector{operationsRetrying, operationsParked},
+				isAvailable: isOperationRetriesAvailable,
+			},
+			"paused": {
+				collectors:  []prometheus.Collector{paused},
+				isAvailable: isPausedAvailable,
+			},
+			"etcd_backup": {
+				collectors:  []prometheus.Collector{etcdBackupLastSuccessTimestampSeconds, etcdBackupLastSizeBytes},
+				isAvailable: isEtcdBackupAvailable,
+			},
+			"certificate_expiry": {
+				collectors:  []prometheus.Collector{certificateExpiryTimestampSeconds},
+				isAvailable: isCertificateExpiryAvailable,
+			},
+			"status_collection": {
+				collectors:  []prometheus.Collector{statusCollectionDurationSeconds},
+				isAvailable: isStatusCollectionAvailable,
+			},
+			"vault_token": {
+				collectors:  []prometheus.Collector{vaultTokenTTLSeconds, vaultTokenLastRenewalTimestampSeconds, vaultTokenRenewalFailuresTotal},
+				isAvailable: isVaultTokenAvailable,
+			},
 			"reboot": {
 				collectors:  []prometheus.Collector{rebootQueueEntries},
 				isAvailable: isRebootAvailable,
@@ -33,6 +33,88 @@ var operationPhaseTimestampSeconds = prometheus.NewGauge(
 	},
 )
 
+var etcdBackupLastSuccessTimestampSeconds = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "etcd_backup_last_success_timestamp_seconds",
+		Help:      "The Unix timestamp when the last successful etcd backup was taken.",
+	},
+)
+
+var etcdBackupLastSizeBytes = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "etcd_backup_last_size_bytes",
+		Help:      "The size of the last successful etcd backup in bytes.",
+	},
+)
+
+var certificateExpiryTimestampSeconds = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "certificate_expiry_timestamp_seconds",
+		Help:      "The Unix timestamp when the earliest certificate of the component expires.",
+	},
+	[]string{"node", "component"},
+)
+
+var operationsRetrying = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "operations_retrying",
+		Help:      "The number of failed operations that are waiting for retries.",
+	},
+)
+
+var operationsParked = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "operations_parked",
+		Help:      "The number of operations that are not retried due to repeated failures.",
+	},
+)
+
+var paused = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "paused",
+		Help:      "1 if operations are paused by ckecli pause.",
+	},
+)
+
+var statusCollectionDurationSeconds = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "status_collection_duration_seconds",
+		Help:      "The time taken by each phase of the last cluster status collection.",
+	},
+	[]string{"phase"},
+)
+
+var vaultTokenTTLSeconds = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "vault_token_ttl_seconds",
+		Help:      "The TTL of the Vault token granted at the last login or renewal.",
+	},
+)
+
+var vaultTokenLastRenewalTimestampSeconds = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "vault_token_last_renewal_timestamp_seconds",
+		Help:      "The Unix timestamp when the Vault token was last obtained or renewed.",
+	},
+)
+
+var vaultTokenRenewalFailuresTotal = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vault_token_renewal_failures_total",
+		Help:      "The number of failures to renew the Vault token or to login again.",
+	},
+)
+
 var rebootQueueEntries = prometheus.NewGauge(
 	prometheus.GaugeOpts{
 		Namespace: namespace,
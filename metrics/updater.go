@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/cybozu-go/cke"
@@ -39,6 +40,111 @@ func isOperationPhaseAvailable(_ context.Context, _ storage) (bool, error) {
 	return isLeader, nil
 }
 
+// UpdateOperationRetries updates "operations_retrying" and "operations_parked".
+func UpdateOperationRetries(retries map[string]*cke.OperationRetry) {
+	var retrying, parked int
+	for _, r := range retries {
+		switch {
+		case r.Parked:
+			parked++
+		case r.Attempts > 0:
+			retrying++
+		}
+	}
+	operationsRetrying.Set(float64(retrying))
+	operationsParked.Set(float64(parked))
+}
+
+func isOperationRetriesAvailable(_ context.Context, _ storage) (bool, error) {
+	return isLeader, nil
+}
+
+// UpdatePaused updates "paused".
+func UpdatePaused(isPaused bool) {
+	if isPaused {
+		paused.Set(1)
+	} else {
+		paused.Set(0)
+	}
+}
+
+func isPausedAvailable(_ context.Context, _ storage) (bool, error) {
+	return isLeader, nil
+}
+
+var etcdBackupTaken bool
+
+// UpdateEtcdBackup updates "etcd_backup_last_success_timestamp_seconds" and "etcd_backup_last_size_bytes".
+func UpdateEtcdBackup(ts time.Time, size int64) {
+	etcdBackupLastSuccessTimestampSeconds.Set(float64(ts.Unix()))
+	etcdBackupLastSizeBytes.Set(float64(size))
+	etcdBackupTaken = true
+}
+
+func isEtcdBackupAvailable(_ context.Context, _ storage) (bool, error) {
+	return isLeader && etcdBackupTaken, nil
+}
+
+// UpdateCertificateExpiry updates "certificate_expiry_timestamp_seconds".
+func UpdateCertificateExpiry(statuses map[string]*cke.NodeStatus) {
+	certificateExpiryTimestampSeconds.Reset()
+	for node, st := range statuses {
+		for component, expiry := range st.CertificateExpiry {
+			certificateExpiryTimestampSeconds.WithLabelValues(node, component).Set(float64(expiry.Unix()))
+		}
+	}
+}
+
+func isCertificateExpiryAvailable(_ context.Context, _ storage) (bool, error) {
+	return isLeader, nil
+}
+
+// Phases of cluster status collection.
+const (
+	StatusPhaseConnect    = "connect"
+	StatusPhaseNodes      = "nodes"
+	StatusPhaseEtcd       = "etcd"
+	StatusPhaseKubernetes = "kubernetes"
+)
+
+// UpdateStatusCollectionDuration updates "status_collection_duration_seconds" for the phase.
+func UpdateStatusCollectionDuration(phase string, d time.Duration) {
+	statusCollectionDurationSeconds.WithLabelValues(phase).Set(d.Seconds())
+}
+
+func isStatusCollectionAvailable(_ context.Context, _ storage) (bool, error) {
+	return isLeader, nil
+}
+
+var (
+	vaultTokenMu       sync.Mutex
+	vaultConnected     bool
+	vaultTokenFailures int
+)
+
+// UpdateVaultToken updates "vault_token_ttl_seconds", "vault_token_last_renewal_timestamp_seconds",
+// and "vault_token_renewal_failures_total".
+func UpdateVaultToken(st cke.VaultTokenStatus) {
+	vaultTokenMu.Lock()
+	defer vaultTokenMu.Unlock()
+
+	vaultTokenTTLSeconds.Set(st.TTL.Seconds())
+	vaultTokenLastRenewalTimestampSeconds.Set(float64(st.LastRenewal.Unix()))
+	// RenewalFailures is reset when the process connects to Vault again.
+	if st.RenewalFailures < vaultTokenFailures {
+		vaultTokenFailures = 0
+	}
+	vaultTokenRenewalFailuresTotal.Add(float64(st.RenewalFailures - vaultTokenFailures))
+	vaultTokenFailures = st.RenewalFailures
+	vaultConnected = st.Connected
+}
+
+func isVaultTokenAvailable(_ context.Context, _ storage) (bool, error) {
+	vaultTokenMu.Lock()
+	defer vaultTokenMu.Unlock()
+	return vaultConnected, nil
+}
+
 // UpdateReboot updates "reboot_queue_entries".
 func UpdateReboot(numEntries int) {
 	rebootQueueEntries.Set(float64(numEntries))
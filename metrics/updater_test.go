@@ -71,6 +71,12 @@ func TestMetricsUpdater(t *testing.T) {
 	t.Run("UpdateLeader", testUpdateLeader)
 	t.Run("UpdateOperationPhase", testUpdateOperationPhase)
 	t.Run("UpdateReboot", testUpdateReboot)
+	t.Run("UpdateOperationRetries", testUpdateOperationRetries)
+	t.Run("UpdatePaused", testUpdatePaused)
+	t.Run("UpdateEtcdBackup", testUpdateEtcdBackup)
+	t.Run("UpdateCertificateExpiry", testUpdateCertificateExpiry)
+	t.Run("UpdateStatusCollectionDuration", testUpdateStatusCollectionDuration)
+	t.Run("UpdateVaultToken", testUpdateVaultToken)
 	t.Run("UpdateSabakanIntegration", testUpdateSabakanIntegration)
 }
 
@@ -295,6 +301,305 @@ func testUpdateReboot(t *testing.T) {
 	}
 }
 
+func testUpdateOperationRetries(t *testing.T) {
+	collector, _ := newTestCollector()
+	handler := GetHandler(collector)
+
+	UpdateLeader(true)
+	UpdateOperationRetries(map[string]*cke.OperationRetry{
+		"a": {Attempts: 1},
+		"b": {Attempts: 2},
+		"c": {Attempts: 5, Parked: true},
+		"d": {Failures: []time.Time{time.Now()}},
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	handler.ServeHTTP(w, req)
+
+	metricsFamily, err := parseMetrics(w.Result())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]float64{
+		"cke_operations_retrying": 2,
+		"cke_operations_parked":   1,
+	}
+	for name, value := range expected {
+		found := false
+		for _, mf := range metricsFamily {
+			if *mf.Name != name {
+				continue
+			}
+			for _, m := range mf.Metric {
+				found = true
+				if *m.Gauge.Value != value {
+					t.Errorf("value for %s is wrong.  expected: %f, actual: %f", name, value, *m.Gauge.Value)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("metrics %s was not found", name)
+		}
+	}
+}
+
+func testUpdatePaused(t *testing.T) {
+	collector, _ := newTestCollector()
+	handler := GetHandler(collector)
+
+	UpdateLeader(true)
+	for _, isPaused := range []bool{true, false} {
+		UpdatePaused(isPaused)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		handler.ServeHTTP(w, req)
+
+		metricsFamily, err := parseMetrics(w.Result())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := 0.0
+		if isPaused {
+			expected = 1
+		}
+		found := false
+		for _, mf := range metricsFamily {
+			if *mf.Name != "cke_paused" {
+				continue
+			}
+			for _, m := range mf.Metric {
+				found = true
+				if *m.Gauge.Value != expected {
+					t.Errorf("value for cke_paused is wrong.  expected: %f, actual: %f", expected, *m.Gauge.Value)
+				}
+			}
+		}
+		if !found {
+			t.Error("metrics cke_paused was not found")
+		}
+	}
+}
+
+func testUpdateEtcdBackup(t *testing.T) {
+	collector, _ := newTestCollector()
+	handler := GetHandler(collector)
+
+	UpdateLeader(true)
+	ts := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	UpdateEtcdBackup(ts, 12345)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	handler.ServeHTTP(w, req)
+
+	metricsFamily, err := parseMetrics(w.Result())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]float64{
+		"cke_etcd_backup_last_success_timestamp_seconds": float64(ts.Unix()),
+		"cke_etcd_backup_last_size_bytes":                12345,
+	}
+	found := make(map[string]bool)
+	for _, mf := range metricsFamily {
+		value, ok := expected[*mf.Name]
+		if !ok {
+			continue
+		}
+		for _, m := range mf.Metric {
+			found[*mf.Name] = true
+			if *m.Gauge.Value != value {
+				t.Errorf("value for %s is wrong.  expected: %f, actual: %f", *mf.Name, value, *m.Gauge.Value)
+			}
+		}
+	}
+	for name := range expected {
+		if !found[name] {
+			t.Errorf("metrics %s was not found", name)
+		}
+	}
+}
+
+func testUpdateCertificateExpiry(t *testing.T) {
+	collector, _ := newTestCollector()
+	handler := GetHandler(collector)
+
+	UpdateLeader(true)
+	ts1 := time.Date(2031, 10, 1, 0, 0, 0, 0, time.UTC)
+	ts2 := time.Date(2032, 10, 1, 0, 0, 0, 0, time.UTC)
+	UpdateCertificateExpiry(map[string]*cke.NodeStatus{
+		"10.0.0.1": {CertificateExpiry: map[string]time.Time{"etcd": ts1, "kubelet": ts2}},
+		"10.0.0.2": {CertificateExpiry: map[string]time.Time{"kubelet": ts1}},
+		"10.0.0.3": {},
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	handler.ServeHTTP(w, req)
+
+	metricsFamily, err := parseMetrics(w.Result())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []labeledValue{
+		{labels: map[string]string{"node": "10.0.0.1", "component": "etcd"}, value: float64(ts1.Unix())},
+		{labels: map[string]string{"node": "10.0.0.1", "component": "kubelet"}, value: float64(ts2.Unix())},
+		{labels: map[string]string{"node": "10.0.0.2", "component": "kubelet"}, value: float64(ts1.Unix())},
+	}
+	var actual []*dto.Metric
+	for _, mf := range metricsFamily {
+		if *mf.Name == "cke_certificate_expiry_timestamp_seconds" {
+			actual = mf.Metric
+		}
+	}
+	if len(actual) != len(expected) {
+		t.Fatalf("unexpected number of metrics: %d", len(actual))
+	}
+	for _, ev := range expected {
+		found := false
+		for _, m := range actual {
+			if !hasLabels(labelToMap(m.Label), ev.labels) {
+				continue
+			}
+			found = true
+			if *m.Gauge.Value != ev.value {
+				t.Errorf("value for %v is wrong.  expected: %f, actual: %f", ev.labels, ev.value, *m.Gauge.Value)
+			}
+		}
+		if !found {
+			t.Errorf("metrics for %v was not found", ev.labels)
+		}
+	}
+}
+
+func testUpdateStatusCollectionDuration(t *testing.T) {
+	collector, _ := newTestCollector()
+	handler := GetHandler(collector)
+
+	UpdateLeader(true)
+	UpdateStatusCollectionDuration(StatusPhaseConnect, 1500*time.Millisecond)
+	UpdateStatusCollectionDuration(StatusPhaseNodes, 3*time.Second)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	handler.ServeHTTP(w, req)
+
+	metricsFamily, err := parseMetrics(w.Result())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []labeledValue{
+		{labels: map[string]string{"phase": "connect"}, value: 1.5},
+		{labels: map[string]string{"phase": "nodes"}, value: 3},
+	}
+	var actual []*dto.Metric
+	for _, mf := range metricsFamily {
+		if *mf.Name == "cke_status_collection_duration_seconds" {
+			actual = mf.Metric
+		}
+	}
+	if len(actual) != len(expected) {
+		t.Fatalf("unexpected number of metrics: %d", len(actual))
+	}
+	for _, ev := range expected {
+		found := false
+		for _, m := range actual {
+			if !hasLabels(labelToMap(m.Label), ev.labels) {
+				continue
+			}
+			found = true
+			if *m.Gauge.Value != ev.value {
+				t.Errorf("value for %v is wrong.  expected: %f, actual: %f", ev.labels, ev.value, *m.Gauge.Value)
+			}
+		}
+		if !found {
+			t.Errorf("metrics for %v was not found", ev.labels)
+		}
+	}
+}
+
+func testUpdateVaultToken(t *testing.T) {
+	collector, _ := newTestCollector()
+	handler := GetHandler(collector)
+
+	collect := func() map[string]float64 {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		handler.ServeHTTP(w, req)
+
+		metricsFamily, err := parseMetrics(w.Result())
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := make(map[string]float64)
+		for _, mf := range metricsFamily {
+			for _, m := range mf.Metric {
+				switch {
+				case m.Gauge != nil:
+					values[*mf.Name] = *m.Gauge.Value
+				case m.Counter != nil:
+					values[*mf.Name] = *m.Counter.Value
+				}
+			}
+		}
+		return values
+	}
+
+	UpdateVaultToken(cke.VaultTokenStatus{})
+	values := collect()
+	if _, ok := values["cke_vault_token_ttl_seconds"]; ok {
+		t.Error("vault token metrics should not be available before connected")
+	}
+
+	ts := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	UpdateVaultToken(cke.VaultTokenStatus{
+		Connected:       true,
+		AuthMethod:      cke.VaultAuthAppRole,
+		TTL:             time.Hour,
+		LastRenewal:     ts,
+		RenewalFailures: 2,
+	})
+	UpdateVaultToken(cke.VaultTokenStatus{
+		Connected:       true,
+		AuthMethod:      cke.VaultAuthAppRole,
+		TTL:             time.Hour,
+		LastRenewal:     ts,
+		RenewalFailures: 3,
+	})
+	// reconnected
+	UpdateVaultToken(cke.VaultTokenStatus{
+		Connected:       true,
+		AuthMethod:      cke.VaultAuthAppRole,
+		TTL:             30 * time.Minute,
+		LastRenewal:     ts.Add(time.Hour),
+		RenewalFailures: 1,
+	})
+
+	values = collect()
+	expected := map[string]float64{
+		"cke_vault_token_ttl_seconds":                    1800,
+		"cke_vault_token_last_renewal_timestamp_seconds": float64(ts.Add(time.Hour).Unix()),
+		"cke_vault_token_renewal_failures_total":         4,
+	}
+	for name, value := range expected {
+		actual, ok := values[name]
+		if !ok {
+			t.Errorf("metrics %s was not found", name)
+			continue
+		}
+		if actual != value {
+			t.Errorf("value for %s is wrong.  expected: %f, actual: %f", name, value, actual)
+		}
+	}
+}
+
 func testUpdateSabakanIntegration(t *testing.T) {
 	testCases := []updateSabakanIntegrationTestCase{
 		{
@@ -0,0 +1,138 @@
+package op
+
+import (
+	"context"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/log"
+)
+
+type caRotationOp struct {
+	rotation *cke.CARotation
+	ops      []cke.Operator
+	targets  []string
+
+	step    int
+	current int
+}
+
+// CARotationOp returns an Operator to proceed the current step of the CA rotation.
+//
+// This first updates the CA certificate in etcd or the default issuer in the backend
+// for the step, then runs ops one by one to restart components and apply resources
+// that depend on the CA.  ops should be ordered so that servers are restarted
+// before clients.  Every step is idempotent so that it can be resumed by another
+// CKE instance.
+func CARotationOp(rotation *cke.CARotation, ops []cke.Operator) cke.Operator {
+	var targets []string
+	seen := make(map[string]bool)
+	for _, o := range ops {
+		for _, t := range o.Targets() {
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+
+	return &caRotationOp{
+		rotation: rotation,
+		ops:      ops,
+		targets:  targets,
+	}
+}
+
+func (o *caRotationOp) Name() string {
+	return "ca-rotation"
+}
+
+func (o *caRotationOp) NextCommand() cke.Commander {
+	switch o.step {
+	case 0:
+		o.step++
+		return updateCACommand{o.rotation}
+	case 1:
+		for o.current < len(o.ops) {
+			if c := o.ops[o.current].NextCommand(); c != nil {
+				return c
+			}
+			o.current++
+		}
+		o.step++
+		return finishCARotationStepCommand{o.rotation}
+	default:
+		return nil
+	}
+}
+
+func (o *caRotationOp) Targets() []string {
+	return o.targets
+}
+
+type updateCACommand struct {
+	rotation *cke.CARotation
+}
+
+func (c updateCACommand) Run(ctx context.Context, inf cke.Infrastructure, leaderKey string) error {
+	switch c.rotation.Step {
+	case cke.CARotationBundle:
+		return inf.Storage().UpdateCACertificate(ctx, leaderKey, c.rotation.CA, c.rotation.Bundle())
+	case cke.CARotationReissue:
+		b, err := inf.Backend()
+		if err != nil {
+			return err
+		}
+		return b.SetDefaultIssuer(ctx, c.rotation.CA, c.rotation.NewIssuer)
+	case cke.CARotationPrune:
+		return inf.Storage().UpdateCACertificate(ctx, leaderKey, c.rotation.CA, c.rotation.NewCertificate)
+	}
+	return nil
+}
+
+func (c updateCACommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "update-ca",
+		Target: c.rotation.CA + "/" + string(c.rotation.Step),
+	}
+}
+
+type finishCARotationStepCommand struct {
+	rotation *cke.CARotation
+}
+
+func (c finishCARotationStepCommand) Run(ctx context.Context, inf cke.Infrastructure, leaderKey string) error {
+	next := c.rotation.NextStep()
+	if next != "" {
+		r := *c.rotation
+		r.Step = next
+		return inf.Storage().UpdateCARotation(ctx, leaderKey, &r)
+	}
+
+	b, err := inf.Backend()
+	if err != nil {
+		return err
+	}
+	if c.rotation.OldIssuer != "" && c.rotation.OldIssuer != c.rotation.NewIssuer {
+		err = b.DeleteIssuer(ctx, c.rotation.CA, c.rotation.OldIssuer)
+		if err != nil {
+			return err
+		}
+	}
+	err = inf.Storage().DeleteCARotation(ctx, leaderKey)
+	if err != nil {
+		return err
+	}
+	log.Info("CA has been rotated", map[string]interface{}{
+		"ca":     c.rotation.CA,
+		"issuer": c.rotation.NewIssuer,
+	})
+	return nil
+}
+
+func (c finishCARotationStepCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "finish-ca-rotation-step",
+		Target: c.rotation.CA + "/" + string(c.rotation.Step),
+	}
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+)
+
+type copyFileCommand struct {
+	nodes []*cke.Node
+	src   string
+	dest  string
+}
+
+// CopyFileCommand returns a Commander to copy a local file src to dest on nodes.
+// Unlike FilesBuilder, the contents are streamed from src and not kept in memory.
+func CopyFileCommand(nodes []*cke.Node, src, dest string) cke.Commander {
+	return copyFileCommand{nodes, src, dest}
+}
+
+func (c copyFileCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	parentDir := filepath.Dir(c.dest)
+	binds := []cke.Mount{{
+		Source:      parentDir,
+		Destination: filepath.Join("/mnt", parentDir),
+		Label:       cke.LabelPrivate,
+	}}
+
+	env := well.NewEnvironment(ctx)
+	for _, n := range c.nodes {
+		ce := inf.Engine(n.Address)
+		env.Go(func(ctx context.Context) error {
+			pr, pw := io.Pipe()
+			go func() {
+				pw.CloseWithError(c.writeTar(pw))
+			}()
+			err := ce.RunWithReader(cke.ToolsImage, binds, "write_files", pr, "/mnt")
+			// unblock writeTar if the command exits before reading all
+			pr.Close()
+			return err
+		})
+	}
+	env.Stop()
+	return env.Wait()
+}
+
+func (c copyFileCommand) writeTar(w io.Writer) error {
+	f, err := os.Open(c.src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	hdr := &tar.Header{
+		Name: c.dest,
+		Mode: 0644,
+		Size: fi.Size(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func (c copyFileCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "copy-file",
+		Target: c.dest,
+	}
+}
@@ -34,6 +34,8 @@ const (
 	RiversContainerName = "rivers"
 	// EtcdRiversContainerName is container name of etcd-rivers
 	EtcdRiversContainerName = "etcd-rivers"
+	// KMSPluginContainerName is container name of KMS plugin
+	KMSPluginContainerName = "kms-plugin"
 
 	// RiversUpstreamPort is upstream port of rivers container
 	RiversUpstreamPort = 6443
@@ -75,6 +77,9 @@ const (
 
 	// ControllerManagerKubeConfigPath is a path for controller-manager kubeconfig
 	ControllerManagerKubeConfigPath = "/etc/kubernetes/controller-manager/kubeconfig"
+
+	// ProxyKubeConfigPath is a path for kube-proxy kubeconfig
+	ProxyKubeConfigPath = "/etc/kubernetes/proxy/kubeconfig"
 )
 
 // EtcdPKIPath returns a certificate file path for k8s.
@@ -15,16 +15,20 @@ type addMemberOp struct {
 	endpoints  []string
 	targetNode *cke.Node
 	params     cke.EtcdParams
+	certTTL    time.Duration
+	img        cke.Image
 	step       int
 	files      *common.FilesBuilder
 }
 
 // AddMemberOp returns an Operator to add member to etcd cluster.
-func AddMemberOp(cp []*cke.Node, targetNode *cke.Node, params cke.EtcdParams) cke.Operator {
+func AddMemberOp(cp []*cke.Node, targetNode *cke.Node, params cke.EtcdParams, certTTL time.Duration, img cke.Image) cke.Operator {
 	return &addMemberOp{
 		endpoints:  etcdEndpoints(cp),
 		targetNode: targetNode,
 		params:     params,
+		certTTL:    certTTL,
+		img:        img,
 		files:      common.NewFilesBuilder([]*cke.Node{targetNode}),
 	}
 }
@@ -41,7 +45,7 @@ func (o *addMemberOp) NextCommand() cke.Commander {
 	switch o.step {
 	case 0:
 		o.step++
-		return common.ImagePullCommand(nodes, cke.EtcdImage)
+		return common.ImagePullCommand(nodes, o.img)
 	case 1:
 		o.step++
 		return common.StopContainerCommand(o.targetNode, op.EtcdContainerName)
@@ -56,7 +60,7 @@ func (o *addMemberOp) NextCommand() cke.Commander {
 		return common.VolumeCreateCommand(nodes, volname)
 	case 5:
 		o.step++
-		return prepareEtcdCertificatesCommand{o.files}
+		return prepareEtcdCertificatesCommand{o.files, o.certTTL}
 	case 6:
 		o.step++
 		return o.files
@@ -66,7 +70,7 @@ func (o *addMemberOp) NextCommand() cke.Commander {
 			"--mount",
 			"type=volume,src=" + volname + ",dst=/var/lib/etcd",
 		}
-		return addMemberCommand{o.endpoints, o.targetNode, opts, extra}
+		return addMemberCommand{o.endpoints, o.targetNode, o.img, opts, extra}
 	case 8:
 		o.step++
 		return waitEtcdSyncCommand{etcdEndpoints([]*cke.Node{o.targetNode}), false}
@@ -86,6 +90,7 @@ func (o *addMemberOp) Targets() []string {
 type addMemberCommand struct {
 	endpoints []string
 	node      *cke.Node
+	img       cke.Image
 	opts      []string
 	extra     cke.ServiceParams
 }
@@ -158,7 +163,7 @@ func (c addMemberCommand) Run(ctx context.Context, inf cke.Infrastructure, _ str
 		}
 	}
 
-	return ce.RunSystem(op.EtcdContainerName, cke.EtcdImage, c.opts, BuiltInParams(c.node, initialCluster, "existing"), c.extra)
+	return ce.RunSystem(op.EtcdContainerName, c.img, c.opts, BuiltInParams(c.node, initialCluster, "existing"), c.extra)
 }
 
 func (c addMemberCommand) Command() cke.Command {
@@ -14,16 +14,20 @@ type bootOp struct {
 	endpoints []string
 	nodes     []*cke.Node
 	params    cke.EtcdParams
+	certTTL   time.Duration
+	img       cke.Image
 	step      int
 	files     *common.FilesBuilder
 }
 
 // BootOp returns an Operator to bootstrap etcd cluster.
-func BootOp(nodes []*cke.Node, params cke.EtcdParams) cke.Operator {
+func BootOp(nodes []*cke.Node, params cke.EtcdParams, certTTL time.Duration, img cke.Image) cke.Operator {
 	return &bootOp{
 		endpoints: etcdEndpoints(nodes),
 		nodes:     nodes,
 		params:    params,
+		certTTL:   certTTL,
+		img:       img,
 		files:     common.NewFilesBuilder(nodes),
 	}
 }
@@ -38,10 +42,10 @@ func (o *bootOp) NextCommand() cke.Commander {
 	switch o.step {
 	case 0:
 		o.step++
-		return common.ImagePullCommand(o.nodes, cke.EtcdImage)
+		return common.ImagePullCommand(o.nodes, o.img)
 	case 1:
 		o.step++
-		return prepareEtcdCertificatesCommand{o.files}
+		return prepareEtcdCertificatesCommand{o.files, o.certTTL}
 	case 2:
 		o.step++
 		return o.files
@@ -62,7 +66,7 @@ func (o *bootOp) NextCommand() cke.Commander {
 		for _, n := range o.nodes {
 			paramsMap[n.Address] = BuiltInParams(n, initialCluster, "new")
 		}
-		return common.RunContainerCommand(o.nodes, op.EtcdContainerName, cke.EtcdImage,
+		return common.RunContainerCommand(o.nodes, op.EtcdContainerName, o.img,
 			common.WithOpts(opts),
 			common.WithParamsMap(paramsMap),
 			common.WithExtra(o.params.ServiceParams))
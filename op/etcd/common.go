@@ -89,12 +89,13 @@ func BuiltInParams(node *cke.Node, initialCluster []string, state string) cke.Se
 }
 
 type prepareEtcdCertificatesCommand struct {
-	files *common.FilesBuilder
+	files   *common.FilesBuilder
+	certTTL time.Duration
 }
 
 func (c prepareEtcdCertificatesCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
 	f := func(ctx context.Context, n *cke.Node) (cert, key []byte, err error) {
-		c, k, e := cke.EtcdCA{}.IssueServerCert(ctx, inf, n)
+		c, k, e := cke.EtcdCA{}.IssueServerCert(ctx, inf, n, c.certTTL)
 		if e != nil {
 			return nil, nil, e
 		}
@@ -106,7 +107,7 @@ func (c prepareEtcdCertificatesCommand) Run(ctx context.Context, inf cke.Infrast
 	}
 
 	f = func(ctx context.Context, n *cke.Node) (cert, key []byte, err error) {
-		c, k, e := cke.EtcdCA{}.IssuePeerCert(ctx, inf, n)
+		c, k, e := cke.EtcdCA{}.IssuePeerCert(ctx, inf, n, c.certTTL)
 		if e != nil {
 			return nil, nil, e
 		}
@@ -1,6 +1,8 @@
 package etcd
 
 import (
+	"time"
+
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/op"
 	"github.com/cybozu-go/cke/op/common"
@@ -10,15 +12,22 @@ type etcdRestartOp struct {
 	cpNodes []*cke.Node
 	target  *cke.Node
 	params  cke.EtcdParams
+	certTTL time.Duration
+	img     cke.Image
 	step    int
+	files   *common.FilesBuilder
 }
 
 // RestartOp returns an Operator to restart an etcd member.
-func RestartOp(cpNodes []*cke.Node, target *cke.Node, params cke.EtcdParams) cke.Operator {
+// The certificates of the member are reissued.
+func RestartOp(cpNodes []*cke.Node, target *cke.Node, params cke.EtcdParams, certTTL time.Duration, img cke.Image) cke.Operator {
 	return &etcdRestartOp{
 		cpNodes: cpNodes,
 		target:  target,
 		params:  params,
+		certTTL: certTTL,
+		img:     img,
+		files:   common.NewFilesBuilder([]*cke.Node{target}),
 	}
 }
 
@@ -33,11 +42,17 @@ func (o *etcdRestartOp) NextCommand() cke.Commander {
 		return waitEtcdSyncCommand{etcdEndpoints(o.cpNodes), true}
 	case 1:
 		o.step++
-		return common.ImagePullCommand([]*cke.Node{o.target}, cke.EtcdImage)
+		return common.ImagePullCommand([]*cke.Node{o.target}, o.img)
 	case 2:
 		o.step++
-		return common.StopContainerCommand(o.target, op.EtcdContainerName)
+		return prepareEtcdCertificatesCommand{o.files, o.certTTL}
 	case 3:
+		o.step++
+		return o.files
+	case 4:
+		o.step++
+		return common.StopContainerCommand(o.target, op.EtcdContainerName)
+	case 5:
 		o.step++
 		opts := []string{
 			"--mount",
@@ -47,7 +62,7 @@ func (o *etcdRestartOp) NextCommand() cke.Commander {
 		for _, n := range o.cpNodes {
 			initialCluster = append(initialCluster, n.Address+"=https://"+n.Address+":2380")
 		}
-		return common.RunContainerCommand([]*cke.Node{o.target}, op.EtcdContainerName, cke.EtcdImage,
+		return common.RunContainerCommand([]*cke.Node{o.target}, op.EtcdContainerName, o.img,
 			common.WithOpts(opts),
 			common.WithParams(BuiltInParams(o.target, initialCluster, "new")),
 			common.WithExtra(o.params.ServiceParams))
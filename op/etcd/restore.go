@@ -0,0 +1,334 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/cke/etcdbackup"
+	"github.com/cybozu-go/cke/op"
+	"github.com/cybozu-go/cke/op/common"
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/well"
+)
+
+const (
+	restoreContainerName = "etcd-restore"
+	restoreDir           = "/var/lib/cke/etcd-restore"
+	restoreSnapshotName  = "snapshot.db"
+)
+
+type restoreOp struct {
+	endpoints []string
+	nodes     []*cke.Node
+	params    cke.EtcdParams
+	img       cke.Image
+	backup    *cke.EtcdBackup
+	req       *cke.EtcdRestoreRequest
+	step      int
+	sum       string
+}
+
+// RestoreOp returns an Operator to restore etcd cluster from a backup.
+//
+// This first fetches the snapshot and verifies it on all nodes.  Only after
+// that, it stops kube-apiserver and etcd on all nodes, wipes the etcd data,
+// restores it from the snapshot with a new cluster token, then boots etcd.
+// kube-apiserver will be started again by the usual operations.
+func RestoreOp(nodes []*cke.Node, params cke.EtcdParams, backup *cke.EtcdBackup, req *cke.EtcdRestoreRequest, img cke.Image) cke.Operator {
+	return &restoreOp{
+		endpoints: etcdEndpoints(nodes),
+		nodes:     nodes,
+		params:    params,
+		img:       img,
+		backup:    backup,
+		req:       req,
+	}
+}
+
+func (o *restoreOp) Name() string {
+	return "etcd-restore"
+}
+
+func (o *restoreOp) NextCommand() cke.Commander {
+	volname := op.EtcdVolumeName(o.params)
+	initialCluster := make([]string, len(o.nodes))
+	for i, n := range o.nodes {
+		initialCluster[i] = n.Address + "=https://" + n.Address + ":2380"
+	}
+
+	switch o.step {
+	case 0:
+		o.step++
+		return common.ImagePullCommand(o.nodes, o.img)
+	case 1:
+		o.step++
+		return fetchSnapshotCommand{o.nodes, o.backup, o.req.Name, &o.sum}
+	case 2:
+		o.step++
+		return verifySnapshotCommand{o.nodes, o.sum}
+	case 3:
+		o.step++
+		return startRestoreCommand{o.req.Name}
+	case 4:
+		o.step++
+		return common.StopContainersCommand(o.nodes, op.KubeAPIServerContainerName)
+	case 5:
+		o.step++
+		return common.StopContainersCommand(o.nodes, op.EtcdContainerName)
+	case 6:
+		o.step++
+		return common.VolumeRemoveCommand(o.nodes, volname)
+	case 7:
+		o.step++
+		return common.VolumeCreateCommand(o.nodes, volname)
+	case 8:
+		o.step++
+		opts := []string{
+			"--entrypoint=etcdutl",
+			"--mount",
+			"type=volume,src=" + volname + ",dst=/var/lib/etcd",
+		}
+		paramsMap := make(map[string]cke.ServiceParams)
+		for _, n := range o.nodes {
+			paramsMap[n.Address] = restoreParams(n, initialCluster, o.req.Token)
+		}
+		return common.RunContainerCommand(o.nodes, restoreContainerName, o.img,
+			common.WithOpts(opts),
+			common.WithParamsMap(paramsMap))
+	case 9:
+		o.step++
+		return waitRestoreCommand{o.nodes, volname}
+	case 10:
+		o.step++
+		opts := []string{
+			"--mount",
+			"type=volume,src=" + volname + ",dst=/var/lib/etcd",
+		}
+		paramsMap := make(map[string]cke.ServiceParams)
+		for _, n := range o.nodes {
+			paramsMap[n.Address] = BuiltInParams(n, initialCluster, "new")
+		}
+		return common.RunContainerCommand(o.nodes, op.EtcdContainerName, o.img,
+			common.WithOpts(opts),
+			common.WithParamsMap(paramsMap),
+			common.WithExtra(o.params.ServiceParams))
+	case 11:
+		o.step++
+		return waitEtcdSyncCommand{o.endpoints, false}
+	case 12:
+		o.step++
+		return finishRestoreCommand{o.req.Name}
+	default:
+		return nil
+	}
+}
+
+func (o *restoreOp) Targets() []string {
+	ips := make([]string, len(o.nodes))
+	for i, n := range o.nodes {
+		ips[i] = n.Address
+	}
+	return ips
+}
+
+// restoreParams returns parameters for etcdutl to restore the snapshot.
+func restoreParams(node *cke.Node, initialCluster []string, token string) cke.ServiceParams {
+	return cke.ServiceParams{
+		ExtraArguments: []string{
+			"snapshot",
+			"restore",
+			filepath.Join(restoreDir, restoreSnapshotName),
+			"--data-dir=/var/lib/etcd",
+			"--name=" + node.Address,
+			"--initial-advertise-peer-urls=https://" + node.Address + ":2380",
+			"--initial-cluster=" + strings.Join(initialCluster, ","),
+			"--initial-cluster-token=" + token,
+		},
+		ExtraBinds: []cke.Mount{
+			{
+				Source:      restoreDir,
+				Destination: restoreDir,
+				ReadOnly:    true,
+				Label:       cke.LabelPrivate,
+			},
+		},
+	}
+}
+
+type fetchSnapshotCommand struct {
+	nodes  []*cke.Node
+	backup *cke.EtcdBackup
+	name   string
+	sum    *string
+}
+
+// Run fetches the snapshot into a temporary file and copies it to the nodes.
+// The snapshot is streamed so that large snapshots do not exhaust memory.
+func (c fetchSnapshotCommand) Run(ctx context.Context, inf cke.Infrastructure, leaderKey string) error {
+	b, err := etcdbackup.NewBackend(c.backup)
+	if err != nil {
+		return err
+	}
+	path, sum, err := etcdbackup.FetchFile(ctx, b, c.name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	*c.sum = sum
+	return common.CopyFileCommand(c.nodes, path, filepath.Join(restoreDir, restoreSnapshotName)).Run(ctx, inf, leaderKey)
+}
+
+func (c fetchSnapshotCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "fetch-etcd-snapshot",
+		Target: c.name,
+	}
+}
+
+type verifySnapshotCommand struct {
+	nodes []*cke.Node
+	sum   string
+}
+
+func (c verifySnapshotCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	target := filepath.Join(restoreDir, restoreSnapshotName)
+	for _, n := range c.nodes {
+		agent := inf.Agent(n.Address)
+		if agent == nil {
+			return fmt.Errorf("unable to prepare agent for %s", n.Address)
+		}
+		stdout, stderr, err := agent.Run("sha256sum " + target)
+		if err != nil {
+			return fmt.Errorf("failed to verify the snapshot on %s: %w, stdout: %s, stderr: %s", n.Address, err, stdout, stderr)
+		}
+		fields := strings.Fields(string(stdout))
+		if len(fields) == 0 || fields[0] != c.sum {
+			return fmt.Errorf("checksum mismatch for the snapshot on %s: expected %s, actual %s", n.Address, c.sum, stdout)
+		}
+	}
+	return nil
+}
+
+func (c verifySnapshotCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "verify-etcd-snapshot",
+		Target: c.sum,
+	}
+}
+
+type startRestoreCommand struct {
+	name string
+}
+
+func (c startRestoreCommand) Run(ctx context.Context, inf cke.Infrastructure, leaderKey string) error {
+	err := inf.Storage().StartEtcdRestore(ctx, leaderKey)
+	if err == cke.ErrNotFound {
+		return errors.New("etcd restore request has been cancelled")
+	}
+	return err
+}
+
+func (c startRestoreCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "start-etcd-restore",
+		Target: c.name,
+	}
+}
+
+type waitRestoreCommand struct {
+	nodes   []*cke.Node
+	volname string
+}
+
+func (c waitRestoreCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	env := well.NewEnvironment(ctx)
+	for _, n := range c.nodes {
+		n := n
+		env.Go(func(ctx context.Context) error {
+			agent := inf.Agent(n.Address)
+			if agent == nil {
+				return fmt.Errorf("unable to prepare agent for %s", n.Address)
+			}
+			return c.wait(ctx, inf.Engine(n.Address), agent)
+		})
+	}
+	env.Stop()
+	return env.Wait()
+}
+
+func (c waitRestoreCommand) wait(ctx context.Context, ce cke.ContainerEngine, agent cke.Agent) error {
+	var st cke.ServiceStatus
+	for i := 0; ; i++ {
+		statuses, err := ce.Inspect([]string{restoreContainerName})
+		if err != nil {
+			return err
+		}
+		var ok bool
+		st, ok = statuses[restoreContainerName]
+		if !ok {
+			return errors.New("etcdutl snapshot restore container is not found")
+		}
+		if !st.Running {
+			break
+		}
+		if i >= 60 {
+			return errors.New("etcdutl snapshot restore did not finish")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	// The container is not removed automatically, so its exit status is available.
+	if st.ExitCode != 0 {
+		return fmt.Errorf("etcdutl snapshot restore failed with exit code %d", st.ExitCode)
+	}
+	if err := ce.Remove(restoreContainerName); err != nil {
+		return err
+	}
+
+	cmdline := "rm -f " + filepath.Join(restoreDir, restoreSnapshotName)
+	stdout, stderr, err := agent.Run(cmdline)
+	if err != nil {
+		return fmt.Errorf("%w, cmdline: %s, stdout: %s, stderr: %s", err, cmdline, stdout, stderr)
+	}
+	return nil
+}
+
+func (c waitRestoreCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "wait-etcd-restore",
+		Target: c.volname,
+	}
+}
+
+type finishRestoreCommand struct {
+	name string
+}
+
+func (c finishRestoreCommand) Run(ctx context.Context, inf cke.Infrastructure, leaderKey string) error {
+	err := inf.Storage().DeleteEtcdRestoreRequest(ctx, leaderKey)
+	if err != nil {
+		return err
+	}
+	log.Info("etcd has been restored", map[string]interface{}{
+		"name": c.name,
+	})
+	return nil
+}
+
+func (c finishRestoreCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "finish-etcd-restore",
+		Target: c.name,
+	}
+}
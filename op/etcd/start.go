@@ -1,24 +1,30 @@
 package etcd
 
 import (
+	"time"
+
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/op"
 	"github.com/cybozu-go/cke/op/common"
 )
 
 type etcdStartOp struct {
-	nodes  []*cke.Node
-	params cke.EtcdParams
-	step   int
-	files  *common.FilesBuilder
+	nodes   []*cke.Node
+	params  cke.EtcdParams
+	certTTL time.Duration
+	img     cke.Image
+	step    int
+	files   *common.FilesBuilder
 }
 
 // StartOp returns an Operator to start etcd containers.
-func StartOp(nodes []*cke.Node, params cke.EtcdParams) cke.Operator {
+func StartOp(nodes []*cke.Node, params cke.EtcdParams, certTTL time.Duration, img cke.Image) cke.Operator {
 	return &etcdStartOp{
-		nodes:  nodes,
-		params: params,
-		files:  common.NewFilesBuilder(nodes),
+		nodes:   nodes,
+		params:  params,
+		certTTL: certTTL,
+		img:     img,
+		files:   common.NewFilesBuilder(nodes),
 	}
 }
 
@@ -30,7 +36,7 @@ func (o *etcdStartOp) NextCommand() cke.Commander {
 	switch o.step {
 	case 0:
 		o.step++
-		return prepareEtcdCertificatesCommand{o.files}
+		return prepareEtcdCertificatesCommand{o.files, o.certTTL}
 	case 1:
 		o.step++
 		return o.files
@@ -44,7 +50,7 @@ func (o *etcdStartOp) NextCommand() cke.Commander {
 		for _, n := range o.nodes {
 			paramsMap[n.Address] = BuiltInParams(n, nil, "")
 		}
-		return common.RunContainerCommand(o.nodes, op.EtcdContainerName, cke.EtcdImage,
+		return common.RunContainerCommand(o.nodes, op.EtcdContainerName, o.img,
 			common.WithOpts(opts),
 			common.WithParamsMap(paramsMap),
 			common.WithExtra(o.params.ServiceParams))
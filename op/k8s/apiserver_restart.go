@@ -5,6 +5,7 @@ import (
 	"crypto/md5"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/op"
@@ -23,6 +24,11 @@ var (
 		// LoadBalancer-type Services are still usable.
 		"DenyServiceExternalIPs",
 	}
+
+	// apiServerRunOpts is the container engine options for kube-apiserver.
+	apiServerRunOpts = []string{
+		"--mount", "type=tmpfs,dst=/run/kubernetes",
+	}
 )
 
 type apiServerRestartOp struct {
@@ -31,20 +37,24 @@ type apiServerRestartOp struct {
 
 	serviceSubnet string
 	params        cke.APIServerParams
+	img           cke.Image
 	clusterDomain string
+	certTTL       time.Duration
 
 	step  int
 	files *common.FilesBuilder
 }
 
 // APIServerRestartOp returns an Operator to restart kube-apiserver
-func APIServerRestartOp(nodes, cps []*cke.Node, serviceSubnet string, params cke.APIServerParams, clusterDomain string) cke.Operator {
+func APIServerRestartOp(nodes, cps []*cke.Node, serviceSubnet string, params cke.APIServerParams, clusterDomain string, certTTL time.Duration, img cke.Image) cke.Operator {
 	return &apiServerRestartOp{
 		nodes:         nodes,
 		cps:           cps,
 		serviceSubnet: serviceSubnet,
 		clusterDomain: clusterDomain,
+		certTTL:       certTTL,
 		params:        params,
+		img:           img,
 		files:         common.NewFilesBuilder(nodes),
 	}
 }
@@ -57,13 +67,13 @@ func (o *apiServerRestartOp) NextCommand() cke.Commander {
 	switch o.step {
 	case 0:
 		o.step++
-		return common.ImagePullCommand(o.nodes, cke.KubernetesImage)
+		return common.ImagePullCommand(o.nodes, o.img)
 	case 1:
 		o.step++
 		return common.MakeDirsCommandWithMode(o.nodes, []string{encryptionConfigDir}, "700")
 	case 2:
 		o.step++
-		return prepareAPIServerFilesCommand{o.files, o.serviceSubnet, o.clusterDomain, o.params}
+		return prepareAPIServerFilesCommand{o.files, o.serviceSubnet, o.clusterDomain, o.params, o.certTTL}
 	case 3:
 		o.step++
 		return o.files
@@ -72,16 +82,13 @@ func (o *apiServerRestartOp) NextCommand() cke.Commander {
 		return common.StopContainersCommand(o.nodes, op.KubeAPIServerContainerName)
 	case 5:
 		o.step++
-		opts := []string{
-			"--mount", "type=tmpfs,dst=/run/kubernetes",
-		}
 		paramsMap := make(map[string]cke.ServiceParams)
 		for _, n := range o.nodes {
-			paramsMap[n.Address] = APIServerParams(n.Address, o.serviceSubnet, o.params.AuditLogEnabled, o.params.AuditLogPolicy, o.params.AuditLogPath, o.clusterDomain)
+			paramsMap[n.Address] = APIServerParams(n.Address, o.serviceSubnet, o.params.AuditLogEnabled, o.params.AuditLogPolicy, o.params.AuditLogPath, o.clusterDomain, o.params.KMS)
 		}
 		return common.RunContainerCommand(o.nodes,
-			op.KubeAPIServerContainerName, cke.KubernetesImage,
-			common.WithOpts(opts),
+			op.KubeAPIServerContainerName, o.img,
+			common.WithOpts(apiServerRunOpts),
 			common.WithParamsMap(paramsMap),
 			common.WithExtra(o.params.ServiceParams))
 	default:
@@ -97,11 +104,20 @@ func (o *apiServerRestartOp) Targets() []string {
 	return ips
 }
 
+func (o *apiServerRestartOp) Footprint() cke.Footprint {
+	// kube-apiserver depends on the KMS plugin on the same node.
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{op.KubeAPIServerContainerName, op.KMSPluginContainerName},
+	}
+}
+
 type prepareAPIServerFilesCommand struct {
 	files         *common.FilesBuilder
 	serviceSubnet string
 	clusterDomain string
 	params        cke.APIServerParams
+	certTTL       time.Duration
 }
 
 func (c prepareAPIServerFilesCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
@@ -109,7 +125,7 @@ func (c prepareAPIServerFilesCommand) Run(ctx context.Context, inf cke.Infrastru
 
 	// server (and client) certs of API server.
 	f := func(ctx context.Context, n *cke.Node) (cert, key []byte, err error) {
-		c, k, e := cke.KubernetesCA{}.IssueForAPIServer(ctx, inf, n, c.serviceSubnet, c.clusterDomain)
+		c, k, e := cke.KubernetesCA{}.IssueForAPIServer(ctx, inf, n, c.serviceSubnet, c.clusterDomain, c.certTTL)
 		if e != nil {
 			return nil, nil, e
 		}
@@ -122,7 +138,7 @@ func (c prepareAPIServerFilesCommand) Run(ctx context.Context, inf cke.Infrastru
 
 	// client certs for etcd auth.
 	f = func(ctx context.Context, n *cke.Node) (cert, key []byte, err error) {
-		c, k, e := cke.EtcdCA{}.IssueForAPIServer(ctx, inf, n)
+		c, k, e := cke.EtcdCA{}.IssueForAPIServer(ctx, inf, n, c.certTTL)
 		if e != nil {
 			return nil, nil, e
 		}
@@ -194,7 +210,7 @@ func (c prepareAPIServerFilesCommand) Run(ctx context.Context, inf cke.Infrastru
 
 	// client certs for Aggregation
 	f = func(ctx context.Context, n *cke.Node) (cert, key []byte, err error) {
-		c, k, e := cke.AggregationCA{}.IssueClientCertificate(ctx, inf)
+		c, k, e := cke.AggregationCA{}.IssueClientCertificate(ctx, inf, c.certTTL)
 		if e != nil {
 			return nil, nil, e
 		}
@@ -206,15 +222,11 @@ func (c prepareAPIServerFilesCommand) Run(ctx context.Context, inf cke.Infrastru
 	}
 
 	// EncryptionConfiguration
-	enccfg, err := getEncryptionConfiguration(ctx, inf)
+	enccfgData, err := encryptionConfigData(ctx, inf, c.params.KMS)
 	if err != nil {
 		return err
 	}
-	enccfgData, err := encodeToYAML(enccfg)
-	if err != nil {
-		return err
-	}
-	err = c.files.AddFile(ctx, encryptionConfigFile, func(ctx context.Context, node *cke.Node) ([]byte, error) {
+	err = c.files.AddFile(ctx, encryptionConfigPath(c.params.KMS), func(ctx context.Context, node *cke.Node) ([]byte, error) {
 		return enccfgData, nil
 	})
 	if err != nil {
@@ -242,7 +254,7 @@ func auditPolicyFilePath(policy string) string {
 }
 
 // APIServerParams returns parameters for API server.
-func APIServerParams(advertiseAddress, serviceSubnet string, auditLogEnabled bool, auditLogPolicy, auditLogPath string, clusterDomain string) cke.ServiceParams {
+func APIServerParams(advertiseAddress, serviceSubnet string, auditLogEnabled bool, auditLogPolicy, auditLogPath string, clusterDomain string, kms *cke.KMSParams) cke.ServiceParams {
 	args := []string{
 		"kube-apiserver",
 		"--allow-privileged",
@@ -286,7 +298,7 @@ func APIServerParams(advertiseAddress, serviceSubnet string, auditLogEnabled boo
 		"--endpoint-reconciler-type=none",
 
 		"--service-cluster-ip-range=" + serviceSubnet,
-		"--encryption-provider-config=" + encryptionConfigFile,
+		"--encryption-provider-config=" + encryptionConfigPath(kms),
 	}
 	if auditLogEnabled {
 		logPath := "-"
@@ -297,23 +309,28 @@ func APIServerParams(advertiseAddress, serviceSubnet string, auditLogEnabled boo
 		args = append(args, "--audit-policy-file="+auditPolicyFilePath(auditLogPolicy))
 	}
 
+	binds := []cke.Mount{
+		{
+			Source:      "/etc/machine-id",
+			Destination: "/etc/machine-id",
+			ReadOnly:    true,
+			Propagation: "",
+			Label:       "",
+		},
+		{
+			Source:      "/etc/kubernetes",
+			Destination: "/etc/kubernetes",
+			ReadOnly:    true,
+			Propagation: "",
+			Label:       cke.LabelShared,
+		},
+	}
+	if kms != nil {
+		binds = append(binds, kmsSocketMount(kms))
+	}
+
 	return cke.ServiceParams{
 		ExtraArguments: args,
-		ExtraBinds: []cke.Mount{
-			{
-				Source:      "/etc/machine-id",
-				Destination: "/etc/machine-id",
-				ReadOnly:    true,
-				Propagation: "",
-				Label:       "",
-			},
-			{
-				Source:      "/etc/kubernetes",
-				Destination: "/etc/kubernetes",
-				ReadOnly:    true,
-				Propagation: "",
-				Label:       cke.LabelShared,
-			},
-		},
+		ExtraBinds:     binds,
 	}
 }
@@ -98,7 +98,7 @@ func GenerateProxyConfiguration(params cke.ProxyParams, n *cke.Node) *proxyv1alp
 	}
 
 	// forced values
-	c.ClientConnection.Kubeconfig = proxyKubeconfigPath
+	c.ClientConnection.Kubeconfig = op.ProxyKubeConfigPath
 
 	return c
 }
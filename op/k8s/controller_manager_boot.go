@@ -2,6 +2,7 @@ package k8s
 
 import (
 	"context"
+	"time"
 
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/op"
@@ -15,18 +16,22 @@ type controllerManagerBootOp struct {
 	cluster       string
 	serviceSubnet string
 	params        cke.ServiceParams
+	certTTL       time.Duration
+	img           cke.Image
 
 	step  int
 	files *common.FilesBuilder
 }
 
 // ControllerManagerBootOp returns an Operator to bootstrap kube-controller-manager
-func ControllerManagerBootOp(nodes []*cke.Node, cluster string, serviceSubnet string, params cke.ServiceParams) cke.Operator {
+func ControllerManagerBootOp(nodes []*cke.Node, cluster string, serviceSubnet string, params cke.ServiceParams, certTTL time.Duration, img cke.Image) cke.Operator {
 	return &controllerManagerBootOp{
 		nodes:         nodes,
 		cluster:       cluster,
 		serviceSubnet: serviceSubnet,
 		params:        params,
+		certTTL:       certTTL,
+		img:           img,
 		files:         common.NewFilesBuilder(nodes),
 	}
 }
@@ -39,17 +44,17 @@ func (o *controllerManagerBootOp) NextCommand() cke.Commander {
 	switch o.step {
 	case 0:
 		o.step++
-		return common.ImagePullCommand(o.nodes, cke.KubernetesImage)
+		return common.ImagePullCommand(o.nodes, o.img)
 	case 1:
 		o.step++
-		return prepareControllerManagerFilesCommand{o.cluster, o.files}
+		return prepareControllerManagerFilesCommand{o.cluster, o.files, o.certTTL}
 	case 2:
 		o.step++
 		return o.files
 	case 3:
 		o.step++
 		return common.RunContainerCommand(o.nodes,
-			op.KubeControllerManagerContainerName, cke.KubernetesImage,
+			op.KubeControllerManagerContainerName, o.img,
 			common.WithParams(ControllerManagerParams(o.cluster, o.serviceSubnet)),
 			common.WithExtra(o.params))
 	default:
@@ -65,9 +70,17 @@ func (o *controllerManagerBootOp) Targets() []string {
 	return ips
 }
 
+func (o *controllerManagerBootOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{op.KubeControllerManagerContainerName},
+	}
+}
+
 type prepareControllerManagerFilesCommand struct {
 	cluster string
 	files   *common.FilesBuilder
+	certTTL time.Duration
 }
 
 func (c prepareControllerManagerFilesCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
@@ -78,7 +91,7 @@ func (c prepareControllerManagerFilesCommand) Run(ctx context.Context, inf cke.I
 		return err
 	}
 	g := func(ctx context.Context, n *cke.Node) ([]byte, error) {
-		crt, key, err := cke.KubernetesCA{}.IssueForControllerManager(ctx, inf)
+		crt, key, err := cke.KubernetesCA{}.IssueForControllerManager(ctx, inf, c.certTTL)
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,8 @@
 package k8s
 
 import (
+	"time"
+
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/op"
 	"github.com/cybozu-go/cke/op/common"
@@ -12,18 +14,24 @@ type controllerManagerRestartOp struct {
 	cluster       string
 	serviceSubnet string
 	params        cke.ServiceParams
+	certTTL       time.Duration
+	img           cke.Image
 
-	pulled   bool
-	finished bool
+	step  int
+	files *common.FilesBuilder
 }
 
-// ControllerManagerRestartOp returns an Operator to restart kube-controller-manager
-func ControllerManagerRestartOp(nodes []*cke.Node, cluster, serviceSubnet string, params cke.ServiceParams) cke.Operator {
+// ControllerManagerRestartOp returns an Operator to restart kube-controller-manager.
+// The client certificate of kube-controller-manager is reissued.
+func ControllerManagerRestartOp(nodes []*cke.Node, cluster, serviceSubnet string, params cke.ServiceParams, certTTL time.Duration, img cke.Image) cke.Operator {
 	return &controllerManagerRestartOp{
 		nodes:         nodes,
 		cluster:       cluster,
 		serviceSubnet: serviceSubnet,
 		params:        params,
+		certTTL:       certTTL,
+		img:           img,
+		files:         common.NewFilesBuilder(nodes),
 	}
 }
 
@@ -32,19 +40,25 @@ func (o *controllerManagerRestartOp) Name() string {
 }
 
 func (o *controllerManagerRestartOp) NextCommand() cke.Commander {
-	if !o.pulled {
-		o.pulled = true
-		return common.ImagePullCommand(o.nodes, cke.KubernetesImage)
-	}
-
-	if !o.finished {
-		o.finished = true
-		return common.RunContainerCommand(o.nodes, op.KubeControllerManagerContainerName, cke.KubernetesImage,
+	switch o.step {
+	case 0:
+		o.step++
+		return common.ImagePullCommand(o.nodes, o.img)
+	case 1:
+		o.step++
+		return prepareControllerManagerFilesCommand{o.cluster, o.files, o.certTTL}
+	case 2:
+		o.step++
+		return o.files
+	case 3:
+		o.step++
+		return common.RunContainerCommand(o.nodes, op.KubeControllerManagerContainerName, o.img,
 			common.WithParams(ControllerManagerParams(o.cluster, o.serviceSubnet)),
 			common.WithExtra(o.params),
 			common.WithRestart())
+	default:
+		return nil
 	}
-	return nil
 }
 
 func (o *controllerManagerRestartOp) Targets() []string {
@@ -54,3 +68,10 @@ func (o *controllerManagerRestartOp) Targets() []string {
 	}
 	return ips
 }
+
+func (o *controllerManagerRestartOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{op.KubeControllerManagerContainerName},
+	}
+}
@@ -1,61 +1,116 @@
 package k8s
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
+	"crypto/md5"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/cybozu-go/cke"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	apiserverv1 "k8s.io/apiserver/pkg/apis/config/v1"
 )
 
 const (
 	encryptionConfigDir  = "/etc/kubernetes/apiserver"
 	encryptionConfigFile = encryptionConfigDir + "/encryption.yml"
+
+	encryptionConfigKMSBasePath = encryptionConfigDir + "/encryption-kms-%x.yml"
 )
 
-func getEncryptionSecret(ctx context.Context, inf cke.Infrastructure, key string) (string, error) {
-	vc, err := inf.Vault()
+// encryptionConfigPath returns the path of the encryption configuration file.
+// When KMS is used, the path contains the hash of the KMS provider configuration
+// so that API servers are restarted when it is changed.
+func encryptionConfigPath(kms *cke.KMSParams) string {
+	if kms == nil {
+		return encryptionConfigFile
+	}
+	h := md5.New()
+	fmt.Fprintf(h, "name=%q endpoint=%q api_version=%q", kms.Name, kms.Endpoint, kms.APIVersion)
+	if kms.CacheSize != nil {
+		fmt.Fprintf(h, " cache_size=%d", *kms.CacheSize)
+	}
+	if kms.TimeoutSeconds != nil {
+		fmt.Fprintf(h, " timeout_seconds=%d", *kms.TimeoutSeconds)
+	}
+	return fmt.Sprintf(encryptionConfigKMSBasePath, h.Sum(nil))
+}
+
+// APIServerUsesKMS returns true if the API server run with params uses
+// an encryption configuration with a KMS provider.
+func APIServerUsesKMS(params cke.ServiceParams) bool {
+	prefix := "--encryption-provider-config=" + strings.SplitN(encryptionConfigKMSBasePath, "%", 2)[0]
+	for _, arg := range params.ExtraArguments {
+		if strings.HasPrefix(arg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func kmsConfiguration(kms *cke.KMSParams) *apiserverv1.KMSConfiguration {
+	cfg := &apiserverv1.KMSConfiguration{
+		Name:      kms.Name,
+		Endpoint:  kms.Endpoint,
+		CacheSize: kms.CacheSize,
+	}
+	if kms.TimeoutSeconds != nil {
+		cfg.Timeout = &metav1.Duration{Duration: time.Duration(*kms.TimeoutSeconds) * time.Second}
+	}
+	return cfg
+}
+
+func getEncryptionConfiguration(ctx context.Context, inf cke.Infrastructure, kms *cke.KMSParams) (*apiserverv1.EncryptionConfiguration, error) {
+	b, err := inf.Backend()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	secret, err := vc.Logical().Read(cke.K8sSecret)
+	keys, err := cke.ReadEncryptionKeys(ctx, b)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if secret == nil {
-		return "", errors.New("no encryption secrets for API server")
+	cfg := keys.Configuration()
+	if kms == nil {
+		return cfg, nil
 	}
 
-	data, ok := secret.Data[key]
-	if !ok {
-		return "", errors.New("no secret data for " + key)
-	}
-	return data.(string), nil
+	// Keys in the backend are kept to decrypt Secrets written before KMS is enabled.
+	providers := []apiserverv1.ProviderConfiguration{{KMS: kmsConfiguration(kms)}}
+	cfg.Resources[0].Providers = append(providers, cfg.Resources[0].Providers...)
+	return cfg, nil
 }
 
-func getEncryptionConfiguration(ctx context.Context, inf cke.Infrastructure) (*apiserverv1.EncryptionConfiguration, error) {
-	data, err := getEncryptionSecret(ctx, inf, "aescbc")
+// encryptionConfigData returns the encryption configuration file for API servers.
+func encryptionConfigData(ctx context.Context, inf cke.Infrastructure, kms *cke.KMSParams) ([]byte, error) {
+	cfg, err := getEncryptionConfiguration(ctx, inf, kms)
 	if err != nil {
 		return nil, err
 	}
+	return encodeEncryptionConfiguration(cfg, kms)
+}
 
-	aescfg := new(apiserverv1.AESConfiguration)
-	err = json.Unmarshal([]byte(data), aescfg)
-	if err != nil {
+func encodeEncryptionConfiguration(cfg *apiserverv1.EncryptionConfiguration, kms *cke.KMSParams) ([]byte, error) {
+	if kms == nil || kms.APIVersion != cke.KMSAPIVersionV2 {
+		return encodeToYAML(cfg)
+	}
+
+	// apiserverv1.KMSConfiguration of this version lacks apiVersion field.
+	unst := &unstructured.Unstructured{}
+	if err := scm.Convert(cfg, unst, nil); err != nil {
 		return nil, err
 	}
+	resources := unst.Object["resources"].([]interface{})
+	providers := resources[0].(map[string]interface{})["providers"].([]interface{})
+	kmsConfig := providers[0].(map[string]interface{})["kms"].(map[string]interface{})
+	kmsConfig["apiVersion"] = cke.KMSAPIVersionV2
 
-	return &apiserverv1.EncryptionConfiguration{
-		Resources: []apiserverv1.ResourceConfiguration{
-			{
-				Resources: []string{"secrets"},
-				Providers: []apiserverv1.ProviderConfiguration{
-					{AESCBC: aescfg},
-					{Identity: &apiserverv1.IdentityConfiguration{}},
-				},
-			},
-		},
-	}, nil
+	buf := &bytes.Buffer{}
+	if err := resourceEncoder.Encode(unst, buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
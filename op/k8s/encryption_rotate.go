@@ -0,0 +1,284 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/cke/op"
+	"github.com/cybozu-go/cke/op/common"
+	"github.com/cybozu-go/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type encryptionKeyRotationOp struct {
+	cps       []*cke.Node
+	apiServer *cke.Node
+
+	serviceSubnet string
+	params        cke.APIServerParams
+	img           cke.Image
+	clusterDomain string
+	rotation      *cke.EncryptionKeyRotation
+
+	step    int
+	current int
+	subStep int
+	files   *common.FilesBuilder
+}
+
+// EncryptionKeyRotationOp returns an Operator to proceed the current step
+// of the encryption key rotation.
+//
+// For the add, promote and prune steps, this updates the keys in Vault,
+// then restarts kube-apiserver one by one with the new encryption configuration.
+// For the rewrite step, this rewrites all Secrets to encrypt them with the new key.
+// Every step is idempotent so that it can be resumed by another CKE instance.
+func EncryptionKeyRotationOp(cps []*cke.Node, apiServer *cke.Node, serviceSubnet string, params cke.APIServerParams, clusterDomain string, img cke.Image, rotation *cke.EncryptionKeyRotation) cke.Operator {
+	return &encryptionKeyRotationOp{
+		cps:           cps,
+		apiServer:     apiServer,
+		serviceSubnet: serviceSubnet,
+		params:        params,
+		img:           img,
+		clusterDomain: clusterDomain,
+		rotation:      rotation,
+	}
+}
+
+func (o *encryptionKeyRotationOp) Name() string {
+	return "encryption-key-rotation"
+}
+
+func (o *encryptionKeyRotationOp) NextCommand() cke.Commander {
+	switch o.step {
+	case 0:
+		o.step++
+		if o.rotation.Step == cke.EncryptionKeyRotationRewrite {
+			return rewriteSecretsCommand{o.apiServer}
+		}
+		return updateEncryptionKeysCommand{o.rotation}
+	case 1:
+		if o.rotation.Step != cke.EncryptionKeyRotationRewrite && o.current < len(o.cps) {
+			return o.restartNext()
+		}
+		o.step++
+		return finishEncryptionKeyRotationStepCommand{o.rotation}
+	default:
+		return nil
+	}
+}
+
+// restartNext returns the commands to restart kube-apiserver on o.cps[o.current].
+func (o *encryptionKeyRotationOp) restartNext() cke.Commander {
+	n := o.cps[o.current]
+	nodes := []*cke.Node{n}
+
+	switch o.subStep {
+	case 0:
+		o.subStep++
+		o.files = common.NewFilesBuilder(nodes)
+		return prepareEncryptionConfigCommand{o.files, o.params.KMS}
+	case 1:
+		o.subStep++
+		return o.files
+	case 2:
+		o.subStep++
+		params := APIServerParams(n.Address, o.serviceSubnet, o.params.AuditLogEnabled, o.params.AuditLogPolicy, o.params.AuditLogPath, o.clusterDomain, o.params.KMS)
+		return common.RunContainerCommand(nodes,
+			op.KubeAPIServerContainerName, o.img,
+			common.WithOpts(apiServerRunOpts),
+			common.WithParams(params),
+			common.WithExtra(o.params.ServiceParams),
+			common.WithRestart())
+	default:
+		o.subStep = 0
+		o.current++
+		return waitForAPIServerReadyCommand{n}
+	}
+}
+
+func (o *encryptionKeyRotationOp) Targets() []string {
+	ips := make([]string, len(o.cps))
+	for i, n := range o.cps {
+		ips[i] = n.Address
+	}
+	return ips
+}
+
+type updateEncryptionKeysCommand struct {
+	rotation *cke.EncryptionKeyRotation
+}
+
+func (c updateEncryptionKeysCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	b, err := inf.Backend()
+	if err != nil {
+		return err
+	}
+	keys, err := cke.ReadEncryptionKeys(ctx, b)
+	if err != nil {
+		return err
+	}
+	if !keys.HasKey(c.rotation.Provider, c.rotation.KeyName) {
+		return fmt.Errorf("no such encryption key: %s/%s", c.rotation.Provider, c.rotation.KeyName)
+	}
+
+	switch c.rotation.Step {
+	case cke.EncryptionKeyRotationPromote:
+		err = keys.Promote(c.rotation.Provider, c.rotation.KeyName)
+	case cke.EncryptionKeyRotationPrune:
+		err = keys.Prune(c.rotation.Provider, c.rotation.KeyName)
+	default:
+		// the new key has been added by ckecli.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return cke.WriteEncryptionKeys(ctx, b, keys)
+}
+
+func (c updateEncryptionKeysCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "update-encryption-keys",
+		Target: string(c.rotation.Step),
+	}
+}
+
+type prepareEncryptionConfigCommand struct {
+	files *common.FilesBuilder
+	kms   *cke.KMSParams
+}
+
+func (c prepareEncryptionConfigCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	enccfgData, err := encryptionConfigData(ctx, inf, c.kms)
+	if err != nil {
+		return err
+	}
+	return c.files.AddFile(ctx, encryptionConfigPath(c.kms), func(ctx context.Context, node *cke.Node) ([]byte, error) {
+		return enccfgData, nil
+	})
+}
+
+func (c prepareEncryptionConfigCommand) Command() cke.Command {
+	return cke.Command{
+		Name: "prepare-encryption-config",
+	}
+}
+
+type waitForAPIServerReadyCommand struct {
+	node *cke.Node
+}
+
+func (c waitForAPIServerReadyCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	for i := 0; i < 11; i++ {
+		err := c.try(ctx, inf)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	// last try
+	return c.try(ctx, inf)
+}
+
+func (c waitForAPIServerReadyCommand) try(ctx context.Context, inf cke.Infrastructure) error {
+	cs, err := inf.K8sClient(ctx, c.node)
+	if err != nil {
+		return err
+	}
+	_, err = cs.CoreV1().Namespaces().List(ctx, metav1.ListOptions{Limit: 1})
+	return err
+}
+
+func (c waitForAPIServerReadyCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "wait-for-apiserver-ready",
+		Target: c.node.Address,
+	}
+}
+
+type rewriteSecretsCommand struct {
+	apiServer *cke.Node
+}
+
+func (c rewriteSecretsCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	cs, err := inf.K8sClient(ctx, c.apiServer)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	opts := metav1.ListOptions{Limit: 100}
+	for {
+		secrets, err := cs.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for i := range secrets.Items {
+			s := &secrets.Items[i]
+			_, err := cs.CoreV1().Secrets(s.Namespace).Update(ctx, s, metav1.UpdateOptions{})
+			switch {
+			case err == nil:
+				count++
+			case apierrors.IsNotFound(err), apierrors.IsConflict(err):
+				// the secret has been deleted or written with the new key by someone else.
+			default:
+				return fmt.Errorf("failed to rewrite secret %s/%s: %w", s.Namespace, s.Name, err)
+			}
+		}
+		if secrets.Continue == "" {
+			break
+		}
+		opts.Continue = secrets.Continue
+	}
+
+	log.Info("rewrote secrets with the new encryption key", map[string]interface{}{
+		"count": count,
+	})
+	return nil
+}
+
+func (c rewriteSecretsCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "rewrite-secrets",
+		Target: "secrets",
+	}
+}
+
+type finishEncryptionKeyRotationStepCommand struct {
+	rotation *cke.EncryptionKeyRotation
+}
+
+func (c finishEncryptionKeyRotationStepCommand) Run(ctx context.Context, inf cke.Infrastructure, leaderKey string) error {
+	next := c.rotation.NextStep()
+	if next == "" {
+		err := inf.Storage().DeleteEncryptionKeyRotation(ctx, leaderKey)
+		if err != nil {
+			return err
+		}
+		log.Info("encryption key has been rotated", map[string]interface{}{
+			"provider": c.rotation.Provider,
+			"key":      c.rotation.KeyName,
+		})
+		return nil
+	}
+
+	r := *c.rotation
+	r.Step = next
+	return inf.Storage().UpdateEncryptionKeyRotation(ctx, leaderKey, &r)
+}
+
+func (c finishEncryptionKeyRotationStepCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "finish-encryption-key-rotation-step",
+		Target: string(c.rotation.Step),
+	}
+}
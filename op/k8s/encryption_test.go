@@ -0,0 +1,104 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cybozu-go/cke"
+	apiserverv1 "k8s.io/apiserver/pkg/apis/config/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestEncryptionConfigPath(t *testing.T) {
+	t.Parallel()
+
+	if p := encryptionConfigPath(nil); p != encryptionConfigFile {
+		t.Error("unexpected path without KMS:", p)
+	}
+
+	kms := &cke.KMSParams{Name: "kms", Endpoint: "unix:///run/kms/kms.sock"}
+	p1 := encryptionConfigPath(kms)
+	if !strings.HasPrefix(p1, encryptionConfigDir+"/encryption-kms-") {
+		t.Error("unexpected path with KMS:", p1)
+	}
+
+	kms.Image = "example.com/kms-plugin:1.0.1"
+	if p := encryptionConfigPath(kms); p != p1 {
+		t.Error("path should not change with the plugin image:", p)
+	}
+
+	kms.APIVersion = cke.KMSAPIVersionV2
+	if p := encryptionConfigPath(kms); p == p1 {
+		t.Error("path should change with KMS API version:", p)
+	}
+}
+
+func TestAPIServerUsesKMS(t *testing.T) {
+	t.Parallel()
+
+	kms := &cke.KMSParams{Name: "kms", Endpoint: "unix:///run/kms/kms.sock"}
+	if !APIServerUsesKMS(APIServerParams("10.0.0.1", "10.68.0.0/16", false, "", "", "cluster.local", kms)) {
+		t.Error("API server with KMS is not detected")
+	}
+	if APIServerUsesKMS(APIServerParams("10.0.0.1", "10.68.0.0/16", false, "", "", "cluster.local", nil)) {
+		t.Error("API server without KMS is detected")
+	}
+}
+
+func TestEncodeEncryptionConfiguration(t *testing.T) {
+	t.Parallel()
+
+	keys := &cke.EncryptionKeys{
+		Primary: cke.EncryptionProviderAESCBC,
+		Providers: map[string][]apiserverv1.Key{
+			cke.EncryptionProviderAESCBC: {{Name: "key1", Secret: "c2VjcmV0"}},
+		},
+	}
+	timeout := 5
+	kms := &cke.KMSParams{
+		APIVersion:     cke.KMSAPIVersionV2,
+		Name:           "kms",
+		Endpoint:       "unix:///run/kms/kms.sock",
+		TimeoutSeconds: &timeout,
+	}
+	cfg := keys.Configuration()
+	cfg.Resources[0].Providers = append([]apiserverv1.ProviderConfiguration{{KMS: kmsConfiguration(kms)}}, cfg.Resources[0].Providers...)
+
+	data, err := encodeEncryptionConfiguration(cfg, kms)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Kind      string `json:"kind"`
+		Resources []struct {
+			Providers []map[string]map[string]interface{} `json:"providers"`
+		} `json:"resources"`
+	}
+	if err := yaml.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Kind != "EncryptionConfiguration" {
+		t.Error("unexpected kind:", decoded.Kind)
+	}
+	providers := decoded.Resources[0].Providers
+	if len(providers) != 3 {
+		t.Fatal("unexpected providers:", string(data))
+	}
+	kmsConfig := providers[0]["kms"]
+	if kmsConfig["apiVersion"] != cke.KMSAPIVersionV2 || kmsConfig["name"] != "kms" || kmsConfig["timeout"] != "5s" {
+		t.Error("unexpected kms provider:", kmsConfig)
+	}
+	if _, ok := providers[1]["aescbc"]; !ok {
+		t.Error("aescbc provider should follow kms:", providers[1])
+	}
+
+	kms.APIVersion = ""
+	data, err = encodeEncryptionConfiguration(cfg, kms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "apiVersion: v2") {
+		t.Error("KMS v1 configuration should not have apiVersion v2:", string(data))
+	}
+}
@@ -0,0 +1,96 @@
+package k8s
+
+import (
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/cke/op"
+	"github.com/cybozu-go/cke/op/common"
+)
+
+type kmsPluginBootOp struct {
+	nodes   []*cke.Node
+	params  cke.KMSParams
+	restart bool
+
+	step int
+}
+
+// KMSPluginBootOp returns an Operator to bootstrap KMS plugin.
+func KMSPluginBootOp(nodes []*cke.Node, params cke.KMSParams) cke.Operator {
+	return &kmsPluginBootOp{
+		nodes:  nodes,
+		params: params,
+	}
+}
+
+// KMSPluginRestartOp returns an Operator to restart KMS plugin.
+func KMSPluginRestartOp(nodes []*cke.Node, params cke.KMSParams) cke.Operator {
+	return &kmsPluginBootOp{
+		nodes:   nodes,
+		params:  params,
+		restart: true,
+	}
+}
+
+func (o *kmsPluginBootOp) Name() string {
+	if o.restart {
+		return "kms-plugin-restart"
+	}
+	return "kms-plugin-bootstrap"
+}
+
+func (o *kmsPluginBootOp) NextCommand() cke.Commander {
+	img := cke.Image(o.params.Image)
+
+	switch o.step {
+	case 0:
+		o.step++
+		return common.ImagePullCommand(o.nodes, img)
+	case 1:
+		o.step++
+		return common.MakeDirsCommandWithMode(o.nodes, []string{o.params.SocketDir()}, "700")
+	case 2:
+		o.step++
+		opts := []common.RunOption{
+			common.WithParams(KMSPluginParams(o.params)),
+			common.WithExtra(o.params.ServiceParams),
+		}
+		if o.restart {
+			opts = append(opts, common.WithRestart())
+		}
+		return common.RunContainerCommand(o.nodes, op.KMSPluginContainerName, img, opts...)
+	default:
+		return nil
+	}
+}
+
+func (o *kmsPluginBootOp) Targets() []string {
+	ips := make([]string, len(o.nodes))
+	for i, n := range o.nodes {
+		ips[i] = n.Address
+	}
+	return ips
+}
+
+func (o *kmsPluginBootOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{op.KMSPluginContainerName},
+	}
+}
+
+// KMSPluginParams returns parameters for KMS plugin.
+func KMSPluginParams(params cke.KMSParams) cke.ServiceParams {
+	return cke.ServiceParams{
+		ExtraBinds: []cke.Mount{kmsSocketMount(&params)},
+	}
+}
+
+// kmsSocketMount returns the mount to access the socket of KMS plugin.
+func kmsSocketMount(params *cke.KMSParams) cke.Mount {
+	return cke.Mount{
+		Source:      params.SocketDir(),
+		Destination: params.SocketDir(),
+		ReadOnly:    false,
+		Label:       cke.LabelShared,
+	}
+}
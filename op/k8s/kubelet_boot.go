@@ -31,6 +31,8 @@ type kubeletBootOp struct {
 
 	cluster      string
 	params       cke.KubeletParams
+	certTTL      time.Duration
+	img          cke.Image
 	nodeStatuses map[string]*cke.NodeStatus
 
 	step  int
@@ -38,13 +40,15 @@ type kubeletBootOp struct {
 }
 
 // KubeletBootOp returns an Operator to boot kubelet.
-func KubeletBootOp(nodes, registeredNodes []*cke.Node, apiServer *cke.Node, cluster string, params cke.KubeletParams, ns map[string]*cke.NodeStatus) cke.Operator {
+func KubeletBootOp(nodes, registeredNodes []*cke.Node, apiServer *cke.Node, cluster string, params cke.KubeletParams, ns map[string]*cke.NodeStatus, certTTL time.Duration, img cke.Image) cke.Operator {
 	return &kubeletBootOp{
 		nodes:           nodes,
 		registeredNodes: registeredNodes,
 		apiServer:       apiServer,
 		cluster:         cluster,
 		params:          params,
+		certTTL:         certTTL,
+		img:             img,
 		nodeStatuses:    ns,
 		files:           common.NewFilesBuilder(nodes),
 	}
@@ -58,7 +62,7 @@ func (o *kubeletBootOp) NextCommand() cke.Commander {
 	switch o.step {
 	case 0:
 		o.step++
-		return common.ImagePullCommand(o.nodes, cke.KubernetesImage)
+		return common.ImagePullCommand(o.nodes, o.img)
 	case 1:
 		o.step++
 		if len(o.params.CNIConfFile.Name) != 0 {
@@ -78,7 +82,7 @@ func (o *kubeletBootOp) NextCommand() cke.Commander {
 		return common.MakeDirsCommand(o.nodes, dirs)
 	case 3:
 		o.step++
-		return prepareKubeletFilesCommand{o.cluster, o.params, o.nodeStatuses, o.files}
+		return prepareKubeletFilesCommand{o.cluster, o.params, o.nodeStatuses, o.files, o.certTTL}
 	case 4:
 		o.step++
 		return o.files
@@ -111,7 +115,7 @@ func (o *kubeletBootOp) NextCommand() cke.Commander {
 			}
 			paramsMap[n.Address] = params
 		}
-		return common.RunContainerCommand(o.nodes, op.KubeletContainerName, cke.KubernetesImage,
+		return common.RunContainerCommand(o.nodes, op.KubeletContainerName, o.img,
 			common.WithOpts(opts),
 			common.WithParamsMap(paramsMap),
 			common.WithExtra(o.params.ServiceParams))
@@ -131,6 +135,24 @@ func (o *kubeletBootOp) Targets() []string {
 	return ips
 }
 
+func (o *kubeletBootOp) Footprint() cke.Footprint {
+	return kubeletFootprint(o.nodes, o.apiServer)
+}
+
+// kubeletFootprint returns the footprint of kubelet operations.
+// They access the API server to register or wait for nodes.
+func kubeletFootprint(nodes []*cke.Node, apiServer *cke.Node) cke.Footprint {
+	var fp cke.Footprint
+	for _, n := range nodes {
+		fp.Nodes = append(fp.Nodes, n.Address)
+	}
+	if apiServer != nil {
+		fp.Nodes = append(fp.Nodes, apiServer.Address)
+	}
+	fp.Components = []string{op.KubeletContainerName, op.KubeAPIServerContainerName}
+	return fp
+}
+
 type emptyDirCommand struct {
 	nodes []*cke.Node
 	dir   string
@@ -169,6 +191,7 @@ type prepareKubeletFilesCommand struct {
 	params       cke.KubeletParams
 	nodeStatuses map[string]*cke.NodeStatus
 	files        *common.FilesBuilder
+	certTTL      time.Duration
 }
 
 func (c prepareKubeletFilesCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
@@ -214,7 +237,7 @@ func (c prepareKubeletFilesCommand) Run(ctx context.Context, inf cke.Infrastruct
 	}
 
 	f := func(ctx context.Context, n *cke.Node) (cert, key []byte, err error) {
-		c, k, e := cke.KubernetesCA{}.IssueForKubelet(ctx, inf, n)
+		c, k, e := cke.KubernetesCA{}.IssueForKubelet(ctx, inf, n, c.certTTL)
 		if e != nil {
 			return nil, nil, e
 		}
@@ -2,6 +2,7 @@ package k8s
 
 import (
 	"context"
+	"time"
 
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/op"
@@ -11,24 +12,37 @@ import (
 )
 
 type kubeletRestartOp struct {
-	nodes []*cke.Node
+	nodes     []*cke.Node
+	apiServer *cke.Node
 
 	cluster      string
 	params       cke.KubeletParams
+	certTTL      time.Duration
+	img          cke.Image
 	nodeStatuses map[string]*cke.NodeStatus
 
-	step  int
-	files *common.FilesBuilder
+	step    int
+	batches *rollingBatches
+	files   *common.FilesBuilder
 }
 
-// KubeletRestartOp returns an Operator to restart kubelet
-func KubeletRestartOp(nodes []*cke.Node, cluster string, params cke.KubeletParams, ns map[string]*cke.NodeStatus) cke.Operator {
+// KubeletRestartOp returns an Operator to restart kubelet.
+// Nodes are restarted in batches of at most batchSize nodes.  Before
+// proceeding to the next batch, the operator waits for the restarted nodes
+// to become Ready by querying apiServer, then pauses as specified by
+// params.RollingUpdate.  If batchSize is not positive, all nodes are
+// restarted at once.
+func KubeletRestartOp(nodes []*cke.Node, apiServer *cke.Node, cluster string, params cke.KubeletParams, ns map[string]*cke.NodeStatus, batchSize int, certTTL time.Duration, img cke.Image) cke.InfoOperator {
+	pause := time.Duration(params.RollingUpdate.PauseSeconds) * time.Second
 	return &kubeletRestartOp{
 		nodes:        nodes,
+		apiServer:    apiServer,
 		cluster:      cluster,
 		params:       params,
+		certTTL:      certTTL,
+		img:          img,
 		nodeStatuses: ns,
-		files:        common.NewFilesBuilder(nodes),
+		batches:      newRollingBatches(nodes, batchSize, pause),
 	}
 }
 
@@ -37,38 +51,53 @@ func (o *kubeletRestartOp) Name() string {
 }
 
 func (o *kubeletRestartOp) NextCommand() cke.Commander {
-	switch o.step {
-	case 0:
+	if o.step == 0 {
 		o.step++
-		return common.ImagePullCommand(o.nodes, cke.KubernetesImage)
-	case 1:
-		o.step++
-		return prepareKubeletConfigCommand{o.cluster, o.params, o.nodeStatuses, o.files}
-	case 2:
-		o.step++
-		return o.files
-	case 3:
+		return common.ImagePullCommand(o.nodes, o.img)
+	}
+
+	for !o.batches.done() {
+		nodes := o.batches.nodes()
+		step := o.step
 		o.step++
-		opts := []string{
-			"--pid=host",
-			"--privileged",
-			"--tmpfs=/tmp",
-		}
-		paramsMap := make(map[string]cke.ServiceParams)
-		for _, n := range o.nodes {
-			paramsMap[n.Address] = KubeletServiceParams(n, o.params)
+
+		switch step {
+		case 1:
+			o.files = common.NewFilesBuilder(nodes)
+			return o.batches.wrap(prepareKubeletConfigCommand{o.cluster, o.params, o.nodeStatuses, o.files, o.certTTL})
+		case 2:
+			return o.batches.wrap(o.files)
+		case 3:
+			opts := []string{
+				"--pid=host",
+				"--privileged",
+				"--tmpfs=/tmp",
+			}
+			paramsMap := make(map[string]cke.ServiceParams)
+			for _, n := range nodes {
+				paramsMap[n.Address] = KubeletServiceParams(n, o.params)
+			}
+			return o.batches.wrap(common.RunContainerCommand(nodes, op.KubeletContainerName, o.img,
+				common.WithOpts(opts),
+				common.WithParamsMap(paramsMap),
+				common.WithExtra(o.params.ServiceParams),
+				common.WithRestart()))
+		case 4:
+			return o.batches.wrap(waitForKubeletReadyCommand{nodes})
+		case 5:
+			if o.batches.hasNext() && o.apiServer != nil {
+				return o.batches.wrap(waitForNodeReadyCommand{nodes, o.apiServer})
+			}
+		case 6:
+			if o.batches.hasNext() && o.batches.pause > 0 {
+				return o.batches.wrap(pauseCommand{o.batches.pause})
+			}
+		default:
+			o.batches.next()
+			o.step = 1
 		}
-		return common.RunContainerCommand(o.nodes, op.KubeletContainerName, cke.KubernetesImage,
-			common.WithOpts(opts),
-			common.WithParamsMap(paramsMap),
-			common.WithExtra(o.params.ServiceParams),
-			common.WithRestart())
-	case 4:
-		o.step++
-		return waitForKubeletReadyCommand{o.nodes}
-	default:
-		return nil
 	}
+	return nil
 }
 
 func (o *kubeletRestartOp) Targets() []string {
@@ -79,11 +108,20 @@ func (o *kubeletRestartOp) Targets() []string {
 	return ips
 }
 
+func (o *kubeletRestartOp) Footprint() cke.Footprint {
+	return kubeletFootprint(o.nodes, o.apiServer)
+}
+
+func (o *kubeletRestartOp) Info() string {
+	return o.batches.info()
+}
+
 type prepareKubeletConfigCommand struct {
 	cluster      string
 	params       cke.KubeletParams
 	nodeStatuses map[string]*cke.NodeStatus
 	files        *common.FilesBuilder
+	certTTL      time.Duration
 }
 
 func (c prepareKubeletConfigCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
@@ -102,7 +140,7 @@ func (c prepareKubeletConfigCommand) Run(ctx context.Context, inf cke.Infrastruc
 	}
 
 	f := func(ctx context.Context, n *cke.Node) (cert, key []byte, err error) {
-		c, k, e := cke.KubernetesCA{}.IssueForKubelet(ctx, inf, n)
+		c, k, e := cke.KubernetesCA{}.IssueForKubelet(ctx, inf, n, c.certTTL)
 		if e != nil {
 			return nil, nil, e
 		}
@@ -3,6 +3,7 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/op"
@@ -10,10 +11,7 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-const (
-	proxyKubeconfigPath = "/etc/kubernetes/proxy/kubeconfig"
-	proxyConfigPath     = "/etc/kubernetes/proxy/config.yml"
-)
+const proxyConfigPath = "/etc/kubernetes/proxy/config.yml"
 
 type kubeProxyBootOp struct {
 	nodes []*cke.Node
@@ -21,18 +19,22 @@ type kubeProxyBootOp struct {
 	cluster string
 	ap      string
 	params  cke.ProxyParams
+	certTTL time.Duration
+	img     cke.Image
 
 	step  int
 	files *common.FilesBuilder
 }
 
 // KubeProxyBootOp returns an Operator to boot kube-proxy.
-func KubeProxyBootOp(nodes []*cke.Node, cluster, ap string, params cke.ProxyParams) cke.Operator {
+func KubeProxyBootOp(nodes []*cke.Node, cluster, ap string, params cke.ProxyParams, certTTL time.Duration, img cke.Image) cke.Operator {
 	return &kubeProxyBootOp{
 		nodes:   nodes,
 		ap:      ap,
 		cluster: cluster,
 		params:  params,
+		certTTL: certTTL,
+		img:     img,
 		files:   common.NewFilesBuilder(nodes),
 	}
 }
@@ -45,10 +47,10 @@ func (o *kubeProxyBootOp) NextCommand() cke.Commander {
 	switch o.step {
 	case 0:
 		o.step++
-		return common.ImagePullCommand(o.nodes, cke.KubernetesImage)
+		return common.ImagePullCommand(o.nodes, o.img)
 	case 1:
 		o.step++
-		return prepareProxyFilesCommand{cluster: o.cluster, ap: o.ap, files: o.files, params: o.params}
+		return prepareProxyFilesCommand{cluster: o.cluster, ap: o.ap, files: o.files, params: o.params, certTTL: o.certTTL}
 	case 2:
 		o.step++
 		return o.files
@@ -63,7 +65,7 @@ func (o *kubeProxyBootOp) NextCommand() cke.Commander {
 			params := ProxyParams()
 			paramsMap[n.Address] = params
 		}
-		return common.RunContainerCommand(o.nodes, op.KubeProxyContainerName, cke.KubernetesImage,
+		return common.RunContainerCommand(o.nodes, op.KubeProxyContainerName, o.img,
 			common.WithOpts(opts),
 			common.WithParamsMap(paramsMap),
 			common.WithExtra(o.params.ServiceParams))
@@ -80,11 +82,19 @@ func (o *kubeProxyBootOp) Targets() []string {
 	return ips
 }
 
+func (o *kubeProxyBootOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{op.KubeProxyContainerName},
+	}
+}
+
 type prepareProxyFilesCommand struct {
 	cluster string
 	ap      string
 	files   *common.FilesBuilder
 	params  cke.ProxyParams
+	certTTL time.Duration
 }
 
 func (c prepareProxyFilesCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
@@ -95,14 +105,14 @@ func (c prepareProxyFilesCommand) Run(ctx context.Context, inf cke.Infrastructur
 		return err
 	}
 	g := func(ctx context.Context, n *cke.Node) ([]byte, error) {
-		crt, key, err := cke.KubernetesCA{}.IssueForProxy(ctx, inf)
+		crt, key, err := cke.KubernetesCA{}.IssueForProxy(ctx, inf, c.certTTL)
 		if err != nil {
 			return nil, err
 		}
 		cfg := proxyKubeconfig(c.cluster, ca, crt, key, c.ap)
 		return clientcmd.Write(*cfg)
 	}
-	if err := c.files.AddFile(ctx, proxyKubeconfigPath, g); err != nil {
+	if err := c.files.AddFile(ctx, op.ProxyKubeConfigPath, g); err != nil {
 		return err
 	}
 
@@ -1,6 +1,8 @@
 package k8s
 
 import (
+	"time"
+
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/op"
 	"github.com/cybozu-go/cke/op/common"
@@ -12,19 +14,29 @@ type kubeProxyRestartOp struct {
 	cluster string
 	ap      string
 	params  cke.ProxyParams
+	certTTL time.Duration
+	img     cke.Image
 
-	step  int
-	files *common.FilesBuilder
+	step    int
+	batches *rollingBatches
+	files   *common.FilesBuilder
 }
 
 // KubeProxyRestartOp returns an Operator to restart kube-proxy.
-func KubeProxyRestartOp(nodes []*cke.Node, cluster, ap string, params cke.ProxyParams) cke.Operator {
+// Nodes are restarted in batches of at most batchSize nodes.  Before
+// proceeding to the next batch, the operator waits for kube-proxy to
+// become healthy, then pauses as specified by params.RollingUpdate.
+// If batchSize is not positive, all nodes are restarted at once.
+func KubeProxyRestartOp(nodes []*cke.Node, cluster, ap string, params cke.ProxyParams, batchSize int, certTTL time.Duration, img cke.Image) cke.InfoOperator {
+	pause := time.Duration(params.RollingUpdate.PauseSeconds) * time.Second
 	return &kubeProxyRestartOp{
 		nodes:   nodes,
 		cluster: cluster,
 		ap:      ap,
 		params:  params,
-		files:   common.NewFilesBuilder(nodes),
+		certTTL: certTTL,
+		img:     img,
+		batches: newRollingBatches(nodes, batchSize, pause),
 	}
 }
 
@@ -33,35 +45,51 @@ func (o *kubeProxyRestartOp) Name() string {
 }
 
 func (o *kubeProxyRestartOp) NextCommand() cke.Commander {
-	switch o.step {
-	case 0:
-		o.step++
-		return common.ImagePullCommand(o.nodes, cke.KubernetesImage)
-	case 1:
-		o.step++
-		return prepareProxyFilesCommand{cluster: o.cluster, ap: o.ap, files: o.files, params: o.params}
-	case 2:
+	if o.step == 0 {
 		o.step++
-		return o.files
-	case 3:
+		return common.ImagePullCommand(o.nodes, o.img)
+	}
+
+	for !o.batches.done() {
+		nodes := o.batches.nodes()
+		step := o.step
 		o.step++
-		opts := []string{
-			"--tmpfs=/run",
-			"--privileged",
-		}
-		paramsMap := make(map[string]cke.ServiceParams)
-		for _, n := range o.nodes {
-			params := ProxyParams()
-			paramsMap[n.Address] = params
+
+		switch step {
+		case 1:
+			o.files = common.NewFilesBuilder(nodes)
+			return o.batches.wrap(prepareProxyFilesCommand{cluster: o.cluster, ap: o.ap, files: o.files, params: o.params, certTTL: o.certTTL})
+		case 2:
+			return o.batches.wrap(o.files)
+		case 3:
+			opts := []string{
+				"--tmpfs=/run",
+				"--privileged",
+			}
+			paramsMap := make(map[string]cke.ServiceParams)
+			for _, n := range nodes {
+				params := ProxyParams()
+				paramsMap[n.Address] = params
+			}
+			return o.batches.wrap(common.RunContainerCommand(nodes, op.KubeProxyContainerName, o.img,
+				common.WithOpts(opts),
+				common.WithParamsMap(paramsMap),
+				common.WithExtra(o.params.ServiceParams),
+				common.WithRestart()))
+		case 4:
+			if o.batches.hasNext() {
+				return o.batches.wrap(waitForProxyReadyCommand{nodes})
+			}
+		case 5:
+			if o.batches.hasNext() && o.batches.pause > 0 {
+				return o.batches.wrap(pauseCommand{o.batches.pause})
+			}
+		default:
+			o.batches.next()
+			o.step = 1
 		}
-		return common.RunContainerCommand(o.nodes, op.KubeProxyContainerName, cke.KubernetesImage,
-			common.WithOpts(opts),
-			common.WithParamsMap(paramsMap),
-			common.WithExtra(o.params.ServiceParams),
-			common.WithRestart())
-	default:
-		return nil
 	}
+	return nil
 }
 
 func (o *kubeProxyRestartOp) Targets() []string {
@@ -71,3 +99,14 @@ func (o *kubeProxyRestartOp) Targets() []string {
 	}
 	return ips
 }
+
+func (o *kubeProxyRestartOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{op.KubeProxyContainerName},
+	}
+}
+
+func (o *kubeProxyRestartOp) Info() string {
+	return o.batches.info()
+}
@@ -0,0 +1,209 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/cke/op"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rollingBatches splits nodes into batches and keeps track of
+// the batch being processed in a rolling update.
+type rollingBatches struct {
+	batches [][]*cke.Node
+	current int
+	pause   time.Duration
+}
+
+// newRollingBatches splits nodes into batches of at most size nodes.
+// If size is not positive, all nodes are processed in a single batch.
+func newRollingBatches(nodes []*cke.Node, size int, pause time.Duration) *rollingBatches {
+	if size <= 0 || size > len(nodes) {
+		size = len(nodes)
+	}
+
+	var batches [][]*cke.Node
+	for len(nodes) > 0 {
+		n := size
+		if n > len(nodes) {
+			n = len(nodes)
+		}
+		batches = append(batches, nodes[:n])
+		nodes = nodes[n:]
+	}
+	return &rollingBatches{
+		batches: batches,
+		pause:   pause,
+	}
+}
+
+func (r *rollingBatches) done() bool {
+	return r.current >= len(r.batches)
+}
+
+func (r *rollingBatches) nodes() []*cke.Node {
+	return r.batches[r.current]
+}
+
+// hasNext returns true if the current batch is not the last one.
+func (r *rollingBatches) hasNext() bool {
+	return r.current+1 < len(r.batches)
+}
+
+func (r *rollingBatches) next() {
+	r.current++
+}
+
+func (r *rollingBatches) info() string {
+	if len(r.batches) < 2 {
+		return ""
+	}
+	return fmt.Sprintf("restarted %d batches", len(r.batches))
+}
+
+// wrap decorates c so that records show the current batch.
+// Commands are returned as is when there is only one batch.
+func (r *rollingBatches) wrap(c cke.Commander) cke.Commander {
+	if len(r.batches) < 2 {
+		return c
+	}
+	return batchCommand{c, r.current + 1, len(r.batches)}
+}
+
+type batchCommand struct {
+	cke.Commander
+	batch int
+	total int
+}
+
+func (c batchCommand) Command() cke.Command {
+	cmd := c.Commander.Command()
+	batch := fmt.Sprintf("batch %d/%d", c.batch, c.total)
+	if len(cmd.Target) == 0 {
+		cmd.Target = batch
+	} else {
+		cmd.Target = cmd.Target + " (" + batch + ")"
+	}
+	return cmd
+}
+
+type pauseCommand struct {
+	duration time.Duration
+}
+
+func (c pauseCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(c.duration):
+	}
+	return nil
+}
+
+func (c pauseCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "pause-between-batches",
+		Target: c.duration.String(),
+	}
+}
+
+type waitForNodeReadyCommand struct {
+	nodes     []*cke.Node
+	apiServer *cke.Node
+}
+
+func (c waitForNodeReadyCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	for i := 0; i < 11; i++ {
+		err := c.try(ctx, inf)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	// last try
+	return c.try(ctx, inf)
+}
+
+func (c waitForNodeReadyCommand) try(ctx context.Context, inf cke.Infrastructure) error {
+	cs, err := inf.K8sClient(ctx, c.apiServer)
+	if err != nil {
+		return err
+	}
+
+	nodesAPI := cs.CoreV1().Nodes()
+	for _, n := range c.nodes {
+		node, err := nodesAPI.Get(ctx, n.Nodename(), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if !isNodeReady(node) {
+			return errors.New("node is not ready: " + n.Nodename())
+		}
+	}
+	return nil
+}
+
+func (c waitForNodeReadyCommand) Command() cke.Command {
+	return cke.Command{
+		Name: "wait-for-node-ready",
+	}
+}
+
+func isNodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+type waitForProxyReadyCommand struct {
+	nodes []*cke.Node
+}
+
+func (c waitForProxyReadyCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	for i := 0; i < 9; i++ {
+		err := c.try(ctx, inf)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	// last try
+	return c.try(ctx, inf)
+}
+
+func (c waitForProxyReadyCommand) try(ctx context.Context, inf cke.Infrastructure) error {
+	for _, node := range c.nodes {
+		isReady, err := op.CheckHealthz(ctx, inf, node.Address, 10249)
+		if err != nil {
+			return err
+		}
+		if !isReady {
+			return errors.New("kube-proxy is not ready: " + node.Address)
+		}
+	}
+	return nil
+}
+
+func (c waitForProxyReadyCommand) Command() cke.Command {
+	return cke.Command{
+		Name: "wait-for-proxy-ready",
+	}
+}
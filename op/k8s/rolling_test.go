@@ -0,0 +1,103 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cybozu-go/cke"
+)
+
+func rollingTestNodes(n int) []*cke.Node {
+	nodes := make([]*cke.Node, n)
+	for i := range nodes {
+		nodes[i] = &cke.Node{Address: "10.0.0." + string(rune('1'+i))}
+	}
+	return nodes
+}
+
+func collectCommands(op cke.Operator) []cke.Command {
+	var cmds []cke.Command
+	for i := 0; i < 100; i++ {
+		c := op.NextCommand()
+		if c == nil {
+			return cmds
+		}
+		cmds = append(cmds, c.Command())
+	}
+	return cmds
+}
+
+func commandNames(cmds []cke.Command) []string {
+	names := make([]string, len(cmds))
+	for i, c := range cmds {
+		names[i] = c.Name
+	}
+	return names
+}
+
+func TestKubeletRestartOpBatches(t *testing.T) {
+	t.Parallel()
+
+	nodes := rollingTestNodes(5)
+	params := cke.KubeletParams{
+		RollingUpdate: cke.RollingUpdate{PauseSeconds: 10},
+	}
+
+	cmds := collectCommands(KubeletRestartOp(nodes, nodes[0], "test", params, nil, 2, 0, cke.KubernetesImage))
+	batch := []string{
+		"prepare-kubelet-config",
+		"make-files",
+		"run-container",
+		"wait-for-kubelet-ready",
+	}
+	var expected []string
+	expected = append(expected, "image-pull")
+	expected = append(expected, batch...)
+	expected = append(expected, "wait-for-node-ready", "pause-between-batches")
+	expected = append(expected, batch...)
+	expected = append(expected, "wait-for-node-ready", "pause-between-batches")
+	expected = append(expected, batch...)
+	if names := commandNames(cmds); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("unexpected commands: %v", names)
+	}
+	if cmds[1].Target != "batch 1/3" {
+		t.Errorf("unexpected target: %s", cmds[1].Target)
+	}
+	if cmds[len(cmds)-1].Target != "batch 3/3" {
+		t.Errorf("unexpected target: %s", cmds[len(cmds)-1].Target)
+	}
+
+	// without batches, commands are the same as before
+	cmds = collectCommands(KubeletRestartOp(nodes, nodes[0], "test", params, nil, 0, 0, cke.KubernetesImage))
+	expected = append([]string{"image-pull"}, batch...)
+	if names := commandNames(cmds); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("unexpected commands: %v", names)
+	}
+	if cmds[1].Target != "" {
+		t.Errorf("unexpected target: %s", cmds[1].Target)
+	}
+}
+
+func TestKubeProxyRestartOpBatches(t *testing.T) {
+	t.Parallel()
+
+	nodes := rollingTestNodes(3)
+	op := KubeProxyRestartOp(nodes, "test", "", cke.ProxyParams{}, 2, 0, cke.KubernetesImage)
+	cmds := collectCommands(op)
+	expected := []string{
+		"image-pull",
+		"prepare-proxy-files",
+		"make-files",
+		"run-container",
+		"wait-for-proxy-ready",
+		"prepare-proxy-files",
+		"make-files",
+		"run-container",
+	}
+	if names := commandNames(cmds); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("unexpected commands: %v", names)
+	}
+	if info := op.Info(); info != "restarted 2 batches" {
+		t.Errorf("unexpected info: %s", info)
+	}
+}
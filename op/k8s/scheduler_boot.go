@@ -2,6 +2,7 @@ package k8s
 
 import (
 	"context"
+	"time"
 
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/op"
@@ -14,17 +15,21 @@ type schedulerBootOp struct {
 
 	cluster string
 	params  cke.SchedulerParams
+	certTTL time.Duration
+	img     cke.Image
 
 	step  int
 	files *common.FilesBuilder
 }
 
 // SchedulerBootOp returns an Operator to bootstrap kube-scheduler
-func SchedulerBootOp(nodes []*cke.Node, cluster string, params cke.SchedulerParams) cke.Operator {
+func SchedulerBootOp(nodes []*cke.Node, cluster string, params cke.SchedulerParams, certTTL time.Duration, img cke.Image) cke.Operator {
 	return &schedulerBootOp{
 		nodes:   nodes,
 		cluster: cluster,
 		params:  params,
+		certTTL: certTTL,
+		img:     img,
 		files:   common.NewFilesBuilder(nodes),
 	}
 }
@@ -37,16 +42,16 @@ func (o *schedulerBootOp) NextCommand() cke.Commander {
 	switch o.step {
 	case 0:
 		o.step++
-		return common.ImagePullCommand(o.nodes, cke.KubernetesImage)
+		return common.ImagePullCommand(o.nodes, o.img)
 	case 1:
 		o.step++
-		return prepareSchedulerFilesCommand{o.cluster, o.files, o.params}
+		return prepareSchedulerFilesCommand{o.cluster, o.files, o.params, o.certTTL}
 	case 2:
 		o.step++
 		return o.files
 	case 3:
 		o.step++
-		return common.RunContainerCommand(o.nodes, op.KubeSchedulerContainerName, cke.KubernetesImage,
+		return common.RunContainerCommand(o.nodes, op.KubeSchedulerContainerName, o.img,
 			common.WithParams(SchedulerParams()),
 			common.WithExtra(o.params.ServiceParams))
 	default:
@@ -62,10 +67,18 @@ func (o *schedulerBootOp) Targets() []string {
 	return ips
 }
 
+func (o *schedulerBootOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{op.KubeSchedulerContainerName},
+	}
+}
+
 type prepareSchedulerFilesCommand struct {
 	cluster string
 	files   *common.FilesBuilder
 	params  cke.SchedulerParams
+	certTTL time.Duration
 }
 
 func (c prepareSchedulerFilesCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
@@ -76,7 +89,7 @@ func (c prepareSchedulerFilesCommand) Run(ctx context.Context, inf cke.Infrastru
 		return err
 	}
 	g := func(ctx context.Context, n *cke.Node) ([]byte, error) {
-		crt, key, err := cke.KubernetesCA{}.IssueForScheduler(ctx, inf)
+		crt, key, err := cke.KubernetesCA{}.IssueForScheduler(ctx, inf, c.certTTL)
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,8 @@
 package k8s
 
 import (
+	"time"
+
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/op"
 	"github.com/cybozu-go/cke/op/common"
@@ -11,17 +13,21 @@ type schedulerRestartOp struct {
 
 	cluster string
 	params  cke.SchedulerParams
+	certTTL time.Duration
+	img     cke.Image
 
 	step  int
 	files *common.FilesBuilder
 }
 
 // SchedulerRestartOp returns an Operator to restart kube-scheduler
-func SchedulerRestartOp(nodes []*cke.Node, cluster string, params cke.SchedulerParams) cke.Operator {
+func SchedulerRestartOp(nodes []*cke.Node, cluster string, params cke.SchedulerParams, certTTL time.Duration, img cke.Image) cke.Operator {
 	return &schedulerRestartOp{
 		nodes:   nodes,
 		cluster: cluster,
 		params:  params,
+		certTTL: certTTL,
+		img:     img,
 		files:   common.NewFilesBuilder(nodes),
 	}
 }
@@ -34,16 +40,16 @@ func (o *schedulerRestartOp) NextCommand() cke.Commander {
 	switch o.step {
 	case 0:
 		o.step++
-		return common.ImagePullCommand(o.nodes, cke.KubernetesImage)
+		return common.ImagePullCommand(o.nodes, o.img)
 	case 1:
 		o.step++
-		return prepareSchedulerFilesCommand{o.cluster, o.files, o.params}
+		return prepareSchedulerFilesCommand{o.cluster, o.files, o.params, o.certTTL}
 	case 2:
 		o.step++
 		return o.files
 	case 3:
 		o.step++
-		return common.RunContainerCommand(o.nodes, op.KubeSchedulerContainerName, cke.KubernetesImage,
+		return common.RunContainerCommand(o.nodes, op.KubeSchedulerContainerName, o.img,
 			common.WithParams(SchedulerParams()),
 			common.WithExtra(o.params.ServiceParams),
 			common.WithRestart())
@@ -59,3 +65,10 @@ func (o *schedulerRestartOp) Targets() []string {
 	}
 	return ips
 }
+
+func (o *schedulerRestartOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{op.KubeSchedulerContainerName},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -13,17 +14,21 @@ import (
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/well"
 	corev1 "k8s.io/api/core/v1"
-	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	policyv1 "k8s.io/api/policy/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/client-go/kubernetes"
 )
 
-const defaultEvictionTimeoutSeconds = 600
+const (
+	defaultEvictionTimeoutSeconds = 600
+	evictionRetryInterval         = 5 * time.Second
+)
 
 type rebootOp struct {
 	apiserver *cke.Node
@@ -34,6 +39,8 @@ type rebootOp struct {
 
 	mu          sync.Mutex
 	failedNodes []string
+
+	hooks hookResults
 }
 
 func (o *rebootOp) notifyFailedNode(n *cke.Node) {
@@ -60,7 +67,7 @@ func (o *rebootOp) NextCommand() cke.Commander {
 	switch o.step {
 	case 0:
 		o.step++
-		return rebootStartCommand{index: o.index}
+		return rebootStartCommand{index: o.index, nodes: o.nodes}
 	case 1:
 		o.step++
 		nodeNames := make([]string, len(o.nodes))
@@ -73,14 +80,27 @@ func (o *rebootOp) NextCommand() cke.Commander {
 			unschedulable: true,
 		}
 	case 2:
+		o.step++
+		if c := o.hookCommand(cke.RebootHookPreDrain); c != nil {
+			return c
+		}
+		fallthrough
+	case 3:
 		o.step++
 		return drainCommand{
 			timeoutSeconds:      o.config.EvictionTimeoutSeconds,
 			apiserver:           o.apiserver,
 			nodes:               o.nodes,
 			protectedNamespaces: o.config.ProtectedNamespaces,
+			policy:              o.config.Drain,
 		}
-	case 3:
+	case 4:
+		o.step++
+		if c := o.hookCommand(cke.RebootHookPreReboot); c != nil {
+			return c
+		}
+		fallthrough
+	case 5:
 		o.step++
 		return rebootCommand{
 			command:          o.config.Command,
@@ -88,11 +108,29 @@ func (o *rebootOp) NextCommand() cke.Commander {
 			nodes:            o.nodes,
 			notifyFailedNode: o.notifyFailedNode,
 		}
+	case 6:
+		o.step++
+		return rebootVerifyStartCommand{index: o.index}
 	default:
 		return nil
 	}
 }
 
+// hookCommand returns a Commander to run hooks for stage, or nil if there are no hooks.
+func (o *rebootOp) hookCommand(stage cke.RebootHookStage) cke.Commander {
+	hooks := o.config.Hooks.Hooks(stage)
+	if len(hooks) == 0 {
+		return nil
+	}
+	return rebootHookCommand{
+		stage:     stage,
+		hooks:     hooks,
+		apiserver: o.apiserver,
+		nodes:     o.nodes,
+		results:   &o.hooks,
+	}
+}
+
 func (o *rebootOp) Targets() []string {
 	ipAddresses := make([]string, len(o.nodes))
 	for i, n := range o.nodes {
@@ -101,6 +139,16 @@ func (o *rebootOp) Targets() []string {
 	return ipAddresses
 }
 
+// rebootComponent is the component name in footprints of reboot operations.
+const rebootComponent = "reboot"
+
+func (o *rebootOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{rebootComponent},
+	}
+}
+
 func (o *rebootOp) Info() string {
 	if len(o.failedNodes) == 0 {
 		return ""
@@ -108,6 +156,10 @@ func (o *rebootOp) Info() string {
 	return fmt.Sprintf("failed to reboot some nodes: %v", o.failedNodes)
 }
 
+func (o *rebootOp) HookResults() []cke.HookResult {
+	return o.hooks.get()
+}
+
 type rebootUncordonOp struct {
 	apiserver *cke.Node
 	nodeNames []string
@@ -145,6 +197,7 @@ func (o *rebootUncordonOp) Targets() []string {
 
 type rebootStartCommand struct {
 	index int64
+	nodes []*cke.Node
 }
 
 func (c rebootStartCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
@@ -152,7 +205,26 @@ func (c rebootStartCommand) Run(ctx context.Context, inf cke.Infrastructure, _ s
 	if err != nil {
 		return err
 	}
+
+	// Boot IDs are compared with new ones to verify that nodes have been rebooted.
+	// Unreachable nodes are rebooted without recording them.
+	bootIDs := make(map[string]string)
+	for _, n := range c.nodes {
+		agent := inf.Agent(n.Address)
+		if agent == nil {
+			continue
+		}
+		bootID, err := GetBootID(agent)
+		if err != nil {
+			return fmt.Errorf("failed to read boot ID of %s: %w", n.Address, err)
+		}
+		bootIDs[n.Address] = bootID
+	}
+
 	entry.Status = cke.RebootStatusRebooting
+	entry.BootIDs = bootIDs
+	entry.Rebooted = nil
+	entry.FailedNodes = nil
 	return inf.Storage().UpdateRebootsEntry(ctx, entry)
 }
 
@@ -163,6 +235,28 @@ func (c rebootStartCommand) Command() cke.Command {
 	}
 }
 
+type rebootVerifyStartCommand struct {
+	index int64
+}
+
+func (c rebootVerifyStartCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	entry, err := inf.Storage().GetRebootsEntry(ctx, c.index)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	entry.Status = cke.RebootStatusVerifying
+	entry.Rebooted = &now
+	return inf.Storage().UpdateRebootsEntry(ctx, entry)
+}
+
+func (c rebootVerifyStartCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "rebootVerifyStartCommand",
+		Target: strconv.FormatInt(c.index, 10),
+	}
+}
+
 type cordonCommand struct {
 	apiserver     *cke.Node
 	nodeNames     []string
@@ -226,6 +320,7 @@ type drainCommand struct {
 	apiserver           *cke.Node
 	nodes               []*cke.Node
 	protectedNamespaces *metav1.LabelSelector
+	policy              cke.DrainPolicy
 }
 
 func listProtectedNamespaces(ctx context.Context, cs *kubernetes.Clientset, ls *metav1.LabelSelector) (map[string]bool, error) {
@@ -271,27 +366,91 @@ func checkJobPodNotExist(ctx context.Context, cs *kubernetes.Clientset, n *cke.N
 	return nil
 }
 
-func evictOrDeleteNodePod(ctx context.Context, cs *kubernetes.Clientset, n *cke.Node, protected map[string]bool) ([]*corev1.Pod, error) {
-	var targets []*corev1.Pod
+// drainTargets returns Pods to be drained among pods according to policy.
+// Pods managed by DaemonSets or Jobs and mirror Pods are excluded.
+func drainTargets(pods []corev1.Pod, policy cke.DrainPolicy) ([]*corev1.Pod, error) {
+	skip, err := metav1.LabelSelectorAsSelector(policy.SkipPods)
+	if err != nil {
+		// policy should have been validated
+		panic(err)
+	}
+	if policy.SkipPods == nil {
+		skip = labels.Nothing()
+	}
+
+	var targets, withEmptyDir []*corev1.Pod
+	for i := range pods {
+		pod := &pods[i]
+		owner := metav1.GetControllerOf(pod)
+		if owner != nil && (owner.Kind == "DaemonSet" || owner.Kind == "Job") {
+			continue
+		}
+		if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+			continue
+		}
+		if skip.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if hasEmptyDir(pod) {
+			switch policy.EmptyDir {
+			case cke.EmptyDirSkip:
+				continue
+			case cke.EmptyDirBlock:
+				withEmptyDir = append(withEmptyDir, pod)
+				continue
+			}
+		}
+		targets = append(targets, pod)
+	}
+
+	if len(withEmptyDir) > 0 {
+		return nil, fmt.Errorf("pods with emptyDir volumes exist: %s", strings.Join(podNames(withEmptyDir), ", "))
+	}
+	return targets, nil
+}
+
+func hasEmptyDir(pod *corev1.Pod) bool {
+	for _, v := range pod.Spec.Volumes {
+		if v.EmptyDir != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func podNames(pods []*corev1.Pod) []string {
+	names := make([]string, len(pods))
+	for i, pod := range pods {
+		names[i] = pod.Namespace + "/" + pod.Name
+	}
+	return names
+}
+
+func listNodePods(ctx context.Context, cs *kubernetes.Clientset, n *cke.Node) ([]corev1.Pod, error) {
 	podList, err := cs.CoreV1().Pods(corev1.NamespaceAll).List(ctx, metav1.ListOptions{
 		FieldSelector: fields.SelectorFromSet(fields.Set{"spec.nodeName": n.Nodename()}).String(),
 	})
 	if err != nil {
 		return nil, err
 	}
-	for _, pod := range podList.Items {
-		pod := pod
-		owner := metav1.GetControllerOf(&pod)
-		if owner != nil && (owner.Kind == "DaemonSet" || owner.Kind == "Job") {
-			continue
-		}
-		targets = append(targets, &pod)
-		err := cs.CoreV1().Pods(pod.Namespace).Evict(ctx, &policyv1beta1.Eviction{
-			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
-		})
+	return podList.Items, nil
+}
+
+func evictPod(ctx context.Context, cs *kubernetes.Clientset, pod *corev1.Pod) error {
+	return cs.PolicyV1().Evictions(pod.Namespace).Evict(ctx, &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+	})
+}
+
+// evictOrDeletePods evicts pods.  Pods not in protected namespaces are
+// deleted if they cannot be evicted.
+func evictOrDeletePods(ctx context.Context, cs *kubernetes.Clientset, pods []*corev1.Pod, protected map[string]bool) error {
+	for _, pod := range pods {
+		err := evictPod(ctx, cs, pod)
 		switch {
+		case err == nil:
 		case apierrors.IsNotFound(err):
-		case err != nil && !protected[pod.Namespace]:
+		case !protected[pod.Namespace]:
 			log.Warn("failed to evict non-protected pod", map[string]interface{}{
 				"namespace": pod.Namespace,
 				"name":      pod.Name,
@@ -299,25 +458,123 @@ func evictOrDeleteNodePod(ctx context.Context, cs *kubernetes.Clientset, n *cke.
 			})
 			err := cs.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
 			if err != nil {
-				return nil, err
+				return err
 			}
 			log.Warn("deleted non-protected pod", map[string]interface{}{
 				"namespace": pod.Namespace,
 				"name":      pod.Name,
 			})
-		case err != nil:
-			return nil, fmt.Errorf("failed to evict pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		case apierrors.IsTooManyRequests(err):
+			return newDrainError(ctx, cs, []*corev1.Pod{pod})
+		default:
+			return fmt.Errorf("failed to evict pod %s/%s: %w", pod.Namespace, pod.Name, err)
 		}
 	}
-	return targets, nil
+	return nil
 }
 
-func waitPodDeletion(ctx context.Context, cs *kubernetes.Clientset, pods []*corev1.Pod, ts *int) error {
-	timeoutSeconds := defaultEvictionTimeoutSeconds
-	if ts != nil {
-		timeoutSeconds = *ts
+// evictPods evicts pods without deleting them.  Evictions rejected by
+// PodDisruptionBudgets are retried until the eviction timeout.
+func evictPods(ctx context.Context, cs *kubernetes.Clientset, pods []*corev1.Pod, ts *int) error {
+	evictCtx, cancel := context.WithTimeout(ctx, evictionTimeout(ts))
+	defer cancel()
+
+	for {
+		var blocked []*corev1.Pod
+		for _, pod := range pods {
+			err := evictPod(evictCtx, cs, pod)
+			switch {
+			case err == nil:
+			case apierrors.IsNotFound(err):
+			case apierrors.IsTooManyRequests(err), evictCtx.Err() != nil:
+				blocked = append(blocked, pod)
+			default:
+				return fmt.Errorf("failed to evict pod %s/%s: %w", pod.Namespace, pod.Name, err)
+			}
+		}
+		if len(blocked) == 0 {
+			return nil
+		}
+		pods = blocked
+
+		select {
+		case <-evictCtx.Done():
+			return newDrainError(ctx, cs, blocked)
+		case <-time.After(evictionRetryInterval):
+			log.Info("retrying eviction blocked by PodDisruptionBudgets", map[string]interface{}{
+				"pods": strings.Join(podNames(blocked), ","),
+			})
+		}
+	}
+}
+
+// drainError is returned when pods cannot be evicted.
+type drainError struct {
+	pods []string
+	pdbs []string
+}
+
+func newDrainError(ctx context.Context, cs *kubernetes.Clientset, pods []*corev1.Pod) *drainError {
+	return &drainError{
+		pods: podNames(pods),
+		pdbs: blockingPDBs(ctx, cs, pods),
+	}
+}
+
+func (e *drainError) Error() string {
+	msg := "pods could not be evicted: " + strings.Join(e.pods, ", ")
+	if len(e.pdbs) > 0 {
+		msg += "; blocking PodDisruptionBudgets: " + strings.Join(e.pdbs, ", ")
+	}
+	return msg
+}
+
+// blockingPDBs returns the names of PodDisruptionBudgets that select pods.
+func blockingPDBs(ctx context.Context, cs *kubernetes.Clientset, pods []*corev1.Pod) []string {
+	pdbs := make(map[string]bool)
+	lists := make(map[string][]policyv1.PodDisruptionBudget)
+	for _, pod := range pods {
+		list, ok := lists[pod.Namespace]
+		if !ok {
+			l, err := cs.PolicyV1().PodDisruptionBudgets(pod.Namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				log.Warn("failed to list PodDisruptionBudgets", map[string]interface{}{
+					log.FnError: err,
+					"namespace": pod.Namespace,
+				})
+				continue
+			}
+			list = l.Items
+			lists[pod.Namespace] = list
+		}
+		for _, pdb := range list {
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err != nil || selector.Empty() {
+				continue
+			}
+			if selector.Matches(labels.Set(pod.Labels)) {
+				pdbs[pdb.Namespace+"/"+pdb.Name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(pdbs))
+	for name := range pdbs {
+		names = append(names, name)
 	}
-	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(timeoutSeconds))
+	sort.Strings(names)
+	return names
+}
+
+func evictionTimeout(ts *int) time.Duration {
+	if ts == nil {
+		return defaultEvictionTimeoutSeconds * time.Second
+	}
+	return time.Duration(*ts) * time.Second
+}
+
+func waitPodDeletion(ctx context.Context, cs *kubernetes.Clientset, pods []*corev1.Pod, ts *int) error {
+	ctx, cancel := context.WithTimeout(ctx, evictionTimeout(ts))
 	defer cancel()
 
 OUTER:
@@ -353,11 +610,6 @@ func (c drainCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string)
 		return err
 	}
 
-	protected, err := listProtectedNamespaces(ctx, cs, c.protectedNamespaces)
-	if err != nil {
-		return err
-	}
-
 	for _, n := range c.nodes {
 		err := checkJobPodNotExist(ctx, cs, n)
 		if err != nil {
@@ -367,11 +619,29 @@ func (c drainCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string)
 
 	var targets []*corev1.Pod
 	for _, n := range c.nodes {
-		pods, err := evictOrDeleteNodePod(ctx, cs, n, protected)
+		pods, err := listNodePods(ctx, cs, n)
+		if err != nil {
+			return err
+		}
+		nodeTargets, err := drainTargets(pods, c.policy)
 		if err != nil {
 			return err
 		}
-		targets = append(targets, pods...)
+		targets = append(targets, nodeTargets...)
+	}
+
+	if c.policy.Mode == cke.DrainEvictOnly {
+		err = evictPods(ctx, cs, targets, c.timeoutSeconds)
+	} else {
+		var protected map[string]bool
+		protected, err = listProtectedNamespaces(ctx, cs, c.protectedNamespaces)
+		if err != nil {
+			return err
+		}
+		err = evictOrDeletePods(ctx, cs, targets, protected)
+	}
+	if err != nil {
+		return err
 	}
 
 	return waitPodDeletion(ctx, cs, targets, c.timeoutSeconds)
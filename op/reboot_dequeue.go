@@ -10,13 +10,16 @@ import (
 
 type rebootDequeueOp struct {
 	index    int64
+	nodes    []string
 	finished bool
 }
 
 // RebootDequeueOp returns an Operator to dequeue a reboot entry.
-func RebootDequeueOp(index int64) cke.Operator {
+// nodes are the addresses of nodes in the entry.
+func RebootDequeueOp(index int64, nodes []string) cke.Operator {
 	return &rebootDequeueOp{
 		index: index,
+		nodes: nodes,
 	}
 }
 
@@ -37,6 +40,13 @@ func (o *rebootDequeueOp) Targets() []string {
 	return nil
 }
 
+func (o *rebootDequeueOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.nodes,
+		Components: []string{rebootComponent},
+	}
+}
+
 type rebootDequeueCommand struct {
 	index int64
 }
@@ -0,0 +1,76 @@
+package op
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/cybozu-go/cke"
+)
+
+type rebootFailOp struct {
+	index       int64
+	nodes       []string
+	failedNodes []string
+	finished    bool
+}
+
+// RebootFailOp returns an Operator to mark a reboot entry as failed.
+// nodes are the addresses of nodes in the entry, and failedNodes are
+// those of nodes that did not come back after reboot.
+func RebootFailOp(index int64, nodes, failedNodes []string) cke.Operator {
+	return &rebootFailOp{
+		index:       index,
+		nodes:       nodes,
+		failedNodes: failedNodes,
+	}
+}
+
+func (o *rebootFailOp) Name() string {
+	return "reboot-fail"
+}
+
+func (o *rebootFailOp) NextCommand() cke.Commander {
+	if o.finished {
+		return nil
+	}
+
+	o.finished = true
+	return rebootFailCommand{index: o.index, failedNodes: o.failedNodes}
+}
+
+func (o *rebootFailOp) Targets() []string {
+	return nil
+}
+
+func (o *rebootFailOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.nodes,
+		Components: []string{rebootComponent},
+	}
+}
+
+type rebootFailCommand struct {
+	index       int64
+	failedNodes []string
+}
+
+func (c rebootFailCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	entry, err := inf.Storage().GetRebootsEntry(ctx, c.index)
+	if err != nil {
+		return err
+	}
+	if entry.Status != cke.RebootStatusVerifying {
+		// The entry has been cancelled.
+		return nil
+	}
+	entry.Status = cke.RebootStatusFailed
+	entry.FailedNodes = c.failedNodes
+	return inf.Storage().UpdateRebootsEntry(ctx, entry)
+}
+
+func (c rebootFailCommand) Command() cke.Command {
+	return cke.Command{
+		Name:   "rebootFailCommand",
+		Target: strconv.FormatInt(c.index, 10),
+	}
+}
@@ -0,0 +1,369 @@
+package op
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/log"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	rebootHookRetryInterval = 10 * time.Second
+	rebootHookJobInterval   = 5 * time.Second
+
+	// Labels of Jobs created by reboot hooks.
+	rebootHookLabelName  = "cke.cybozu.com/reboot-hook"
+	rebootHookLabelStage = "cke.cybozu.com/reboot-stage"
+	rebootHookLabelNode  = "cke.cybozu.com/reboot-node"
+)
+
+// hookResults collects the results of hooks run by an operation.
+type hookResults struct {
+	mu      sync.Mutex
+	results []cke.HookResult
+}
+
+func (r *hookResults) add(result cke.HookResult) {
+	r.mu.Lock()
+	r.results = append(r.results, result)
+	r.mu.Unlock()
+}
+
+func (r *hookResults) get() []cke.HookResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]cke.HookResult(nil), r.results...)
+}
+
+type rebootPostHookOp struct {
+	apiserver *cke.Node
+	nodes     []*cke.Node
+	index     int64
+	hooks     []cke.RebootHook
+	step      int
+
+	results hookResults
+}
+
+// RebootPostHookOp returns an Operator to run post-reboot hooks for the
+// verified nodes and then dequeue the reboot entry.
+// If the hooks abort, the entry is marked as failed instead.
+func RebootPostHookOp(apiserver *cke.Node, nodes []*cke.Node, index int64, config *cke.Reboot) cke.HookOperator {
+	return &rebootPostHookOp{
+		apiserver: apiserver,
+		nodes:     nodes,
+		index:     index,
+		hooks:     config.Hooks.PostReboot,
+	}
+}
+
+func (o *rebootPostHookOp) Name() string {
+	return "reboot-post-hook"
+}
+
+func (o *rebootPostHookOp) NextCommand() cke.Commander {
+	switch o.step {
+	case 0:
+		o.step++
+		return rebootPostHookCommand{
+			rebootHookCommand: rebootHookCommand{
+				stage:     cke.RebootHookPostReboot,
+				hooks:     o.hooks,
+				apiserver: o.apiserver,
+				nodes:     o.nodes,
+				results:   &o.results,
+			},
+			index: o.index,
+		}
+	case 1:
+		o.step++
+		return rebootDequeueCommand{index: o.index}
+	default:
+		return nil
+	}
+}
+
+func (o *rebootPostHookOp) Targets() []string {
+	ipAddresses := make([]string, len(o.nodes))
+	for i, n := range o.nodes {
+		ipAddresses[i] = n.Address
+	}
+	return ipAddresses
+}
+
+func (o *rebootPostHookOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{rebootComponent},
+	}
+}
+
+func (o *rebootPostHookOp) HookResults() []cke.HookResult {
+	return o.results.get()
+}
+
+// rebootPostHookCommand runs post-reboot hooks.  If they abort, this marks
+// the entry as failed so that it does not block other entries.
+type rebootPostHookCommand struct {
+	rebootHookCommand
+	index int64
+}
+
+func (c rebootPostHookCommand) Run(ctx context.Context, inf cke.Infrastructure, leaderKey string) error {
+	err := c.rebootHookCommand.Run(ctx, inf, leaderKey)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	var failedNodes []string
+	for _, r := range c.results.get() {
+		if r.Error != "" && !r.Skipped {
+			failedNodes = append(failedNodes, r.Node)
+		}
+	}
+	log.Error("post-reboot hooks failed", map[string]interface{}{
+		log.FnError: err,
+		"index":     c.index,
+		"nodes":     failedNodes,
+	})
+	if ferr := (rebootFailCommand{index: c.index, failedNodes: failedNodes}).Run(ctx, inf, leaderKey); ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+// rebootHookPayload is the body of requests sent by webhooks.
+type rebootHookPayload struct {
+	Stage cke.RebootHookStage `json:"stage"`
+	Node  *cke.Node           `json:"node"`
+}
+
+type rebootHookCommand struct {
+	stage     cke.RebootHookStage
+	hooks     []cke.RebootHook
+	apiserver *cke.Node
+	nodes     []*cke.Node
+	results   *hookResults
+}
+
+// Run runs each hook for each node in order.
+// It stops at the first failure of a hook whose failure policy is not "skip".
+func (c rebootHookCommand) Run(ctx context.Context, inf cke.Infrastructure, _ string) error {
+	for _, h := range c.hooks {
+		for _, n := range c.nodes {
+			result := cke.HookResult{
+				Name:    h.Name,
+				Type:    h.Type(),
+				Stage:   c.stage,
+				Node:    n.Address,
+				StartAt: time.Now().UTC(),
+			}
+			err := c.runWithRetry(ctx, inf, h, n, &result)
+			result.EndAt = time.Now().UTC()
+			if err != nil {
+				result.Error = err.Error()
+				result.Skipped = h.Policy() == cke.HookFailureSkip
+			}
+			c.results.add(result)
+
+			if err == nil {
+				continue
+			}
+			if result.Skipped {
+				log.Warn("reboot hook failed; skipped", map[string]interface{}{
+					log.FnError: err,
+					"hook":      h.Name,
+					"stage":     c.stage,
+					"node":      n.Address,
+				})
+				continue
+			}
+			return fmt.Errorf("%s hook %s failed for %s: %w", c.stage, h.Name, n.Address, err)
+		}
+	}
+	return nil
+}
+
+func (c rebootHookCommand) runWithRetry(ctx context.Context, inf cke.Infrastructure, h cke.RebootHook, n *cke.Node, result *cke.HookResult) error {
+	var err error
+	for i := 0; i < h.Attempts(); i++ {
+		if i > 0 {
+			log.Warn("reboot hook failed; retrying", map[string]interface{}{
+				log.FnError: err,
+				"hook":      h.Name,
+				"stage":     c.stage,
+				"node":      n.Address,
+			})
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(rebootHookRetryInterval):
+			}
+		}
+		result.Attempts++
+		err = c.runOnce(ctx, inf, h, n)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (c rebootHookCommand) runOnce(ctx context.Context, inf cke.Infrastructure, h cke.RebootHook, n *cke.Node) error {
+	switch {
+	case h.Webhook != nil:
+		return c.runWebhook(ctx, h, n)
+	case h.Command != "":
+		return c.runNodeCommand(inf, h, n)
+	case h.Job != nil:
+		return c.runJob(ctx, inf, h, n)
+	}
+	// hooks should have been validated
+	return fmt.Errorf("hook %s has nothing to run", h.Name)
+}
+
+func (c rebootHookCommand) runWebhook(ctx context.Context, h cke.RebootHook, n *cke.Node) error {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout())
+	defer cancel()
+
+	data, err := json.Marshal(rebootHookPayload{Stage: c.stage, Node: n})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.Webhook.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (c rebootHookCommand) runNodeCommand(inf cke.Infrastructure, h cke.RebootHook, n *cke.Node) error {
+	agent := inf.Agent(n.Address)
+	if agent == nil {
+		return fmt.Errorf("unable to prepare agent for %s", n.Address)
+	}
+	_, stderr, err := agent.RunWithTimeout(h.Command, "", h.Timeout())
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(stderr)))
+	}
+	return nil
+}
+
+// rebootHookJob returns a Job to run h for n.
+// Information of the node is given to containers through environment variables.
+func rebootHookJob(stage cke.RebootHookStage, h cke.RebootHook, n *cke.Node) *batchv1.Job {
+	labels := map[string]string{
+		rebootHookLabelName:  h.Name,
+		rebootHookLabelStage: string(stage),
+		rebootHookLabelNode:  n.Nodename(),
+	}
+	spec := h.Job.Spec.DeepCopy()
+	env := []corev1.EnvVar{
+		{Name: "CKE_REBOOT_STAGE", Value: string(stage)},
+		{Name: "CKE_NODE_NAME", Value: n.Nodename()},
+		{Name: "CKE_NODE_ADDRESS", Value: n.Address},
+	}
+	for i := range spec.Template.Spec.InitContainers {
+		c := &spec.Template.Spec.InitContainers[i]
+		c.Env = append(c.Env, env...)
+	}
+	for i := range spec.Template.Spec.Containers {
+		c := &spec.Template.Spec.Containers[i]
+		c.Env = append(c.Env, env...)
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "cke-reboot-" + h.Name + "-",
+			Namespace:    h.Job.Namespace,
+			Labels:       labels,
+		},
+		Spec: *spec,
+	}
+}
+
+func (c rebootHookCommand) runJob(ctx context.Context, inf cke.Infrastructure, h cke.RebootHook, n *cke.Node) error {
+	cs, err := inf.K8sClient(ctx, c.apiserver)
+	if err != nil {
+		return err
+	}
+	jobsAPI := cs.BatchV1().Jobs(h.Job.Namespace)
+
+	job, err := jobsAPI.Create(ctx, rebootHookJob(c.stage, h, n), metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		policy := metav1.DeletePropagationBackground
+		err := jobsAPI.Delete(ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &policy})
+		if err != nil && !apierrors.IsNotFound(err) {
+			log.Warn("failed to delete reboot hook job", map[string]interface{}{
+				log.FnError: err,
+				"namespace": job.Namespace,
+				"name":      job.Name,
+			})
+		}
+	}()
+
+	timeout := time.After(h.Timeout())
+	for {
+		j, err := jobsAPI.Get(ctx, job.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		for _, cond := range j.Status.Conditions {
+			if cond.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch cond.Type {
+			case batchv1.JobComplete:
+				return nil
+			case batchv1.JobFailed:
+				return fmt.Errorf("job %s/%s failed: %s", j.Namespace, j.Name, cond.Message)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("job %s/%s did not complete in time", j.Namespace, j.Name)
+		case <-time.After(rebootHookJobInterval):
+		}
+	}
+}
+
+func (c rebootHookCommand) Command() cke.Command {
+	ipAddresses := make([]string, len(c.nodes))
+	for i, n := range c.nodes {
+		ipAddresses[i] = n.Address
+	}
+	return cke.Command{
+		Name:   "rebootHookCommand",
+		Target: string(c.stage) + ":" + strings.Join(ipAddresses, ","),
+	}
+}
@@ -0,0 +1,107 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/cybozu-go/cke"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRebootHookWebhook(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var received []rebootHookPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p rebootHookPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, p)
+		mu.Unlock()
+		if p.Node.Address == "10.0.0.2" {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	nodes := []*cke.Node{{Address: "10.0.0.1"}, {Address: "10.0.0.2"}}
+	run := func(policy cke.HookFailurePolicy) ([]cke.HookResult, error) {
+		results := &hookResults{}
+		c := rebootHookCommand{
+			stage: cke.RebootHookPreDrain,
+			hooks: []cke.RebootHook{
+				{Name: "lb", Webhook: &cke.RebootHookWebhook{URL: ts.URL}, FailurePolicy: policy},
+			},
+			nodes:   nodes,
+			results: results,
+		}
+		err := c.Run(context.Background(), nil, "")
+		return results.get(), err
+	}
+
+	results, err := run(cke.HookFailureAbort)
+	if err == nil {
+		t.Error("abort policy should return an error")
+	}
+	if len(results) != 2 {
+		t.Fatal("unexpected results:", results)
+	}
+	if results[0].Error != "" || results[0].Attempts != 1 || results[0].Type != "webhook" {
+		t.Error("unexpected result for 10.0.0.1:", results[0])
+	}
+	if results[1].Error == "" || results[1].Skipped {
+		t.Error("unexpected result for 10.0.0.2:", results[1])
+	}
+
+	results, err = run(cke.HookFailureSkip)
+	if err != nil {
+		t.Error("skip policy should not return an error:", err)
+	}
+	if len(results) != 2 || !results[1].Skipped {
+		t.Error("unexpected results:", results)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 4 || received[0].Stage != cke.RebootHookPreDrain {
+		t.Error("unexpected requests:", received)
+	}
+}
+
+func TestRebootHookJob(t *testing.T) {
+	t.Parallel()
+
+	h := cke.RebootHook{
+		Name: "rebalance",
+		Job:  &cke.RebootHookJob{Namespace: "storage"},
+	}
+	h.Job.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "busybox"}}
+	n := &cke.Node{Address: "10.0.0.1", Hostname: "node1"}
+
+	job := rebootHookJob(cke.RebootHookPreReboot, h, n)
+	if job.Namespace != "storage" || job.GenerateName != "cke-reboot-rebalance-" {
+		t.Error("unexpected metadata:", job.ObjectMeta)
+	}
+	if job.Labels[rebootHookLabelNode] != "node1" {
+		t.Error("unexpected labels:", job.Labels)
+	}
+
+	env := make(map[string]string)
+	for _, e := range job.Spec.Template.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if env["CKE_REBOOT_STAGE"] != "pre-reboot" || env["CKE_NODE_NAME"] != "node1" || env["CKE_NODE_ADDRESS"] != "10.0.0.1" {
+		t.Error("unexpected env:", env)
+	}
+	if len(h.Job.Spec.Template.Spec.Containers[0].Env) != 0 {
+		t.Error("the hook template should not be modified")
+	}
+}
@@ -0,0 +1,105 @@
+package op
+
+import (
+	"testing"
+
+	"github.com/cybozu-go/cke"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDrainTargets(t *testing.T) {
+	t.Parallel()
+
+	isController := true
+	pod := func(name string, f func(*corev1.Pod)) corev1.Pod {
+		p := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+		if f != nil {
+			f(&p)
+		}
+		return p
+	}
+	ownedBy := func(kind string) func(*corev1.Pod) {
+		return func(p *corev1.Pod) {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: kind, Name: "owner", Controller: &isController}}
+		}
+	}
+	pods := []corev1.Pod{
+		pod("plain", nil),
+		pod("replicaset", ownedBy("ReplicaSet")),
+		pod("daemonset", ownedBy("DaemonSet")),
+		pod("job", ownedBy("Job")),
+		pod("mirror", func(p *corev1.Pod) {
+			p.Annotations = map[string]string{corev1.MirrorPodAnnotationKey: "hash"}
+		}),
+		pod("labeled", func(p *corev1.Pod) {
+			p.Labels = map[string]string{"drain": "skip"}
+		}),
+		pod("emptydir", func(p *corev1.Pod) {
+			p.Spec.Volumes = []corev1.Volume{{
+				Name:         "tmp",
+				VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+			}}
+		}),
+	}
+
+	testCases := []struct {
+		name     string
+		policy   cke.DrainPolicy
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "default",
+			expected: []string{"default/plain", "default/replicaset", "default/labeled", "default/emptydir"},
+		},
+		{
+			name:     "skip pods",
+			policy:   cke.DrainPolicy{SkipPods: &metav1.LabelSelector{MatchLabels: map[string]string{"drain": "skip"}}},
+			expected: []string{"default/plain", "default/replicaset", "default/emptydir"},
+		},
+		{
+			name:     "skip emptyDir",
+			policy:   cke.DrainPolicy{EmptyDir: cke.EmptyDirSkip},
+			expected: []string{"default/plain", "default/replicaset", "default/labeled"},
+		},
+		{
+			name:   "block emptyDir",
+			policy: cke.DrainPolicy{EmptyDir: cke.EmptyDirBlock},
+			err:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			targets, err := drainTargets(pods, tc.policy)
+			if tc.err {
+				if err == nil {
+					t.Error("error is expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := podNames(targets); !cmp.Equal(tc.expected, actual) {
+				t.Error("unexpected targets:", cmp.Diff(tc.expected, actual))
+			}
+		})
+	}
+}
+
+func TestDrainError(t *testing.T) {
+	t.Parallel()
+
+	err := &drainError{
+		pods: []string{"default/a", "default/b"},
+		pdbs: []string{"default/pdb"},
+	}
+	expected := "pods could not be evicted: default/a, default/b; blocking PodDisruptionBudgets: default/pdb"
+	if err.Error() != expected {
+		t.Error("unexpected message:", err.Error())
+	}
+}
@@ -70,3 +70,10 @@ func (o *riversBootOp) Targets() []string {
 	}
 	return ips
 }
+
+func (o *riversBootOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{o.name},
+	}
+}
@@ -56,3 +56,10 @@ func (o *riversRestartOp) Targets() []string {
 	}
 	return ips
 }
+
+func (o *riversRestartOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{o.name},
+	}
+}
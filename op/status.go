@@ -1,7 +1,10 @@
 package op
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
@@ -10,6 +13,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/static"
@@ -25,6 +29,7 @@ import (
 	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
 	proxyv1alpha1 "k8s.io/kube-proxy/config/v1alpha1"
 	schedulerv1beta1 "k8s.io/kube-scheduler/config/v1beta1"
 	kubeletv1beta1 "k8s.io/kubelet/config/v1beta1"
@@ -32,29 +37,84 @@ import (
 
 var decUnstructured = yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
 
+// checkContainerEngine returns an error if CKE-managed containers or the etcd
+// volume are found in a container engine other than the configured one.
+//
+// This happens when options.container-engine is changed for a running
+// cluster.  CKE would otherwise bootstrap the node again with the new
+// engine while the old containers keep running, or start etcd with no data.
+func checkContainerEngine(agent cke.Agent, engine string, names []string, volume string) error {
+	if engine == "" {
+		engine = cke.EngineDocker
+	}
+	for _, other := range []string{cke.EngineDocker, cke.EngineContainerd} {
+		if other == engine {
+			continue
+		}
+		ce := cke.NewContainerEngine(other, agent)
+		ss, err := ce.Inspect(names)
+		if err != nil {
+			// the engine is not installed on the node.
+			continue
+		}
+		if len(ss) > 0 {
+			return fmt.Errorf("CKE-managed containers exist in %s, but the cluster is configured to use %s", other, engine)
+		}
+		exists, err := ce.VolumeExists(volume)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("etcd volume %s exists in %s, but the cluster is configured to use %s", volume, other, engine)
+		}
+	}
+	return nil
+}
+
 // GetNodeStatus returns NodeStatus.
-func GetNodeStatus(ctx context.Context, inf cke.Infrastructure, node *cke.Node, cluster *cke.Cluster) (*cke.NodeStatus, error) {
+// Configuration files and certificates of components are read through cache.
+// cache may be nil.
+func GetNodeStatus(ctx context.Context, inf cke.Infrastructure, node *cke.Node, cluster *cke.Cluster, cache *NodeStatusCache) (*cke.NodeStatus, error) {
 	status := &cke.NodeStatus{}
 	agent := inf.Agent(node.Address)
 	status.SSHConnected = agent != nil
 	if !status.SSHConnected {
+		if err := inf.AgentError(node.Address); err != nil {
+			status.SSHError = err.Error()
+		}
 		return status, nil
 	}
 
-	ce := inf.Engine(node.Address)
-	ss, err := ce.Inspect([]string{
+	bootID, err := GetBootID(agent)
+	if err != nil {
+		log.Warn("failed to read boot ID", map[string]interface{}{
+			log.FnError: err,
+			"node":      node.Address,
+		})
+	}
+	status.BootID = bootID
+
+	names := []string{
 		EtcdContainerName,
 		RiversContainerName,
 		EtcdRiversContainerName,
 		KubeAPIServerContainerName,
+		KMSPluginContainerName,
 		KubeControllerManagerContainerName,
 		KubeSchedulerContainerName,
 		KubeProxyContainerName,
 		KubeletContainerName,
-	})
+	}
+	ce := inf.Engine(node.Address)
+	ss, err := ce.Inspect(names)
 	if err != nil {
 		return nil, err
 	}
+	if len(ss) == 0 {
+		if err := checkContainerEngine(agent, cluster.Options.ContainerEngine, names, EtcdVolumeName(cluster.Options.Etcd)); err != nil {
+			return nil, err
+		}
+	}
 
 	etcdVolumeExists, err := ce.VolumeExists(EtcdVolumeName(cluster.Options.Etcd))
 	if err != nil {
@@ -72,6 +132,7 @@ func GetNodeStatus(ctx context.Context, inf cke.Infrastructure, node *cke.Node,
 	}
 	status.Rivers = ss[RiversContainerName]
 	status.EtcdRivers = ss[EtcdRiversContainerName]
+	status.KMSPlugin = ss[KMSPluginContainerName]
 
 	status.APIServer = cke.KubeComponentStatus{
 		ServiceStatus: ss[KubeAPIServerContainerName],
@@ -115,7 +176,22 @@ func GetNodeStatus(ctx context.Context, inf cke.Infrastructure, node *cke.Node,
 			})
 		}
 
-		cfgData, _, err := agent.Run(fmt.Sprintf("cat %s", SchedulerConfigPath))
+		v, err := cache.get(node.Address, KubeSchedulerContainerName, status.Scheduler.ContainerID, func() (interface{}, error) {
+			cfgData, _, err := agent.Run(fmt.Sprintf("cat %s", SchedulerConfigPath))
+			if err != nil {
+				return nil, err
+			}
+			config := &schedulerv1beta1.KubeSchedulerConfiguration{}
+			_, _, err = decUnstructured.Decode(cfgData, nil, config)
+			if err != nil {
+				return (*schedulerv1beta1.KubeSchedulerConfiguration)(nil), nil
+			}
+			// Nullify TypeMeta for later comparison using reflect.DeepEqual
+			if config.APIVersion == schedulerv1beta1.SchemeGroupVersion.String() {
+				config.TypeMeta = metav1.TypeMeta{}
+			}
+			return config, nil
+		})
 		if err != nil {
 			log.Error("failed to cat "+SchedulerConfigPath, map[string]interface{}{
 				log.FnError: err,
@@ -123,15 +199,7 @@ func GetNodeStatus(ctx context.Context, inf cke.Infrastructure, node *cke.Node,
 			})
 			return nil, err
 		}
-		config := &schedulerv1beta1.KubeSchedulerConfiguration{}
-		_, _, err = decUnstructured.Decode(cfgData, nil, config)
-		if err == nil {
-			// Nullify TypeMeta for later comparison using reflect.DeepEqual
-			if config.APIVersion == schedulerv1beta1.SchemeGroupVersion.String() {
-				config.TypeMeta = metav1.TypeMeta{}
-			}
-			status.Scheduler.Config = config
-		}
+		status.Scheduler.Config = v.(*schedulerv1beta1.KubeSchedulerConfiguration)
 	}
 
 	status.Proxy = cke.ProxyStatus{
@@ -147,17 +215,24 @@ func GetNodeStatus(ctx context.Context, inf cke.Infrastructure, node *cke.Node,
 			})
 		}
 
-		cfgData, _, err := agent.Run("cat /etc/kubernetes/proxy/config.yml")
-		if err == nil {
+		v, err := cache.get(node.Address, KubeProxyContainerName, status.Proxy.ContainerID, func() (interface{}, error) {
+			cfgData, _, err := agent.Run("cat /etc/kubernetes/proxy/config.yml")
+			if err != nil {
+				return nil, err
+			}
 			var v proxyv1alpha1.KubeProxyConfiguration
 			_, _, err = decUnstructured.Decode(cfgData, nil, &v)
-			if err == nil {
-				// Nullify TypeMeta for later comparison using reflect.DeepEqual
-				if v.APIVersion == proxyv1alpha1.SchemeGroupVersion.String() {
-					v.TypeMeta = metav1.TypeMeta{}
-				}
-				status.Proxy.Config = &v
+			if err != nil {
+				return (*proxyv1alpha1.KubeProxyConfiguration)(nil), nil
+			}
+			// Nullify TypeMeta for later comparison using reflect.DeepEqual
+			if v.APIVersion == proxyv1alpha1.SchemeGroupVersion.String() {
+				v.TypeMeta = metav1.TypeMeta{}
 			}
+			return &v, nil
+		})
+		if err == nil {
+			status.Proxy.Config = v.(*proxyv1alpha1.KubeProxyConfiguration)
 		}
 	}
 
@@ -174,23 +249,129 @@ func GetNodeStatus(ctx context.Context, inf cke.Infrastructure, node *cke.Node,
 			})
 		}
 
-		cfgData, _, err := agent.Run("cat /etc/kubernetes/kubelet/config.yml")
-		if err == nil {
+		v, err := cache.get(node.Address, KubeletContainerName, status.Kubelet.ContainerID, func() (interface{}, error) {
+			cfgData, _, err := agent.Run("cat /etc/kubernetes/kubelet/config.yml")
+			if err != nil {
+				return nil, err
+			}
 			var v kubeletv1beta1.KubeletConfiguration
 			_, _, err = decUnstructured.Decode(cfgData, nil, &v)
-			if err == nil {
-				// Nullify TypeMeta for later comparison using reflect.DeepEqual
-				if v.APIVersion == kubeletv1beta1.SchemeGroupVersion.String() {
-					v.TypeMeta = metav1.TypeMeta{}
-				}
-				status.Kubelet.Config = &v
+			if err != nil {
+				return (*kubeletv1beta1.KubeletConfiguration)(nil), nil
 			}
+			// Nullify TypeMeta for later comparison using reflect.DeepEqual
+			if v.APIVersion == kubeletv1beta1.SchemeGroupVersion.String() {
+				v.TypeMeta = metav1.TypeMeta{}
+			}
+			return &v, nil
+		})
+		if err == nil {
+			status.Kubelet.Config = v.(*kubeletv1beta1.KubeletConfiguration)
 		}
 	}
 
+	status.CertificateExpiry = getCertificateExpiry(agent, node, ss, cache)
+
 	return status, nil
 }
 
+// GetBootID returns the boot ID of the node of agent.
+func GetBootID(agent cke.Agent) (string, error) {
+	data, _, err := agent.Run("cat /proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// certificateFiles is the list of certificate files of each component.
+// Kubeconfig files embed client certificates.
+var certificateFiles = map[string][]string{
+	EtcdContainerName:                  {EtcdPKIPath("server.crt"), EtcdPKIPath("peer.crt")},
+	KubeAPIServerContainerName:         {K8sPKIPath("apiserver.crt"), K8sPKIPath("apiserver-etcd-client.crt"), K8sPKIPath("aggregation.crt")},
+	KubeControllerManagerContainerName: {ControllerManagerKubeConfigPath},
+	KubeSchedulerContainerName:         {SchedulerKubeConfigPath},
+	KubeProxyContainerName:             {ProxyKubeConfigPath},
+	KubeletContainerName:               {K8sPKIPath("kubelet.crt")},
+}
+
+func getCertificateExpiry(agent cke.Agent, node *cke.Node, ss map[string]cke.ServiceStatus, cache *NodeStatusCache) map[string]time.Time {
+	expiry := make(map[string]time.Time)
+	for component, files := range certificateFiles {
+		st := ss[component]
+		if !st.Running {
+			continue
+		}
+
+		v, err := cache.get(node.Address, component+"/certificates", st.ContainerID, func() (interface{}, error) {
+			data, _, err := agent.Run("cat " + strings.Join(files, " "))
+			if err != nil {
+				log.Warn("failed to read certificates", map[string]interface{}{
+					log.FnError: err,
+					"node":      node.Address,
+					"component": component,
+				})
+				return nil, err
+			}
+			t, err := EarliestCertificateExpiry(data)
+			if err != nil {
+				log.Warn("failed to parse certificates", map[string]interface{}{
+					log.FnError: err,
+					"node":      node.Address,
+					"component": component,
+				})
+				return nil, err
+			}
+			return t, nil
+		})
+		if err != nil {
+			continue
+		}
+		expiry[component] = v.(time.Time)
+	}
+	return expiry
+}
+
+// EarliestCertificateExpiry returns the earliest expiration time of the
+// certificates in data.  data is either PEM-encoded certificates or a
+// kubeconfig that embeds client certificates.
+func EarliestCertificateExpiry(data []byte) (time.Time, error) {
+	if !bytes.Contains(data, []byte("-----BEGIN ")) {
+		cfg, err := clientcmd.Load(data)
+		if err != nil {
+			return time.Time{}, err
+		}
+		var certs []byte
+		for _, ai := range cfg.AuthInfos {
+			certs = append(certs, ai.ClientCertificateData...)
+		}
+		data = certs
+	}
+
+	var expiry time.Time
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if expiry.IsZero() || cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	if expiry.IsZero() {
+		return time.Time{}, errors.New("no certificate found")
+	}
+	return expiry, nil
+}
+
 // GetEtcdClusterStatus returns EtcdClusterStatus
 func GetEtcdClusterStatus(ctx context.Context, inf cke.Infrastructure, nodes []*cke.Node) (cke.EtcdClusterStatus, error) {
 	clusterStatus := cke.EtcdClusterStatus{}
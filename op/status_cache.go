@@ -0,0 +1,106 @@
+package op
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cybozu-go/cke"
+)
+
+// DefaultNodeStatusCacheTTL is the default lifetime of NodeStatusCache entries.
+const DefaultNodeStatusCacheTTL = 10 * time.Minute
+
+// NodeStatusCache caches the parts of NodeStatus that require reading files
+// on nodes, i.e. configuration files and certificates of components.
+//
+// An entry is used only while the container of the component is the same
+// one as when the entry was stored.  Entries are also discarded when they
+// get older than the TTL or when the node is invalidated explicitly.
+// Cached values are shared between statuses and must not be modified.
+//
+// A nil *NodeStatusCache is valid and caches nothing.
+type NodeStatusCache struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	nodes map[string]map[string]*statusCacheEntry
+}
+
+type statusCacheEntry struct {
+	containerID string
+	timestamp   time.Time
+	value       interface{}
+}
+
+// NewNodeStatusCache creates a NodeStatusCache whose entries live for ttl.
+func NewNodeStatusCache(ttl time.Duration) *NodeStatusCache {
+	return &NodeStatusCache{
+		ttl:   ttl,
+		nodes: make(map[string]map[string]*statusCacheEntry),
+	}
+}
+
+// Invalidate discards all entries for the nodes.
+func (c *NodeStatusCache) Invalidate(addresses ...string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, addr := range addresses {
+		delete(c.nodes, addr)
+	}
+}
+
+// Retain discards entries for nodes not in the list.
+func (c *NodeStatusCache) Retain(nodes []*cke.Node) {
+	if c == nil {
+		return
+	}
+
+	addresses := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		addresses[n.Address] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for addr := range c.nodes {
+		if !addresses[addr] {
+			delete(c.nodes, addr)
+		}
+	}
+}
+
+// get returns the cached value for the key of the node.
+// If there is no valid entry, this calls fetch and caches its result.
+// Errors from fetch are not cached.
+func (c *NodeStatusCache) get(address, key, containerID string, fetch func() (interface{}, error)) (interface{}, error) {
+	if c == nil || containerID == "" {
+		return fetch()
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	e := c.nodes[address][key]
+	c.mu.Unlock()
+	if e != nil && e.containerID == containerID && now.Sub(e.timestamp) < c.ttl {
+		return e.value, nil
+	}
+
+	v, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := c.nodes[address]
+	if entries == nil {
+		entries = make(map[string]*statusCacheEntry)
+		c.nodes[address] = entries
+	}
+	entries[key] = &statusCacheEntry{containerID: containerID, timestamp: now, value: v}
+	return v, nil
+}
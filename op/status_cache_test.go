@@ -0,0 +1,93 @@
+package op
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/cke"
+)
+
+func TestNodeStatusCache(t *testing.T) {
+	c := NewNodeStatusCache(time.Hour)
+
+	calls := 0
+	fetch := func(v string) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			calls++
+			return v, nil
+		}
+	}
+
+	v, err := c.get("10.0.0.1", "kubelet", "id1", fetch("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "a" || calls != 1 {
+		t.Error("unexpected result for the first get", v, calls)
+	}
+
+	v, err = c.get("10.0.0.1", "kubelet", "id1", fetch("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "a" || calls != 1 {
+		t.Error("cached value should be returned", v, calls)
+	}
+
+	// re-created container
+	v, _ = c.get("10.0.0.1", "kubelet", "id2", fetch("c"))
+	if v != "c" || calls != 2 {
+		t.Error("entry for an old container should be discarded", v, calls)
+	}
+
+	// unknown container
+	v, _ = c.get("10.0.0.1", "kubelet", "", fetch("d"))
+	if v != "d" || calls != 3 {
+		t.Error("entry without container ID should not be used", v, calls)
+	}
+
+	c.Invalidate("10.0.0.1")
+	v, _ = c.get("10.0.0.1", "kubelet", "id2", fetch("e"))
+	if v != "e" || calls != 4 {
+		t.Error("invalidated entry should be discarded", v, calls)
+	}
+
+	c.get("10.0.0.2", "kubelet", "id3", fetch("f"))
+	c.Retain([]*cke.Node{{Address: "10.0.0.2"}})
+	v, _ = c.get("10.0.0.1", "kubelet", "id2", fetch("g"))
+	if v != "g" || calls != 6 {
+		t.Error("entry for a removed node should be discarded", v, calls)
+	}
+	v, _ = c.get("10.0.0.2", "kubelet", "id3", fetch("h"))
+	if v != "f" || calls != 6 {
+		t.Error("entry for a retained node should be kept", v, calls)
+	}
+
+	_, err = c.get("10.0.0.3", "kubelet", "id4", func() (interface{}, error) {
+		calls++
+		return nil, errors.New("error")
+	})
+	if err == nil {
+		t.Error("error should be returned")
+	}
+	v, _ = c.get("10.0.0.3", "kubelet", "id4", fetch("i"))
+	if v != "i" || calls != 8 {
+		t.Error("error should not be cached", v, calls)
+	}
+
+	expired := NewNodeStatusCache(0)
+	expired.get("10.0.0.1", "kubelet", "id1", fetch("j"))
+	v, _ = expired.get("10.0.0.1", "kubelet", "id1", fetch("k"))
+	if v != "k" || calls != 10 {
+		t.Error("expired entry should be discarded", v, calls)
+	}
+
+	var nilCache *NodeStatusCache
+	nilCache.Invalidate("10.0.0.1")
+	nilCache.Retain(nil)
+	v, _ = nilCache.get("10.0.0.1", "kubelet", "id1", fetch("l"))
+	if v != "l" || calls != 11 {
+		t.Error("nil cache should always fetch", v, calls)
+	}
+}
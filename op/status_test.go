@@ -1,6 +1,23 @@
 package op
 
-import "testing"
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
 
 func TestContainCommandOption(t *testing.T) {
 	type args struct {
@@ -41,3 +58,133 @@ func TestContainCommandOption(t *testing.T) {
 		})
 	}
 }
+
+func testCertificatePEM(t *testing.T, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    notAfter.Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestEarliestCertificateExpiry(t *testing.T) {
+	t1 := time.Date(2031, 10, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2030, 10, 1, 0, 0, 0, 0, time.UTC)
+	cert1 := testCertificatePEM(t, t1)
+	cert2 := testCertificatePEM(t, t2)
+
+	expiry, err := EarliestCertificateExpiry(append(append([]byte{}, cert1...), cert2...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.Equal(t2) {
+		t.Errorf("unexpected expiry: %v", expiry)
+	}
+
+	cfg := api.NewConfig()
+	cfg.AuthInfos["user"] = &api.AuthInfo{ClientCertificateData: cert1}
+	kubeconfig, err := clientcmd.Write(*cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry, err = EarliestCertificateExpiry(kubeconfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.Equal(t1) {
+		t.Errorf("unexpected expiry: %v", expiry)
+	}
+
+	_, err = EarliestCertificateExpiry([]byte("apiVersion: v1\nkind: Config\n"))
+	if err == nil {
+		t.Error("should fail without certificates")
+	}
+}
+
+// engineAgent is a fake cke.Agent.  Commands without a matching prefix fail.
+type engineAgent struct {
+	outputs map[string]string
+}
+
+func (a engineAgent) Close() error {
+	return nil
+}
+
+func (a engineAgent) Run(command string) ([]byte, []byte, error) {
+	for prefix, out := range a.outputs {
+		if strings.HasPrefix(command, prefix) {
+			return []byte(out), nil, nil
+		}
+	}
+	return nil, nil, errors.New("command not found")
+}
+
+func (a engineAgent) RunWithInput(command, input string) error {
+	_, _, err := a.Run(command)
+	return err
+}
+
+func (a engineAgent) RunWithTimeout(command, input string, timeout time.Duration) ([]byte, []byte, error) {
+	return a.Run(command)
+}
+
+func (a engineAgent) RunWithReader(command string, input io.Reader, timeout time.Duration) ([]byte, []byte, error) {
+	return a.Run(command)
+}
+
+func TestCheckContainerEngine(t *testing.T) {
+	t.Parallel()
+
+	names := []string{EtcdContainerName, KubeletContainerName}
+	inspect := `[{"Name": "/etcd", "Config": {"Image": "etcd", "Labels": {"com.cybozu.cke": "{}"}}, "State": {"Running": true}}]`
+	dockerNode := engineAgent{outputs: map[string]string{
+		"docker ps":                "etcd:1234\n",
+		"docker container inspect": inspect,
+	}}
+	emptyDockerNode := engineAgent{outputs: map[string]string{
+		"docker ps":     "",
+		"docker volume": "",
+	}}
+	dockerVolumeNode := engineAgent{outputs: map[string]string{
+		"docker ps":     "",
+		"docker volume": "etcd-cke\n",
+	}}
+	containerdNode := engineAgent{outputs: map[string]string{
+		"nerdctl --namespace=cke ps":                "etcd:1234\n",
+		"nerdctl --namespace=cke container inspect": inspect,
+	}}
+
+	testCases := []struct {
+		name    string
+		agent   cke.Agent
+		engine  string
+		succeed bool
+	}{
+		{"docker to containerd", dockerNode, cke.EngineContainerd, false},
+		{"containerd to docker", containerdNode, cke.EngineDocker, false},
+		{"containerd to default", containerdNode, "", false},
+		{"docker volume", dockerVolumeNode, cke.EngineContainerd, false},
+		{"no containers", emptyDockerNode, cke.EngineContainerd, true},
+		{"engine not installed", engineAgent{}, cke.EngineContainerd, true},
+	}
+
+	for _, tc := range testCases {
+		err := checkContainerEngine(tc.agent, tc.engine, names, "etcd-cke")
+		if tc.succeed && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.succeed && err == nil {
+			t.Errorf("%s: should fail", tc.name)
+		}
+	}
+}
@@ -31,6 +31,13 @@ func (o *containerStopOp) Targets() []string {
 	return ips
 }
 
+func (o *containerStopOp) Footprint() cke.Footprint {
+	return cke.Footprint{
+		Nodes:      o.Targets(),
+		Components: []string{o.name},
+	}
+}
+
 // APIServerStopOp returns an Operator to stop API server
 func APIServerStopOp(nodes []*cke.Node) cke.Operator {
 	return &containerStopOp{
@@ -39,6 +46,14 @@ func APIServerStopOp(nodes []*cke.Node) cke.Operator {
 	}
 }
 
+// KMSPluginStopOp returns an Operator to stop KMS plugin
+func KMSPluginStopOp(nodes []*cke.Node) cke.Operator {
+	return &containerStopOp{
+		nodes: nodes,
+		name:  KMSPluginContainerName,
+	}
+}
+
 // ControllerManagerStopOp returns an Operator to stop kube-controller-manager
 func ControllerManagerStopOp(nodes []*cke.Node) cke.Operator {
 	return &containerStopOp{
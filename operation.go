@@ -2,7 +2,11 @@ package cke
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 // Operator is the interface for operations
@@ -15,12 +19,68 @@ type Operator interface {
 	Targets() []string
 }
 
+// OperationID returns an ID that identifies the operation and its targets.
+// The same operation for different targets has a different ID.
+func OperationID(op Operator) string {
+	targets := append([]string(nil), op.Targets()...)
+	sort.Strings(targets)
+	sum := sha256.Sum256([]byte(strings.Join(targets, "\n")))
+	return op.Name() + "-" + hex.EncodeToString(sum[:4])
+}
+
 // InfoOperator is an extension of Operator that provides some information after the operation
 type InfoOperator interface {
 	Operator
 	Info() string
 }
 
+// HookOperator is an extension of Operator that runs hooks
+type HookOperator interface {
+	Operator
+	HookResults() []HookResult
+}
+
+// Footprint represents the resources that an operation changes or depends on.
+type Footprint struct {
+	// Nodes are the addresses of nodes that the operation accesses.
+	// Empty means the operation affects the whole cluster.
+	Nodes []string
+	// Components are the names of components that the operation changes or depends on.
+	Components []string
+}
+
+// Conflicts returns true if operations with f and other must not run concurrently,
+// i.e. they share a component on a common node.
+func (f Footprint) Conflicts(other Footprint) bool {
+	if !intersects(f.Components, other.Components) {
+		return false
+	}
+	if len(f.Nodes) == 0 || len(other.Nodes) == 0 {
+		return true
+	}
+	return intersects(f.Nodes, other.Nodes)
+}
+
+func intersects(a, b []string) bool {
+	m := make(map[string]bool, len(a))
+	for _, s := range a {
+		m[s] = true
+	}
+	for _, s := range b {
+		if m[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// FootprintOperator is an extension of Operator that declares its footprint.
+// Operators that do not implement this conflict with every other operator.
+type FootprintOperator interface {
+	Operator
+	Footprint() Footprint
+}
+
 // Commander is a single step to proceed an operation
 type Commander interface {
 	// Run executes the command
@@ -0,0 +1,85 @@
+package cke
+
+import "testing"
+
+type testOperator struct {
+	name    string
+	targets []string
+}
+
+func (o testOperator) Name() string           { return o.name }
+func (o testOperator) NextCommand() Commander { return nil }
+func (o testOperator) Targets() []string      { return o.targets }
+
+func TestOperationID(t *testing.T) {
+	t.Parallel()
+
+	id1 := OperationID(testOperator{"kubelet-restart", []string{"10.0.0.1", "10.0.0.2"}})
+	id2 := OperationID(testOperator{"kubelet-restart", []string{"10.0.0.2", "10.0.0.1"}})
+	id3 := OperationID(testOperator{"kubelet-restart", []string{"10.0.0.1"}})
+	id4 := OperationID(testOperator{"kube-proxy-restart", []string{"10.0.0.1"}})
+
+	if id1 != id2 {
+		t.Error("ID should not depend on the order of targets", id1, id2)
+	}
+	if id1 == id3 {
+		t.Error("ID should depend on targets", id1)
+	}
+	if id3 == id4 {
+		t.Error("ID should depend on the operation name", id3)
+	}
+}
+
+func TestFootprintConflicts(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		a        Footprint
+		b        Footprint
+		expected bool
+	}{
+		{
+			name:     "same component on the same node",
+			a:        Footprint{Nodes: []string{"10.0.0.1", "10.0.0.2"}, Components: []string{"kubelet"}},
+			b:        Footprint{Nodes: []string{"10.0.0.2"}, Components: []string{"kubelet"}},
+			expected: true,
+		},
+		{
+			name:     "same component on different nodes",
+			a:        Footprint{Nodes: []string{"10.0.0.1"}, Components: []string{"kubelet"}},
+			b:        Footprint{Nodes: []string{"10.0.0.2"}, Components: []string{"kubelet"}},
+			expected: false,
+		},
+		{
+			name:     "different components on the same node",
+			a:        Footprint{Nodes: []string{"10.0.0.1"}, Components: []string{"kubelet"}},
+			b:        Footprint{Nodes: []string{"10.0.0.1"}, Components: []string{"kube-proxy"}},
+			expected: false,
+		},
+		{
+			name:     "cluster-wide",
+			a:        Footprint{Components: []string{"kubelet"}},
+			b:        Footprint{Nodes: []string{"10.0.0.2"}, Components: []string{"kube-proxy", "kubelet"}},
+			expected: true,
+		},
+		{
+			name:     "cluster-wide with different components",
+			a:        Footprint{Components: []string{"kubelet"}},
+			b:        Footprint{Components: []string{"kube-proxy"}},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.a.Conflicts(tc.b); actual != tc.expected {
+				t.Errorf("a.Conflicts(b) = %v, expected %v", actual, tc.expected)
+			}
+			if actual := tc.b.Conflicts(tc.a); actual != tc.expected {
+				t.Errorf("b.Conflicts(a) = %v, expected %v", actual, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package cke
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Pause represents a request to pause operations of CKE.
+//
+// If neither Phases nor Nodes is specified, all operations are paused.
+// Otherwise, operations are paused if the phase is one of Phases or
+// if the operation targets any of Nodes.
+type Pause struct {
+	Reason string           `json:"reason,omitempty"`
+	Phases []OperationPhase `json:"phases,omitempty"`
+	// Nodes are the addresses of nodes.
+	Nodes []string  `json:"nodes,omitempty"`
+	Since time.Time `json:"since"`
+}
+
+// Validate validates the pause request.
+func (p *Pause) Validate() error {
+	for _, phase := range p.Phases {
+		if !isKnownPhase(phase) {
+			return fmt.Errorf("unknown phase: %s", phase)
+		}
+	}
+	for _, n := range p.Nodes {
+		if n == "" {
+			return errors.New("empty node address")
+		}
+	}
+	return nil
+}
+
+func isKnownPhase(phase OperationPhase) bool {
+	for _, p := range AllOperationPhases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// IsGlobal returns true if all operations are paused.
+func (p *Pause) IsGlobal() bool {
+	return len(p.Phases) == 0 && len(p.Nodes) == 0
+}
+
+// Covers returns true if op in phase is paused.
+// A nil *Pause pauses nothing.
+//
+// Operations may target nodes by name as well as by address, so the
+// names of Nodes are looked up in cluster.  Operations that do not
+// target specific nodes, such as updates of resources, are not paused
+// by Nodes.
+func (p *Pause) Covers(phase OperationPhase, op Operator, cluster *Cluster) bool {
+	if p == nil {
+		return false
+	}
+	if p.IsGlobal() {
+		return true
+	}
+	for _, ph := range p.Phases {
+		if ph == phase {
+			return true
+		}
+	}
+	if len(p.Nodes) == 0 {
+		return false
+	}
+
+	nodes := append([]string(nil), p.Nodes...)
+	if cluster != nil {
+		for _, n := range cluster.Nodes {
+			for _, a := range p.Nodes {
+				if n.Address == a {
+					nodes = append(nodes, n.Nodename())
+				}
+			}
+		}
+	}
+	if intersects(nodes, op.Targets()) {
+		return true
+	}
+	if fop, ok := op.(FootprintOperator); ok {
+		return intersects(p.Nodes, fop.Footprint().Nodes)
+	}
+	return false
+}
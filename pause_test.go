@@ -0,0 +1,78 @@
+package cke
+
+import "testing"
+
+func TestPauseCovers(t *testing.T) {
+	t.Parallel()
+
+	op := testOperator{"kubelet-restart", []string{"10.0.0.1", "10.0.0.2"}}
+
+	testCases := []struct {
+		name     string
+		pause    *Pause
+		phase    OperationPhase
+		expected bool
+	}{
+		{"nil", nil, PhaseK8sMaintain, false},
+		{"global", &Pause{Reason: "test"}, PhaseK8sMaintain, true},
+		{"phase", &Pause{Phases: []OperationPhase{PhaseK8sMaintain}}, PhaseK8sMaintain, true},
+		{"other phase", &Pause{Phases: []OperationPhase{PhaseEtcdMaintain}}, PhaseK8sMaintain, false},
+		{"node", &Pause{Nodes: []string{"10.0.0.2"}}, PhaseK8sMaintain, true},
+		{"other node", &Pause{Nodes: []string{"10.0.0.3"}}, PhaseK8sMaintain, false},
+		{"phase or node", &Pause{Phases: []OperationPhase{PhaseEtcdMaintain}, Nodes: []string{"10.0.0.1"}}, PhaseK8sMaintain, true},
+	}
+
+	for _, tc := range testCases {
+		if tc.pause.Covers(tc.phase, op, nil) != tc.expected {
+			t.Errorf("%s: expected %v", tc.name, tc.expected)
+		}
+	}
+}
+
+type testFootprintOperator struct {
+	testOperator
+	footprint Footprint
+}
+
+func (o testFootprintOperator) Footprint() Footprint { return o.footprint }
+
+func TestPauseCoversNodeNames(t *testing.T) {
+	t.Parallel()
+
+	cluster := &Cluster{
+		Nodes: []*Node{
+			{Address: "10.0.0.1", Hostname: "node1"},
+			{Address: "10.0.0.2"},
+		},
+	}
+	pause := &Pause{Nodes: []string{"10.0.0.1"}}
+
+	if !pause.Covers(PhaseUncordonNodes, testOperator{"reboot-uncordon", []string{"node1"}}, cluster) {
+		t.Error("operations targeting the node name should be paused")
+	}
+	if pause.Covers(PhaseUncordonNodes, testOperator{"reboot-uncordon", []string{"10.0.0.2"}}, cluster) {
+		t.Error("operations targeting other nodes should not be paused")
+	}
+
+	fop := testFootprintOperator{testOperator{"reboot-dequeue", nil}, Footprint{Nodes: []string{"10.0.0.1"}}}
+	if !pause.Covers(PhaseRebootNodes, fop, cluster) {
+		t.Error("operations accessing the node should be paused")
+	}
+	if pause.Covers(PhaseK8sMaintain, testOperator{"resource-apply", nil}, cluster) {
+		t.Error("operations without node targets should not be paused")
+	}
+}
+
+func TestPauseValidate(t *testing.T) {
+	t.Parallel()
+
+	if err := (&Pause{Phases: []OperationPhase{PhaseStopCP}, Nodes: []string{"10.0.0.1"}}).Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := (&Pause{Phases: []OperationPhase{"no-such-phase"}}).Validate(); err == nil {
+		t.Error("unknown phase should be rejected")
+	}
+	if err := (&Pause{Nodes: []string{""}}).Validate(); err == nil {
+		t.Error("empty node should be rejected")
+	}
+}
@@ -7,20 +7,28 @@ type OperationPhase string
 
 // Processing statuses of CKE server.
 const (
-	PhaseUpgradeAborted  = OperationPhase("upgrade-aborted")
-	PhaseUpgrade         = OperationPhase("upgrade")
-	PhaseRivers          = OperationPhase("rivers")
-	PhaseEtcdBootAborted = OperationPhase("etcd-boot-aborted")
-	PhaseEtcdBoot        = OperationPhase("etcd-boot")
-	PhaseEtcdStart       = OperationPhase("etcd-start")
-	PhaseEtcdWait        = OperationPhase("etcd-wait")
-	PhaseK8sStart        = OperationPhase("k8s-start")
-	PhaseEtcdMaintain    = OperationPhase("etcd-maintain")
-	PhaseK8sMaintain     = OperationPhase("k8s-maintain")
-	PhaseStopCP          = OperationPhase("stop-control-plane")
-	PhaseUncordonNodes   = OperationPhase("uncordon-nodes")
-	PhaseRebootNodes     = OperationPhase("reboot-nodes")
-	PhaseCompleted       = OperationPhase("completed")
+	PhaseUpgradeAborted        = OperationPhase("upgrade-aborted")
+	PhaseUpgrade               = OperationPhase("upgrade")
+	PhaseRivers                = OperationPhase("rivers")
+	PhaseEtcdRestoreAborted    = OperationPhase("etcd-restore-aborted")
+	PhaseEtcdRestore           = OperationPhase("etcd-restore")
+	PhaseEtcdBootAborted       = OperationPhase("etcd-boot-aborted")
+	PhaseEtcdBoot              = OperationPhase("etcd-boot")
+	PhaseEtcdStart             = OperationPhase("etcd-start")
+	PhaseEtcdWait              = OperationPhase("etcd-wait")
+	PhaseK8sStart              = OperationPhase("k8s-start")
+	PhaseEtcdMaintain          = OperationPhase("etcd-maintain")
+	PhaseEncryptionKeyRotation = OperationPhase("encryption-key-rotation")
+	PhaseCARotation            = OperationPhase("ca-rotation")
+	PhaseCertificateRenewal    = OperationPhase("certificate-renewal")
+	PhaseK8sMaintain           = OperationPhase("k8s-maintain")
+	PhaseStopCP                = OperationPhase("stop-control-plane")
+	PhaseUncordonNodes         = OperationPhase("uncordon-nodes")
+	PhaseRebootNodes           = OperationPhase("reboot-nodes")
+	PhaseWaitingWindow         = OperationPhase("waiting-for-window")
+	PhaseWaitingApproval       = OperationPhase("waiting-for-approval")
+	PhaseVersionUpgradeAborted = OperationPhase("version-upgrade-aborted")
+	PhaseCompleted             = OperationPhase("completed")
 )
 
 // AllOperationPhases contains all kinds of OperationPhases.
@@ -28,16 +36,24 @@ var AllOperationPhases = []OperationPhase{
 	PhaseUpgradeAborted,
 	PhaseUpgrade,
 	PhaseRivers,
+	PhaseEtcdRestoreAborted,
+	PhaseEtcdRestore,
 	PhaseEtcdBootAborted,
 	PhaseEtcdBoot,
 	PhaseEtcdStart,
 	PhaseEtcdWait,
 	PhaseK8sStart,
 	PhaseEtcdMaintain,
+	PhaseEncryptionKeyRotation,
+	PhaseCARotation,
+	PhaseCertificateRenewal,
 	PhaseK8sMaintain,
 	PhaseStopCP,
 	PhaseUncordonNodes,
 	PhaseRebootNodes,
+	PhaseWaitingWindow,
+	PhaseWaitingApproval,
+	PhaseVersionUpgradeAborted,
 	PhaseCompleted,
 }
 
@@ -45,4 +61,54 @@ var AllOperationPhases = []OperationPhase{
 type ServerStatus struct {
 	Phase     OperationPhase `json:"phase"`
 	Timestamp time.Time      `json:"timestamp"`
+
+	// Upgrade is non-nil while etcd or Kubernetes images are being upgraded.
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+
+	// SSHErrors are the reasons why nodes are not connected via SSH.
+	// Keys are node addresses.
+	SSHErrors map[string]string `json:"ssh_errors,omitempty"`
+
+	// Retries are the retry states of failed operations.
+	// Keys are the IDs of the states.
+	Retries map[string]*OperationRetry `json:"retries,omitempty"`
+
+	// Pause is the pause request in effect, if any.
+	Pause *Pause `json:"pause,omitempty"`
+	// PausedOperations are the names of operations not run due to Pause.
+	PausedOperations []string `json:"paused_operations,omitempty"`
+
+	// PendingOperations are the operations waiting for approval.
+	PendingOperations []*PendingOperation `json:"pending_operations,omitempty"`
+
+	// Vault is the state of the Vault token of the server.
+	// Nil if the server has not logged in to Vault.
+	Vault *VaultStatus `json:"vault,omitempty"`
+}
+
+// VaultStatus represents the state of the Vault token of the server.
+type VaultStatus struct {
+	AuthMethod string `json:"auth_method,omitempty"`
+	Connected  bool   `json:"connected"`
+	// TokenExpiry is the time when the token expires unless it is renewed.
+	// Zero if the token never expires.
+	TokenExpiry     time.Time `json:"token_expiry"`
+	LastRenewal     time.Time `json:"last_renewal"`
+	RenewalFailures int       `json:"renewal_failures"`
+}
+
+// UpgradeStatus represents the progress of upgrading etcd and Kubernetes images.
+type UpgradeStatus struct {
+	Components []ComponentUpgradeStatus `json:"components"`
+
+	// PreflightError is the reason why the upgrade cannot proceed, if any.
+	PreflightError string `json:"preflight_error,omitempty"`
+}
+
+// ComponentUpgradeStatus represents the progress of upgrading a component.
+type ComponentUpgradeStatus struct {
+	Name     string `json:"name"`
+	Image    string `json:"image"`
+	Upgraded int    `json:"upgraded"`
+	Running  int    `json:"running"`
 }
@@ -14,8 +14,9 @@ import (
 )
 
 var (
-	flgConfigPath = pflag.String("config", "/etc/cke/config.yml", "configuration file path")
-	flgInterval   = pflag.Duration("interval", 1*time.Minute, "check interval")
+	flgConfigPath     = pflag.String("config", "/etc/cke/config.yml", "configuration file path")
+	flgInterval       = pflag.Duration("interval", 1*time.Minute, "check interval")
+	flgBuiltinKeyFile = pflag.String("builtin-key-file", cke.DefaultBuiltinKeyFile, "file containing the key to encrypt data of the built-in backend")
 )
 
 func loadConfig(p string) (*etcdutil.Config, error) {
@@ -41,6 +42,11 @@ func main() {
 		log.ErrorExit(err)
 	}
 
+	err = cke.LoadBuiltinKey(*flgBuiltinKeyFile)
+	if err != nil {
+		log.ErrorExit(err)
+	}
+
 	etcd, err := etcdutil.NewClient(cfg)
 	if err != nil {
 		log.ErrorExit(err)
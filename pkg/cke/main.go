@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/cybozu-go/cke"
@@ -19,12 +20,15 @@ import (
 )
 
 var (
-	flgHTTP            = pflag.String("http", "0.0.0.0:10180", "<Listen IP>:<Port number>")
-	flgConfigPath      = pflag.String("config", "/etc/cke/config.yml", "configuration file path")
-	flgInterval        = pflag.String("interval", "1m", "check interval")
-	flgCertsGCInterval = pflag.String("certs-gc-interval", "1h", "tidy interval for expired certificates")
-	flgSessionTTL      = pflag.String("session-ttl", "60s", "leader session's TTL")
-	flgDebugSabakan    = pflag.Bool("debug-sabakan", false, "debug sabakan integration")
+	flgHTTP             = pflag.String("http", "0.0.0.0:10180", "<Listen IP>:<Port number>")
+	flgConfigPath       = pflag.String("config", "/etc/cke/config.yml", "configuration file path")
+	flgInterval         = pflag.String("interval", "1m", "check interval")
+	flgCertsGCInterval  = pflag.String("certs-gc-interval", "1h", "tidy interval for expired certificates")
+	flgSessionTTL       = pflag.String("session-ttl", "60s", "leader session's TTL")
+	flgDebugSabakan     = pflag.Bool("debug-sabakan", false, "debug sabakan integration")
+	flgAPITokenFile     = pflag.String("api-token-file", "", "file containing the bearer token for REST API; empty to disable authenticated APIs")
+	flgMaxConcurrentOps = pflag.Int("max-concurrent-ops", 1, "maximum number of operations run concurrently")
+	flgBuiltinKeyFile   = pflag.String("builtin-key-file", cke.DefaultBuiltinKeyFile, "file containing the key to encrypt data of the built-in backend")
 )
 
 func loadConfig(p string) (*etcdutil.Config, error) {
@@ -77,6 +81,12 @@ func main() {
 		log.ErrorExit(err)
 	}
 
+	err = cke.LoadBuiltinKey(*flgBuiltinKeyFile)
+	if err != nil {
+		log.ErrorExit(err)
+	}
+	cke.SetVaultTokenObserver(metrics.UpdateVaultToken)
+
 	etcd, err := etcdutil.NewClient(cfg)
 	if err != nil {
 		log.ErrorExit(err)
@@ -108,8 +118,17 @@ func main() {
 		log.ErrorExit(err)
 	}
 
+	var apiToken string
+	if *flgAPITokenFile != "" {
+		data, err := os.ReadFile(*flgAPITokenFile)
+		if err != nil {
+			log.ErrorExit(err)
+		}
+		apiToken = strings.TrimSpace(string(data))
+	}
+
 	// Controller
-	controller := server.NewController(session, interval, gcInterval, timeout, addon)
+	controller := server.NewController(session, interval, gcInterval, timeout, *flgMaxConcurrentOps, addon)
 	well.Go(controller.Run)
 
 	// API server
@@ -122,6 +141,7 @@ func main() {
 	server := server.Server{
 		EtcdClient: etcd,
 		Timeout:    timeout,
+		APIToken:   apiToken,
 	}
 	mux.Handle("/", server)
 	s := &well.HTTPServer{
@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// builtinCmd represents the builtin command
+var builtinCmd = &cobra.Command{
+	Use:   "builtin",
+	Short: "builtin subcommand",
+	Long:  `builtin subcommand`,
+}
+
+func init() {
+	rootCmd.AddCommand(builtinCmd)
+}
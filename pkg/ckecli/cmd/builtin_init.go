@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+// builtinInitCmd represents the "builtin init" command
+var builtinInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "configure the built-in backend for CKE",
+	Long: `Configure the built-in backend that does not require Vault.
+
+This command will:
+
+    * generate the key file specified by --builtin-key-file if not exists.
+    * make the built-in backend the backend of CKE.
+    * generate root CAs and store their certificates in etcd.
+    * generate initial encryption key for Kubernetes Secrets.
+
+The key file must be copied to all hosts running CKE and ckecli.
+It cannot be used for clusters that have been configured with Vault.`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(initBuiltin)
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	builtinCmd.AddCommand(builtinInitCmd)
+}
+
+func initBuiltin(ctx context.Context) error {
+	cfg, err := storage.GetBackendConfig(ctx)
+	switch err {
+	case nil:
+		if cfg.Type != cke.BackendBuiltin {
+			return errors.New("backend is already configured: " + cfg.Type)
+		}
+	case cke.ErrNotFound:
+		_, err := storage.GetVaultConfig(ctx)
+		if err == nil {
+			return errors.New("vault is already configured")
+		}
+		if err != cke.ErrNotFound {
+			return err
+		}
+	default:
+		return err
+	}
+
+	err = createBuiltinKey(builtinKeyFile)
+	if err != nil {
+		return err
+	}
+	err = cke.LoadBuiltinKey(builtinKeyFile)
+	if err != nil {
+		return err
+	}
+
+	err = storage.PutBackendConfig(ctx, &cke.BackendConfig{Type: cke.BackendBuiltin})
+	if err != nil {
+		return err
+	}
+
+	b, err := inf.Backend()
+	if err != nil {
+		return err
+	}
+
+	for _, ca := range cas {
+		err = createBuiltinRootCA(ctx, b, ca)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = b.ReadSecret(ctx, cke.SecretK8s)
+	switch err {
+	case nil:
+		return nil
+	case cke.ErrNotFound:
+	default:
+		return err
+	}
+
+	return rotateK8sEncryptionKey(ctx, b)
+}
+
+func createBuiltinKey(p string) error {
+	_, err := os.Stat(p)
+	if err == nil {
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	key, err := cke.GenerateBuiltinKey()
+	if err != nil {
+		return err
+	}
+	data := base64.StdEncoding.EncodeToString(key) + "\n"
+	err = os.WriteFile(p, []byte(data), 0600)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("generated key file %s\n", p)
+	return nil
+}
+
+func createBuiltinRootCA(ctx context.Context, b cke.Backend, ca caParams) error {
+	_, err := storage.GetCACertificate(ctx, ca.key)
+	if err == nil {
+		return nil
+	}
+
+	if err != cke.ErrNotFound {
+		return err
+	}
+
+	_, cert, err := b.GenerateIssuer(ctx, ca.key, ca.commonName, caLifetime)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("issued root certificate for %s\n", ca.key)
+	return storage.PutCACertificate(ctx, ca.key, cert)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+// caRotateCmd represents the "ca rotate" command
+var caRotateCmd = &cobra.Command{
+	Use:   "rotate NAME",
+	Short: "rotate a CA",
+	Long: `Rotate a CA.
+
+This command generates a new root certificate of the CA in the backend
+and registers a rotation request.  CKE server then proceeds the
+rotation step by step:
+
+1. Publish the bundle of the old and new CA certificates and
+   restart components that depend on the CA.
+2. Make the new CA the issuer and restart the components to
+   reissue their certificates.
+3. Remove the old CA certificate from the bundle, restart the
+   components, and delete the old CA in the backend.
+
+The progress is stored in etcd, so the rotation can be resumed
+even if the leader of CKE server changes.
+
+NAME is one of:
+    server
+    etcd-peer
+    etcd-client
+    kubernetes
+    kubernetes-aggregation
+    kubernetes-webhook`,
+
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("wrong number of arguments")
+		}
+
+		if !cke.IsValidCAKey(args[0]) {
+			return errors.New("wrong CA name: " + args[0])
+		}
+
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			_, err := storage.GetCARotation(ctx)
+			switch err {
+			case nil:
+				return errors.New("CA rotation is already in progress")
+			case cke.ErrNotFound:
+			default:
+				return err
+			}
+
+			var commonName string
+			for _, ca := range cas {
+				if ca.key == args[0] {
+					commonName = ca.commonName
+				}
+			}
+
+			oldCert, err := storage.GetCACertificate(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			b, err := inf.Backend()
+			if err != nil {
+				return err
+			}
+			oldIssuer, err := b.DefaultIssuer(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			newIssuer, newCert, err := b.GenerateIssuer(ctx, args[0], commonName, caLifetime)
+			if err != nil {
+				return err
+			}
+
+			r := &cke.CARotation{
+				CA:             args[0],
+				OldIssuer:      oldIssuer,
+				NewIssuer:      newIssuer,
+				OldCertificate: oldCert,
+				NewCertificate: newCert,
+				Step:           cke.CARotationBundle,
+				Started:        time.Now().UTC(),
+			}
+			err = storage.PutCARotation(ctx, r)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("rotating " + r.CA + " to issuer " + r.NewIssuer)
+			return nil
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	caCmd.AddCommand(caRotateCmd)
+}
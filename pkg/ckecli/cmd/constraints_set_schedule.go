@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// constraintsSetScheduleCmd represents the "constraints set-schedule" command
+var constraintsSetScheduleCmd = &cobra.Command{
+	Use:   "set-schedule FILE",
+	Short: "set maintenance windows and blackouts",
+	Long: `Set maintenance windows and blackouts for disruptive operations.
+
+FILE should contain a YAML or JSON object that has "maintenance-windows"
+and/or "blackouts" fields.  The current windows and blackouts are
+replaced with them.  If FILE is "-", the contents are read from stdin.`,
+
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var data []byte
+		var err error
+		if args[0] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return err
+		}
+
+		schedule := new(cke.Constraints)
+		err = yaml.Unmarshal(data, schedule)
+		if err != nil {
+			return err
+		}
+		err = schedule.Validate()
+		if err != nil {
+			return err
+		}
+
+		well.Go(func(ctx context.Context) error {
+			cstr, err := storage.GetConstraints(ctx)
+			switch err {
+			case cke.ErrNotFound:
+				cstr = cke.DefaultConstraints()
+			case nil:
+			default:
+				return err
+			}
+
+			cstr.MaintenanceWindows = schedule.MaintenanceWindows
+			cstr.Blackouts = schedule.Blackouts
+			return storage.PutConstraints(ctx, cstr)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	constraintsCmd.AddCommand(constraintsSetScheduleCmd)
+}
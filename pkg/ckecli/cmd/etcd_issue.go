@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/well"
@@ -13,7 +14,7 @@ import (
 )
 
 var etcdIssueOpts struct {
-	TTL    string
+	TTL    time.Duration
 	Output string
 }
 
@@ -42,7 +43,7 @@ NAME is the username of etcd user to be authenticated.`,
 		}
 
 		well.Go(func(ctx context.Context) error {
-			cert, key, err := cke.IssueEtcdClientCertificate(inf, username, etcdIssueOpts.TTL)
+			cert, key, err := cke.IssueEtcdClientCertificate(ctx, inf, username, etcdIssueOpts.TTL)
 			if err != nil {
 				return err
 			}
@@ -87,7 +88,7 @@ NAME is the username of etcd user to be authenticated.`,
 
 func init() {
 	fs := etcdIssueCmd.Flags()
-	fs.StringVar(&etcdIssueOpts.TTL, "ttl", "87600h", "TTL of the certificate")
+	fs.DurationVar(&etcdIssueOpts.TTL, "ttl", 87600*time.Hour, "TTL of the certificate")
 	fs.StringVar(&etcdIssueOpts.Output, "output", "json", `output format ("json" or "file")`)
 	etcdCmd.AddCommand(etcdIssueCmd)
 }
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cybozu-go/cke/etcdbackup"
 	"github.com/cybozu-go/well"
 	"github.com/spf13/cobra"
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -95,7 +96,7 @@ func backup(ctx context.Context, etcd *clientv3.Client) error {
 		return err
 	}
 
-	ss := snapshot.NewV3(nil)
+	ss := snapshot.NewV3(etcdbackup.Logger())
 	if _, err := ss.Status(fullName); err != nil {
 		os.Remove(fullName)
 		return fmt.Errorf("failed to check status of the new backup: %w", err)
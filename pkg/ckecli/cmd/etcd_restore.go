@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/cke/etcdbackup"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var etcdRestoreToken string
+
+var etcdRestoreCmd = &cobra.Command{
+	Use:   "restore NAME",
+	Short: "restore CKE-managed etcd from a backup",
+	Long: `Restore CKE-managed etcd that stores Kubernetes data from a backup.
+
+NAME is the name of a backup in the backup target configured in
+etcd_backup of the cluster configuration, e.g. etcd-20211001-000000.backup.
+
+This command checks that the backup can be read and its checksum matches,
+then registers the request.  CKE server then copies the backup to all
+control plane nodes and verifies it, stops kube-apiserver and etcd on
+them, wipes etcd data, restores it from the backup, and boots etcd again.
+Kubernetes will be started by the usual operations after that.
+
+ALL DATA WRITTEN AFTER THE BACKUP WILL BE LOST.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := etcdbackup.ValidateName(args[0]); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		req := &cke.EtcdRestoreRequest{
+			Name:      args[0],
+			Token:     etcdRestoreToken,
+			Requested: now,
+		}
+		if len(req.Token) == 0 {
+			req.Token = fmt.Sprintf("cke-restore-%d", now.Unix())
+		}
+
+		well.Go(func(ctx context.Context) error {
+			cluster, err := storage.GetCluster(ctx)
+			if err != nil {
+				return err
+			}
+			b := cluster.EtcdBackup
+			if b.Local == nil && b.S3 == nil && b.HTTP == nil {
+				return errors.New("no backup target is configured in etcd_backup")
+			}
+
+			_, err = storage.GetEtcdRestoreRequest(ctx)
+			switch err {
+			case nil:
+				return errors.New("etcd restore is already requested")
+			case cke.ErrNotFound:
+			default:
+				return err
+			}
+
+			backend, err := etcdbackup.NewBackend(&b)
+			if err != nil {
+				return err
+			}
+			path, _, err := etcdbackup.FetchFile(ctx, backend, req.Name)
+			if err != nil {
+				return err
+			}
+			os.Remove(path)
+
+			// Another request may have been registered during the check.
+			err = storage.PutEtcdRestoreRequest(ctx, req)
+			if err == cke.ErrEtcdRestoreRequested {
+				return errors.New("etcd restore is already requested")
+			}
+			return err
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	etcdRestoreCmd.Flags().StringVar(&etcdRestoreToken, "token", "", "initial cluster token for the restored etcd (default: cke-restore-<UNIX time>)")
+	etcdCmd.AddCommand(etcdRestoreCmd)
+}
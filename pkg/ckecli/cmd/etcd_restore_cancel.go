@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var etcdRestoreCancelCmd = &cobra.Command{
+	Use:   "restore-cancel",
+	Short: "cancel the pending etcd restore request",
+	Long: `Cancel the etcd restore request registered by "ckecli etcd restore".
+
+Once CKE server has started stopping etcd for the restore, the request
+can no longer be cancelled and this command fails.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			err := storage.CancelEtcdRestoreRequest(ctx)
+			switch err {
+			case cke.ErrNotFound:
+				return errors.New("etcd restore is not requested")
+			case cke.ErrEtcdRestoreStarted:
+				return errors.New("etcd restore has already started and cannot be cancelled")
+			}
+			return err
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	etcdCmd.AddCommand(etcdRestoreCancelCmd)
+}
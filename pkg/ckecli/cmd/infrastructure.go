@@ -55,6 +55,10 @@ func (i *cliInfrastructure) Vault() (*vault.Client, error) {
 	return vc, nil
 }
 
+func (i *cliInfrastructure) Backend() (cke.Backend, error) {
+	return cke.OpenBackend(context.Background(), storage, i.Vault)
+}
+
 // The second argument is not used.
 func (i *cliInfrastructure) NewEtcdClient(ctx context.Context, _ []string) (*clientv3.Client, error) {
 	if i.etcd != nil {
@@ -106,7 +110,7 @@ func (i *cliInfrastructure) K8sConfig(ctx context.Context, n *cke.Node) (*rest.C
 }
 
 func (i *cliInfrastructure) K8sClient(ctx context.Context, n *cke.Node) (*kubernetes.Clientset, error) {
-	c, k, err := cke.KubernetesCA{}.IssueUserCert(ctx, i, cke.RoleAdmin, cke.AdminGroup, "1h")
+	c, k, err := cke.KubernetesCA{}.IssueUserCert(ctx, i, cke.RoleAdmin, cke.AdminGroup, time.Hour)
 	if err != nil {
 		return nil, err
 	}
@@ -136,6 +140,9 @@ func (i *cliInfrastructure) HTTPSClient(ctx context.Context) (*well.HTTPClient,
 func (i *cliInfrastructure) Agent(addr string) cke.Agent {
 	panic("not implemented")
 }
+func (i *cliInfrastructure) AgentError(addr string) error {
+	panic("not implemented")
+}
 func (i *cliInfrastructure) Engine(addr string) cke.ContainerEngine {
 	panic("not implemented")
 }
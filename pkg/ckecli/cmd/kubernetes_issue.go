@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/well"
@@ -13,7 +14,7 @@ import (
 )
 
 var kubernetesIssueOpts struct {
-	TTL       string
+	TTL       time.Duration
 	GroupName string
 	UserName  string
 }
@@ -64,7 +65,7 @@ var kubernetesIssueCmd = &cobra.Command{
 
 func init() {
 	fs := kubernetesIssueCmd.Flags()
-	fs.StringVar(&kubernetesIssueOpts.TTL, "ttl", "2h", "TTL of the certificate")
+	fs.DurationVar(&kubernetesIssueOpts.TTL, "ttl", 2*time.Hour, "TTL of the certificate")
 	fs.StringVarP(&kubernetesIssueOpts.GroupName, "group", "g", cke.AdminGroup, "Group name of the issuing config")
 	fs.StringVarP(&kubernetesIssueOpts.UserName, "user", "u", cke.RoleAdmin, "User name of the issuing config")
 	kubernetesCmd.AddCommand(kubernetesIssueCmd)
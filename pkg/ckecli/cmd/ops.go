@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/spf13/cobra"
+)
+
+// opsCmd represents the ops command
+var opsCmd = &cobra.Command{
+	Use:   "ops",
+	Short: "ops subcommand",
+	Long: `Manage operations waiting for approval.
+
+When approval is enabled in the cluster configuration, CKE does not
+run disruptive operations until they are approved.  An approval is
+consumed when the operation completes successfully.`,
+}
+
+// decidePendingOperation approves or rejects the pending operation.
+func decidePendingOperation(ctx context.Context, id string, status cke.PendingOperationStatus) error {
+	p, err := storage.GetPendingOperation(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	p.Status = status
+	p.Decided = time.Now().UTC()
+	return storage.UpdatePendingOperation(ctx, p)
+}
+
+func init() {
+	rootCmd.AddCommand(opsCmd)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+// opsApproveCmd represents the "ops approve" command
+var opsApproveCmd = &cobra.Command{
+	Use:   "approve ID",
+	Short: "approve an operation",
+	Long: `Approve the operation specified by ID.
+
+IDs are shown by "ckecli ops list".`,
+
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			return decidePendingOperation(ctx, args[0], cke.PendingOperationApproved)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	opsCmd.AddCommand(opsApproveCmd)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+// opsListCmd represents the "ops list" command
+var opsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list operations waiting for approval",
+	Long: `List operations waiting for approval.
+
+The output is a list of PendingOperation formatted in JSON.`,
+
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			pending, err := storage.GetPendingOperations(ctx)
+			if err != nil {
+				return err
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "    ")
+			return enc.Encode(pending)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	opsCmd.AddCommand(opsListCmd)
+}
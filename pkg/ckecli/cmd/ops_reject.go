@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+// opsRejectCmd represents the "ops reject" command
+var opsRejectCmd = &cobra.Command{
+	Use:   "reject ID",
+	Short: "reject an operation",
+	Long: `Reject the operation specified by ID.
+
+The operation is not run until the rejection expires.
+CKE then asks for approval again if the operation is still needed.`,
+
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			return decidePendingOperation(ctx, args[0], cke.PendingOperationRejected)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	opsCmd.AddCommand(opsRejectCmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var pauseOpts struct {
+	Reason string
+	Phases []string
+	Nodes  []string
+}
+
+// pauseCmd represents the pause command
+var pauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "pause operations of CKE",
+	Long: `Pause operations of CKE.
+
+Without --phase or --node, all operations are paused.
+With them, operations are paused if CKE is in one of the phases
+or if the operations target any of the nodes.
+
+This replaces the current pause request, if any.
+CKE keeps collecting the cluster status and exporting metrics
+while paused.  Use "ckecli resume" to resume operations.`,
+
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p := &cke.Pause{
+			Reason: pauseOpts.Reason,
+			Nodes:  pauseOpts.Nodes,
+			Since:  time.Now().UTC(),
+		}
+		for _, phase := range pauseOpts.Phases {
+			p.Phases = append(p.Phases, cke.OperationPhase(phase))
+		}
+		if err := p.Validate(); err != nil {
+			return err
+		}
+
+		well.Go(func(ctx context.Context) error {
+			return storage.PutPause(ctx, p)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	fs := pauseCmd.Flags()
+	fs.StringVar(&pauseOpts.Reason, "reason", "", "the reason to pause operations")
+	fs.StringSliceVar(&pauseOpts.Phases, "phase", nil, "pause operations in the phase such as k8s-maintain")
+	fs.StringSliceVar(&pauseOpts.Nodes, "node", nil, "pause operations targeting the node address")
+	rootCmd.AddCommand(pauseCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/cke/server"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan FILE",
+	Short: "show operations that CKE would run for a cluster configuration",
+	Long: `Show operations that CKE would run if the cluster configuration
+in FILE were stored in etcd.
+
+The file must be either YAML or JSON.  The current cluster status is
+collected from the nodes, but no operations are executed.
+
+The output is a JSON object containing the operation phase and
+the operators with their targets and the first few commands.`,
+
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		b, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+
+		cfg := cke.NewCluster()
+		err = yaml.Unmarshal(b, cfg)
+		if err != nil {
+			return err
+		}
+		err = cfg.Validate(false)
+		if err != nil {
+			return err
+		}
+
+		well.Go(func(ctx context.Context) error {
+			// Vault is connected only if it is the backend.
+			vc := (&cliInfrastructure{}).Vault
+			ckeInf, err := cke.NewReadOnlyInfrastructure(ctx, cfg, storage, vc)
+			if err != nil {
+				return err
+			}
+			defer ckeInf.Close()
+
+			plan, err := server.MakePlan(ctx, cfg, ckeInf)
+			if err != nil {
+				return err
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(plan)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+}
@@ -2,24 +2,38 @@ package cmd
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/user"
 	"strings"
+	"time"
 
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/well"
 	"github.com/spf13/cobra"
 )
 
+var rebootQueueAddOpts struct {
+	Priority  int
+	NotBefore string
+	Deadline  string
+	Reason    string
+	Requester string
+}
+
 var rebootQueueAddCmd = &cobra.Command{
 	Use:   "add FILE",
 	Short: "append the nodes written in FILE to the reboot queue",
 	Long: `Append the nodes written in FILE to the reboot queue.
 
 The nodes should be specified with their IP addresses.
-If FILE is -, the contents are read from stdin.`,
+If FILE is -, the contents are read from stdin.
+
+Entries with higher --priority are processed first.  --not-before and
+--deadline take either an RFC3339 time or a duration from now such as
+"8h".  The entry is not started before --not-before, and is removed from
+the queue if it has not been started by --deadline.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		f := os.Stdin
@@ -38,13 +52,29 @@ If FILE is -, the contents are read from stdin.`,
 		}
 		nodes := strings.Fields(string(data))
 		entry := cke.NewRebootQueueEntry(nodes)
+		entry.Priority = rebootQueueAddOpts.Priority
+		entry.Reason = rebootQueueAddOpts.Reason
+		entry.Requester = rebootQueueAddOpts.Requester
+
+		now := time.Now()
+		entry.NotBefore, err = parseRebootTime(rebootQueueAddOpts.NotBefore, now)
+		if err != nil {
+			return fmt.Errorf("invalid --not-before: %w", err)
+		}
+		entry.Deadline, err = parseRebootTime(rebootQueueAddOpts.Deadline, now)
+		if err != nil {
+			return fmt.Errorf("invalid --deadline: %w", err)
+		}
+		if err := entry.Validate(); err != nil {
+			return err
+		}
 
 		well.Go(func(ctx context.Context) error {
 			cluster, err := storage.GetCluster(ctx)
 			if err != nil {
 				return err
 			}
-			err = validateNodes(nodes, cluster)
+			err = cke.ValidateRebootNodes(nodes, cluster)
 			if err != nil {
 				return err
 			}
@@ -56,28 +86,35 @@ If FILE is -, the contents are read from stdin.`,
 	},
 }
 
-func validateNodes(nodes []string, cluster *cke.Cluster) error {
-	numCPs := 0
-OUTER:
-	for _, rebootNode := range nodes {
-		for _, clusterNode := range cluster.Nodes {
-			if rebootNode == clusterNode.Address {
-				if clusterNode.ControlPlane {
-					numCPs++
-				}
-				continue OUTER
-			}
-		}
-		return fmt.Errorf("%s is not a valid node IP address", rebootNode)
+// parseRebootTime parses s as an RFC3339 time or a duration from now.
+// This returns nil if s is empty.
+func parseRebootTime(s string, now time.Time) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
 	}
-
-	if numCPs > 1 {
-		return errors.New("multiple control planes cannot be enqueued in one entry")
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		d, err2 := time.ParseDuration(s)
+		if err2 != nil {
+			return nil, err
+		}
+		t = now.Add(d)
 	}
-
-	return nil
+	t = t.UTC()
+	return &t, nil
 }
 
 func init() {
+	var requester string
+	if u, err := user.Current(); err == nil {
+		requester = u.Username
+	}
+
+	fs := rebootQueueAddCmd.Flags()
+	fs.IntVar(&rebootQueueAddOpts.Priority, "priority", 0, "priority of the entry; higher is processed first")
+	fs.StringVar(&rebootQueueAddOpts.NotBefore, "not-before", "", "do not start the entry before this time")
+	fs.StringVar(&rebootQueueAddOpts.Deadline, "deadline", "", "remove the entry if not started by this time")
+	fs.StringVar(&rebootQueueAddOpts.Reason, "reason", "", "the reason to reboot the nodes")
+	fs.StringVar(&rebootQueueAddOpts.Requester, "requester", requester, "the requester of the entry")
 	rebootQueueCmd.AddCommand(rebootQueueAddCmd)
 }
@@ -2,57 +2,54 @@ package cmd
 
 import (
 	"testing"
-
-	"github.com/cybozu-go/cke"
+	"time"
 )
 
-func TestValidateNodes(t *testing.T) {
-	cluster := &cke.Cluster{
-		Nodes: []*cke.Node{
-			{
-				Address:      "1.1.1.1",
-				ControlPlane: true,
-			},
-			{
-				Address:      "2.2.2.2",
-				ControlPlane: true,
-			},
-		},
-	}
+func TestParseRebootTime(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
 
 	testCases := []struct {
-		name    string
-		nodes   []string
-		succeed bool
+		name     string
+		input    string
+		expected *time.Time
+		succeed  bool
 	}{
 		{
-			name:    "succeed",
-			nodes:   []string{"1.1.1.1"},
+			name:    "empty",
 			succeed: true,
 		},
 		{
-			name:    "non-existing node",
-			nodes:   []string{"3.3.3.3"},
-			succeed: false,
+			name:     "RFC3339",
+			input:    "2022-01-02T09:00:00+09:00",
+			expected: func() *time.Time { t := time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC); return &t }(),
+			succeed:  true,
+		},
+		{
+			name:     "duration",
+			input:    "8h",
+			expected: func() *time.Time { t := now.Add(8 * time.Hour); return &t }(),
+			succeed:  true,
 		},
 		{
-			name:    "multiple control-plane nodes",
-			nodes:   []string{"1.1.1.1", "2.2.2.2"},
-			succeed: false,
+			name:  "invalid",
+			input: "tomorrow",
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ret := validateNodes(tc.nodes, cluster)
-			if tc.succeed {
-				if ret != nil {
-					t.Errorf("validateNodes() failed unexpectedly: %v", ret)
-				}
-			} else {
-				if ret == nil {
-					t.Error("validateNodes() succeeded unexpectedly")
+			ret, err := parseRebootTime(tc.input, now)
+			if !tc.succeed {
+				if err == nil {
+					t.Error("parseRebootTime() succeeded unexpectedly")
 				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRebootTime() failed unexpectedly: %v", err)
+			}
+			if (ret == nil) != (tc.expected == nil) || (ret != nil && !ret.Equal(*tc.expected)) {
+				t.Errorf("unexpected time: expected=%v, actual=%v", tc.expected, ret)
 			}
 		})
 	}
@@ -4,17 +4,27 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"time"
 
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/cke/server"
 	"github.com/cybozu-go/well"
 	"github.com/spf13/cobra"
 )
 
+var rebootQueueListBatchDuration time.Duration
+
 var rebootQueueListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "list the entries in the reboot queue",
 	Long: `List the entries in the reboot queue.
 
-The output is a list of RebootQueueEntry formatted in JSON.`,
+The output is a list of RebootQueueEntry formatted in JSON.
+Queued entries also have "eta", the estimated time when they will be
+started.  The estimation takes the priority and order of entries, their
+schedules, the limits of concurrent reboots, and maintenance windows into
+account, assuming that all nodes are reachable and that each batch of
+reboots takes --batch-duration.`,
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		well.Go(func(ctx context.Context) error {
@@ -23,9 +33,22 @@ The output is a list of RebootQueueEntry formatted in JSON.`,
 				return err
 			}
 
+			etas, err := rebootETAs(ctx, entries, time.Now().UTC())
+			if err != nil {
+				return err
+			}
+
+			items := make([]rebootQueueListItem, len(entries))
+			for i, e := range entries {
+				items[i] = rebootQueueListItem{RebootQueueEntry: e}
+				if eta, ok := etas[e.Index]; ok {
+					items[i].ETA = &eta
+				}
+			}
+
 			enc := json.NewEncoder(os.Stdout)
 			enc.SetIndent("", "    ")
-			if err := enc.Encode(entries); err != nil {
+			if err := enc.Encode(items); err != nil {
 				return err
 			}
 			return nil
@@ -35,6 +58,34 @@ The output is a list of RebootQueueEntry formatted in JSON.`,
 	},
 }
 
+type rebootQueueListItem struct {
+	*cke.RebootQueueEntry
+	ETA *time.Time `json:"eta,omitempty"`
+}
+
+// rebootETAs estimates when the queued entries will be started.
+// If the cluster or constraints are not configured, this returns nothing.
+func rebootETAs(ctx context.Context, entries []*cke.RebootQueueEntry, now time.Time) (map[int64]time.Time, error) {
+	cluster, err := storage.GetCluster(ctx)
+	if err == cke.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	constraints, err := storage.GetConstraints(ctx)
+	if err == cke.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return server.EstimateRebootStarts(cluster, constraints, entries, now, rebootQueueListBatchDuration), nil
+}
+
 func init() {
+	rebootQueueListCmd.Flags().DurationVar(&rebootQueueListBatchDuration, "batch-duration", 30*time.Minute, "estimated time to reboot and verify a batch of nodes")
 	rebootQueueCmd.AddCommand(rebootQueueListCmd)
 }
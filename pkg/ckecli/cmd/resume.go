@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+// resumeCmd represents the resume command
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "resume operations of CKE",
+	Long:  `Resume operations of CKE paused by "ckecli pause".`,
+
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			return storage.DeletePause(ctx)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// retryCmd represents the retry command
+var retryCmd = &cobra.Command{
+	Use:   "retry",
+	Short: "retry subcommand",
+	Long: `Manage retry states of failed operations.
+
+When an operation fails, CKE retries it with exponential backoff.
+An operation is parked, i.e. not retried any longer, if it fails
+with a permanent error or fails too many times.`,
+}
+
+func init() {
+	rootCmd.AddCommand(retryCmd)
+}
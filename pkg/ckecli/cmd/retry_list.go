@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+// retryListCmd represents the "retry list" command
+var retryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list retry states of failed operations",
+	Long:  `List retry states of failed operations in JSON.  Keys are the IDs of the states.`,
+
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			retries, err := storage.GetOperationRetries(ctx)
+			if err != nil {
+				return err
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(retries)
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	retryCmd.AddCommand(retryListCmd)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var retryResetAll bool
+
+// retryResetCmd represents the "retry reset" command
+var retryResetCmd = &cobra.Command{
+	Use:   "reset [ID...]",
+	Short: "reset retry states of operations",
+	Long: `Reset retry states of operations.
+
+CKE runs the operations again without waiting for backoff.
+Use this to resume parked operations after fixing the cause of failures.
+IDs are shown by "ckecli retry list".`,
+
+	Args: func(cmd *cobra.Command, args []string) error {
+		if retryResetAll == (len(args) > 0) {
+			return errors.New("specify either IDs or --all")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			ids := args
+			if retryResetAll {
+				retries, err := storage.GetOperationRetries(ctx)
+				if err != nil {
+					return err
+				}
+				for id := range retries {
+					ids = append(ids, id)
+				}
+			}
+
+			for _, id := range ids {
+				err := storage.ResetOperationRetry(ctx, id)
+				if err == cke.ErrNotFound && retryResetAll {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	retryResetCmd.Flags().BoolVar(&retryResetAll, "all", false, "reset all retry states")
+	retryCmd.AddCommand(retryResetCmd)
+}
@@ -13,10 +13,11 @@ import (
 )
 
 var (
-	cfgFile    string
-	etcdClient *clientv3.Client
-	storage    cke.Storage
-	inf        = &cliInfrastructure{}
+	cfgFile        string
+	builtinKeyFile string
+	etcdClient     *clientv3.Client
+	storage        cke.Storage
+	inf            = &cliInfrastructure{}
 )
 
 func loadConfig(p string) (*etcdutil.Config, error) {
@@ -58,6 +59,11 @@ with etcd.  CKE server watches etcd to receive any updates.`,
 			return err
 		}
 
+		err = cke.LoadBuiltinKey(builtinKeyFile)
+		if err != nil {
+			return err
+		}
+
 		etcd, err := etcdutil.NewClient(cfg)
 		if err != nil {
 			return err
@@ -85,4 +91,5 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "/etc/cke/config.yml", "config file")
+	rootCmd.PersistentFlags().StringVar(&builtinKeyFile, "builtin-key-file", cke.DefaultBuiltinKeyFile, "key file of the built-in backend")
 }
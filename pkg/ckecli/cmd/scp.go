@@ -33,7 +33,13 @@ func scp(ctx context.Context, args []string) error {
 	if err != nil {
 		return err
 	}
-	fifo, err := sshPrivateKey(node)
+	knownHosts, hostKeyOpts, err := sshKnownHosts(ctx, node)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(knownHosts)
+
+	fifo, err := sshPrivateKey(ctx, node)
 	if err != nil {
 		return err
 	}
@@ -41,10 +47,9 @@ func scp(ctx context.Context, args []string) error {
 
 	scpArgs := []string{
 		"-i", fifo,
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "StrictHostKeyChecking=no",
 		"-o", "ConnectTimeout=60",
 	}
+	scpArgs = append(scpArgs, hostKeyOpts...)
 	if scpParams.recursive {
 		scpArgs = append(scpArgs, "-r")
 	}
@@ -70,6 +75,7 @@ var scpCmd = &cobra.Command{
 	Long: `Copy files between hosts via scp.
 
 NODE is IP address or hostname of the node.
+The host key of the node is verified with the key pinned in etcd.
 `,
 
 	Args: cobra.MinimumNArgs(2),
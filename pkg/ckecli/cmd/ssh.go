@@ -46,7 +46,7 @@ func writeToFifo(fifo string, data string) {
 	}
 }
 
-func sshPrivateKey(nodeName string) (string, error) {
+func sshPrivateKey(ctx context.Context, nodeName string) (string, error) {
 	usr, err := user.Current()
 	if err != nil {
 		return "", err
@@ -61,42 +61,93 @@ func sshPrivateKey(nodeName string) (string, error) {
 		return "", err
 	}
 
-	vc, err := inf.Vault()
+	b, err := inf.Backend()
 	if err != nil {
 		return "", err
 	}
-	secret, err := vc.Logical().Read(cke.SSHSecret)
+	privKeys, err := b.ReadSecret(ctx, cke.SecretSSH)
+	if err == cke.ErrNotFound {
+		return "", errors.New("no ssh private keys")
+	}
 	if err != nil {
 		return "", err
 	}
-	if secret == nil {
-		return "", errors.New("no ssh private keys")
-	}
-	privKeys := secret.Data
 
 	mykey, ok := privKeys[nodeName]
 	if !ok {
-		mykey = privKeys[""]
+		mykey, ok = privKeys[""]
 	}
-	if mykey == nil {
+	if !ok {
 		return "", errors.New("no ssh private key for " + nodeName)
 	}
 
 	go func() {
 		// OpenSSH reads the private key file three times, it need to write key three times.
-		writeToFifo(fifo, mykey.(string))
+		writeToFifo(fifo, mykey)
 		time.Sleep(100 * time.Millisecond)
-		writeToFifo(fifo, mykey.(string))
+		writeToFifo(fifo, mykey)
 		time.Sleep(100 * time.Millisecond)
-		writeToFifo(fifo, mykey.(string))
+		writeToFifo(fifo, mykey)
 	}()
 
 	return fifo, nil
 }
 
+// sshKnownHosts writes the pinned host key of the node to a temporary
+// known_hosts file, and returns ssh options to verify the node with it.
+// The caller should remove the returned file.
+func sshKnownHosts(ctx context.Context, nodeName string) (string, []string, error) {
+	address := nodeName
+	cluster, err := storage.GetCluster(ctx)
+	if err != nil && err != cke.ErrNotFound {
+		return "", nil, err
+	}
+	if cluster != nil {
+		for _, n := range cluster.Nodes {
+			if n.Address == nodeName || n.Hostname == nodeName {
+				address = n.Address
+				break
+			}
+		}
+	}
+
+	key, err := storage.GetSSHHostKey(ctx, address)
+	if err == cke.ErrNotFound {
+		return "", nil, errors.New("no pinned ssh host key for " + nodeName)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	f, err := os.CreateTemp("", "ckecli-known-hosts-")
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(address + " " + strings.TrimSpace(key) + "\n")
+	if err != nil {
+		os.Remove(f.Name())
+		return "", nil, err
+	}
+
+	opts := []string{
+		"-o", "UserKnownHostsFile=" + f.Name(),
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "HostKeyAlias=" + address,
+	}
+	return f.Name(), opts, nil
+}
+
 func ssh(ctx context.Context, args []string) error {
 	node := detectSSHNode(args[0])
-	fifo, err := sshPrivateKey(node)
+	knownHosts, hostKeyOpts, err := sshKnownHosts(ctx, node)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(knownHosts)
+
+	fifo, err := sshPrivateKey(ctx, node)
 	if err != nil {
 		return err
 	}
@@ -104,10 +155,9 @@ func ssh(ctx context.Context, args []string) error {
 
 	sshArgs := []string{
 		"-i", fifo,
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "StrictHostKeyChecking=no",
 		"-o", "ConnectTimeout=60",
 	}
+	sshArgs = append(sshArgs, hostKeyOpts...)
 	sshArgs = append(sshArgs, args...)
 	c := exec.CommandContext(ctx, "ssh", sshArgs...)
 	c.Stdin = os.Stdin
@@ -123,6 +173,7 @@ var sshCmd = &cobra.Command{
 	Long: `Connect to the node via ssh.
 
 NODE is IP address or hostname of the node to be connected.
+The host key of the node is verified with the key pinned in etcd.
 
 If COMMAND is specified, it will be executed on the node.
 `,
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// sshHostKeyCmd represents the ssh-hostkey command
+var sshHostKeyCmd = &cobra.Command{
+	Use:   "ssh-hostkey",
+	Short: "ssh-hostkey subcommand",
+	Long: `Manage SSH host keys of nodes pinned in etcd.
+
+CKE and "ckecli ssh/scp" connect to a node only when its host key
+matches the pinned one.`,
+}
+
+func init() {
+	rootCmd.AddCommand(sshHostKeyCmd)
+}
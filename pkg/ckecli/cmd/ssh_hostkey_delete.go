@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+// sshHostKeyDeleteCmd represents the "ssh-hostkey delete" command
+var sshHostKeyDeleteCmd = &cobra.Command{
+	Use:   "delete ADDRESS",
+	Short: "unpin SSH host key of a node",
+	Long: `Unpin SSH host key of a node.
+
+Use this when the host key of the node has been regenerated legitimately,
+e.g. after reinstalling the OS.  With the default "tofu" policy, CKE pins
+the new key at the next connection.`,
+
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			return storage.DeleteSSHHostKey(ctx, args[0])
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	sshHostKeyCmd.AddCommand(sshHostKeyDeleteCmd)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+// sshHostKeyListCmd represents the "ssh-hostkey list" command
+var sshHostKeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list pinned SSH host keys",
+	Long:  `List pinned SSH host keys with their fingerprints.`,
+
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		well.Go(func(ctx context.Context) error {
+			keys, err := storage.GetSSHHostKeys(ctx)
+			if err != nil {
+				return err
+			}
+
+			addrs := make([]string, 0, len(keys))
+			for addr := range keys {
+				addrs = append(addrs, addr)
+			}
+			sort.Strings(addrs)
+
+			for _, addr := range addrs {
+				key, err := cke.ParseSSHHostKey(keys[addr])
+				if err != nil {
+					return fmt.Errorf("invalid ssh host key for %s: %w", addr, err)
+				}
+				fmt.Printf("%s\t%s\t%s\n", addr, key.Type(), cryptossh.FingerprintSHA256(key))
+			}
+			return nil
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	sshHostKeyCmd.AddCommand(sshHostKeyListCmd)
+}
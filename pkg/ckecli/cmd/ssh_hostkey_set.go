@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+// sshHostKeySetCmd represents the "ssh-hostkey set" command
+var sshHostKeySetCmd = &cobra.Command{
+	Use:   "set ADDRESS FILE|-",
+	Short: "pin SSH host key of a node",
+	Long: `Pin SSH host key of a node.
+
+ADDRESS is the address of the node.
+FILE should be a SSH public host key file such as ssh_host_ed25519_key.pub.
+If FILE is -, the contents are read from stdin.
+
+The existing key for the node, if any, is replaced.`,
+
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f := os.Stdin
+		if args[1] != "-" {
+			var err error
+			f, err = os.Open(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+		}
+
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		key, err := cke.ParseSSHHostKey(string(data))
+		if err != nil {
+			return err
+		}
+
+		well.Go(func(ctx context.Context) error {
+			return storage.PutSSHHostKey(ctx, args[0], cke.MarshalSSHHostKey(key))
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func init() {
+	sshHostKeyCmd.AddCommand(sshHostKeySetCmd)
+}
@@ -19,10 +19,17 @@ and stores it in etcd.
 
 The parameters are given by a JSON object having these fields:
 
-    endpoint:  Vault URL.
-    ca-cert:   PEM encoded CA certificate to verify server certificate.
-    role-id:   AppRole ID to login to Vault.
-    secret-id: AppRole secret to login to Vault.
+    endpoint:              Vault URL.
+    ca-cert:               PEM encoded CA certificate to verify server certificate.
+    auth-method:           "approle" (default), "kubernetes", "cert", or "token".
+    auth-mount:            Path of the auth method.  Default is the name of the method.
+    role-id:               AppRole ID to login to Vault.
+    secret-id:             AppRole secret to login to Vault.
+    role:                  Role for "kubernetes" and "cert" auth methods.
+    kubernetes-token-file: Service account token file for "kubernetes" auth method.
+    client-cert:           PEM encoded client certificate for "cert" auth method.
+    client-key:            PEM encoded private key for "cert" auth method.
+    token-file:            File containing a Vault token for "token" auth method.
 
 If the argument is "-", the JSON is read from stdin.`,
 
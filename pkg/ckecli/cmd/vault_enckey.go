@@ -1,14 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/cybozu-go/cke"
-	vault "github.com/hashicorp/vault/api"
+	"github.com/cybozu-go/well"
 	"github.com/spf13/cobra"
 	apiserverv1 "k8s.io/apiserver/pkg/apis/config/v1"
 )
@@ -16,26 +16,31 @@ import (
 var vaultEncKeyCmd = &cobra.Command{
 	Use:   "enckey",
 	Short: "generate new encryption key for Kubernetes Secrets",
-	Long: `Generate or rotate encryption keys for Kubernetes Secrets.
+	Long: `Generate new encryption keys for Kubernetes Secrets.
 
-WARNING: Key rotation is not fully implemented in this version!!
+This command generates a new encryption key for Kubernetes Secrets
+and makes it the key for encryption immediately.  The current key,
+if any, is retained to decrypt existing data.  Other old keys are removed.
 
-This command generates new encryption keys for Kubernetes Secrets and
-rotate old keys.  The current key, if any, is retained to decrypt
-existing data.  Other old keys are removed.`,
+Existing Secrets are NOT re-encrypted by this command.
+Use "ckecli vault enckey rotate" to rotate the key safely.`,
 
 	RunE: func(cmd *cobra.Command, args []string) error {
-		vc, err := inf.Vault()
-		if err != nil {
-			return err
-		}
-		err = rotateK8sEncryptionKey(vc)
-		if err != nil {
-			return err
-		}
+		well.Go(func(ctx context.Context) error {
+			b, err := inf.Backend()
+			if err != nil {
+				return err
+			}
+			err = rotateK8sEncryptionKey(ctx, b)
+			if err != nil {
+				return err
+			}
 
-		fmt.Println("succeeded")
-		return nil
+			fmt.Println("succeeded")
+			return nil
+		})
+		well.Stop()
+		return well.Wait()
 	},
 }
 
@@ -43,52 +48,54 @@ func init() {
 	vaultCmd.AddCommand(vaultEncKeyCmd)
 }
 
-func rotateK8sEncryptionKey(vc *vault.Client) error {
-	secret, err := vc.Logical().Read(cke.K8sSecret)
-	if err != nil {
+func rotateK8sEncryptionKey(ctx context.Context, b cke.Backend) error {
+	_, err := b.ReadSecret(ctx, cke.SecretK8s)
+	switch err {
+	case nil:
+	case cke.ErrNotFound:
+	default:
 		return err
 	}
 
-	var enckeys map[string]interface{}
-	if secret != nil && secret.Data != nil {
-		enckeys = secret.Data
-	} else {
-		enckeys = make(map[string]interface{})
+	keys := &cke.EncryptionKeys{
+		Primary:   cke.EncryptionProviderAESCBC,
+		Providers: make(map[string][]apiserverv1.Key),
 	}
-
-	var cfg apiserverv1.AESConfiguration
-	if data, ok := enckeys["aescbc"]; ok {
-		err = json.Unmarshal([]byte(data.(string)), &cfg)
+	if err == nil {
+		keys, err = cke.ReadEncryptionKeys(ctx, b)
 		if err != nil {
 			return err
 		}
 	}
 
-	newKey, err := generateKey()
+	newKey, err := newEncryptionKey()
 	if err != nil {
 		return err
 	}
-	keys := []apiserverv1.Key{
-		{
-			Name:   time.Now().UTC().Format(time.RFC3339),
-			Secret: base64.StdEncoding.EncodeToString(newKey),
-		},
+	current := []apiserverv1.Key{newKey}
+	if old := keys.Providers[keys.Primary]; len(old) > 0 {
+		current = append(current, old[0])
 	}
-	if len(cfg.Keys) > 0 {
-		keys = append(keys, cfg.Keys[0])
+	keys.Providers = map[string][]apiserverv1.Key{
+		keys.Primary: current,
 	}
-	cfg.Keys = keys
-	cfgData, err := json.Marshal(cfg)
+
+	return cke.WriteEncryptionKeys(ctx, b, keys)
+}
+
+// newEncryptionKey generates a new encryption key named after the current time.
+func newEncryptionKey() (apiserverv1.Key, error) {
+	key, err := generateKey()
 	if err != nil {
-		return err
+		return apiserverv1.Key{}, err
 	}
-	enckeys["aescbc"] = string(cfgData)
-
-	_, err = vc.Logical().Write(cke.K8sSecret, enckeys)
-	return err
+	return apiserverv1.Key{
+		Name:   time.Now().UTC().Format(time.RFC3339),
+		Secret: base64.StdEncoding.EncodeToString(key),
+	}, nil
 }
 
-// generateKey generates key for aescbc
+// generateKey generates key for aescbc, aesgcm, and secretbox
 // ref: https://kubernetes.io/docs/tasks/administer-cluster/encrypt-data/#providers
 func generateKey() ([]byte, error) {
 	key := make([]byte, 32)
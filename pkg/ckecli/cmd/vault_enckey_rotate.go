@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var vaultEncKeyRotateProvider string
+
+var vaultEncKeyRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "rotate encryption key for Kubernetes Secrets",
+	Long: `Rotate the encryption key for Kubernetes Secrets.
+
+This command generates a new encryption key for the provider,
+adds it to the backend as a key only for decryption, and registers
+a rotation request.  CKE server then proceeds the rotation step by step:
+
+1. Restart API servers one by one to load the new key.
+2. Make the new key the one for encryption and restart API servers.
+3. Rewrite all Secrets to encrypt them with the new key.
+4. Remove the old keys and restart API servers.
+
+The progress is stored in etcd, so the rotation can be resumed
+even if the leader of CKE server changes.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !isValidEncryptionProvider(vaultEncKeyRotateProvider) {
+			return errors.New("unsupported provider: " + vaultEncKeyRotateProvider)
+		}
+
+		well.Go(func(ctx context.Context) error {
+			_, err := storage.GetEncryptionKeyRotation(ctx)
+			switch err {
+			case nil:
+				return errors.New("encryption key rotation is already in progress")
+			case cke.ErrNotFound:
+			default:
+				return err
+			}
+
+			b, err := inf.Backend()
+			if err != nil {
+				return err
+			}
+			keys, err := cke.ReadEncryptionKeys(ctx, b)
+			if err != nil {
+				return err
+			}
+			newKey, err := newEncryptionKey()
+			if err != nil {
+				return err
+			}
+			keys.AddKey(vaultEncKeyRotateProvider, newKey)
+			err = cke.WriteEncryptionKeys(ctx, b, keys)
+			if err != nil {
+				return err
+			}
+
+			r := &cke.EncryptionKeyRotation{
+				Provider: vaultEncKeyRotateProvider,
+				KeyName:  newKey.Name,
+				Step:     cke.EncryptionKeyRotationAdd,
+				Started:  time.Now().UTC(),
+			}
+			err = storage.PutEncryptionKeyRotation(ctx, r)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("rotating to " + r.Provider + "/" + r.KeyName)
+			return nil
+		})
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+func isValidEncryptionProvider(provider string) bool {
+	for _, p := range cke.EncryptionProviders {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	vaultEncKeyRotateCmd.Flags().StringVar(&vaultEncKeyRotateProvider, "provider", cke.EncryptionProviderAESCBC, "encryption provider of the new key (aescbc, aesgcm, or secretbox)")
+	vaultEncKeyCmd.AddCommand(vaultEncKeyRotateCmd)
+}
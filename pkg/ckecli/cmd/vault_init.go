@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"time"
 
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/well"
@@ -17,6 +18,9 @@ import (
 const (
 	ttl100Year = "876000h"
 	ttl10Year  = "87600h"
+
+	// caLifetime is the lifetime of root CA certificates.
+	caLifetime = 876000 * time.Hour
 )
 
 type caParams struct {
@@ -199,15 +203,17 @@ func initVault(ctx context.Context) error {
 		}
 	}
 
-	secret, err := vc2.Logical().Read(cke.K8sSecret)
-	if err != nil {
-		return err
-	}
-	if secret != nil && secret.Data != nil {
+	b := cke.VaultBackend{Client: vc2}
+	_, err = b.ReadSecret(ctx, cke.SecretK8s)
+	switch err {
+	case nil:
 		return nil
+	case cke.ErrNotFound:
+	default:
+		return err
 	}
 
-	return rotateK8sEncryptionKey(vc2)
+	return rotateK8sEncryptionKey(ctx, b)
 }
 
 func createPKI(ctx context.Context, vc *vault.Client, ca caParams) error {
@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"io"
 	"os"
 
 	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
 	"github.com/spf13/cobra"
 )
 
@@ -13,8 +15,8 @@ var vaultSSHPrivKeyHost string
 // vaultSSHPrivKeyCmd represents the "vault ssh-privkey" command
 var vaultSSHPrivKeyCmd = &cobra.Command{
 	Use:   "ssh-privkey FILE|-",
-	Short: "store SSH private key into Vault",
-	Long: `Store SSH private key for a host into Vault.
+	Short: "store SSH private key into the backend",
+	Long: `Store SSH private key for a host into the backend.
 
 If --host is not specified, the key will be used as the default key.
 
@@ -38,26 +40,26 @@ If FILE is -, the contents are read from stdin.`,
 			return err
 		}
 
-		vc, err := inf.Vault()
-		if err != nil {
-			return err
-		}
-
-		secret, err := vc.Logical().Read(cke.SSHSecret)
-		if err != nil {
-			return err
-		}
+		well.Go(func(ctx context.Context) error {
+			b, err := inf.Backend()
+			if err != nil {
+				return err
+			}
 
-		var privkeys map[string]interface{}
-		if secret != nil && secret.Data != nil {
-			privkeys = secret.Data
-		} else {
-			privkeys = make(map[string]interface{})
-		}
-		privkeys[vaultSSHPrivKeyHost] = string(data)
+			privkeys, err := b.ReadSecret(ctx, cke.SecretSSH)
+			switch err {
+			case nil:
+			case cke.ErrNotFound:
+				privkeys = make(map[string]string)
+			default:
+				return err
+			}
+			privkeys[vaultSSHPrivKeyHost] = string(data)
 
-		_, err = vc.Logical().Write(cke.SSHSecret, privkeys)
-		return err
+			return b.WriteSecret(ctx, cke.SecretSSH, privkeys)
+		})
+		well.Stop()
+		return well.Wait()
 	},
 }
 
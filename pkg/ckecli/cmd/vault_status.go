@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+type vaultStatus struct {
+	Endpoint        string    `json:"endpoint"`
+	AuthMethod      string    `json:"auth_method"`
+	Connected       bool      `json:"connected"`
+	TokenTTL        string    `json:"token_ttl,omitempty"`
+	LastRenewal     time.Time `json:"last_renewal"`
+	RenewalFailures int       `json:"renewal_failures"`
+	Timestamp       time.Time `json:"timestamp"`
+	Error           string    `json:"error,omitempty"`
+}
+
+var vaultStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "show the connection state of Vault",
+	Long: `Show the state of the Vault token of the CKE server as JSON.
+
+The state is taken from the server status, so this command
+does not login to Vault by itself.  timestamp is the time when
+the server updated the status.
+If the server has not logged in to Vault or the token has expired,
+this command exits with status code 4.`,
+
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		st := new(vaultStatus)
+		well.Go(func(ctx context.Context) error {
+			cfg, err := storage.GetVaultConfig(ctx)
+			if err != nil {
+				return err
+			}
+			st.Endpoint = cfg.Endpoint
+			st.AuthMethod = cfg.Method()
+
+			ss, err := storage.GetStatus(ctx)
+			if err == cke.ErrNotFound {
+				st.Error = "no server status"
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			st.Timestamp = ss.Timestamp
+
+			vs := ss.Vault
+			if vs == nil {
+				st.Error = "the server has not logged in to vault"
+				return nil
+			}
+			st.AuthMethod = vs.AuthMethod
+			st.Connected = vs.Connected
+			st.LastRenewal = vs.LastRenewal
+			st.RenewalFailures = vs.RenewalFailures
+			if !vs.TokenExpiry.IsZero() {
+				ttl := time.Until(vs.TokenExpiry).Truncate(time.Second)
+				if ttl <= 0 {
+					st.Connected = false
+					st.Error = "the token has expired"
+					return nil
+				}
+				st.TokenTTL = ttl.String()
+			}
+			return nil
+		})
+		well.Stop()
+		err := well.Wait()
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(st)
+		if err != nil {
+			return err
+		}
+		if !st.Connected {
+			os.Exit(4)
+		}
+		return nil
+	},
+}
+
+func init() {
+	vaultCmd.AddCommand(vaultStatusCmd)
+}
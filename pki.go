@@ -3,13 +3,9 @@ package cke
 import (
 	"context"
 	"net"
-	"path"
-	"strings"
-	"sync"
+	"time"
 
-	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/netutil"
-	vault "github.com/hashicorp/vault/api"
 )
 
 // CNAPIServer is the common name of API server for aggregation
@@ -61,159 +57,100 @@ type IssueResponse struct {
 	CACert string `json:"ca_certificate"`
 }
 
-var roleLock sync.Mutex
-
-// addRole adds a role to CA if not exists.
-func addRole(client *vault.Client, ca, role string, data map[string]interface{}) error {
-	roleLock.Lock()
-	defer roleLock.Unlock()
-
-	l := client.Logical()
-	rpath := path.Join(ca, "roles", role)
-	secret, err := l.Read(rpath)
-	if err != nil {
-		return err
-	}
-	if secret != nil {
-		// already exists
-		return nil
-	}
-
-	_, err = l.Write(rpath, data)
-	if err != nil {
-		log.Error("failed to create vault role", map[string]interface{}{
-			log.FnError: err,
-			"ca":        ca,
-			"role":      role,
-		})
-	}
-	return err
-}
-
-// deleteRole deletes a role of CA.
-func deleteRole(client *vault.Client, ca, role string) error {
-	roleLock.Lock()
-	defer roleLock.Unlock()
-
-	l := client.Logical()
-	rpath := path.Join(ca, "roles", role)
-	l.Delete(rpath)
-	_, err := l.Read(rpath)
-	if err != nil {
-		return err
-	}
-	return err
-}
+// maxCertificateTTL is the maximum lifetime of certificates issued by roles
+// except for short-lived ones.
+const maxCertificateTTL = 87600 * time.Hour
 
 // EtcdCA is a certificate authority for etcd cluster.
 type EtcdCA struct{}
 
 // IssueServerCert issues TLS server certificates.
-func (e EtcdCA) IssueServerCert(ctx context.Context, inf Infrastructure, node *Node) (crt, key string, err error) {
+func (e EtcdCA) IssueServerCert(ctx context.Context, inf Infrastructure, node *Node, ttl time.Duration) (crt, key string, err error) {
 	altNames := []string{
 		"localhost",
 		"cke-etcd",
 		"cke-etcd.kube-system",
 		"cke-etcd.kube-system.svc",
 	}
-	return issueCertificate(inf, CAServer, RoleSystem, false,
-		map[string]interface{}{
-			"ttl":            "87600h",
-			"max_ttl":        "87600h",
-			"client_flag":    "false",
-			"allow_any_name": "true",
-		},
-		map[string]interface{}{
-			"common_name": node.Nodename(),
-			"alt_names":   strings.Join(altNames, ","),
-			"ip_sans":     "127.0.0.1," + node.Address,
-		})
+	if nodename := node.Nodename(); nodename != node.Address {
+		altNames = append(altNames, nodename)
+	}
+	return issueCertificate(ctx, inf, CAServer, CertificateRequest{
+		Role:        RoleSystem,
+		CommonName:  node.Nodename(),
+		AltNames:    altNames,
+		IPAddresses: []string{"127.0.0.1", node.Address},
+		ServerAuth:  true,
+		TTL:         certTTL(ttl),
+		MaxTTL:      maxCertificateTTL,
+	})
 }
 
 // IssuePeerCert issues TLS certificates for mutual peer authentication.
-func (e EtcdCA) IssuePeerCert(ctx context.Context, inf Infrastructure, node *Node) (crt, key string, err error) {
-	return issueCertificate(inf, CAEtcdPeer, RoleSystem, false,
-		map[string]interface{}{
-			"ttl":            "87600h",
-			"max_ttl":        "87600h",
-			"allow_any_name": "true",
-		},
-		map[string]interface{}{
-			"common_name":          node.Nodename(),
-			"ip_sans":              "127.0.0.1," + node.Address,
-			"exclude_cn_from_sans": "true",
-		})
+func (e EtcdCA) IssuePeerCert(ctx context.Context, inf Infrastructure, node *Node, ttl time.Duration) (crt, key string, err error) {
+	return issueCertificate(ctx, inf, CAEtcdPeer, CertificateRequest{
+		Role:        RoleSystem,
+		CommonName:  node.Nodename(),
+		IPAddresses: []string{"127.0.0.1", node.Address},
+		ServerAuth:  true,
+		ClientAuth:  true,
+		TTL:         certTTL(ttl),
+		MaxTTL:      maxCertificateTTL,
+	})
 }
 
 // IssueForAPIServer issues TLC client certificate for Kubernetes.
-func (e EtcdCA) IssueForAPIServer(ctx context.Context, inf Infrastructure, node *Node) (crt, key string, err error) {
-	return issueCertificate(inf, CAEtcdClient, RoleSystem, false,
-		map[string]interface{}{
-			"ttl":            "87600h",
-			"max_ttl":        "87600h",
-			"server_flag":    "false",
-			"allow_any_name": "true",
-		},
-		map[string]interface{}{
-			"common_name":          "kube-apiserver",
-			"exclude_cn_from_sans": "true",
-		})
+func (e EtcdCA) IssueForAPIServer(ctx context.Context, inf Infrastructure, node *Node, ttl time.Duration) (crt, key string, err error) {
+	return issueCertificate(ctx, inf, CAEtcdClient, CertificateRequest{
+		Role:       RoleSystem,
+		CommonName: "kube-apiserver",
+		ClientAuth: true,
+		TTL:        certTTL(ttl),
+		MaxTTL:     maxCertificateTTL,
+	})
 }
 
 // IssueRoot issues certificate for root user.
 func (e EtcdCA) IssueRoot(ctx context.Context, inf Infrastructure) (cert, key string, err error) {
-	return issueCertificate(inf, CAEtcdClient, RoleAdmin, false,
-		map[string]interface{}{
-			"ttl":            "2h",
-			"max_ttl":        "24h",
-			"server_flag":    "false",
-			"allow_any_name": "true",
-		},
-		map[string]interface{}{
-			"common_name":          "root",
-			"exclude_cn_from_sans": "true",
-			"ttl":                  "1h",
-		})
+	return issueCertificate(ctx, inf, CAEtcdClient, CertificateRequest{
+		Role:       RoleAdmin,
+		CommonName: "root",
+		ClientAuth: true,
+		TTL:        time.Hour,
+		MaxTTL:     24 * time.Hour,
+	})
 }
 
 // IssueEtcdClientCertificate issues TLS client certificate for a user.
-func IssueEtcdClientCertificate(inf Infrastructure, username, ttl string) (cert, key string, err error) {
-	return issueCertificate(inf, CAEtcdClient, RoleSystem, false,
-		map[string]interface{}{
-			"ttl":            "87600h",
-			"max_ttl":        "87600h",
-			"server_flag":    "false",
-			"allow_any_name": "true",
-		},
-		map[string]interface{}{
-			"common_name":          username,
-			"exclude_cn_from_sans": "true",
-			"ttl":                  ttl,
-		})
+func IssueEtcdClientCertificate(ctx context.Context, inf Infrastructure, username string, ttl time.Duration) (cert, key string, err error) {
+	return issueCertificate(ctx, inf, CAEtcdClient, CertificateRequest{
+		Role:       RoleSystem,
+		CommonName: username,
+		ClientAuth: true,
+		TTL:        ttl,
+		MaxTTL:     maxCertificateTTL,
+	})
 }
 
 // KubernetesCA is a certificate authority for k8s cluster.
 type KubernetesCA struct{}
 
 // IssueUserCert issues client certificate for user.
-func (k KubernetesCA) IssueUserCert(ctx context.Context, inf Infrastructure, userName, groupName string, ttl string) (crt, key string, err error) {
-	return issueCertificate(inf, CAKubernetes, RoleAdmin, true,
-		map[string]interface{}{
-			"ttl":               "2h",
-			"max_ttl":           "48h",
-			"enforce_hostnames": "false",
-			"allow_any_name":    "true",
-			"organization":      groupName,
-		},
-		map[string]interface{}{
-			"ttl":                  ttl,
-			"common_name":          userName,
-			"exclude_cn_from_sans": "true",
-		})
+func (k KubernetesCA) IssueUserCert(ctx context.Context, inf Infrastructure, userName, groupName string, ttl time.Duration) (crt, key string, err error) {
+	return issueCertificate(ctx, inf, CAKubernetes, CertificateRequest{
+		Role:         RoleAdmin,
+		Onetime:      true,
+		CommonName:   userName,
+		Organization: groupName,
+		ServerAuth:   true,
+		ClientAuth:   true,
+		TTL:          ttl,
+		MaxTTL:       48 * time.Hour,
+	})
 }
 
 // IssueForAPIServer issues TLS certificate for API servers.
-func (k KubernetesCA) IssueForAPIServer(ctx context.Context, inf Infrastructure, n *Node, serviceSubnet, clusterDomain string) (crt, key string, err error) {
+func (k KubernetesCA) IssueForAPIServer(ctx context.Context, inf Infrastructure, n *Node, serviceSubnet, clusterDomain string, ttl time.Duration) (crt, key string, err error) {
 	altNames := []string{
 		"localhost",
 		"kubernetes",
@@ -227,127 +164,100 @@ func (k KubernetesCA) IssueForAPIServer(ctx context.Context, inf Infrastructure,
 	}
 	kubeSvcAddr := netutil.IPAdd(ip, 1)
 
-	return issueCertificate(inf, CAKubernetes, RoleSystem, false,
-		map[string]interface{}{
-			"ttl":               "87600h",
-			"max_ttl":           "87600h",
-			"enforce_hostnames": "false",
-			"allow_any_name":    "true",
-		},
-		map[string]interface{}{
-			"common_name":          "kubernetes",
-			"alt_names":            strings.Join(altNames, ","),
-			"ip_sans":              "127.0.0.1," + n.Address + "," + kubeSvcAddr.String(),
-			"exclude_cn_from_sans": "true",
-		})
+	return issueCertificate(ctx, inf, CAKubernetes, CertificateRequest{
+		Role:        RoleSystem,
+		CommonName:  "kubernetes",
+		AltNames:    altNames,
+		IPAddresses: []string{"127.0.0.1", n.Address, kubeSvcAddr.String()},
+		ServerAuth:  true,
+		ClientAuth:  true,
+		TTL:         certTTL(ttl),
+		MaxTTL:      maxCertificateTTL,
+	})
 }
 
 // IssueForScheduler issues TLS certificate for kube-scheduler.
-func (k KubernetesCA) IssueForScheduler(ctx context.Context, inf Infrastructure) (crt, key string, err error) {
-	return issueCertificate(inf, CAKubernetes, RoleKubeScheduler, false,
-		map[string]interface{}{
-			"ttl":               "87600h",
-			"max_ttl":           "87600h",
-			"enforce_hostnames": "false",
-			"allow_any_name":    "true",
-			"organization":      "system:kube-scheduler",
-		},
-		map[string]interface{}{
-			"common_name":          "system:kube-scheduler",
-			"exclude_cn_from_sans": "true",
-		})
+func (k KubernetesCA) IssueForScheduler(ctx context.Context, inf Infrastructure, ttl time.Duration) (crt, key string, err error) {
+	return issueCertificate(ctx, inf, CAKubernetes, CertificateRequest{
+		Role:         RoleKubeScheduler,
+		CommonName:   "system:kube-scheduler",
+		Organization: "system:kube-scheduler",
+		ServerAuth:   true,
+		ClientAuth:   true,
+		TTL:          certTTL(ttl),
+		MaxTTL:       maxCertificateTTL,
+	})
 }
 
 // IssueForControllerManager issues TLS certificate for kube-controller-manager.
-func (k KubernetesCA) IssueForControllerManager(ctx context.Context, inf Infrastructure) (crt, key string, err error) {
-	return issueCertificate(inf, CAKubernetes, RoleKubeControllerManager, false,
-		map[string]interface{}{
-			"ttl":               "87600h",
-			"max_ttl":           "87600h",
-			"enforce_hostnames": "false",
-			"allow_any_name":    "true",
-			"organization":      "system:kube-controller-manager",
-		},
-		map[string]interface{}{
-			"common_name":          "system:kube-controller-manager",
-			"exclude_cn_from_sans": "true",
-		})
+func (k KubernetesCA) IssueForControllerManager(ctx context.Context, inf Infrastructure, ttl time.Duration) (crt, key string, err error) {
+	return issueCertificate(ctx, inf, CAKubernetes, CertificateRequest{
+		Role:         RoleKubeControllerManager,
+		CommonName:   "system:kube-controller-manager",
+		Organization: "system:kube-controller-manager",
+		ServerAuth:   true,
+		ClientAuth:   true,
+		TTL:          certTTL(ttl),
+		MaxTTL:       maxCertificateTTL,
+	})
 }
 
 // IssueForKubelet issues TLS certificate for kubelet.
-func (k KubernetesCA) IssueForKubelet(ctx context.Context, inf Infrastructure, node *Node) (crt, key string, err error) {
+func (k KubernetesCA) IssueForKubelet(ctx context.Context, inf Infrastructure, node *Node, ttl time.Duration) (crt, key string, err error) {
 	nodename := node.Nodename()
-	altNames := "localhost"
+	altNames := []string{"localhost"}
 	if nodename != node.Address {
-		altNames = "localhost," + nodename
+		altNames = append(altNames, nodename)
 	}
 
-	return issueCertificate(inf, CAKubernetes, RoleKubelet, false,
-		map[string]interface{}{
-			"ttl":               "87600h",
-			"max_ttl":           "87600h",
-			"enforce_hostnames": "false",
-			"allow_any_name":    "true",
-			"organization":      "system:nodes",
-		},
-		map[string]interface{}{
-			"common_name":          "system:node:" + nodename,
-			"alt_names":            altNames,
-			"ip_sans":              "127.0.0.1," + node.Address,
-			"exclude_cn_from_sans": "true",
-		})
+	return issueCertificate(ctx, inf, CAKubernetes, CertificateRequest{
+		Role:         RoleKubelet,
+		CommonName:   "system:node:" + nodename,
+		Organization: "system:nodes",
+		AltNames:     altNames,
+		IPAddresses:  []string{"127.0.0.1", node.Address},
+		ServerAuth:   true,
+		ClientAuth:   true,
+		TTL:          certTTL(ttl),
+		MaxTTL:       maxCertificateTTL,
+	})
 }
 
 // IssueForProxy issues TLS certificate for kube-proxy.
-func (k KubernetesCA) IssueForProxy(ctx context.Context, inf Infrastructure) (crt, key string, err error) {
-	return issueCertificate(inf, CAKubernetes, RoleKubeProxy, false,
-		map[string]interface{}{
-			"ttl":               "87600h",
-			"max_ttl":           "87600h",
-			"enforce_hostnames": "false",
-			"allow_any_name":    "true",
-			"organization":      "system:node-proxier",
-		},
-		map[string]interface{}{
-			"common_name":          "system:kube-proxy",
-			"exclude_cn_from_sans": "true",
-		})
+func (k KubernetesCA) IssueForProxy(ctx context.Context, inf Infrastructure, ttl time.Duration) (crt, key string, err error) {
+	return issueCertificate(ctx, inf, CAKubernetes, CertificateRequest{
+		Role:         RoleKubeProxy,
+		CommonName:   "system:kube-proxy",
+		Organization: "system:node-proxier",
+		ServerAuth:   true,
+		ClientAuth:   true,
+		TTL:          certTTL(ttl),
+		MaxTTL:       maxCertificateTTL,
+	})
 }
 
 // IssueForServiceAccount issues TLS certificate to sign service account tokens.
 func (k KubernetesCA) IssueForServiceAccount(ctx context.Context, inf Infrastructure) (crt, key string, err error) {
-	return issueCertificate(inf, CAKubernetes, RoleServiceAccount, false,
-		map[string]interface{}{
-			"ttl":            "87600h",
-			"max_ttl":        "87600h",
-			"allow_any_name": "true",
-			"client_flag":    "false",
-			"server_flag":    "false",
-			"key_usage":      "DigitalSignature,CertSign",
-			"no_store":       "true",
-		},
-		map[string]interface{}{
-			"common_name":          "service-account",
-			"exclude_cn_from_sans": "true",
-		})
+	return issueCertificate(ctx, inf, CAKubernetes, CertificateRequest{
+		Role:       RoleServiceAccount,
+		CommonName: "service-account",
+		Signing:    true,
+		MaxTTL:     maxCertificateTTL,
+	})
 }
 
 // AggregationCA is a certificate authority for kubernetes aggregation API server
 type AggregationCA struct{}
 
 // IssueClientCertificate issues TLS client certificate for API server
-func (a AggregationCA) IssueClientCertificate(ctx context.Context, inf Infrastructure) (cert, key string, err error) {
-	return issueCertificate(inf, CAKubernetesAggregation, RoleSystem, false,
-		map[string]interface{}{
-			"ttl":            "87600h",
-			"max_ttl":        "87600h",
-			"server_flag":    "false",
-			"allow_any_name": "true",
-		},
-		map[string]interface{}{
-			"common_name":          CNAPIServer,
-			"exclude_cn_from_sans": "true",
-		})
+func (a AggregationCA) IssueClientCertificate(ctx context.Context, inf Infrastructure, ttl time.Duration) (cert, key string, err error) {
+	return issueCertificate(ctx, inf, CAKubernetesAggregation, CertificateRequest{
+		Role:       RoleSystem,
+		CommonName: CNAPIServer,
+		ClientAuth: true,
+		TTL:        certTTL(ttl),
+		MaxTTL:     maxCertificateTTL,
+	})
 }
 
 // WebhookCA is a certificate authority for kubernetes admission webhooks
@@ -356,45 +266,27 @@ type WebhookCA struct{}
 // IssueCertificate issues TLS server certificate
 // `namespace` and `name` specifies the namespace/name of a webhook Service.
 func (WebhookCA) IssueCertificate(ctx context.Context, inf Infrastructure, namespace, name string) (cert, key string, err error) {
-	altNames := []string{name, name + "." + namespace, name + "." + namespace + ".svc"}
-	return issueCertificate(inf, CAWebhook, RoleSystem, false,
-		map[string]interface{}{
-			"ttl":               "175200h",
-			"max_ttl":           "175200h",
-			"enforce_hostnames": "false",
-			"allow_any_name":    "true",
-			"server_flag":       "true",
-			"client_flag":       "false",
-		},
-		map[string]interface{}{
-			"common_name":          namespace + "/" + name,
-			"alt_names":            strings.Join(altNames, ","),
-			"exclude_cn_from_sans": "true",
-		})
+	return issueCertificate(ctx, inf, CAWebhook, CertificateRequest{
+		Role:       RoleSystem,
+		CommonName: namespace + "/" + name,
+		AltNames:   []string{name, name + "." + namespace, name + "." + namespace + ".svc"},
+		ServerAuth: true,
+		MaxTTL:     175200 * time.Hour,
+	})
 }
 
-func issueCertificate(inf Infrastructure, ca, role string, onetime bool, roleOpts, certOpts map[string]interface{}) (crt, key string, err error) {
-	pkiKey := VaultPKIKey(ca)
-	client, err := inf.Vault()
-	if err != nil {
-		return "", "", err
-	}
-
-	err = addRole(client, pkiKey, role, roleOpts)
-	if err != nil {
-		return "", "", err
+// certTTL returns TTL of a component certificate.
+func certTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return DefaultCertificateTTL
 	}
+	return ttl
+}
 
-	secret, err := client.Logical().Write(path.Join(pkiKey, "issue", role), certOpts)
+func issueCertificate(ctx context.Context, inf Infrastructure, ca string, req CertificateRequest) (crt, key string, err error) {
+	b, err := inf.Backend()
 	if err != nil {
 		return "", "", err
 	}
-	crt = secret.Data["certificate"].(string)
-	if onetime {
-		if err := deleteRole(client, pkiKey, role); err != nil {
-			return "", "", err
-		}
-	}
-	key = secret.Data["private_key"].(string)
-	return crt, key, err
+	return b.IssueCertificate(ctx, ca, req)
 }
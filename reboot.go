@@ -1,5 +1,11 @@
 package cke
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
 // RebootStatus is status of reboot operation
 type RebootStatus string
 
@@ -7,6 +13,8 @@ type RebootStatus string
 const (
 	RebootStatusQueued    = RebootStatus("queued")
 	RebootStatusRebooting = RebootStatus("rebooting")
+	RebootStatusVerifying = RebootStatus("verifying")
+	RebootStatusFailed    = RebootStatus("failed")
 	RebootStatusCancelled = RebootStatus("cancelled")
 )
 
@@ -15,6 +23,25 @@ type RebootQueueEntry struct {
 	Index  int64        `json:"index,string"`
 	Nodes  []string     `json:"nodes"`
 	Status RebootStatus `json:"status"`
+
+	// Priority determines the order of entries.  Entries with higher
+	// priority are processed first, and those with the same priority
+	// are processed in the order of Index.
+	Priority int `json:"priority,omitempty"`
+	// NotBefore is the time before which the entry is not started.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// Deadline is the time after which the entry is removed if not started.
+	Deadline  *time.Time `json:"deadline,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	Requester string     `json:"requester,omitempty"`
+
+	// BootIDs are the boot IDs of nodes recorded before rebooting.
+	// Keys are IP addresses.  Nodes that were unreachable have no entry.
+	BootIDs map[string]string `json:"boot_ids,omitempty"`
+	// Rebooted is the time when the reboot command was run.
+	Rebooted *time.Time `json:"rebooted,omitempty"`
+	// FailedNodes are the addresses of nodes that failed verification.
+	FailedNodes []string `json:"failed_nodes,omitempty"`
 }
 
 // NewRebootQueueEntry creates new `RebootQueueEntry`.
@@ -25,3 +52,44 @@ func NewRebootQueueEntry(nodes []string) *RebootQueueEntry {
 		Status: RebootStatusQueued,
 	}
 }
+
+// Validate validates the schedule of the entry.
+func (e *RebootQueueEntry) Validate() error {
+	if e.NotBefore != nil && e.Deadline != nil && !e.Deadline.After(*e.NotBefore) {
+		return errors.New("deadline must be after not-before")
+	}
+	return nil
+}
+
+// Ready returns true if the entry may be started at now.
+func (e *RebootQueueEntry) Ready(now time.Time) bool {
+	return e.NotBefore == nil || !now.Before(*e.NotBefore)
+}
+
+// Expired returns true if the entry has not been started by its deadline.
+func (e *RebootQueueEntry) Expired(now time.Time) bool {
+	return e.Status == RebootStatusQueued && e.Deadline != nil && now.After(*e.Deadline)
+}
+
+// ValidateRebootNodes checks that nodes to be rebooted in an entry are in
+// the cluster.  For safety, an entry may contain at most one control plane.
+func ValidateRebootNodes(nodes []string, cluster *Cluster) error {
+	numCPs := 0
+OUTER:
+	for _, rebootNode := range nodes {
+		for _, clusterNode := range cluster.Nodes {
+			if rebootNode == clusterNode.Address {
+				if clusterNode.ControlPlane {
+					numCPs++
+				}
+				continue OUTER
+			}
+		}
+		return fmt.Errorf("%s is not a valid node IP address", rebootNode)
+	}
+
+	if numCPs > 1 {
+		return errors.New("multiple control planes cannot be enqueued in one entry")
+	}
+	return nil
+}
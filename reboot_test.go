@@ -0,0 +1,116 @@
+package cke
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRebootQueueEntrySchedule(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	testCases := []struct {
+		name    string
+		entry   RebootQueueEntry
+		ready   bool
+		expired bool
+	}{
+		{"no schedule", RebootQueueEntry{Status: RebootStatusQueued}, true, false},
+		{"not before in the future", RebootQueueEntry{Status: RebootStatusQueued, NotBefore: &after}, false, false},
+		{"not before in the past", RebootQueueEntry{Status: RebootStatusQueued, NotBefore: &before}, true, false},
+		{"deadline in the future", RebootQueueEntry{Status: RebootStatusQueued, Deadline: &after}, true, false},
+		{"deadline in the past", RebootQueueEntry{Status: RebootStatusQueued, Deadline: &before}, true, true},
+		{"rebooting after deadline", RebootQueueEntry{Status: RebootStatusRebooting, Deadline: &before}, true, false},
+	}
+
+	for _, tc := range testCases {
+		if tc.entry.Ready(now) != tc.ready {
+			t.Errorf("%s: Ready() should be %v", tc.name, tc.ready)
+		}
+		if tc.entry.Expired(now) != tc.expired {
+			t.Errorf("%s: Expired() should be %v", tc.name, tc.expired)
+		}
+	}
+}
+
+func TestRebootQueueEntryValidate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	if err := (&RebootQueueEntry{NotBefore: &now, Deadline: &later}).Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := (&RebootQueueEntry{NotBefore: &later, Deadline: &now}).Validate(); err == nil {
+		t.Error("deadline before not-before should be rejected")
+	}
+	if err := (&RebootQueueEntry{NotBefore: &now, Deadline: &now}).Validate(); err == nil {
+		t.Error("deadline equal to not-before should be rejected")
+	}
+}
+
+func TestValidateRebootNodes(t *testing.T) {
+	t.Parallel()
+
+	cluster := &Cluster{
+		Nodes: []*Node{
+			{
+				Address:      "1.1.1.1",
+				ControlPlane: true,
+			},
+			{
+				Address:      "2.2.2.2",
+				ControlPlane: true,
+			},
+			{
+				Address: "4.4.4.4",
+			},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		nodes   []string
+		succeed bool
+	}{
+		{
+			name:    "succeed",
+			nodes:   []string{"1.1.1.1"},
+			succeed: true,
+		},
+		{
+			name:    "control plane and worker",
+			nodes:   []string{"1.1.1.1", "4.4.4.4"},
+			succeed: true,
+		},
+		{
+			name:    "non-existing node",
+			nodes:   []string{"3.3.3.3"},
+			succeed: false,
+		},
+		{
+			name:    "multiple control-plane nodes",
+			nodes:   []string{"1.1.1.1", "2.2.2.2"},
+			succeed: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ret := ValidateRebootNodes(tc.nodes, cluster)
+			if tc.succeed {
+				if ret != nil {
+					t.Errorf("ValidateRebootNodes() failed unexpectedly: %v", ret)
+				}
+			} else {
+				if ret == nil {
+					t.Error("ValidateRebootNodes() succeeded unexpectedly")
+				}
+			}
+		})
+	}
+}
@@ -26,6 +26,20 @@ type Record struct {
 	Error     string       `json:"error"`
 	StartAt   time.Time    `json:"start-at"`
 	EndAt     time.Time    `json:"end-at"`
+	Hooks     []HookResult `json:"hooks,omitempty"`
+}
+
+// HookResult represents the result of a hook run by an operation
+type HookResult struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Stage    RebootHookStage `json:"stage"`
+	Node     string          `json:"node"`
+	Attempts int             `json:"attempts"`
+	Skipped  bool            `json:"skipped,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	StartAt  time.Time       `json:"start-at"`
+	EndAt    time.Time       `json:"end-at"`
 }
 
 // NewRecord creates new `Record`
@@ -62,6 +76,11 @@ func (r *Record) SetInfo(i string) {
 	r.Info = i
 }
 
+// SetHookResults records the results of hooks run so far
+func (r *Record) SetHookResults(h []HookResult) {
+	r.Hooks = h
+}
+
 // SetError cancels the operation with error information
 func (r *Record) SetError(e error) {
 	r.Status = StatusCancelled
@@ -0,0 +1,70 @@
+package cke
+
+import (
+	"errors"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// OperationRetry is the retry state of an operation that has failed.
+type OperationRetry struct {
+	Operation string   `json:"operation"`
+	Targets   []string `json:"targets,omitempty"`
+
+	// Attempts is the number of consecutive failures.
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	// Permanent is true if the last error is not likely to be resolved by retrying.
+	Permanent bool `json:"permanent,omitempty"`
+
+	// Failures are the times of recent failures including those
+	// followed by successful attempts.  They are used to detect flapping.
+	Failures []time.Time `json:"failures,omitempty"`
+	// NextRetry is the time after which the operation may be retried.
+	NextRetry time.Time `json:"next_retry"`
+
+	// Parked is true if the operation will not be retried until the state is reset.
+	Parked bool `json:"parked,omitempty"`
+}
+
+// PermanentError represents an error that is not resolved by retrying.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks err as not resolved by retrying.
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// IsPermanentError returns true if err is not likely to be resolved by retrying.
+// Errors not known to be permanent are considered transient.
+// Authentication and authorization errors are transient because
+// they can be resolved by renewing credentials or fixing RBAC rules.
+func IsPermanentError(err error) bool {
+	var pe *PermanentError
+	if errors.As(err, &pe) {
+		return true
+	}
+	var he *HostKeyError
+	if errors.As(err, &he) {
+		return true
+	}
+
+	switch {
+	case apierrors.IsBadRequest(err),
+		apierrors.IsInvalid(err),
+		apierrors.IsMethodNotSupported(err):
+		return true
+	}
+	return false
+}
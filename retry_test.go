@@ -0,0 +1,41 @@
+package cke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIsPermanentError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"plain", errors.New("connection refused"), false},
+		{"timeout", context.DeadlineExceeded, false},
+		{"permanent", Permanent(errors.New("bad")), true},
+		{"wrapped permanent", fmt.Errorf("op: %w", Permanent(errors.New("bad"))), true},
+		{"host key", &HostKeyError{Address: "10.0.0.1", Actual: "SHA256:xxx"}, true},
+		{"invalid", apierrors.NewInvalid(schema.GroupKind{Kind: "Node"}, "node1", nil), true},
+		{"forbidden", apierrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "node1", errors.New("no")), false},
+		{"unauthorized", apierrors.NewUnauthorized("expired"), false},
+		{"conflict", apierrors.NewConflict(schema.GroupResource{Resource: "nodes"}, "node1", errors.New("no")), false},
+		{"server timeout", apierrors.NewServerTimeout(schema.GroupResource{Resource: "nodes"}, "get", 1), false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := IsPermanentError(tc.err); actual != tc.expected {
+				t.Errorf("IsPermanentError(%v) = %v, expected %v", tc.err, actual, tc.expected)
+			}
+		})
+	}
+}
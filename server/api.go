@@ -0,0 +1,539 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/log"
+	k8sYaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+const apiV1Prefix = "/api/v1/"
+
+type resource struct {
+	Key        string `json:"key"`
+	Revision   int64  `json:"revision"`
+	Definition string `json:"definition"`
+}
+
+type rebootRequest struct {
+	Nodes     []string   `json:"nodes"`
+	Priority  int        `json:"priority"`
+	NotBefore *time.Time `json:"not_before"`
+	Deadline  *time.Time `json:"deadline"`
+	Reason    string     `json:"reason"`
+	Requester string     `json:"requester"`
+}
+
+// authenticate checks the bearer token in the request.
+// This returns nil if the request is authenticated.
+func (s Server) authenticate(r *http.Request) *APIError {
+	if s.APIToken == "" {
+		e := APIErrForbidden
+		return &e
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		e := Unauthorized("no bearer token")
+		return &e
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.APIToken)) != 1 {
+		e := Unauthorized("invalid bearer token")
+		return &e
+	}
+	return nil
+}
+
+func (s Server) handleAPIV1(w http.ResponseWriter, r *http.Request) {
+	p := r.URL.Path[len(apiV1Prefix):]
+
+	sep := strings.IndexByte(p, '/')
+	var param string
+	if sep != -1 {
+		param = p[sep+1:]
+		p = p[:sep]
+	}
+
+	switch {
+	case p == "cluster" && sep == -1:
+		s.handleCluster(w, r)
+	case p == "constraints" && sep == -1:
+		s.handleConstraints(w, r)
+	case p == "resources":
+		s.handleResources(w, r, param)
+	case p == "reboot-queue":
+		s.handleRebootQueue(w, r, param)
+	case p == "records" && sep == -1:
+		s.handleRecords(w, r)
+	default:
+		renderError(r.Context(), w, APIErrNotFound)
+	}
+}
+
+func (s Server) storage() cke.Storage {
+	return cke.Storage{Client: s.EtcdClient}
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxClusterSize+1))
+	if err != nil {
+		return nil, InternalServerError(err)
+	}
+	if len(data) > maxClusterSize {
+		return nil, APIErrTooLargeAsset
+	}
+	return data, nil
+}
+
+func renderStorageError(ctx context.Context, w http.ResponseWriter, err error) {
+	var apiErr APIError
+	switch {
+	case errors.As(err, &apiErr):
+		renderError(ctx, w, apiErr)
+	case err == cke.ErrNotFound:
+		renderError(ctx, w, APIErrNotFound)
+	default:
+		renderError(ctx, w, InternalServerError(err))
+	}
+}
+
+func (s Server) handleCluster(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleClusterGet(w, r)
+	case http.MethodPut:
+		s.handleClusterPut(w, r)
+	default:
+		renderError(r.Context(), w, APIErrBadMethod)
+	}
+}
+
+func (s Server) handleClusterGet(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	cluster, err := s.storage().GetCluster(ctx)
+	if err != nil {
+		renderStorageError(r.Context(), w, err)
+		return
+	}
+	renderJSON(w, cluster, http.StatusOK)
+}
+
+func (s Server) handleClusterPut(w http.ResponseWriter, r *http.Request) {
+	data, err := readBody(r)
+	if err != nil {
+		renderStorageError(r.Context(), w, err)
+		return
+	}
+
+	cluster := cke.NewCluster()
+	if err := yaml.Unmarshal(data, cluster); err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+	if err := cluster.Validate(false); err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	storage := s.storage()
+	constraints, err := storage.GetConstraints(ctx)
+	switch err {
+	case cke.ErrNotFound:
+		constraints = cke.DefaultConstraints()
+	case nil:
+	default:
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	if err := constraints.Check(cluster); err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+
+	if err := storage.PutCluster(ctx, cluster); err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s Server) handleConstraints(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleConstraintsGet(w, r)
+	case http.MethodPut:
+		s.handleConstraintsPut(w, r)
+	default:
+		renderError(r.Context(), w, APIErrBadMethod)
+	}
+}
+
+func (s Server) handleConstraintsGet(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	constraints, err := s.storage().GetConstraints(ctx)
+	switch err {
+	case cke.ErrNotFound:
+		constraints = cke.DefaultConstraints()
+	case nil:
+	default:
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	renderJSON(w, constraints, http.StatusOK)
+}
+
+func (s Server) handleConstraintsPut(w http.ResponseWriter, r *http.Request) {
+	data, err := readBody(r)
+	if err != nil {
+		renderStorageError(r.Context(), w, err)
+		return
+	}
+
+	constraints := cke.DefaultConstraints()
+	if err := yaml.Unmarshal(data, constraints); err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+	if err := constraints.Validate(); err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	if err := s.storage().PutConstraints(ctx, constraints); err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s Server) handleResources(w http.ResponseWriter, r *http.Request, key string) {
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		s.handleResourcesList(w, r)
+	case r.Method == http.MethodGet:
+		s.handleResourcesGet(w, r, key)
+	case r.Method == http.MethodPut && key == "":
+		s.handleResourcesPut(w, r)
+	case r.Method == http.MethodDelete && key != "":
+		s.handleResourcesDelete(w, r, key)
+	default:
+		renderError(r.Context(), w, APIErrBadMethod)
+	}
+}
+
+func (s Server) handleResourcesList(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	keys, err := s.storage().ListResources(ctx)
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	renderJSON(w, keys, http.StatusOK)
+}
+
+func (s Server) handleResourcesGet(w http.ResponseWriter, r *http.Request, key string) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	data, rev, err := s.storage().GetResource(ctx, key)
+	if err != nil {
+		renderStorageError(r.Context(), w, err)
+		return
+	}
+	renderJSON(w, resource{
+		Key:        key,
+		Revision:   rev,
+		Definition: string(data),
+	}, http.StatusOK)
+}
+
+func (s Server) handleResourcesPut(w http.ResponseWriter, r *http.Request) {
+	data, err := readBody(r)
+	if err != nil {
+		renderStorageError(r.Context(), w, err)
+		return
+	}
+
+	// parse all resources before storing any of them.
+	var keys []string
+	var defs [][]byte
+	y := k8sYaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		def, err := y.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			renderError(r.Context(), w, BadRequest(err.Error()))
+			return
+		}
+		if len(bytes.TrimSpace(def)) == 0 {
+			continue
+		}
+
+		key, err := cke.ParseResource(def)
+		if err != nil {
+			renderError(r.Context(), w, BadRequest(err.Error()))
+			return
+		}
+		keys = append(keys, key)
+		defs = append(defs, def)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	storage := s.storage()
+	for i, key := range keys {
+		if err := storage.SetResource(ctx, key, string(defs[i])); err != nil {
+			renderError(r.Context(), w, InternalServerError(err))
+			return
+		}
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	renderJSON(w, keys, http.StatusOK)
+}
+
+func (s Server) handleResourcesDelete(w http.ResponseWriter, r *http.Request, key string) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	if err := s.storage().DeleteResource(ctx, key); err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s Server) handleRebootQueue(w http.ResponseWriter, r *http.Request, param string) {
+	switch {
+	case r.Method == http.MethodGet && param == "":
+		s.handleRebootQueueList(w, r)
+	case r.Method == http.MethodPost && param == "":
+		s.handleRebootQueueAdd(w, r)
+	case r.Method == http.MethodDelete && param == "":
+		s.handleRebootQueueCancelAll(w, r)
+	case r.Method == http.MethodDelete:
+		index, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			renderError(r.Context(), w, BadRequest("invalid index: "+param))
+			return
+		}
+		s.handleRebootQueueCancel(w, r, index)
+	default:
+		renderError(r.Context(), w, APIErrBadMethod)
+	}
+}
+
+func (s Server) handleRebootQueueList(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	entries, err := s.storage().GetRebootsEntries(ctx)
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	if entries == nil {
+		entries = []*cke.RebootQueueEntry{}
+	}
+	renderJSON(w, entries, http.StatusOK)
+}
+
+func (s Server) handleRebootQueueAdd(w http.ResponseWriter, r *http.Request) {
+	data, err := readBody(r)
+	if err != nil {
+		renderStorageError(r.Context(), w, err)
+		return
+	}
+
+	req := new(rebootRequest)
+	if err := json.Unmarshal(data, req); err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+	if len(req.Nodes) == 0 {
+		renderError(r.Context(), w, BadRequest("no nodes"))
+		return
+	}
+	entry := cke.NewRebootQueueEntry(req.Nodes)
+	entry.Priority = req.Priority
+	entry.NotBefore = req.NotBefore
+	entry.Deadline = req.Deadline
+	entry.Reason = req.Reason
+	entry.Requester = req.Requester
+	if err := entry.Validate(); err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	storage := s.storage()
+	cluster, err := storage.GetCluster(ctx)
+	if err != nil {
+		renderStorageError(r.Context(), w, err)
+		return
+	}
+	if err := cke.ValidateRebootNodes(req.Nodes, cluster); err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+
+	if err := storage.RegisterRebootsEntry(ctx, entry); err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	renderJSON(w, entry, http.StatusCreated)
+}
+
+func (s Server) handleRebootQueueCancel(w http.ResponseWriter, r *http.Request, index int64) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	storage := s.storage()
+	entry, err := storage.GetRebootsEntry(ctx, index)
+	if err != nil {
+		renderStorageError(r.Context(), w, err)
+		return
+	}
+
+	entry.Status = cke.RebootStatusCancelled
+	if err := storage.UpdateRebootsEntry(ctx, entry); err != nil {
+		renderStorageError(r.Context(), w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s Server) handleRebootQueueCancelAll(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	storage := s.storage()
+	entries, err := storage.GetRebootsEntries(ctx)
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.Status == cke.RebootStatusCancelled {
+			continue
+		}
+
+		entry.Status = cke.RebootStatusCancelled
+		err := storage.UpdateRebootsEntry(ctx, entry)
+		if err == cke.ErrNotFound {
+			// The entry has just finished
+			continue
+		}
+		if err != nil {
+			renderError(r.Context(), w, InternalServerError(err))
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s Server) handleRecords(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		renderError(r.Context(), w, APIErrBadMethod)
+		return
+	}
+
+	var count int64
+	if v := r.URL.Query().Get("count"); v != "" {
+		c, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || c < 0 {
+			renderError(r.Context(), w, BadRequest("invalid count: "+v))
+			return
+		}
+		count = c
+	}
+
+	if r.URL.Query().Get("watch") == "true" {
+		s.handleRecordsWatch(w, r, count)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+	defer cancel()
+
+	records, err := s.storage().GetRecords(ctx, count)
+	if err != nil {
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	if records == nil {
+		records = []*cke.Record{}
+	}
+	renderJSON(w, records, http.StatusOK)
+}
+
+func (s Server) handleRecordsWatch(w http.ResponseWriter, r *http.Request, count int64) {
+	ctx, cancel := context.WithCancel(r.Context())
+	ch, err := s.storage().WatchRecords(ctx, count)
+	if err != nil {
+		cancel()
+		renderError(r.Context(), w, InternalServerError(err))
+		return
+	}
+	defer func() {
+		// stop watching and drain the channel so that the watcher goroutine exits.
+		cancel()
+		for range ch {
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	enc := json.NewEncoder(w)
+	for record := range ch {
+		if err := enc.Encode(record); err != nil {
+			log.Warn("failed to send a record", map[string]interface{}{
+				log.FnError: err,
+			})
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
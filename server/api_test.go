@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIAuthentication(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		auth   string
+		status int
+	}{
+		{"disabled", "", http.MethodGet, "/api/v1/cluster", "Bearer foo", http.StatusForbidden},
+		{"disabled-plan", "", http.MethodPost, "/plan", "Bearer foo", http.StatusForbidden},
+		{"plan-no-header", "foo", http.MethodPost, "/plan", "", http.StatusUnauthorized},
+		{"plan-wrong-token", "foo", http.MethodPost, "/plan", "Bearer bar", http.StatusUnauthorized},
+		{"no-header", "foo", http.MethodGet, "/api/v1/cluster", "", http.StatusUnauthorized},
+		{"not-bearer", "foo", http.MethodGet, "/api/v1/cluster", "Basic foo", http.StatusUnauthorized},
+		{"wrong-token", "foo", http.MethodGet, "/api/v1/cluster", "Bearer bar", http.StatusUnauthorized},
+		{"not-found", "foo", http.MethodGet, "/api/v1/foo", "Bearer foo", http.StatusNotFound},
+		{"cluster-subpath", "foo", http.MethodGet, "/api/v1/cluster/foo", "Bearer foo", http.StatusNotFound},
+		{"bad-method", "foo", http.MethodPost, "/api/v1/cluster", "Bearer foo", http.StatusMethodNotAllowed},
+		{"plan-bad-method", "foo", http.MethodGet, "/plan", "Bearer foo", http.StatusMethodNotAllowed},
+		{"delete-all-resources", "foo", http.MethodDelete, "/api/v1/resources", "Bearer foo", http.StatusMethodNotAllowed},
+		{"invalid-index", "foo", http.MethodDelete, "/api/v1/reboot-queue/abc", "Bearer foo", http.StatusBadRequest},
+		{"invalid-count", "foo", http.MethodGet, "/api/v1/records?count=-1", "Bearer foo", http.StatusBadRequest},
+		{"invalid-cluster", "foo", http.MethodPut, "/api/v1/cluster", "Bearer foo", http.StatusBadRequest},
+		{"invalid-reboot", "foo", http.MethodPost, "/api/v1/reboot-queue", "Bearer foo", http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := Server{Timeout: time.Second, APIToken: tc.token}
+			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{"nodes": []}`))
+			if tc.auth != "" {
+				r.Header.Set("Authorization", tc.auth)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Errorf("unexpected status: expected=%d, actual=%d, body=%s", tc.status, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate header is not set")
+			}
+		})
+	}
+}
@@ -32,9 +32,15 @@ func BadRequest(reason string) APIError {
 	return APIError{http.StatusBadRequest, "invalid request: " + reason, nil}
 }
 
+// Unauthorized creates an APIError for a request without valid credentials.
+func Unauthorized(reason string) APIError {
+	return APIError{http.StatusUnauthorized, "unauthorized: " + reason, nil}
+}
+
 // Common API errors
 var (
 	APIErrBadRequest     = APIError{http.StatusBadRequest, "invalid request", nil}
+	APIErrUnauthorized   = APIError{http.StatusUnauthorized, "unauthorized", nil}
 	APIErrForbidden      = APIError{http.StatusForbidden, "forbidden", nil}
 	APIErrNotFound       = APIError{http.StatusNotFound, "requested resource is not found", nil}
 	APIErrBadMethod      = APIError{http.StatusMethodNotAllowed, "method not allowed", nil}
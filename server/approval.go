@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/log"
+)
+
+// approvalGate holds operations that need approval until they are approved.
+// An approval is consumed when the operation succeeds.
+// A nil *approvalGate holds no operations.
+type approvalGate struct {
+	storage   cke.Storage
+	leaderKey string
+	approval  cke.Approval
+
+	mu      sync.Mutex
+	pending map[string]*cke.PendingOperation
+}
+
+// newApprovalGate loads pending operations and deletes expired ones.
+func newApprovalGate(ctx context.Context, storage cke.Storage, leaderKey string, approval cke.Approval, now time.Time) (*approvalGate, error) {
+	entries, err := storage.GetPendingOperations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string]*cke.PendingOperation, len(entries))
+	for _, p := range entries {
+		if !p.Expired(approval.Expiry(), now) {
+			pending[p.ID] = p
+			continue
+		}
+
+		log.Info("pending operation expired", map[string]interface{}{
+			"id":     p.ID,
+			"op":     p.Operation,
+			"status": p.Status,
+		})
+		err := storage.DeletePendingOperation(ctx, leaderKey, p.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &approvalGate{
+		storage:   storage,
+		leaderKey: leaderKey,
+		approval:  approval,
+		pending:   pending,
+	}, nil
+}
+
+// filter removes operations that need approval and have not been approved.
+// New pending operations are registered for them.
+// This returns the remaining operations and the held ones.
+func (g *approvalGate) filter(ctx context.Context, phase cke.OperationPhase, ops []cke.Operator, now time.Time) ([]cke.Operator, []*cke.PendingOperation, error) {
+	if g == nil {
+		return ops, nil, nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var remaining []cke.Operator
+	var held []*cke.PendingOperation
+	for _, op := range ops {
+		if !g.approval.NeedsApproval(op.Name()) {
+			remaining = append(remaining, op)
+			continue
+		}
+
+		p := g.pending[cke.OperationID(op)]
+		if p == nil {
+			p = cke.NewPendingOperation(op, phase, now)
+			err := g.storage.PutPendingOperation(ctx, g.leaderKey, p)
+			if err != nil {
+				return nil, nil, err
+			}
+			g.pending[p.ID] = p
+			log.Warn("operation is waiting for approval", map[string]interface{}{
+				"id":      p.ID,
+				"op":      p.Operation,
+				"targets": p.Targets,
+			})
+		}
+
+		if p.Status == cke.PendingOperationApproved {
+			remaining = append(remaining, op)
+			continue
+		}
+		held = append(held, p)
+	}
+	return remaining, held, nil
+}
+
+// approvedOps removes operations that need approval and have not been
+// approved, like approvalGate.filter, without registering pending operations.
+// This returns the remaining operations and the names of the held ones.
+func approvedOps(approval cke.Approval, pending []*cke.PendingOperation, ops []cke.Operator, now time.Time) ([]cke.Operator, []string) {
+	approved := make(map[string]bool)
+	for _, p := range pending {
+		if p.Status == cke.PendingOperationApproved && !p.Expired(approval.Expiry(), now) {
+			approved[p.ID] = true
+		}
+	}
+
+	var remaining []cke.Operator
+	var held []string
+	for _, op := range ops {
+		if !approval.NeedsApproval(op.Name()) || approved[cke.OperationID(op)] {
+			remaining = append(remaining, op)
+			continue
+		}
+		held = append(held, op.Name())
+	}
+	return remaining, held
+}
+
+// done consumes the approval of the operation that has succeeded.
+func (g *approvalGate) done(ctx context.Context, op cke.Operator) error {
+	if g == nil {
+		return nil
+	}
+
+	id := cke.OperationID(op)
+	g.mu.Lock()
+	p := g.pending[id]
+	delete(g.pending, id)
+	g.mu.Unlock()
+	if p == nil {
+		return nil
+	}
+	return g.storage.DeletePendingOperation(ctx, g.leaderKey, id)
+}
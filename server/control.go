@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/metrics"
+	"github.com/cybozu-go/cke/op"
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/well"
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -20,6 +22,24 @@ var (
 	errCommandFailure = errors.New("command failed")
 )
 
+// commandError is returned from runOp when a command of the operation fails.
+// It matches errCommandFailure with errors.Is.
+type commandError struct {
+	err error
+}
+
+func (e *commandError) Error() string {
+	return e.err.Error()
+}
+
+func (e *commandError) Unwrap() error {
+	return e.err
+}
+
+func (e *commandError) Is(target error) bool {
+	return target == errCommandFailure
+}
+
 // Controller manage operations
 type Controller struct {
 	session         *concurrency.Session
@@ -27,11 +47,30 @@ type Controller struct {
 	certsGCInterval time.Duration
 	timeout         time.Duration
 	addon           Integrator
+
+	// maxConcurrentOps is the maximum number of operations run concurrently.
+	maxConcurrentOps int
+	// agentPool keeps SSH connections to nodes while this server is the leader.
+	agentPool *cke.AgentPool
+	// statusCache caches node statuses between operation loops.
+	statusCache *op.NodeStatusCache
+	// planning is true if the controller only makes a plan.
+	// Metrics are not updated in that case.
+	planning bool
 }
 
 // NewController construct controller instance
-func NewController(s *concurrency.Session, interval, gcInterval, timeout time.Duration, addon Integrator) Controller {
-	return Controller{s, interval, gcInterval, timeout, addon}
+func NewController(s *concurrency.Session, interval, gcInterval, timeout time.Duration, maxConcurrentOps int, addon Integrator) Controller {
+	return Controller{
+		session:          s,
+		interval:         interval,
+		certsGCInterval:  gcInterval,
+		timeout:          timeout,
+		addon:            addon,
+		maxConcurrentOps: maxConcurrentOps,
+		agentPool:        cke.NewAgentPool(),
+		statusCache:      op.NewNodeStatusCache(op.DefaultNodeStatusCacheTTL),
+	}
 }
 
 // Run execute procedures with leader elections
@@ -67,6 +106,7 @@ func (c Controller) Run(ctx context.Context) error {
 		"session": c.session.Lease(),
 	})
 	metrics.UpdateLeader(true)
+	defer c.agentPool.Close()
 
 	// Release the leader before terminating.
 	defer func() {
@@ -183,6 +223,22 @@ func (c Controller) runLoop(ctx context.Context, leaderKey string) error {
 		}
 	})
 
+	env.Go(func(ctx context.Context) error {
+		ticker := time.NewTicker(etcdBackupCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+			err := c.runEtcdBackup(ctx, leaderKey)
+			if err != nil {
+				return err
+			}
+		}
+	})
+
 	env.Stop()
 	return env.Wait()
 }
@@ -191,25 +247,28 @@ func (c Controller) checkLastOp(ctx context.Context, leaderKey string) error {
 	storage := cke.Storage{
 		Client: c.session.Client(),
 	}
-	records, err := storage.GetRecords(ctx, 1)
+
+	// Operations may have run concurrently, so check all records.
+	records, err := storage.GetRecords(ctx, 0)
 	if err != nil {
 		return err
 	}
-	if len(records) == 0 {
-		return nil
-	}
 
-	r := records[0]
-	if r.Status == cke.StatusCancelled || r.Status == cke.StatusCompleted {
-		return nil
-	}
+	for _, r := range records {
+		if r.Status == cke.StatusCancelled || r.Status == cke.StatusCompleted {
+			continue
+		}
 
-	log.Warn("cancel the orphaned operation", map[string]interface{}{
-		"id": r.ID,
-		"op": r.Operation,
-	})
-	r.Cancel()
-	return storage.UpdateRecord(ctx, leaderKey, r)
+		log.Warn("cancel the orphaned operation", map[string]interface{}{
+			"id": r.ID,
+			"op": r.Operation,
+		})
+		r.Cancel()
+		if err := storage.UpdateRecord(ctx, leaderKey, r); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c Controller) runOnce(ctx context.Context, leaderKey string, tick <-chan time.Time, watchChan, addonChan <-chan struct{}) error {
@@ -254,7 +313,8 @@ func (c Controller) runOnce(ctx context.Context, leaderKey string, tick <-chan t
 		return nil
 	}
 
-	inf, err := cke.NewInfrastructure(ctx, cluster, storage)
+	connectStart := time.Now()
+	inf, err := cke.NewPooledInfrastructure(ctx, cluster, storage, c.agentPool)
 	if err != nil {
 		// When the vault token is revoked, the following error will be returned. In this case, CKE can not continue any operations.
 		// Error: "Error making API request.\n\nURL: GET <<URL>>\nCode: 403. Errors:\n\n* permission denied"
@@ -273,6 +333,7 @@ func (c Controller) runOnce(ctx context.Context, leaderKey string, tick <-chan t
 		return nil
 	}
 	defer inf.Close()
+	metrics.UpdateStatusCollectionDuration(metrics.StatusPhaseConnect, time.Since(connectStart))
 
 	// prepare service account signing
 	_, err = storage.GetServiceAccountCert(ctx)
@@ -317,27 +378,64 @@ func (c Controller) runOnce(ctx context.Context, leaderKey string, tick <-chan t
 	}
 	metrics.UpdateReboot(len(re))
 
-	var reboot *cke.RebootQueueEntry
+	var reboots []*cke.RebootQueueEntry
 	if len(re) > 0 {
 		disabled, err := inf.Storage().IsRebootQueueDisabled(ctx)
 		if err != nil {
 			return err
 		}
 		if !disabled {
-			reboot = re[0]
+			reboots = re
 		}
 	}
-	ops, phase := DecideOps(cluster, status, constraints, rcs, reboot)
+	ops, phase := DecideOps(cluster, status, constraints, rcs, reboots, ts)
+
+	retries, err := storage.GetOperationRetries(ctx)
+	if err != nil {
+		return err
+	}
+
+	pause, err := storage.GetPause(ctx)
+	switch err {
+	case nil:
+	case cke.ErrNotFound:
+		pause = nil
+	default:
+		return err
+	}
+	ops, pausedOps := filterPausedOps(pause, phase, ops, cluster)
+
+	approvals, err := newApprovalGate(ctx, storage, leaderKey, cluster.Approval, ts)
+	if err != nil {
+		return err
+	}
+	ops, pendingOps, err := approvals.filter(ctx, phase, ops, ts)
+	if err != nil {
+		return err
+	}
+	if len(ops) == 0 && len(pendingOps) > 0 {
+		phase = cke.PhaseWaitingApproval
+	}
 
 	st := &cke.ServerStatus{
-		Phase:     phase,
-		Timestamp: ts,
+		Phase:             phase,
+		Timestamp:         ts,
+		Upgrade:           NewNodeFilter(cluster, status).UpgradeStatus(),
+		SSHErrors:         sshErrors(status),
+		Retries:           retries,
+		Pause:             pause,
+		PausedOperations:  pausedOps,
+		PendingOperations: pendingOps,
+		Vault:             vaultStatus(),
 	}
 	err = storage.SetStatus(ctx, c.session.Lease(), st)
 	if err != nil {
 		return err
 	}
 	metrics.UpdateOperationPhase(phase, ts)
+	metrics.UpdateCertificateExpiry(status.NodeStatuses)
+	metrics.UpdateOperationRetries(retries)
+	metrics.UpdatePaused(pause != nil)
 
 	if len(ops) == 0 {
 		wait = true
@@ -355,29 +453,142 @@ func (c Controller) runOnce(ctx context.Context, leaderKey string, tick <-chan t
 		}
 	}
 
-	for _, op := range ops {
-		err := runOp(ctx, op, leaderKey, storage, inf)
-		switch err {
-		case nil:
-		case errCommandFailure:
-			wait = true
-			return nil
-		default:
-			return err
-		}
+	err = c.runOps(ctx, ops, leaderKey, storage, inf, newRetryTracker(storage, leaderKey, retries), approvals)
+	switch err {
+	case nil:
+	case errCommandFailure:
+		wait = true
+		return nil
+	default:
+		return err
 	}
 
 	return nil
 }
 
-func runOp(ctx context.Context, op cke.Operator, leaderKey string, storage cke.Storage, inf cke.Infrastructure) error {
-	// register operation record
+// conflicts returns true if the operators must not run concurrently.
+func conflicts(a, b cke.Operator) bool {
+	fa, ok := a.(cke.FootprintOperator)
+	if !ok {
+		return true
+	}
+	fb, ok := b.(cke.FootprintOperator)
+	if !ok {
+		return true
+	}
+	return fa.Footprint().Conflicts(fb.Footprint())
+}
+
+// runOps runs operators concurrently up to c.maxConcurrentOps.
+// Conflicting operators run in the order of ops.  When an operator fails,
+// operators that conflict with it are skipped while others continue.
+//
+// Operators that are backing off or parked by retries are skipped too.
+// Approvals of operators that have succeeded are consumed.
+//
+// This returns errCommandFailure if any operator has failed or been skipped.
+func (c Controller) runOps(ctx context.Context, ops []cke.Operator, leaderKey string, storage cke.Storage, inf cke.Infrastructure, retries *retryTracker, approvals *approvalGate) error {
+	type result struct {
+		done chan struct{}
+		err  error
+	}
+
+	results := make([]*result, len(ops))
+	for i := range ops {
+		results[i] = &result{done: make(chan struct{})}
+	}
+
+	maxOps := c.maxConcurrentOps
+	if maxOps < 1 {
+		maxOps = 1
+	}
+	sem := make(chan struct{}, maxOps)
+
+	env := well.NewEnvironment(ctx)
+	for i, o := range ops {
+		i, o := i, o
+		env.Go(func(ctx context.Context) error {
+			r := results[i]
+			defer close(r.done)
+
+			for j := 0; j < i; j++ {
+				if !conflicts(ops[j], o) {
+					continue
+				}
+				<-results[j].done
+				if results[j].err != nil {
+					log.Warn("skip the operation because a conflicting operation has not completed", map[string]interface{}{
+						"op":          o.Name(),
+						"conflicting": ops[j].Name(),
+					})
+					r.err = errCommandFailure
+					return nil
+				}
+			}
+
+			if !retries.allow(o, time.Now()) {
+				r.err = errCommandFailure
+				return nil
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				r.err = ctx.Err()
+				return nil
+			}
+			r.err = runOp(ctx, o, leaderKey, storage, inf)
+			<-sem
+
+			// The operation may have changed files or containers on the targets.
+			c.statusCache.Invalidate(o.Targets()...)
+			switch {
+			case r.err == nil:
+				if err := approvals.done(ctx, o); err != nil {
+					return err
+				}
+				return retries.succeeded(ctx, o, time.Now())
+			case errors.Is(r.err, errCommandFailure):
+				return retries.failed(ctx, o, r.err, time.Now())
+			}
+			return r.err
+		})
+	}
+	env.Stop()
+	if err := env.Wait(); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if r.err != nil {
+			return errCommandFailure
+		}
+	}
+	return nil
+}
+
+// recordMu serializes registration of records by concurrent operations.
+var recordMu sync.Mutex
+
+func registerRecord(ctx context.Context, op cke.Operator, leaderKey string, storage cke.Storage) (*cke.Record, error) {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+
 	id, err := storage.NextRecordID(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	record := cke.NewRecord(id, op.Name(), op.Targets())
 	err = storage.RegisterRecord(ctx, leaderKey, record)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func runOp(ctx context.Context, op cke.Operator, leaderKey string, storage cke.Storage, inf cke.Infrastructure) error {
+	// register operation record
+	record, err := registerRecord(ctx, op, leaderKey, storage)
 	if err != nil {
 		return err
 	}
@@ -412,6 +623,7 @@ func runOp(ctx context.Context, op cke.Operator, leaderKey string, storage cke.S
 		})
 
 		record.SetCommand(commander.Command())
+		setHookResults(record, op)
 		err = storage.UpdateRecord(ctx, leaderKey, record)
 		if err != nil {
 			return err
@@ -430,19 +642,21 @@ func runOp(ctx context.Context, op cke.Operator, leaderKey string, storage cke.S
 			"command":   commander.Command().String(),
 		})
 		record.SetError(err)
+		setHookResults(record, op)
 		err2 := storage.UpdateRecord(ctx, leaderKey, record)
 		if err2 != nil {
 			return err2
 		}
 
-		// return errCommandFailure instead of err as command failure need to be
+		// return commandError instead of err as command failure need to be
 		// handled gracefully.
-		return errCommandFailure
+		return &commandError{err: err}
 	}
 
 	if iop, ok := op.(cke.InfoOperator); ok {
 		record.SetInfo(iop.Info())
 	}
+	setHookResults(record, op)
 
 	record.Complete()
 	err = storage.UpdateRecord(ctx, leaderKey, record)
@@ -455,11 +669,23 @@ func runOp(ctx context.Context, op cke.Operator, leaderKey string, storage cke.S
 	return nil
 }
 
+func setHookResults(record *cke.Record, op cke.Operator) {
+	if hop, ok := op.(cke.HookOperator); ok {
+		record.SetHookResults(hop.HookResults())
+	}
+}
+
 func (c Controller) runTidyExpiredCertificates(ctx context.Context) error {
 	storage := cke.Storage{
 		Client: c.session.Client(),
 	}
 
+	// The built-in backend does not store issued certificates.
+	bc, err := storage.GetBackendConfig(ctx)
+	if err == nil && bc.Type == cke.BackendBuiltin {
+		return nil
+	}
+
 	cfg, err := storage.GetVaultConfig(ctx)
 	if err != nil {
 		log.Warn("failed to get vault config. skip tidy", map[string]interface{}{
@@ -485,3 +711,38 @@ func (c Controller) runTidyExpiredCertificates(ctx context.Context) error {
 
 	return nil
 }
+
+// vaultStatus returns the state of the Vault token of this process,
+// or nil if this process has not logged in to Vault.
+func vaultStatus() *cke.VaultStatus {
+	st := cke.GetVaultTokenStatus()
+	if st.AuthMethod == "" {
+		return nil
+	}
+
+	vs := &cke.VaultStatus{
+		AuthMethod:      st.AuthMethod,
+		Connected:       st.Connected,
+		LastRenewal:     st.LastRenewal,
+		RenewalFailures: st.RenewalFailures,
+	}
+	if st.TTL > 0 {
+		vs.TokenExpiry = st.LastRenewal.Add(st.TTL)
+	}
+	return vs
+}
+
+// sshErrors returns the reasons why nodes are not connected via SSH.
+func sshErrors(status *cke.ClusterStatus) map[string]string {
+	var errs map[string]string
+	for addr, ns := range status.NodeStatuses {
+		if ns.SSHConnected || len(ns.SSHError) == 0 {
+			continue
+		}
+		if errs == nil {
+			errs = make(map[string]string)
+		}
+		errs[addr] = ns.SSHError
+	}
+	return errs
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/cke/op"
+	"github.com/cybozu-go/cke/op/k8s"
+)
+
+func TestConflicts(t *testing.T) {
+	t.Parallel()
+
+	cp := &cke.Node{Address: "10.0.0.11", ControlPlane: true}
+	worker1 := &cke.Node{Address: "10.0.0.101"}
+	worker2 := &cke.Node{Address: "10.0.0.102"}
+	img := cke.Image("kubernetes")
+
+	kubeletBoot := k8s.KubeletBootOp([]*cke.Node{worker1}, nil, cp, "test", cke.KubeletParams{}, nil, 0, img)
+	kubeletRestart := k8s.KubeletRestartOp([]*cke.Node{worker2}, cp, "test", cke.KubeletParams{}, nil, 0, 0, img)
+	proxyRestart := k8s.KubeProxyRestartOp([]*cke.Node{worker1, worker2}, "test", "", cke.ProxyParams{}, 0, 0, img)
+	kmsBoot := k8s.KMSPluginBootOp([]*cke.Node{cp}, cke.KMSParams{})
+	apiServerRestart := k8s.APIServerRestartOp([]*cke.Node{cp}, []*cke.Node{cp}, "10.68.0.0/16", cke.APIServerParams{}, "cluster.local", 0, img)
+	schedulerRestart := k8s.SchedulerRestartOp([]*cke.Node{cp}, "test", cke.SchedulerParams{}, 0, img)
+	wait := op.KubeWaitOp(cp)
+
+	testCases := []struct {
+		name     string
+		a        cke.Operator
+		b        cke.Operator
+		expected bool
+	}{
+		{"kubelet and kube-proxy", kubeletBoot, proxyRestart, false},
+		{"kubelets through the same API server", kubeletBoot, kubeletRestart, true},
+		{"kubelet and its API server", kubeletBoot, apiServerRestart, true},
+		{"KMS plugin and API server", kmsBoot, apiServerRestart, true},
+		{"scheduler and API server", schedulerRestart, apiServerRestart, false},
+		{"operator without footprint", wait, proxyRestart, true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := conflicts(tc.a, tc.b); actual != tc.expected {
+				t.Errorf("conflicts(a, b) = %v, expected %v", actual, tc.expected)
+			}
+			if actual := conflicts(tc.b, tc.a); actual != tc.expected {
+				t.Errorf("conflicts(b, a) = %v, expected %v", actual, tc.expected)
+			}
+		})
+	}
+}
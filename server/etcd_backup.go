@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/cke/etcdbackup"
+	"github.com/cybozu-go/cke/metrics"
+	"github.com/cybozu-go/log"
+)
+
+// etcdBackupCheckInterval is the interval to check whether an etcd backup is due.
+const etcdBackupCheckInterval = time.Minute
+
+func (c Controller) runEtcdBackup(ctx context.Context, leaderKey string) error {
+	storage := cke.Storage{
+		Client: c.session.Client(),
+	}
+
+	cluster, err := storage.GetCluster(ctx)
+	switch err {
+	case nil:
+	case cke.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+	if !cluster.EtcdBackup.Enabled {
+		return nil
+	}
+
+	// Do not take backups of etcd being restored.
+	_, err = storage.GetEtcdRestoreRequest(ctx)
+	switch err {
+	case nil:
+		return nil
+	case cke.ErrNotFound:
+	default:
+		return err
+	}
+
+	st, err := storage.GetEtcdBackupStatus(ctx)
+	switch err {
+	case nil:
+		metrics.UpdateEtcdBackup(st.Time, st.Size)
+		if time.Since(st.Time) < etcdbackup.Interval(&cluster.EtcdBackup) {
+			return nil
+		}
+	case cke.ErrNotFound:
+	default:
+		return err
+	}
+
+	st, err = takeEtcdBackup(ctx, cluster, storage)
+	if err != nil {
+		log.Error("failed to take etcd backup", map[string]interface{}{
+			log.FnError: err,
+		})
+		// lint:ignore nilerr  Try again later.
+		return nil
+	}
+
+	err = storage.PutEtcdBackupStatus(ctx, leaderKey, st)
+	if err != nil {
+		return err
+	}
+	metrics.UpdateEtcdBackup(st.Time, st.Size)
+	log.Info("took etcd backup", map[string]interface{}{
+		"name":     st.Name,
+		"size":     st.Size,
+		"checksum": st.Checksum,
+	})
+	return nil
+}
+
+func takeEtcdBackup(ctx context.Context, cluster *cke.Cluster, storage cke.Storage) (*cke.EtcdBackupStatus, error) {
+	backend, err := etcdbackup.NewBackend(&cluster.EtcdBackup)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only control plane nodes are needed to access etcd.
+	// Omitting other nodes avoids opening SSH connections to them.
+	var endpoints []string
+	cpCluster := *cluster
+	cpCluster.Nodes = nil
+	for _, n := range cluster.Nodes {
+		if n.ControlPlane {
+			cpCluster.Nodes = append(cpCluster.Nodes, n)
+			endpoints = append(endpoints, fmt.Sprintf("https://%s:2379", n.Address))
+		}
+	}
+
+	inf, err := cke.NewInfrastructure(ctx, &cpCluster, storage)
+	if err != nil {
+		return nil, err
+	}
+	defer inf.Close()
+
+	etcd, err := inf.NewEtcdClient(ctx, endpoints)
+	if err != nil {
+		return nil, err
+	}
+	defer etcd.Close()
+
+	st, err := etcdbackup.Take(ctx, etcd, backend, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = etcdbackup.RemoveOld(ctx, backend, etcdbackup.Rotate(&cluster.EtcdBackup))
+	if err != nil {
+		log.Warn("failed to remove old etcd backups", map[string]interface{}{
+			log.FnError: err,
+		})
+	}
+	return st, nil
+}
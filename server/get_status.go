@@ -4,23 +4,39 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/cke/metrics"
 	"github.com/cybozu-go/cke/op"
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/well"
 )
 
+// maxConcurrentNodeStatus is the maximum number of nodes whose statuses are gathered concurrently.
+const maxConcurrentNodeStatus = 32
+
 // GetClusterStatus consults the whole cluster and constructs *ClusterStatus.
 func (c Controller) GetClusterStatus(ctx context.Context, cluster *cke.Cluster, inf cke.Infrastructure) (*cke.ClusterStatus, error) {
 	var mu sync.Mutex
 	statuses := make(map[string]*cke.NodeStatus)
 
+	c.statusCache.Retain(cluster.Nodes)
+
+	start := time.Now()
+	sem := make(chan struct{}, maxConcurrentNodeStatus)
 	env := well.NewEnvironment(ctx)
 	for _, n := range cluster.Nodes {
 		n := n
 		env.Go(func(ctx context.Context) error {
-			ns, err := op.GetNodeStatus(ctx, inf, n, cluster)
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			defer func() { <-sem }()
+
+			ns, err := op.GetNodeStatus(ctx, inf, n, cluster, c.statusCache)
 			if err != nil {
 				return fmt.Errorf("%s: %v", n.Address, err)
 			}
@@ -36,6 +52,7 @@ func (c Controller) GetClusterStatus(ctx context.Context, cluster *cke.Cluster,
 	if err != nil {
 		return nil, err
 	}
+	c.updateStatusCollectionDuration(metrics.StatusPhaseNodes, time.Since(start))
 
 	cs := new(cke.ClusterStatus)
 	version, err := inf.Storage().GetConfigVersion(ctx)
@@ -45,6 +62,35 @@ func (c Controller) GetClusterStatus(ctx context.Context, cluster *cke.Cluster,
 	cs.ConfigVersion = version
 	cs.NodeStatuses = statuses
 
+	restore, err := inf.Storage().GetEtcdRestoreRequest(ctx)
+	switch err {
+	case nil:
+		// etcd and Kubernetes are going to be restored.  Their statuses are not needed.
+		cs.EtcdRestore = restore
+		return cs, nil
+	case cke.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	rotation, err := inf.Storage().GetEncryptionKeyRotation(ctx)
+	switch err {
+	case nil:
+		cs.EncryptionKeyRotation = rotation
+	case cke.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	caRotation, err := inf.Storage().GetCARotation(ctx)
+	switch err {
+	case nil:
+		cs.CARotation = caRotation
+	case cke.ErrNotFound:
+	default:
+		return nil, err
+	}
+
 	var etcdRunning bool
 	for _, n := range cke.ControlPlanes(cluster.Nodes) {
 		ns := statuses[n.Address]
@@ -57,7 +103,9 @@ func (c Controller) GetClusterStatus(ctx context.Context, cluster *cke.Cluster,
 		return cs, nil
 	}
 
+	start = time.Now()
 	ecs, err := op.GetEtcdClusterStatus(ctx, inf, cluster.Nodes)
+	c.updateStatusCollectionDuration(metrics.StatusPhaseEtcd, time.Since(start))
 	if err != nil {
 		log.Warn("failed to get etcd cluster status", map[string]interface{}{
 			log.FnError: err,
@@ -79,7 +127,9 @@ func (c Controller) GetClusterStatus(ctx context.Context, cluster *cke.Cluster,
 		return cs, nil
 	}
 
+	start = time.Now()
 	kcs, err := op.GetKubernetesClusterStatus(ctx, inf, livingMaster, cluster)
+	c.updateStatusCollectionDuration(metrics.StatusPhaseKubernetes, time.Since(start))
 	if err != nil {
 		log.Error("failed to get kubernetes cluster status", map[string]interface{}{
 			log.FnError: err,
@@ -90,3 +140,10 @@ func (c Controller) GetClusterStatus(ctx context.Context, cluster *cke.Cluster,
 
 	return cs, nil
 }
+
+func (c Controller) updateStatusCollectionDuration(phase string, d time.Duration) {
+	if c.planning {
+		return
+	}
+	metrics.UpdateStatusCollectionDuration(phase, d)
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/op"
@@ -270,7 +271,7 @@ func (nf *NodeFilter) EtcdOutdatedMembers() (nodes []*cke.Node) {
 		}
 		currentBuiltIn := etcd.BuiltInParams(n, []string{}, "new")
 		switch {
-		case cke.EtcdImage.Name() != st.Image:
+		case nf.cluster.Images.EtcdImage().Name() != st.Image:
 			fallthrough
 		case !etcdEqualParams(st.BuiltInParams, currentBuiltIn):
 			fallthrough
@@ -292,18 +293,27 @@ func (nf *NodeFilter) APIServerStoppedNodes() (nodes []*cke.Node) {
 }
 
 // APIServerOutdatedNodes returns nodes that are running API server with outdated image or params.
+//
+// While etcd is being upgraded, this returns nothing.  While API servers are
+// being upgraded, this returns only one node to upgrade them one by one.
 func (nf *NodeFilter) APIServerOutdatedNodes() (nodes []*cke.Node) {
+	stage := nf.upgradeStage()
+	if stage < upgradeStageAPIServer {
+		return nil
+	}
 	currentExtra := nf.cluster.Options.APIServer
 	kubeletConfig := k8s.GenerateKubeletConfiguration(nf.cluster.Options.Kubelet, "0.0.0.0", nil)
 
 	for _, n := range nf.cp {
 		st := nf.nodeStatus(n).APIServer
 		currentBuiltIn := k8s.APIServerParams(n.Address, nf.cluster.ServiceSubnet,
-			currentExtra.AuditLogEnabled, currentExtra.AuditLogPolicy, currentExtra.AuditLogPath, kubeletConfig.ClusterDomain)
+			currentExtra.AuditLogEnabled, currentExtra.AuditLogPolicy, currentExtra.AuditLogPath, kubeletConfig.ClusterDomain, currentExtra.KMS)
 		switch {
 		case !st.Running:
 			// stopped nodes are excluded
-		case cke.KubernetesImage.Name() != st.Image:
+		case !nf.kmsPluginReady(n):
+			// API server using KMS is restarted after the plugin becomes up-to-date
+		case nf.cluster.Images.KubernetesImage().Name() != st.Image:
 			fallthrough
 		case !currentBuiltIn.Equal(st.BuiltInParams):
 			fallthrough
@@ -311,9 +321,82 @@ func (nf *NodeFilter) APIServerOutdatedNodes() (nodes []*cke.Node) {
 			nodes = append(nodes, n)
 		}
 	}
+	if stage == upgradeStageAPIServer && len(nodes) > 0 {
+		// wait for the previously upgraded API server to become healthy
+		for _, n := range nf.cp {
+			st := nf.nodeStatus(n).APIServer
+			if st.Running && !st.IsHealthy {
+				return nil
+			}
+		}
+		return nodes[:1]
+	}
 	return nodes
 }
 
+// KMSPluginStoppedNodes returns control plane nodes that are not running KMS plugin.
+// This returns nothing if KMS is not used.
+func (nf *NodeFilter) KMSPluginStoppedNodes() (nodes []*cke.Node) {
+	if nf.cluster.Options.APIServer.KMS == nil {
+		return nil
+	}
+	for _, n := range nf.cp {
+		if !nf.nodeStatus(n).KMSPlugin.Running {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// KMSPluginOutdatedNodes returns nodes that are running KMS plugin with outdated image or params.
+func (nf *NodeFilter) KMSPluginOutdatedNodes() (nodes []*cke.Node) {
+	if nf.cluster.Options.APIServer.KMS == nil {
+		return nil
+	}
+	for _, n := range nf.cp {
+		// stopped nodes are excluded
+		if nf.nodeStatus(n).KMSPlugin.Running && !nf.kmsPluginReady(n) {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// KMSPluginRunningUnexpectedlyNodes returns control plane nodes that are running
+// KMS plugin while KMS is not used.
+// Nodes whose API server still uses KMS are excluded; the plugin is stopped
+// after the API server is restarted without KMS.
+func (nf *NodeFilter) KMSPluginRunningUnexpectedlyNodes() (nodes []*cke.Node) {
+	if nf.cluster.Options.APIServer.KMS != nil {
+		return nil
+	}
+	for _, n := range nf.cp {
+		st := nf.nodeStatus(n)
+		if !st.KMSPlugin.Running {
+			continue
+		}
+		if st.APIServer.Running && k8s.APIServerUsesKMS(st.APIServer.BuiltInParams) {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// kmsPluginReady returns true if KMS is not used, or the node is running
+// KMS plugin with the current image and params.
+func (nf *NodeFilter) kmsPluginReady(n *cke.Node) bool {
+	kms := nf.cluster.Options.APIServer.KMS
+	if kms == nil {
+		return true
+	}
+	st := nf.nodeStatus(n).KMSPlugin
+	return st.Running &&
+		kms.Image == st.Image &&
+		k8s.KMSPluginParams(*kms).Equal(st.BuiltInParams) &&
+		kms.ServiceParams.Equal(st.ExtraParams)
+}
+
 // ControllerManagerStoppedNodes returns control plane nodes that are not running controller manager.
 func (nf *NodeFilter) ControllerManagerStoppedNodes() (nodes []*cke.Node) {
 	for _, n := range nf.cp {
@@ -326,6 +409,9 @@ func (nf *NodeFilter) ControllerManagerStoppedNodes() (nodes []*cke.Node) {
 
 // ControllerManagerOutdatedNodes returns nodes that are running controller manager with outdated image or params.
 func (nf *NodeFilter) ControllerManagerOutdatedNodes() (nodes []*cke.Node) {
+	if nf.upgradeFrozen(upgradeStageControlPlane) {
+		return nil
+	}
 	currentBuiltIn := k8s.ControllerManagerParams(nf.cluster.Name, nf.cluster.ServiceSubnet)
 	currentExtra := nf.cluster.Options.ControllerManager
 
@@ -334,7 +420,7 @@ func (nf *NodeFilter) ControllerManagerOutdatedNodes() (nodes []*cke.Node) {
 		switch {
 		case !st.Running:
 			// stopped nodes are excluded
-		case cke.KubernetesImage.Name() != st.Image:
+		case nf.cluster.Images.KubernetesImage().Name() != st.Image:
 			fallthrough
 		case !currentBuiltIn.Equal(st.BuiltInParams):
 			fallthrough
@@ -355,7 +441,11 @@ func (nf *NodeFilter) SchedulerStoppedNodes() (nodes []*cke.Node) {
 	return nodes
 }
 
+// SchedulerOutdatedNodes returns nodes that are running kube-scheduler with outdated image or params.
 func (nf *NodeFilter) SchedulerOutdatedNodes(params cke.SchedulerParams) (nodes []*cke.Node) {
+	if nf.upgradeFrozen(upgradeStageControlPlane) {
+		return nil
+	}
 	currentBuiltIn := k8s.SchedulerParams()
 	currentExtra := nf.cluster.Options.Scheduler
 	currentConfig := k8s.GenerateSchedulerConfiguration(params)
@@ -367,7 +457,7 @@ func (nf *NodeFilter) SchedulerOutdatedNodes(params cke.SchedulerParams) (nodes
 		switch {
 		case !st.Running:
 			// stopped nodes are excluded
-		case cke.KubernetesImage.Name() != st.Image:
+		case nf.cluster.Images.KubernetesImage().Name() != st.Image:
 			fallthrough
 		case !currentBuiltIn.Equal(st.BuiltInParams):
 			fallthrough
@@ -420,6 +510,9 @@ func (nf *NodeFilter) KubeletStoppedRegisteredNodes() (nodes []*cke.Node) {
 
 // KubeletOutdatedNodes returns nodes that are running kubelet with outdated image or params.
 func (nf *NodeFilter) KubeletOutdatedNodes() (nodes []*cke.Node) {
+	if nf.upgradeFrozen(upgradeStageNodes) {
+		return nil
+	}
 	currentOpts := nf.cluster.Options.Kubelet
 	currentExtra := nf.cluster.Options.Kubelet.ServiceParams
 
@@ -434,7 +527,7 @@ func (nf *NodeFilter) KubeletOutdatedNodes() (nodes []*cke.Node) {
 			// stopped nodes are excluded
 		case kubeletRuntimeChanged(st.BuiltInParams, currentBuiltIn):
 			log.Warn("kubelet's container runtime cannot be changed", nil)
-		case cke.KubernetesImage.Name() != st.Image:
+		case nf.cluster.Images.KubernetesImage().Name() != st.Image:
 			fallthrough
 		case !reflect.DeepEqual(currentConfig, runningConfig):
 			fallthrough
@@ -543,6 +636,20 @@ func (nf *NodeFilter) ProxyStoppedNodes() (nodes []*cke.Node) {
 	return nodes
 }
 
+// ProxyRunningNodes returns nodes that are running kube-proxy.
+func (nf *NodeFilter) ProxyRunningNodes() (nodes []*cke.Node) {
+	if nf.cluster.Options.Proxy.Disable {
+		return nil
+	}
+
+	for _, n := range nf.cluster.Nodes {
+		if nf.nodeStatus(n).Proxy.Running {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
 // ProxyRunningUnexpectedlyNodes returns nodes that are running kube-proxy unexpectedly.
 func (nf *NodeFilter) ProxyRunningUnexpectedlyNodes() (nodes []*cke.Node) {
 	if !nf.cluster.Options.Proxy.Disable {
@@ -562,6 +669,9 @@ func (nf *NodeFilter) ProxyOutdatedNodes(params cke.ProxyParams) (nodes []*cke.N
 	if nf.cluster.Options.Proxy.Disable {
 		return nil
 	}
+	if nf.upgradeFrozen(upgradeStageNodes) {
+		return nil
+	}
 	currentExtra := nf.cluster.Options.Proxy
 
 	for _, n := range nf.cluster.Nodes {
@@ -572,7 +682,7 @@ func (nf *NodeFilter) ProxyOutdatedNodes(params cke.ProxyParams) (nodes []*cke.N
 		switch {
 		case !st.Running:
 			// stopped nodes are excluded
-		case cke.KubernetesImage.Name() != st.Image:
+		case nf.cluster.Images.KubernetesImage().Name() != st.Image:
 			fallthrough
 		case !currentBuiltIn.Equal(st.BuiltInParams):
 			fallthrough
@@ -828,6 +938,60 @@ func (nf *NodeFilter) CordonedNodes() (nodes []*corev1.Node) {
 	return nodes
 }
 
+// RebootVerified returns true if n has been rebooted and become ready.
+// bootID is the boot ID of n before reboot, or empty if unknown.
+func (nf *NodeFilter) RebootVerified(n *cke.Node, bootID string) bool {
+	st := nf.nodeStatus(n)
+	if st == nil || !st.SSHConnected || st.BootID == "" || st.BootID == bootID {
+		return false
+	}
+	if !st.Kubelet.IsHealthy {
+		return false
+	}
+
+	for _, kn := range nf.status.Kubernetes.Nodes {
+		if kn.Name != n.Nodename() {
+			continue
+		}
+		for _, cond := range kn.Status.Conditions {
+			if cond.Type == corev1.NodeReady {
+				return cond.Status == corev1.ConditionTrue
+			}
+		}
+	}
+	return false
+}
+
+// certificateComponents is the list of components whose certificates are
+// renewed by CKE, in the order of renewal.
+var certificateComponents = []string{
+	op.EtcdContainerName,
+	op.KubeAPIServerContainerName,
+	op.KubeControllerManagerContainerName,
+	op.KubeSchedulerContainerName,
+	op.KubeletContainerName,
+	op.KubeProxyContainerName,
+}
+
+// CertificateExpiringComponents returns the components on the node whose
+// certificates expire within the renewal window from now.
+func (nf *NodeFilter) CertificateExpiringComponents(n *cke.Node, now time.Time) (components []string) {
+	deadline := now.Add(nf.cluster.Certificates.RenewBefore())
+	expiry := nf.nodeStatus(n).CertificateExpiry
+	for _, c := range certificateComponents {
+		t, ok := expiry[c]
+		if !ok || !t.Before(deadline) {
+			continue
+		}
+		if !n.ControlPlane && c != op.KubeletContainerName && c != op.KubeProxyContainerName {
+			// control plane components on non-CP nodes are to be stopped.
+			continue
+		}
+		components = append(components, c)
+	}
+	return components
+}
+
 // SSHNotConnectedNodes returns nodes that are not connected via SSH out of targets.
 func (nf *NodeFilter) SSHNotConnectedNodes(targets []*cke.Node, includeControlPlane, includeWorker bool) (nodes []*cke.Node) {
 	for _, n := range targets {
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/log"
+)
+
+// filterPausedOps removes operations paused by p from ops.
+// This returns the remaining operations and the names of paused ones.
+func filterPausedOps(p *cke.Pause, phase cke.OperationPhase, ops []cke.Operator, cluster *cke.Cluster) ([]cke.Operator, []string) {
+	if p == nil {
+		return ops, nil
+	}
+
+	var remaining []cke.Operator
+	var paused []string
+	for _, op := range ops {
+		if p.Covers(phase, op, cluster) {
+			paused = append(paused, op.Name())
+			continue
+		}
+		remaining = append(remaining, op)
+	}
+
+	if len(paused) > 0 {
+		log.Info("operations are paused", map[string]interface{}{
+			"phase":  phase,
+			"ops":    paused,
+			"reason": p.Reason,
+		})
+	}
+	return remaining, paused
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cybozu-go/cke"
+)
+
+// maxPlanCommands is the maximum number of commands shown for each operator in a plan.
+const maxPlanCommands = 5
+
+// Plan represents the operations that CKE would run for a cluster configuration.
+//
+// Operations that CKE would decide but not run now are listed by their
+// names in Paused, WaitingApproval, and BackingOff.
+type Plan struct {
+	Phase     cke.OperationPhase `json:"phase"`
+	Operators []PlanOperator     `json:"operators"`
+
+	Paused          []string `json:"paused,omitempty"`
+	WaitingApproval []string `json:"waiting_approval,omitempty"`
+	BackingOff      []string `json:"backing_off,omitempty"`
+
+	// UnpinnedNodes are the addresses of nodes not connected because their
+	// SSH host keys are not pinned.  Planning never pins host keys.
+	UnpinnedNodes []string `json:"unpinned_nodes,omitempty"`
+}
+
+// PlanOperator represents an operator in a Plan.
+type PlanOperator struct {
+	Name     string        `json:"name"`
+	Targets  []string      `json:"targets"`
+	Commands []cke.Command `json:"commands"`
+}
+
+// MakePlan consults the current cluster status and decides operations
+// for cluster without running them.
+//
+// Operations are filtered by pauses, approvals, and retry states in the
+// same way as the leader does, but nothing is written to the storage.
+// inf should be created by cke.NewReadOnlyInfrastructure so that SSH host
+// keys are not pinned.
+func MakePlan(ctx context.Context, cluster *cke.Cluster, inf cke.Infrastructure) (*Plan, error) {
+	status, err := Controller{planning: true}.GetClusterStatus(ctx, cluster, inf)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := inf.Storage()
+	constraints, err := storage.GetConstraints(ctx)
+	switch err {
+	case nil:
+	case cke.ErrNotFound:
+		constraints = cke.DefaultConstraints()
+	default:
+		return nil, err
+	}
+
+	rcs, err := storage.GetAllResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := storage.GetRebootsEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reboots []*cke.RebootQueueEntry
+	if len(re) > 0 {
+		disabled, err := storage.IsRebootQueueDisabled(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !disabled {
+			reboots = re
+		}
+	}
+
+	now := time.Now().UTC()
+	ops, phase := DecideOps(cluster, status, constraints, rcs, reboots, now)
+
+	pause, err := storage.GetPause(ctx)
+	switch err {
+	case nil:
+	case cke.ErrNotFound:
+		pause = nil
+	default:
+		return nil, err
+	}
+	pending, err := storage.GetPendingOperations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	retries, err := storage.GetOperationRetries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := filterPlan(ops, phase, pause, cluster, pending, retries, now)
+	for _, n := range cluster.Nodes {
+		var hkErr *cke.HostKeyError
+		if errors.As(inf.AgentError(n.Address), &hkErr) && hkErr.Expected == "" {
+			plan.UnpinnedNodes = append(plan.UnpinnedNodes, n.Address)
+		}
+	}
+	return plan, nil
+}
+
+// filterPlan makes a plan from ops filtered by the pause, approvals, and retry states.
+func filterPlan(ops []cke.Operator, phase cke.OperationPhase, pause *cke.Pause, cluster *cke.Cluster, pending []*cke.PendingOperation, retries map[string]*cke.OperationRetry, now time.Time) *Plan {
+	ops, paused := filterPausedOps(pause, phase, ops, cluster)
+	ops, waiting := approvedOps(cluster.Approval, pending, ops, now)
+	if len(ops) == 0 && len(waiting) > 0 {
+		phase = cke.PhaseWaitingApproval
+	}
+
+	var runnable []cke.Operator
+	var backingOff []string
+	for _, op := range ops {
+		if retryBlocked(retries[cke.OperationID(op)], now) {
+			backingOff = append(backingOff, op.Name())
+			continue
+		}
+		runnable = append(runnable, op)
+	}
+
+	plan := newPlan(runnable, phase)
+	plan.Paused = paused
+	plan.WaitingApproval = waiting
+	plan.BackingOff = backingOff
+	return plan
+}
+
+func newPlan(ops []cke.Operator, phase cke.OperationPhase) *Plan {
+	plan := &Plan{
+		Phase:     phase,
+		Operators: make([]PlanOperator, 0, len(ops)),
+	}
+	for _, op := range ops {
+		po := PlanOperator{
+			Name:     op.Name(),
+			Targets:  op.Targets(),
+			Commands: []cke.Command{},
+		}
+		for len(po.Commands) < maxPlanCommands {
+			commander := op.NextCommand()
+			if commander == nil {
+				break
+			}
+			po.Commands = append(po.Commands, commander.Command())
+		}
+		plan.Operators = append(plan.Operators, po)
+	}
+	return plan
+}
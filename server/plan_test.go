@@ -0,0 +1,104 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/cke/op"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewPlan(t *testing.T) {
+	t.Parallel()
+
+	d := newData()
+	ops, phase := DecideOps(d.Cluster, d.Status, d.Constraints, d.Resources, d.Reboots, d.Now)
+	plan := newPlan(ops, phase)
+
+	if plan.Phase != cke.PhaseRivers {
+		t.Errorf("unexpected phase: %s", plan.Phase)
+	}
+	if len(plan.Operators) != len(ops) {
+		t.Fatalf("unexpected number of operators: %d", len(plan.Operators))
+	}
+	for i, po := range plan.Operators {
+		if po.Name != ops[i].Name() {
+			t.Errorf("unexpected operator name: %s", po.Name)
+		}
+		if len(po.Targets) != len(ops[i].Targets()) {
+			t.Errorf("unexpected targets for %s: %v", po.Name, po.Targets)
+		}
+		if len(po.Commands) == 0 || len(po.Commands) > maxPlanCommands {
+			t.Errorf("unexpected number of commands for %s: %d", po.Name, len(po.Commands))
+		}
+		for _, c := range po.Commands {
+			if c.Name == "" {
+				t.Errorf("empty command name in %s", po.Name)
+			}
+		}
+	}
+
+	plan = newPlan(nil, cke.PhaseCompleted)
+	if plan.Phase != cke.PhaseCompleted {
+		t.Errorf("unexpected phase: %s", plan.Phase)
+	}
+	if plan.Operators == nil || len(plan.Operators) != 0 {
+		t.Errorf("operators should be an empty list: %#v", plan.Operators)
+	}
+}
+
+func TestFilterPlan(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	dequeue := op.RebootDequeueOp(1, []string{"10.0.0.1"})
+	uncordon := op.RebootUncordonOp(nil, []string{"node2"})
+	held := op.RebootFailOp(2, []string{"10.0.0.3"}, []string{"10.0.0.3"})
+	ops := []cke.Operator{dequeue, uncordon, held}
+
+	// reboot-uncordon targets nodes by name.
+	pause := &cke.Pause{Nodes: []string{"10.0.0.2"}}
+	cluster := &cke.Cluster{
+		Nodes: []*cke.Node{
+			{Address: "10.0.0.1"},
+			{Address: "10.0.0.2", Hostname: "node2"},
+			{Address: "10.0.0.3"},
+		},
+		Approval: cke.Approval{Enabled: true, Operations: []string{"reboot-dequeue", "reboot-fail"}},
+	}
+	pending := []*cke.PendingOperation{
+		{ID: cke.OperationID(dequeue), Operation: "reboot-dequeue", Status: cke.PendingOperationApproved, Decided: now},
+		{ID: cke.OperationID(held), Operation: "reboot-fail", Status: cke.PendingOperationWaiting, Requested: now},
+	}
+	retries := map[string]*cke.OperationRetry{
+		cke.OperationID(dequeue): {Attempts: 1, NextRetry: now.Add(time.Minute)},
+	}
+
+	plan := filterPlan(ops, cke.PhaseUncordonNodes, pause, cluster, pending, retries, now)
+	if len(plan.Operators) != 0 {
+		t.Errorf("no operators should run: %v", plan.Operators)
+	}
+	if !cmp.Equal(plan.Paused, []string{"reboot-uncordon"}) {
+		t.Errorf("unexpected paused operations: %v", plan.Paused)
+	}
+	if !cmp.Equal(plan.WaitingApproval, []string{"reboot-fail"}) {
+		t.Errorf("unexpected operations waiting for approval: %v", plan.WaitingApproval)
+	}
+	if !cmp.Equal(plan.BackingOff, []string{"reboot-dequeue"}) {
+		t.Errorf("unexpected operations backing off: %v", plan.BackingOff)
+	}
+
+	plan = filterPlan(ops, cke.PhaseUncordonNodes, nil, cluster, pending, nil, now)
+	if len(plan.Operators) != 2 || plan.Operators[0].Name != "reboot-dequeue" || plan.Operators[1].Name != "reboot-uncordon" {
+		t.Errorf("unexpected operators: %v", plan.Operators)
+	}
+	if plan.Phase != cke.PhaseUncordonNodes {
+		t.Errorf("unexpected phase: %s", plan.Phase)
+	}
+
+	plan = filterPlan([]cke.Operator{held}, cke.PhaseRebootNodes, nil, cluster, pending, nil, now)
+	if plan.Phase != cke.PhaseWaitingApproval {
+		t.Errorf("unexpected phase: %s", plan.Phase)
+	}
+}
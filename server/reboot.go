@@ -0,0 +1,289 @@
+package server
+
+import (
+	"sort"
+	"time"
+
+	"github.com/cybozu-go/cke"
+)
+
+// rebootBatch returns the reboot queue entries to be processed at once.
+//
+// If there are cancelled entries or entries past their deadlines, all of
+// them are returned to be dequeued, wherever they are in the queue.
+//
+// Otherwise, entries being rebooted come first, and others follow in the
+// descending order of priority.  Entries of the same priority are ordered
+// by their indices.  Queued entries whose not_before time has not come are
+// left for later.
+//
+// The first entry is always chosen.  If concurrent reboots are not
+// configured, only the first entry is returned.
+// Otherwise, following entries are added as long as the limits in the
+// reboot configuration and constraints are satisfied:
+//
+//   - The number of nodes does not exceed max_concurrent_nodes.
+//   - The number of unreachable nodes plus the nodes to be rebooted does not
+//     exceed maximum-unreachable-nodes-for-reboot.
+//   - At most one control plane node is rebooted.
+//   - The number of racks does not exceed max_concurrent_racks.
+//   - The share of nodes of each role does not exceed max_nodes_per_role_percent.
+//
+// Entries that do not satisfy the limits are skipped so that later entries
+// for the same rack can be processed together.
+//
+// Entries being verified or failed verification are not processed.
+func rebootBatch(c *cke.Cluster, constraints *cke.Constraints, entries []*cke.RebootQueueEntry, nf *NodeFilter, now time.Time) []*cke.RebootQueueEntry {
+	var pending, discarded []*cke.RebootQueueEntry
+	for _, e := range entries {
+		switch {
+		case e.Status == cke.RebootStatusVerifying || e.Status == cke.RebootStatusFailed:
+			continue
+		case rebootDiscarded(e, now):
+			discarded = append(discarded, e)
+			continue
+		case e.Status == cke.RebootStatusQueued && !e.Ready(now):
+			continue
+		}
+		pending = append(pending, e)
+	}
+	if len(discarded) > 0 {
+		return discarded
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		ri := pending[i].Status == cke.RebootStatusRebooting
+		rj := pending[j].Status == cke.RebootStatusRebooting
+		if ri != rj {
+			return ri
+		}
+		return pending[i].Priority > pending[j].Priority
+	})
+	entries = pending
+
+	if len(entries) == 0 {
+		return nil
+	}
+	first := entries[0]
+	if c.Reboot.MaxConcurrentNodes == nil {
+		return entries[:1]
+	}
+
+	b := newRebootBatchLimits(c, constraints, nf)
+	b.add(first)
+	batch := []*cke.RebootQueueEntry{first}
+	for _, e := range entries[1:] {
+		if !b.fits(e) {
+			continue
+		}
+		b.add(e)
+		batch = append(batch, e)
+	}
+	return batch
+}
+
+// EstimateRebootStarts estimates when the queued reboot entries will be
+// started.  Keys of the returned map are the indices of the entries.
+//
+// Batches are simulated in the same order and with the same limits as
+// the leader chooses them, assuming that all nodes are reachable and that
+// each batch takes batchDuration including verification.  A batch starts
+// only when disruptive operations are allowed by constraints.
+// Entries that will pass their deadlines before being started are not
+// included.
+func EstimateRebootStarts(c *cke.Cluster, constraints *cke.Constraints, entries []*cke.RebootQueueEntry, now time.Time, batchDuration time.Duration) map[int64]time.Time {
+	status := &cke.ClusterStatus{NodeStatuses: make(map[string]*cke.NodeStatus)}
+	for _, n := range c.Nodes {
+		status.NodeStatuses[n.Address] = &cke.NodeStatus{SSHConnected: true}
+	}
+	nf := NewNodeFilter(c, status)
+
+	// new batches are not started until the entries being verified are done.
+	t := now
+	var remaining []*cke.RebootQueueEntry
+	for _, e := range entries {
+		switch e.Status {
+		case cke.RebootStatusVerifying:
+			if e.Rebooted != nil && e.Rebooted.Add(batchDuration).After(t) {
+				t = e.Rebooted.Add(batchDuration)
+			}
+		case cke.RebootStatusQueued, cke.RebootStatusRebooting, cke.RebootStatusCancelled:
+			remaining = append(remaining, e)
+		}
+	}
+
+	starts := make(map[int64]time.Time)
+	for len(remaining) > 0 {
+		next, ok := constraints.NextDisruptionAllowed(t)
+		if !ok {
+			break
+		}
+		t = next
+
+		batch := rebootBatch(c, constraints, remaining, nf, t)
+		if len(batch) == 0 {
+			// wait for the earliest not_before among the remaining entries.
+			var wait *time.Time
+			for _, e := range remaining {
+				if e.NotBefore != nil && e.NotBefore.After(t) && (wait == nil || e.NotBefore.Before(*wait)) {
+					wait = e.NotBefore
+				}
+			}
+			if wait == nil {
+				break
+			}
+			t = *wait
+			continue
+		}
+
+		done := make(map[int64]bool)
+		for _, e := range batch {
+			done[e.Index] = true
+			if e.Status == cke.RebootStatusQueued && !rebootDiscarded(e, t) {
+				starts[e.Index] = t
+			}
+		}
+		var rest []*cke.RebootQueueEntry
+		for _, e := range remaining {
+			if !done[e.Index] {
+				rest = append(rest, e)
+			}
+		}
+		remaining = rest
+
+		if !rebootDiscarded(batch[0], t) {
+			t = t.Add(batchDuration)
+		}
+	}
+	return starts
+}
+
+// rebootDiscarded returns true if the entry is to be dequeued without rebooting.
+func rebootDiscarded(e *cke.RebootQueueEntry, now time.Time) bool {
+	return e.Status == cke.RebootStatusCancelled || e.Expired(now)
+}
+
+type rebootBatchLimits struct {
+	nf *NodeFilter
+
+	maxNodes       int
+	maxUnreachable int
+	maxRacks       int
+	maxPerRole     map[string]int
+
+	nodes         map[string]bool
+	controlPlanes int
+	racks         map[string]bool
+	roles         map[string]int
+}
+
+func newRebootBatchLimits(c *cke.Cluster, constraints *cke.Constraints, nf *NodeFilter) *rebootBatchLimits {
+	b := &rebootBatchLimits{
+		nf:             nf,
+		maxNodes:       *c.Reboot.MaxConcurrentNodes,
+		maxUnreachable: constraints.RebootMaximumUnreachable - len(nf.SSHNotConnectedNodes(c.Nodes, true, true)),
+		maxRacks:       -1,
+		nodes:          make(map[string]bool),
+		racks:          make(map[string]bool),
+		roles:          make(map[string]int),
+	}
+	if c.Reboot.MaxConcurrentRacks != nil {
+		b.maxRacks = *c.Reboot.MaxConcurrentRacks
+	}
+	if p := c.Reboot.MaxNodesPerRolePercent; p != nil {
+		total := make(map[string]int)
+		for _, n := range c.Nodes {
+			total[n.Labels[cke.RoleLabel]]++
+		}
+		b.maxPerRole = make(map[string]int)
+		for role, count := range total {
+			max := count * *p / 100
+			if max < 1 {
+				max = 1
+			}
+			b.maxPerRole[role] = max
+		}
+	}
+	return b
+}
+
+// entryNodes returns the nodes in the cluster that are listed in the entry.
+func (b *rebootBatchLimits) entryNodes(e *cke.RebootQueueEntry) []*cke.Node {
+	var nodes []*cke.Node
+	for _, addr := range e.Nodes {
+		if n, ok := b.nf.nodeMap[addr]; ok {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+func (b *rebootBatchLimits) fits(e *cke.RebootQueueEntry) bool {
+	nodes := b.entryNodes(e)
+	if len(nodes) == 0 {
+		return false
+	}
+
+	numNodes := len(b.nodes) + len(nodes)
+	if numNodes > b.maxNodes || numNodes > b.maxUnreachable {
+		return false
+	}
+
+	controlPlanes := b.controlPlanes
+	racks := make(map[string]bool)
+	roles := make(map[string]int)
+	for _, n := range nodes {
+		if b.nodes[n.Address] {
+			return false
+		}
+		if n.ControlPlane {
+			controlPlanes++
+		}
+		racks[n.Labels[cke.RackLabel]] = true
+		roles[n.Labels[cke.RoleLabel]]++
+	}
+	if controlPlanes > 1 {
+		return false
+	}
+
+	if b.maxRacks >= 0 {
+		for rack := range b.racks {
+			racks[rack] = true
+		}
+		if len(racks) > b.maxRacks {
+			return false
+		}
+	}
+
+	if b.maxPerRole != nil {
+		for role, count := range roles {
+			if b.roles[role]+count > b.maxPerRole[role] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (b *rebootBatchLimits) add(e *cke.RebootQueueEntry) {
+	for _, n := range b.entryNodes(e) {
+		b.nodes[n.Address] = true
+		if n.ControlPlane {
+			b.controlPlanes++
+		}
+		b.racks[n.Labels[cke.RackLabel]] = true
+		b.roles[n.Labels[cke.RoleLabel]]++
+	}
+}
+
+// rebootingEntries returns the entries in batch and those being verified.
+func rebootingEntries(batch, entries []*cke.RebootQueueEntry) []*cke.RebootQueueEntry {
+	result := make([]*cke.RebootQueueEntry, 0, len(batch))
+	result = append(result, batch...)
+	for _, e := range entries {
+		if e.Status == cke.RebootStatusVerifying {
+			result = append(result, e)
+		}
+	}
+	return result
+}
@@ -0,0 +1,345 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/utils/pointer"
+)
+
+func TestRebootBatch(t *testing.T) {
+	t.Parallel()
+
+	node := func(addr, rack, role string, cp bool) *cke.Node {
+		return &cke.Node{
+			Address:      addr,
+			ControlPlane: cp,
+			Labels:       map[string]string{cke.RackLabel: rack, cke.RoleLabel: role},
+		}
+	}
+	nodes := []*cke.Node{
+		node("10.0.0.1", "0", "cs", true),
+		node("10.0.0.2", "1", "cs", true),
+		node("10.0.0.3", "0", "cs", false),
+		node("10.0.0.4", "0", "cs", false),
+		node("10.0.0.5", "1", "cs", false),
+		node("10.0.0.6", "1", "ss", false),
+		node("10.0.0.7", "0", "ss", false),
+		node("10.0.0.8", "0", "cs", false),
+	}
+	status := &cke.ClusterStatus{NodeStatuses: make(map[string]*cke.NodeStatus)}
+	for _, n := range nodes {
+		status.NodeStatuses[n.Address] = &cke.NodeStatus{SSHConnected: true}
+	}
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	entries := func(addrs ...string) []*cke.RebootQueueEntry {
+		var es []*cke.RebootQueueEntry
+		for i, a := range addrs {
+			es = append(es, &cke.RebootQueueEntry{
+				Index:  int64(i),
+				Nodes:  []string{a},
+				Status: cke.RebootStatusQueued,
+			})
+		}
+		return es
+	}
+
+	testCases := []struct {
+		name        string
+		reboot      cke.Reboot
+		unreachable int
+		entries     []*cke.RebootQueueEntry
+		expected    []int64
+	}{
+		{
+			name:     "serial by default",
+			entries:  entries("10.0.0.3", "10.0.0.4"),
+			expected: []int64{0},
+		},
+		{
+			name:     "max nodes",
+			reboot:   cke.Reboot{MaxConcurrentNodes: pointer.Int(2)},
+			entries:  entries("10.0.0.3", "10.0.0.4", "10.0.0.5"),
+			expected: []int64{0, 1},
+		},
+		{
+			name:     "one control plane at a time",
+			reboot:   cke.Reboot{MaxConcurrentNodes: pointer.Int(10)},
+			entries:  entries("10.0.0.1", "10.0.0.2", "10.0.0.3"),
+			expected: []int64{0, 2},
+		},
+		{
+			name:     "one rack at a time",
+			reboot:   cke.Reboot{MaxConcurrentNodes: pointer.Int(10), MaxConcurrentRacks: pointer.Int(1)},
+			entries:  entries("10.0.0.3", "10.0.0.5", "10.0.0.4", "10.0.0.6", "10.0.0.7"),
+			expected: []int64{0, 2, 4},
+		},
+		{
+			name:     "share per role",
+			reboot:   cke.Reboot{MaxConcurrentNodes: pointer.Int(10), MaxNodesPerRolePercent: pointer.Int(40)},
+			entries:  entries("10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6", "10.0.0.7"),
+			expected: []int64{0, 1, 3},
+		},
+		{
+			name:        "unreachable nodes",
+			reboot:      cke.Reboot{MaxConcurrentNodes: pointer.Int(10)},
+			unreachable: 1,
+			entries:     entries("10.0.0.3", "10.0.0.4", "10.0.0.5"),
+			expected:    []int64{0, 1},
+		},
+		{
+			name:   "cancelled entries",
+			reboot: cke.Reboot{MaxConcurrentNodes: pointer.Int(10)},
+			entries: []*cke.RebootQueueEntry{
+				{Index: 0, Nodes: []string{"10.0.0.3"}, Status: cke.RebootStatusQueued},
+				{Index: 1, Nodes: []string{"10.0.0.4"}, Status: cke.RebootStatusCancelled},
+				{Index: 2, Nodes: []string{"10.0.0.5"}, Status: cke.RebootStatusQueued},
+			},
+			expected: []int64{1},
+		},
+		{
+			name:   "cancelled first entry",
+			reboot: cke.Reboot{MaxConcurrentNodes: pointer.Int(10)},
+			entries: []*cke.RebootQueueEntry{
+				{Index: 0, Nodes: []string{"10.0.0.3"}, Status: cke.RebootStatusCancelled},
+				{Index: 1, Nodes: []string{"10.0.0.4"}, Status: cke.RebootStatusQueued},
+			},
+			expected: []int64{0},
+		},
+		{
+			name: "verifying and failed entries",
+			entries: []*cke.RebootQueueEntry{
+				{Index: 0, Nodes: []string{"10.0.0.3"}, Status: cke.RebootStatusVerifying},
+				{Index: 1, Nodes: []string{"10.0.0.4"}, Status: cke.RebootStatusFailed},
+				{Index: 2, Nodes: []string{"10.0.0.5"}, Status: cke.RebootStatusQueued},
+			},
+			expected: []int64{2},
+		},
+		{
+			name:   "priority",
+			reboot: cke.Reboot{MaxConcurrentNodes: pointer.Int(2)},
+			entries: []*cke.RebootQueueEntry{
+				{Index: 0, Nodes: []string{"10.0.0.3"}, Status: cke.RebootStatusQueued},
+				{Index: 1, Nodes: []string{"10.0.0.4"}, Status: cke.RebootStatusQueued, Priority: 10},
+				{Index: 2, Nodes: []string{"10.0.0.5"}, Status: cke.RebootStatusQueued, Priority: 5},
+			},
+			expected: []int64{1, 2},
+		},
+		{
+			name: "rebooting entries first",
+			entries: []*cke.RebootQueueEntry{
+				{Index: 0, Nodes: []string{"10.0.0.3"}, Status: cke.RebootStatusQueued, Priority: 10},
+				{Index: 1, Nodes: []string{"10.0.0.4"}, Status: cke.RebootStatusRebooting},
+			},
+			expected: []int64{1},
+		},
+		{
+			name: "not before",
+			entries: []*cke.RebootQueueEntry{
+				{Index: 0, Nodes: []string{"10.0.0.3"}, Status: cke.RebootStatusQueued, Priority: 10, NotBefore: &after},
+				{Index: 1, Nodes: []string{"10.0.0.4"}, Status: cke.RebootStatusQueued, NotBefore: &before},
+			},
+			expected: []int64{1},
+		},
+		{
+			name: "not ready entries only",
+			entries: []*cke.RebootQueueEntry{
+				{Index: 0, Nodes: []string{"10.0.0.3"}, Status: cke.RebootStatusQueued, NotBefore: &after},
+			},
+		},
+		{
+			name:   "expired entries",
+			reboot: cke.Reboot{MaxConcurrentNodes: pointer.Int(10)},
+			entries: []*cke.RebootQueueEntry{
+				{Index: 0, Nodes: []string{"10.0.0.3"}, Status: cke.RebootStatusQueued, Priority: 10},
+				{Index: 1, Nodes: []string{"10.0.0.4"}, Status: cke.RebootStatusQueued, Deadline: &before},
+				{Index: 2, Nodes: []string{"10.0.0.5"}, Status: cke.RebootStatusQueued, Deadline: &after},
+			},
+			expected: []int64{1},
+		},
+		{
+			name:   "expired first entry",
+			reboot: cke.Reboot{MaxConcurrentNodes: pointer.Int(10)},
+			entries: []*cke.RebootQueueEntry{
+				{Index: 0, Nodes: []string{"10.0.0.3"}, Status: cke.RebootStatusQueued, Deadline: &before},
+				{Index: 1, Nodes: []string{"10.0.0.4"}, Status: cke.RebootStatusQueued},
+			},
+			expected: []int64{0},
+		},
+		{
+			name: "discarded entries without concurrent reboots",
+			entries: []*cke.RebootQueueEntry{
+				{Index: 0, Nodes: []string{"10.0.0.3"}, Status: cke.RebootStatusQueued, Priority: 10},
+				{Index: 1, Nodes: []string{"10.0.0.4"}, Status: cke.RebootStatusCancelled},
+				{Index: 2, Nodes: []string{"10.0.0.5"}, Status: cke.RebootStatusQueued, Deadline: &before},
+			},
+			expected: []int64{1, 2},
+		},
+		{
+			name:     "duplicate nodes and unknown nodes",
+			reboot:   cke.Reboot{MaxConcurrentNodes: pointer.Int(10)},
+			entries:  entries("10.0.0.3", "10.0.0.3", "10.0.0.100", "10.0.0.4"),
+			expected: []int64{0, 3},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			c := &cke.Cluster{Nodes: nodes, Reboot: tc.reboot}
+			constraints := &cke.Constraints{RebootMaximumUnreachable: 3}
+			st := &cke.ClusterStatus{NodeStatuses: make(map[string]*cke.NodeStatus)}
+			for addr, ns := range status.NodeStatuses {
+				copied := *ns
+				st.NodeStatuses[addr] = &copied
+			}
+			for i := 0; i < tc.unreachable; i++ {
+				st.NodeStatuses[nodes[len(nodes)-1-i].Address].SSHConnected = false
+			}
+
+			batch := rebootBatch(c, constraints, tc.entries, NewNodeFilter(c, st), now)
+			var actual []int64
+			for _, e := range batch {
+				actual = append(actual, e.Index)
+			}
+			if !cmp.Equal(tc.expected, actual) {
+				t.Error("unexpected batch:", cmp.Diff(tc.expected, actual))
+			}
+		})
+	}
+}
+
+func TestEstimateRebootStarts(t *testing.T) {
+	t.Parallel()
+
+	nodes := []*cke.Node{
+		{Address: "10.0.0.1", ControlPlane: true},
+		{Address: "10.0.0.2"},
+		{Address: "10.0.0.3"},
+		{Address: "10.0.0.4"},
+	}
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	entry := func(index int64, addr string, status cke.RebootStatus) *cke.RebootQueueEntry {
+		return &cke.RebootQueueEntry{Index: index, Nodes: []string{addr}, Status: status}
+	}
+	withPriority := func(e *cke.RebootQueueEntry, p int) *cke.RebootQueueEntry {
+		e.Priority = p
+		return e
+	}
+	withSchedule := func(e *cke.RebootQueueEntry, notBefore, deadline *time.Time) *cke.RebootQueueEntry {
+		e.NotBefore = notBefore
+		e.Deadline = deadline
+		return e
+	}
+	rebooted := func(e *cke.RebootQueueEntry, t *time.Time) *cke.RebootQueueEntry {
+		e.Rebooted = t
+		return e
+	}
+
+	testCases := []struct {
+		name        string
+		reboot      cke.Reboot
+		constraints cke.Constraints
+		entries     []*cke.RebootQueueEntry
+		expected    map[int64]time.Time
+	}{
+		{
+			name: "serial",
+			entries: []*cke.RebootQueueEntry{
+				entry(0, "10.0.0.2", cke.RebootStatusQueued),
+				entry(1, "10.0.0.3", cke.RebootStatusQueued),
+				entry(2, "10.0.0.4", cke.RebootStatusQueued),
+			},
+			expected: map[int64]time.Time{0: now, 1: *at(time.Hour), 2: *at(2 * time.Hour)},
+		},
+		{
+			name:   "concurrent",
+			reboot: cke.Reboot{MaxConcurrentNodes: pointer.Int(2)},
+			entries: []*cke.RebootQueueEntry{
+				entry(0, "10.0.0.2", cke.RebootStatusQueued),
+				entry(1, "10.0.0.3", cke.RebootStatusQueued),
+				entry(2, "10.0.0.4", cke.RebootStatusQueued),
+			},
+			expected: map[int64]time.Time{0: now, 1: now, 2: *at(time.Hour)},
+		},
+		{
+			name: "priority",
+			entries: []*cke.RebootQueueEntry{
+				entry(0, "10.0.0.2", cke.RebootStatusQueued),
+				withPriority(entry(1, "10.0.0.3", cke.RebootStatusQueued), 10),
+			},
+			expected: map[int64]time.Time{1: now, 0: *at(time.Hour)},
+		},
+		{
+			name: "rebooting and verifying entries",
+			entries: []*cke.RebootQueueEntry{
+				rebooted(entry(0, "10.0.0.2", cke.RebootStatusVerifying), at(-30*time.Minute)),
+				entry(1, "10.0.0.3", cke.RebootStatusRebooting),
+				entry(2, "10.0.0.4", cke.RebootStatusQueued),
+			},
+			expected: map[int64]time.Time{2: *at(90 * time.Minute)},
+		},
+		{
+			name: "not before",
+			entries: []*cke.RebootQueueEntry{
+				withSchedule(entry(0, "10.0.0.2", cke.RebootStatusQueued), at(3*time.Hour), nil),
+				entry(1, "10.0.0.3", cke.RebootStatusQueued),
+			},
+			expected: map[int64]time.Time{1: now, 0: *at(3 * time.Hour)},
+		},
+		{
+			name: "deadline",
+			entries: []*cke.RebootQueueEntry{
+				entry(0, "10.0.0.2", cke.RebootStatusQueued),
+				withSchedule(entry(1, "10.0.0.3", cke.RebootStatusQueued), nil, at(30*time.Minute)),
+				entry(2, "10.0.0.4", cke.RebootStatusQueued),
+			},
+			expected: map[int64]time.Time{0: now, 2: *at(time.Hour)},
+		},
+		{
+			name: "cancelled entries",
+			entries: []*cke.RebootQueueEntry{
+				entry(0, "10.0.0.2", cke.RebootStatusCancelled),
+				entry(1, "10.0.0.3", cke.RebootStatusQueued),
+			},
+			expected: map[int64]time.Time{1: now},
+		},
+		{
+			name: "maintenance windows",
+			constraints: cke.Constraints{
+				MaintenanceWindows: []cke.MaintenanceWindow{{Start: "02:00", Duration: "90m"}},
+			},
+			entries: []*cke.RebootQueueEntry{
+				entry(0, "10.0.0.2", cke.RebootStatusQueued),
+				entry(1, "10.0.0.3", cke.RebootStatusQueued),
+				entry(2, "10.0.0.4", cke.RebootStatusQueued),
+			},
+			expected: map[int64]time.Time{0: *at(2 * time.Hour), 1: *at(3 * time.Hour), 2: *at(26 * time.Hour)},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			c := &cke.Cluster{Nodes: nodes, Reboot: tc.reboot}
+			constraints := tc.constraints
+			constraints.RebootMaximumUnreachable = 3
+
+			actual := EstimateRebootStarts(c, &constraints, tc.entries, now, time.Hour)
+			if !cmp.Equal(tc.expected, actual) {
+				t.Error("unexpected starts:", cmp.Diff(tc.expected, actual))
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/log"
+)
+
+const (
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = 30 * time.Minute
+
+	// maxConsecutiveFailures is the number of consecutive failures to park an operation.
+	maxConsecutiveFailures = 5
+
+	// An operation that fails maxFlaps times within flapWindow is parked
+	// even if it succeeds in between.
+	flapWindow = time.Hour
+	maxFlaps   = 10
+)
+
+// retryDelay returns the delay before retrying an operation that has failed
+// n times in a row.  The delay grows exponentially and is randomized
+// so that retries of different operations do not synchronize.
+func retryDelay(n int) time.Duration {
+	d := retryBaseDelay
+	for i := 1; i < n && d < retryMaxDelay; i++ {
+		d *= 2
+	}
+	if d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func recentFailures(failures []time.Time, now time.Time) []time.Time {
+	var recent []time.Time
+	for _, t := range failures {
+		if now.Sub(t) < flapWindow {
+			recent = append(recent, t)
+		}
+	}
+	return recent
+}
+
+// recordFailure updates r for the failure of the operation at now.
+func recordFailure(r *cke.OperationRetry, err error, now time.Time) {
+	r.Attempts++
+	r.LastError = err.Error()
+	r.Permanent = cke.IsPermanentError(err)
+	r.Failures = append(recentFailures(r.Failures, now), now)
+	r.NextRetry = now.Add(retryDelay(r.Attempts))
+	r.Parked = r.Permanent || r.Attempts >= maxConsecutiveFailures || len(r.Failures) >= maxFlaps
+}
+
+// recordSuccess updates r for the success of the operation at now.
+// This returns false if r need not be kept any longer.
+func recordSuccess(r *cke.OperationRetry, now time.Time) bool {
+	r.Attempts = 0
+	r.LastError = ""
+	r.Permanent = false
+	r.Failures = recentFailures(r.Failures, now)
+	r.NextRetry = time.Time{}
+	return len(r.Failures) > 0
+}
+
+// retryBlocked returns true if the operation whose retry state is r
+// must not run at now.
+func retryBlocked(r *cke.OperationRetry, now time.Time) bool {
+	return r != nil && (r.Parked || now.Before(r.NextRetry))
+}
+
+// retryTracker keeps the retry states of operations during an operation loop.
+// A nil *retryTracker allows every operation.
+type retryTracker struct {
+	storage   cke.Storage
+	leaderKey string
+
+	mu      sync.Mutex
+	retries map[string]*cke.OperationRetry
+}
+
+func newRetryTracker(storage cke.Storage, leaderKey string, retries map[string]*cke.OperationRetry) *retryTracker {
+	return &retryTracker{
+		storage:   storage,
+		leaderKey: leaderKey,
+		retries:   retries,
+	}
+}
+
+// allow returns true if the operation may run at now.
+func (t *retryTracker) allow(op cke.Operator, now time.Time) bool {
+	if t == nil {
+		return true
+	}
+
+	t.mu.Lock()
+	r := t.retries[cke.OperationID(op)]
+	t.mu.Unlock()
+	if !retryBlocked(r, now) {
+		return true
+	}
+
+	if r.Parked {
+		log.Warn("operation is parked due to repeated failures", map[string]interface{}{
+			"op":         op.Name(),
+			"attempts":   r.Attempts,
+			"last_error": r.LastError,
+		})
+		return false
+	}
+	log.Info("operation is backing off", map[string]interface{}{
+		"op":         op.Name(),
+		"attempts":   r.Attempts,
+		"next_retry": r.NextRetry,
+	})
+	return false
+}
+
+// succeeded records the success of the operation.
+func (t *retryTracker) succeeded(ctx context.Context, op cke.Operator, now time.Time) error {
+	if t == nil {
+		return nil
+	}
+
+	id := cke.OperationID(op)
+	t.mu.Lock()
+	r := t.retries[id]
+	t.mu.Unlock()
+	if r == nil {
+		return nil
+	}
+
+	if recordSuccess(r, now) {
+		return t.storage.PutOperationRetry(ctx, t.leaderKey, id, r)
+	}
+	t.mu.Lock()
+	delete(t.retries, id)
+	t.mu.Unlock()
+	err := t.storage.DeleteOperationRetry(ctx, t.leaderKey, id)
+	if err == cke.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// failed records the failure of the operation.
+func (t *retryTracker) failed(ctx context.Context, op cke.Operator, cmdErr error, now time.Time) error {
+	if t == nil {
+		return nil
+	}
+
+	id := cke.OperationID(op)
+	t.mu.Lock()
+	r := t.retries[id]
+	if r == nil {
+		r = &cke.OperationRetry{
+			Operation: op.Name(),
+			Targets:   op.Targets(),
+		}
+		t.retries[id] = r
+	}
+	t.mu.Unlock()
+
+	recordFailure(r, cmdErr, now)
+	if r.Parked {
+		log.Error("operation is parked", map[string]interface{}{
+			log.FnError: cmdErr,
+			"op":        op.Name(),
+			"attempts":  r.Attempts,
+			"permanent": r.Permanent,
+		})
+	}
+	return t.storage.PutOperationRetry(ctx, t.leaderKey, id, r)
+}
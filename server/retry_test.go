@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/cke"
+)
+
+func TestRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	for n := 1; n <= 20; n++ {
+		max := retryBaseDelay << (n - 1)
+		if n > 10 || max > retryMaxDelay {
+			max = retryMaxDelay
+		}
+		for i := 0; i < 10; i++ {
+			d := retryDelay(n)
+			if d < max/2 || d > max {
+				t.Fatalf("retryDelay(%d) = %v, expected between %v and %v", n, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestRecordFailure(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	transient := errors.New("connection reset")
+
+	r := &cke.OperationRetry{}
+	for i := 1; i < maxConsecutiveFailures; i++ {
+		recordFailure(r, transient, now)
+		if r.Attempts != i {
+			t.Fatalf("unexpected attempts: %d", r.Attempts)
+		}
+		if r.Parked {
+			t.Fatalf("parked after %d failures", i)
+		}
+		if !r.NextRetry.After(now) {
+			t.Fatalf("next retry is not in the future: %v", r.NextRetry)
+		}
+	}
+	recordFailure(r, transient, now)
+	if !r.Parked {
+		t.Error("not parked after consecutive failures")
+	}
+
+	r = &cke.OperationRetry{}
+	recordFailure(r, cke.Permanent(errors.New("invalid")), now)
+	if !r.Parked || !r.Permanent {
+		t.Error("not parked by a permanent error", r)
+	}
+
+	// flapping operation
+	r = &cke.OperationRetry{}
+	for i := 0; i < maxFlaps-1; i++ {
+		recordFailure(r, transient, now)
+		if !recordSuccess(r, now) {
+			t.Fatal("recent failures should be kept")
+		}
+		if r.Attempts != 0 || r.Parked {
+			t.Fatal("unexpected state after success", r)
+		}
+	}
+	recordFailure(r, transient, now)
+	if !r.Parked {
+		t.Error("flapping operation is not parked")
+	}
+
+	// old failures are forgotten
+	r = &cke.OperationRetry{}
+	recordFailure(r, transient, now)
+	if recordSuccess(r, now.Add(flapWindow)) {
+		t.Error("old failures should be discarded", r)
+	}
+}
@@ -3,17 +3,26 @@ package server
 import (
 	"context"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/cybozu-go/cke"
 	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"sigs.k8s.io/yaml"
 )
 
+// maxClusterSize is the maximum size of a cluster configuration in a request body.
+const maxClusterSize = 10 << 20
+
 // Server is the cke server.
 type Server struct {
 	EtcdClient *clientv3.Client
 	Timeout    time.Duration
+
+	// APIToken is the bearer token to access authenticated APIs.
+	// If empty, authenticated APIs are disabled.
+	APIToken string
 }
 
 type version struct {
@@ -29,6 +38,8 @@ func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		s.handleVersion(w, r)
 	} else if r.Method == http.MethodGet && r.URL.Path == "/health" {
 		s.handleHealth(w, r)
+	} else if r.URL.Path == "/plan" || strings.HasPrefix(r.URL.Path, apiV1Prefix) {
+		s.handleAuthenticated(w, r)
 	} else {
 		renderError(r.Context(), w, APIErrNotFound)
 	}
@@ -55,3 +66,57 @@ func (s Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		}, http.StatusInternalServerError)
 	}
 }
+
+func (s Server) handleAuthenticated(w http.ResponseWriter, r *http.Request) {
+	if e := s.authenticate(r); e != nil {
+		if e.Status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="cke"`)
+		}
+		renderError(r.Context(), w, *e)
+		return
+	}
+
+	if r.URL.Path == "/plan" {
+		if r.Method != http.MethodPost {
+			renderError(r.Context(), w, APIErrBadMethod)
+			return
+		}
+		s.handlePlan(w, r)
+		return
+	}
+	s.handleAPIV1(w, r)
+}
+
+func (s Server) handlePlan(w http.ResponseWriter, r *http.Request) {
+	data, err := readBody(r)
+	if err != nil {
+		renderStorageError(r.Context(), w, err)
+		return
+	}
+
+	cluster := cke.NewCluster()
+	if err := yaml.Unmarshal(data, cluster); err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+	if err := cluster.Validate(false); err != nil {
+		renderError(r.Context(), w, BadRequest(err.Error()))
+		return
+	}
+
+	ctx := r.Context()
+	storage := cke.Storage{Client: s.EtcdClient}
+	inf, err := cke.NewReadOnlyInfrastructure(ctx, cluster, storage, nil)
+	if err != nil {
+		renderError(ctx, w, APIError{http.StatusServiceUnavailable, "infrastructure is not available; ask the leader", err})
+		return
+	}
+	defer inf.Close()
+
+	plan, err := MakePlan(ctx, cluster, inf)
+	if err != nil {
+		renderError(ctx, w, InternalServerError(err))
+		return
+	}
+	renderJSON(w, plan, http.StatusOK)
+}
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"time"
+
 	"github.com/cybozu-go/cke"
 	"github.com/cybozu-go/cke/op"
 	"github.com/cybozu-go/cke/op/clusterdns"
@@ -16,8 +18,12 @@ import (
 
 // DecideOps returns the next operations to do and the operation phase.
 // This returns nil when no operations need to be done.
-func DecideOps(c *cke.Cluster, cs *cke.ClusterStatus, constraints *cke.Constraints, resources []cke.ResourceDefinition, reboot *cke.RebootQueueEntry) ([]cke.Operator, cke.OperationPhase) {
+//
+// Disruptive operations are postponed unless constraints allow them at now.
+func DecideOps(c *cke.Cluster, cs *cke.ClusterStatus, constraints *cke.Constraints, resources []cke.ResourceDefinition, reboots []*cke.RebootQueueEntry, now time.Time) ([]cke.Operator, cke.OperationPhase) {
 	nf := NewNodeFilter(c, cs)
+	allowDisruption := constraints.DisruptionAllowed(now)
+	rebootEntries := rebootBatch(c, constraints, reboots, nf, now)
 
 	// 0. Execute upgrade operation if necessary
 	if cs.ConfigVersion != cke.ConfigVersion {
@@ -36,55 +42,121 @@ func DecideOps(c *cke.Cluster, cs *cke.ClusterStatus, constraints *cke.Constrain
 		return ops, cke.PhaseRivers
 	}
 
-	// 2. Bootstrap etcd cluster, if not yet.
+	// 2. Restore etcd cluster from a backup, if requested.
+	if cs.EtcdRestore != nil {
+		// Etcd restore operations run only when all CPs are SSH reachable
+		if len(nf.SSHNotConnectedNodes(nf.cluster.Nodes, true, false)) > 0 {
+			log.Warn("cannot restore etcd for unreachable nodes", nil)
+			return nil, cke.PhaseEtcdRestoreAborted
+		}
+		if c.EtcdBackup.Local == nil && c.EtcdBackup.S3 == nil && c.EtcdBackup.HTTP == nil {
+			log.Warn("cannot restore etcd because no backup target is configured", nil)
+			return nil, cke.PhaseEtcdRestoreAborted
+		}
+		return []cke.Operator{etcd.RestoreOp(nf.ControlPlane(), c.Options.Etcd, &c.EtcdBackup, cs.EtcdRestore, c.Images.EtcdImage())}, cke.PhaseEtcdRestore
+	}
+
+	// 3. Bootstrap etcd cluster, if not yet.
 	if !nf.EtcdBootstrapped() {
 		// Etcd boot operations run only when all CPs are SSH reachable
 		if len(nf.SSHNotConnectedNodes(nf.cluster.Nodes, true, false)) > 0 {
 			log.Warn("cannot bootstrap etcd for unreachable nodes", nil)
 			return nil, cke.PhaseEtcdBootAborted
 		}
-		return []cke.Operator{etcd.BootOp(nf.ControlPlane(), c.Options.Etcd)}, cke.PhaseEtcdBoot
+		return []cke.Operator{etcd.BootOp(nf.ControlPlane(), c.Options.Etcd, c.Certificates.TTL(), c.Images.EtcdImage())}, cke.PhaseEtcdBoot
 	}
 
-	// 3. Start etcd containers.
+	// 4. Start etcd containers.
 	if nodes := nf.SSHConnectedNodes(nf.EtcdStoppedMembers(), true, false); len(nodes) > 0 {
-		return []cke.Operator{etcd.StartOp(nodes, c.Options.Etcd)}, cke.PhaseEtcdStart
+		return []cke.Operator{etcd.StartOp(nodes, c.Options.Etcd, c.Certificates.TTL(), c.Images.EtcdImage())}, cke.PhaseEtcdStart
 	}
 
-	// 4. Wait for etcd cluster to become ready
+	// 5. Wait for etcd cluster to become ready
 	if !cs.Etcd.IsHealthy {
 		return []cke.Operator{etcd.WaitClusterOp(nf.ControlPlane())}, cke.PhaseEtcdWait
 	}
 
-	// 5. Run or restart kubernetes components.
-	if ops := k8sOps(c, nf, cs); len(ops) > 0 {
+	// 6. Check the version skew policy if etcd or kubernetes images are being upgraded.
+	// The upgrade itself is done by the following steps in the order of
+	// etcd, API servers, controller-manager and scheduler, and then kubelet and kube-proxy.
+	if nf.UpgradeInProgress() {
+		if err := nf.UpgradePreflight(); err != nil {
+			log.Warn("cannot upgrade etcd or kubernetes", map[string]interface{}{
+				log.FnError: err,
+			})
+			return nil, cke.PhaseVersionUpgradeAborted
+		}
+	}
+
+	// 7. Run or restart kubernetes components.
+	if ops := k8sOps(c, nf, cs, allowDisruption); len(ops) > 0 {
 		return ops, cke.PhaseK8sStart
 	}
 
-	// 6. Maintain etcd cluster, only when all CPs are SSH reachable.
+	// 8. Maintain etcd cluster, only when all CPs are SSH reachable.
 	if len(nf.SSHNotConnectedNodes(nf.cluster.Nodes, true, false)) == 0 {
-		if o := etcdMaintOp(c, nf); o != nil {
+		if o := etcdMaintOp(c, nf, allowDisruption); o != nil {
 			return []cke.Operator{o}, cke.PhaseEtcdMaintain
 		}
 	}
 
-	// 7. Maintain k8s resources.
-	if ops := k8sMaintOps(c, cs, resources, reboot, nf); len(ops) > 0 {
+	// 9. Rotate the encryption key for Secrets, only when all CPs are SSH reachable
+	// and all API servers are healthy.  This restarts API servers, so it waits for
+	// a maintenance window.
+	if cs.EncryptionKeyRotation != nil && allowDisruption {
+		if o := encryptionKeyRotationOp(c, cs, nf); o != nil {
+			return []cke.Operator{o}, cke.PhaseEncryptionKeyRotation
+		}
+	}
+
+	// 10. Rotate a CA, only when all nodes are SSH reachable, etcd is good,
+	// and all API servers are healthy.  Rotations that restart components
+	// wait for a maintenance window.
+	if cs.CARotation != nil && (allowDisruption || !caRotationDisruptive(cs.CARotation.CA)) {
+		if o := caRotationOp(c, cs, nf, resources); o != nil {
+			return []cke.Operator{o}, cke.PhaseCARotation
+		}
+	}
+
+	// 11. Renew certificates that are about to expire, one node at a time.
+	if ops := certificateRenewalOps(c, nf, now, allowDisruption); len(ops) > 0 {
+		return ops, cke.PhaseCertificateRenewal
+	}
+
+	// 12. Maintain k8s resources.
+	if ops := k8sMaintOps(c, cs, resources, rebootingEntries(rebootEntries, reboots), nf); len(ops) > 0 {
 		return ops, cke.PhaseK8sMaintain
 	}
 
-	// 8. Stop and delete control plane services running on non control plane nodes.
+	// 13. Stop and delete control plane services running on non control plane nodes.
 	if ops := cleanOps(c, nf); len(ops) > 0 {
 		return ops, cke.PhaseStopCP
 	}
 
-	// 9. Uncordon nodes if nodes are cordoned by CKE.
-	if o := rebootUncordonOp(nf); o != nil {
+	// 14. Uncordon nodes if nodes are cordoned by CKE, except for those being verified or failed verification.
+	if o := rebootUncordonOp(reboots, nf); o != nil {
 		return []cke.Operator{o}, cke.PhaseUncordonNodes
 	}
 
-	// 10. Reboot nodes if reboot request has been arrived to the reboot queue, and the number of unreachable nodes is less than a threshold.
-	if ops := rebootOps(c, reboot, nf); len(ops) > 0 {
+	// 15. Verify that rebooted nodes have come back, and dequeue the reboot queue entries.
+	// New reboots are not started until the verification completes.
+	if ops, verifying := rebootVerifyOps(c, reboots, nf, now); len(ops) > 0 {
+		return ops, cke.PhaseRebootNodes
+	} else if verifying {
+		return nil, cke.PhaseRebootNodes
+	}
+
+	// 16. Reboot nodes if reboot request has been arrived to the reboot queue, and the number of unreachable nodes is less than a threshold.
+	// Multiple entries are processed at once if allowed by the reboot configuration.
+	// Cancelled and expired entries are dequeued first, without rebooting.
+	if ops := rebootOps(c, rebootEntries, nf, now); len(ops) > 0 {
+		if rebootDiscarded(rebootEntries[0], now) {
+			return ops, cke.PhaseRebootNodes
+		}
+		if !allowDisruption {
+			log.Info("reboot is postponed until the next maintenance window", nil)
+			return nil, cke.PhaseWaitingWindow
+		}
 		if len(nf.SSHNotConnectedNodes(nf.cluster.Nodes, true, true)) > constraints.RebootMaximumUnreachable {
 			log.Warn("cannot reboot nodes because too many nodes are unreachable", nil)
 			return nil, cke.PhaseRebootNodes
@@ -96,9 +168,42 @@ func DecideOps(c *cke.Cluster, cs *cke.ClusterStatus, constraints *cke.Constrain
 		return ops, cke.PhaseRebootNodes
 	}
 
+	// 17. Wait for a maintenance window if disruptive operations are postponed.
+	if !allowDisruption && hasDisruptiveOps(nf, cs, now) {
+		return nil, cke.PhaseWaitingWindow
+	}
+
 	return nil, cke.PhaseCompleted
 }
 
+// hasDisruptiveOps returns true if there are disruptive operations that
+// DecideOps postpones outside of maintenance windows.
+func hasDisruptiveOps(nf *NodeFilter, cs *cke.ClusterStatus, now time.Time) bool {
+	if len(nf.SSHConnectedNodes(nf.APIServerOutdatedNodes(), true, false)) > 0 {
+		return true
+	}
+	if cs.EncryptionKeyRotation != nil {
+		return true
+	}
+	if cs.CARotation != nil && caRotationDisruptive(cs.CARotation.CA) {
+		return true
+	}
+	for _, n := range nf.SSHConnectedNodes(nf.cluster.Nodes, true, true) {
+		for _, component := range nf.CertificateExpiringComponents(n, now) {
+			if disruptiveRenewal(component) {
+				return true
+			}
+		}
+	}
+	if len(nf.SSHConnectedNodes(nf.KubeletOutdatedNodes(), true, true)) > 0 {
+		return true
+	}
+	if len(nf.SSHNotConnectedNodes(nf.cluster.Nodes, true, false)) == 0 && len(nf.EtcdOutdatedMembers()) > 0 {
+		return true
+	}
+	return false
+}
+
 func riversOps(c *cke.Cluster, nf *NodeFilter) (ops []cke.Operator) {
 	if nodes := nf.SSHConnectedNodes(nf.RiversStoppedNodes(), true, true); len(nodes) > 0 {
 		ops = append(ops, op.RiversBootOp(nodes, nf.ControlPlane(), c.Options.Rivers, op.RiversContainerName, op.RiversUpstreamPort, op.RiversListenPort))
@@ -115,46 +220,61 @@ func riversOps(c *cke.Cluster, nf *NodeFilter) (ops []cke.Operator) {
 	return ops
 }
 
-func k8sOps(c *cke.Cluster, nf *NodeFilter, cs *cke.ClusterStatus) (ops []cke.Operator) {
+func k8sOps(c *cke.Cluster, nf *NodeFilter, cs *cke.ClusterStatus, allowDisruption bool) (ops []cke.Operator) {
+	k8sImage := c.Images.KubernetesImage()
+
 	// For cp nodes
+	if kms := c.Options.APIServer.KMS; kms != nil {
+		if nodes := nf.SSHConnectedNodes(nf.KMSPluginStoppedNodes(), true, false); len(nodes) > 0 {
+			ops = append(ops, k8s.KMSPluginBootOp(nodes, *kms))
+		}
+		if nodes := nf.SSHConnectedNodes(nf.KMSPluginOutdatedNodes(), true, false); len(nodes) > 0 {
+			ops = append(ops, k8s.KMSPluginRestartOp(nodes, *kms))
+		}
+	}
+	if nodes := nf.SSHConnectedNodes(nf.KMSPluginRunningUnexpectedlyNodes(), true, false); len(nodes) > 0 {
+		ops = append(ops, op.KMSPluginStopOp(nodes))
+	}
 	if nodes := nf.SSHConnectedNodes(nf.APIServerStoppedNodes(), true, false); len(nodes) > 0 {
 		kubeletConfig := k8s.GenerateKubeletConfiguration(c.Options.Kubelet, "0.0.0.0", nil)
-		ops = append(ops, k8s.APIServerRestartOp(nodes, nf.ControlPlane(), c.ServiceSubnet, c.Options.APIServer, kubeletConfig.ClusterDomain))
+		ops = append(ops, k8s.APIServerRestartOp(nodes, nf.ControlPlane(), c.ServiceSubnet, c.Options.APIServer, kubeletConfig.ClusterDomain, c.Certificates.TTL(), k8sImage))
 	}
-	if nodes := nf.SSHConnectedNodes(nf.APIServerOutdatedNodes(), true, false); len(nodes) > 0 {
+	if nodes := nf.SSHConnectedNodes(nf.APIServerOutdatedNodes(), true, false); len(nodes) > 0 && allowDisruption {
 		kubeletConfig := k8s.GenerateKubeletConfiguration(c.Options.Kubelet, "0.0.0.0", nil)
-		ops = append(ops, k8s.APIServerRestartOp(nodes, nf.ControlPlane(), c.ServiceSubnet, c.Options.APIServer, kubeletConfig.ClusterDomain))
+		ops = append(ops, k8s.APIServerRestartOp(nodes, nf.ControlPlane(), c.ServiceSubnet, c.Options.APIServer, kubeletConfig.ClusterDomain, c.Certificates.TTL(), k8sImage))
 	}
 	if nodes := nf.SSHConnectedNodes(nf.ControllerManagerStoppedNodes(), true, false); len(nodes) > 0 {
-		ops = append(ops, k8s.ControllerManagerBootOp(nodes, c.Name, c.ServiceSubnet, c.Options.ControllerManager))
+		ops = append(ops, k8s.ControllerManagerBootOp(nodes, c.Name, c.ServiceSubnet, c.Options.ControllerManager, c.Certificates.TTL(), k8sImage))
 	}
 	if nodes := nf.SSHConnectedNodes(nf.ControllerManagerOutdatedNodes(), true, false); len(nodes) > 0 {
-		ops = append(ops, k8s.ControllerManagerRestartOp(nodes, c.Name, c.ServiceSubnet, c.Options.ControllerManager))
+		ops = append(ops, k8s.ControllerManagerRestartOp(nodes, c.Name, c.ServiceSubnet, c.Options.ControllerManager, c.Certificates.TTL(), k8sImage))
 	}
 	if nodes := nf.SSHConnectedNodes(nf.SchedulerStoppedNodes(), true, false); len(nodes) > 0 {
-		ops = append(ops, k8s.SchedulerBootOp(nodes, c.Name, c.Options.Scheduler))
+		ops = append(ops, k8s.SchedulerBootOp(nodes, c.Name, c.Options.Scheduler, c.Certificates.TTL(), k8sImage))
 	}
 	if nodes := nf.SSHConnectedNodes(nf.SchedulerOutdatedNodes(c.Options.Scheduler), true, false); len(nodes) > 0 {
-		ops = append(ops, k8s.SchedulerRestartOp(nodes, c.Name, c.Options.Scheduler))
+		ops = append(ops, k8s.SchedulerRestartOp(nodes, c.Name, c.Options.Scheduler, c.Certificates.TTL(), k8sImage))
 	}
 
 	// For all nodes
 	apiServer := nf.HealthyAPIServer()
 	if nodes := nf.SSHConnectedNodes(nf.KubeletUnrecognizedNodes(), true, true); len(nodes) > 0 {
-		ops = append(ops, k8s.KubeletRestartOp(nodes, c.Name, c.Options.Kubelet, cs.NodeStatuses))
+		ops = append(ops, k8s.KubeletRestartOp(nodes, apiServer, c.Name, c.Options.Kubelet, cs.NodeStatuses, 0, c.Certificates.TTL(), k8sImage))
 	}
 	if nodes := nf.SSHConnectedNodes(nf.KubeletStoppedNodes(), true, true); len(nodes) > 0 {
 		ops = append(ops, k8s.KubeletBootOp(nodes, nf.KubeletStoppedRegisteredNodes(),
-			apiServer, c.Name, c.Options.Kubelet, cs.NodeStatuses))
+			apiServer, c.Name, c.Options.Kubelet, cs.NodeStatuses, c.Certificates.TTL(), k8sImage))
 	}
-	if nodes := nf.SSHConnectedNodes(nf.KubeletOutdatedNodes(), true, true); len(nodes) > 0 {
-		ops = append(ops, k8s.KubeletRestartOp(nodes, c.Name, c.Options.Kubelet, cs.NodeStatuses))
+	if nodes := nf.SSHConnectedNodes(nf.KubeletOutdatedNodes(), true, true); len(nodes) > 0 && allowDisruption {
+		batchSize := c.Options.Kubelet.RollingUpdate.BatchSize(len(c.Nodes))
+		ops = append(ops, k8s.KubeletRestartOp(nodes, apiServer, c.Name, c.Options.Kubelet, cs.NodeStatuses, batchSize, c.Certificates.TTL(), k8sImage))
 	}
 	if nodes := nf.SSHConnectedNodes(nf.ProxyStoppedNodes(), true, true); len(nodes) > 0 {
-		ops = append(ops, k8s.KubeProxyBootOp(nodes, c.Name, "", c.Options.Proxy))
+		ops = append(ops, k8s.KubeProxyBootOp(nodes, c.Name, "", c.Options.Proxy, c.Certificates.TTL(), k8sImage))
 	}
 	if nodes := nf.SSHConnectedNodes(nf.ProxyOutdatedNodes(c.Options.Proxy), true, true); len(nodes) > 0 {
-		ops = append(ops, k8s.KubeProxyRestartOp(nodes, c.Name, "", c.Options.Proxy))
+		batchSize := c.Options.Proxy.RollingUpdate.BatchSize(len(c.Nodes))
+		ops = append(ops, k8s.KubeProxyRestartOp(nodes, c.Name, "", c.Options.Proxy, batchSize, c.Certificates.TTL(), k8sImage))
 	}
 	if nodes := nf.SSHConnectedNodes(nf.ProxyRunningUnexpectedlyNodes(), true, true); len(nodes) > 0 {
 		ops = append(ops, op.ProxyStopOp(nodes))
@@ -162,7 +282,7 @@ func k8sOps(c *cke.Cluster, nf *NodeFilter, cs *cke.ClusterStatus) (ops []cke.Op
 	return ops
 }
 
-func etcdMaintOp(c *cke.Cluster, nf *NodeFilter) cke.Operator {
+func etcdMaintOp(c *cke.Cluster, nf *NodeFilter, allowDisruption bool) cke.Operator {
 	// this function is called only when all the CPs are reachable.
 	// so, filtering by SSHConnectedNodes(nodes, true, ...) is not required.
 
@@ -173,7 +293,7 @@ func etcdMaintOp(c *cke.Cluster, nf *NodeFilter) cke.Operator {
 		return etcd.DestroyMemberOp(nf.ControlPlane(), nf.SSHConnectedNodes(nodes, false, true), ids)
 	}
 	if nodes := nf.EtcdUnstartedMembers(); len(nodes) > 0 {
-		return etcd.AddMemberOp(nf.ControlPlane(), nodes[0], c.Options.Etcd)
+		return etcd.AddMemberOp(nf.ControlPlane(), nodes[0], c.Options.Etcd, c.Certificates.TTL(), c.Images.EtcdImage())
 	}
 
 	if !nf.EtcdIsGood() {
@@ -186,7 +306,7 @@ func etcdMaintOp(c *cke.Cluster, nf *NodeFilter) cke.Operator {
 	// all members are in sync.
 
 	if nodes := nf.EtcdNewMembers(); len(nodes) > 0 {
-		return etcd.AddMemberOp(nf.ControlPlane(), nodes[0], c.Options.Etcd)
+		return etcd.AddMemberOp(nf.ControlPlane(), nodes[0], c.Options.Etcd, c.Certificates.TTL(), c.Images.EtcdImage())
 	}
 	if members := nf.EtcdNonClusterMembers(true); len(members) > 0 {
 		return etcd.RemoveMemberOp(nf.ControlPlane(), members)
@@ -194,14 +314,158 @@ func etcdMaintOp(c *cke.Cluster, nf *NodeFilter) cke.Operator {
 	if nodes, ids := nf.EtcdNonCPMembers(true); len(nodes) > 0 {
 		return etcd.DestroyMemberOp(nf.ControlPlane(), nf.SSHConnectedNodes(nodes, false, true), ids)
 	}
-	if nodes := nf.EtcdOutdatedMembers(); len(nodes) > 0 {
-		return etcd.RestartOp(nf.ControlPlane(), nodes[0], c.Options.Etcd)
+	if nodes := nf.EtcdOutdatedMembers(); len(nodes) > 0 && allowDisruption {
+		return etcd.RestartOp(nf.ControlPlane(), nodes[0], c.Options.Etcd, c.Certificates.TTL(), c.Images.EtcdImage())
 	}
 
 	return nil
 }
 
-func k8sMaintOps(c *cke.Cluster, cs *cke.ClusterStatus, resources []cke.ResourceDefinition, reboot *cke.RebootQueueEntry, nf *NodeFilter) (ops []cke.Operator) {
+func encryptionKeyRotationOp(c *cke.Cluster, cs *cke.ClusterStatus, nf *NodeFilter) cke.Operator {
+	if len(nf.SSHNotConnectedNodes(nf.cluster.Nodes, true, false)) > 0 {
+		log.Warn("cannot rotate the encryption key for unreachable nodes", nil)
+		return nil
+	}
+	if len(nf.UnhealthyAPIServerNodes()) > 0 {
+		log.Warn("cannot rotate the encryption key while API servers are unhealthy", nil)
+		return nil
+	}
+
+	kubeletConfig := k8s.GenerateKubeletConfiguration(c.Options.Kubelet, "0.0.0.0", nil)
+	return k8s.EncryptionKeyRotationOp(nf.ControlPlane(), nf.HealthyAPIServer(), c.ServiceSubnet, c.Options.APIServer,
+		kubeletConfig.ClusterDomain, c.Images.KubernetesImage(), cs.EncryptionKeyRotation)
+}
+
+// disruptiveRenewal returns true if renewing the certificate of the component
+// restarts it disruptively.  Such renewals wait for a maintenance window.
+func disruptiveRenewal(component string) bool {
+	switch component {
+	case op.EtcdContainerName, op.KubeAPIServerContainerName, op.KubeletContainerName:
+		return true
+	}
+	return false
+}
+
+func certificateRenewalOps(c *cke.Cluster, nf *NodeFilter, now time.Time, allowDisruption bool) (ops []cke.Operator) {
+	certTTL := c.Certificates.TTL()
+	k8sImage := c.Images.KubernetesImage()
+
+	for _, n := range nf.SSHConnectedNodes(c.Nodes, true, true) {
+		nodes := []*cke.Node{n}
+		for _, component := range nf.CertificateExpiringComponents(n, now) {
+			if disruptiveRenewal(component) && !allowDisruption {
+				continue
+			}
+			switch component {
+			case op.EtcdContainerName:
+				// etcd members are restarted only when the cluster is good,
+				// and without restarting other components at the same time.
+				if len(nf.SSHNotConnectedNodes(c.Nodes, true, false)) > 0 || !nf.EtcdIsGood() {
+					log.Warn("cannot renew etcd certificates because etcd is not good", map[string]interface{}{
+						"node": n.Address,
+					})
+					continue
+				}
+				return []cke.Operator{etcd.RestartOp(nf.ControlPlane(), n, c.Options.Etcd, certTTL, c.Images.EtcdImage())}
+			case op.KubeAPIServerContainerName:
+				kubeletConfig := k8s.GenerateKubeletConfiguration(c.Options.Kubelet, "0.0.0.0", nil)
+				ops = append(ops, k8s.APIServerRestartOp(nodes, nf.ControlPlane(), c.ServiceSubnet, c.Options.APIServer, kubeletConfig.ClusterDomain, certTTL, k8sImage))
+			case op.KubeControllerManagerContainerName:
+				ops = append(ops, k8s.ControllerManagerRestartOp(nodes, c.Name, c.ServiceSubnet, c.Options.ControllerManager, certTTL, k8sImage))
+			case op.KubeSchedulerContainerName:
+				ops = append(ops, k8s.SchedulerRestartOp(nodes, c.Name, c.Options.Scheduler, certTTL, k8sImage))
+			case op.KubeletContainerName:
+				ops = append(ops, k8s.KubeletRestartOp(nodes, nf.HealthyAPIServer(), c.Name, c.Options.Kubelet, nf.status.NodeStatuses, 0, certTTL, k8sImage))
+			case op.KubeProxyContainerName:
+				ops = append(ops, k8s.KubeProxyRestartOp(nodes, c.Name, "", c.Options.Proxy, 0, certTTL, k8sImage))
+			}
+		}
+		if len(ops) > 0 {
+			return ops
+		}
+	}
+	return nil
+}
+
+// caComponents lists the components that depend on each CA, in the order to restart.
+var caComponents = map[string][]string{
+	cke.CAServer:     {op.EtcdContainerName, op.KubeAPIServerContainerName},
+	cke.CAEtcdPeer:   {op.EtcdContainerName},
+	cke.CAEtcdClient: {op.EtcdContainerName, op.KubeAPIServerContainerName},
+	cke.CAKubernetes: {op.KubeAPIServerContainerName, op.KubeControllerManagerContainerName,
+		op.KubeSchedulerContainerName, op.KubeletContainerName, op.KubeProxyContainerName},
+	cke.CAKubernetesAggregation: {op.KubeAPIServerContainerName},
+}
+
+// caRotationDisruptive returns true if rotating the CA restarts components.
+// Such rotations wait for a maintenance window.
+func caRotationDisruptive(ca string) bool {
+	return len(caComponents[ca]) > 0
+}
+
+func caRotationOp(c *cke.Cluster, cs *cke.ClusterStatus, nf *NodeFilter, resources []cke.ResourceDefinition) cke.Operator {
+	if len(nf.SSHNotConnectedNodes(nf.cluster.Nodes, true, true)) > 0 {
+		log.Warn("cannot rotate the CA for unreachable nodes", nil)
+		return nil
+	}
+	if !nf.EtcdIsGood() {
+		log.Warn("cannot rotate the CA because etcd cluster is not responding and in-sync", nil)
+		return nil
+	}
+	if len(nf.UnhealthyAPIServerNodes()) > 0 {
+		log.Warn("cannot rotate the CA while API servers are unhealthy", nil)
+		return nil
+	}
+
+	certTTL := c.Certificates.TTL()
+	k8sImage := c.Images.KubernetesImage()
+	apiServer := nf.HealthyAPIServer()
+	cps := nf.ControlPlane()
+
+	var ops []cke.Operator
+	for _, component := range caComponents[cs.CARotation.CA] {
+		switch component {
+		case op.EtcdContainerName:
+			for _, n := range cps {
+				ops = append(ops, etcd.RestartOp(cps, n, c.Options.Etcd, certTTL, c.Images.EtcdImage()))
+			}
+		case op.KubeAPIServerContainerName:
+			kubeletConfig := k8s.GenerateKubeletConfiguration(c.Options.Kubelet, "0.0.0.0", nil)
+			for _, n := range cps {
+				ops = append(ops,
+					k8s.APIServerRestartOp([]*cke.Node{n}, cps, c.ServiceSubnet, c.Options.APIServer, kubeletConfig.ClusterDomain, certTTL, k8sImage),
+					op.KubeWaitOp(n))
+			}
+		case op.KubeControllerManagerContainerName:
+			ops = append(ops, k8s.ControllerManagerRestartOp(cps, c.Name, c.ServiceSubnet, c.Options.ControllerManager, certTTL, k8sImage))
+		case op.KubeSchedulerContainerName:
+			ops = append(ops, k8s.SchedulerRestartOp(cps, c.Name, c.Options.Scheduler, certTTL, k8sImage))
+		case op.KubeletContainerName:
+			batchSize := c.Options.Kubelet.RollingUpdate.BatchSize(len(c.Nodes))
+			ops = append(ops, k8s.KubeletRestartOp(c.Nodes, apiServer, c.Name, c.Options.Kubelet, cs.NodeStatuses, batchSize, certTTL, k8sImage))
+		case op.KubeProxyContainerName:
+			if nodes := nf.ProxyRunningNodes(); len(nodes) > 0 {
+				batchSize := c.Options.Proxy.RollingUpdate.BatchSize(len(c.Nodes))
+				ops = append(ops, k8s.KubeProxyRestartOp(nodes, c.Name, "", c.Options.Proxy, batchSize, certTTL, k8sImage))
+			}
+		}
+	}
+
+	if cs.CARotation.CA == cke.CAWebhook {
+		// re-apply resources to update caBundle of webhook configurations
+		// and certificates in Secrets.
+		for _, res := range resources {
+			switch res.Kind {
+			case cke.KindValidatingWebhookConfiguration, cke.KindMutatingWebhookConfiguration, cke.KindSecret:
+				ops = append(ops, op.ResourceApplyOp(apiServer, res, false))
+			}
+		}
+	}
+
+	return op.CARotationOp(cs.CARotation, ops)
+}
+
+func k8sMaintOps(c *cke.Cluster, cs *cke.ClusterStatus, resources []cke.ResourceDefinition, reboots []*cke.RebootQueueEntry, nf *NodeFilter) (ops []cke.Operator) {
 	ks := cs.Kubernetes
 	apiServer := nf.HealthyAPIServer()
 
@@ -216,15 +480,10 @@ func k8sMaintOps(c *cke.Cluster, cs *cke.ClusterStatus, resources []cke.Resource
 	ops = append(ops, decideNodeDNSOps(apiServer, c, ks)...)
 
 	var masterReadyAddresses, masterNotReadyAddresses []string
-OUTER_MASTER:
 	for _, n := range nf.HealthyAPIServerNodes() {
-		if reboot != nil && reboot.Status != cke.RebootStatusCancelled {
-			for _, r := range reboot.Nodes {
-				if n.Address == r {
-					masterNotReadyAddresses = append(masterNotReadyAddresses, n.Address)
-					continue OUTER_MASTER
-				}
-			}
+		if isRebooting(reboots, n.Address) {
+			masterNotReadyAddresses = append(masterNotReadyAddresses, n.Address)
+			continue
 		}
 		masterReadyAddresses = append(masterReadyAddresses, n.Address)
 	}
@@ -252,15 +511,10 @@ OUTER_MASTER:
 	}
 
 	var etcdReadyAddresses, etcdNotReadyAddresses []string
-OUTER_ETCD:
 	for _, n := range nf.ControlPlane() {
-		if reboot != nil && reboot.Status != cke.RebootStatusCancelled {
-			for _, r := range reboot.Nodes {
-				if n.Address == r {
-					etcdNotReadyAddresses = append(etcdNotReadyAddresses, n.Address)
-					continue OUTER_ETCD
-				}
-			}
+		if isRebooting(reboots, n.Address) {
+			etcdNotReadyAddresses = append(etcdNotReadyAddresses, n.Address)
+			continue
 		}
 		etcdReadyAddresses = append(etcdReadyAddresses, n.Address)
 	}
@@ -582,7 +836,7 @@ func decideResourceOps(apiServer *cke.Node, ks cke.KubernetesClusterStatus, reso
 }
 
 func cleanOps(c *cke.Cluster, nf *NodeFilter) (ops []cke.Operator) {
-	var apiServers, controllerManagers, schedulers, etcds, etcdRivers []*cke.Node
+	var apiServers, kmsPlugins, controllerManagers, schedulers, etcds, etcdRivers []*cke.Node
 
 	for _, n := range c.Nodes {
 		if !nf.status.NodeStatuses[n.Address].SSHConnected || n.ControlPlane {
@@ -596,6 +850,9 @@ func cleanOps(c *cke.Cluster, nf *NodeFilter) (ops []cke.Operator) {
 		if st.APIServer.Running {
 			apiServers = append(apiServers, n)
 		}
+		if st.KMSPlugin.Running {
+			kmsPlugins = append(kmsPlugins, n)
+		}
 		if st.ControllerManager.Running {
 			controllerManagers = append(controllerManagers, n)
 		}
@@ -610,6 +867,9 @@ func cleanOps(c *cke.Cluster, nf *NodeFilter) (ops []cke.Operator) {
 	if len(apiServers) > 0 {
 		ops = append(ops, op.APIServerStopOp(apiServers))
 	}
+	if len(kmsPlugins) > 0 {
+		ops = append(ops, op.KMSPluginStopOp(kmsPlugins))
+	}
 	if len(controllerManagers) > 0 {
 		ops = append(ops, op.ControllerManagerStopOp(controllerManagers))
 	}
@@ -625,48 +885,131 @@ func cleanOps(c *cke.Cluster, nf *NodeFilter) (ops []cke.Operator) {
 	return ops
 }
 
-func rebootOps(c *cke.Cluster, entry *cke.RebootQueueEntry, nf *NodeFilter) []cke.Operator {
-	if entry == nil {
-		return nil
-	}
-	if entry.Status == cke.RebootStatusCancelled {
-		return []cke.Operator{op.RebootDequeueOp(entry.Index)}
-	}
-	if len(c.Reboot.Command) == 0 {
-		log.Warn("reboot command is not specified in the cluster configuration", nil)
-		return nil
+func rebootOps(c *cke.Cluster, entries []*cke.RebootQueueEntry, nf *NodeFilter, now time.Time) (ops []cke.Operator) {
+	for _, entry := range entries {
+		if rebootDiscarded(entry, now) {
+			if entry.Expired(now) {
+				log.Warn("reboot queue entry is removed because its deadline has passed", map[string]interface{}{
+					"index":    entry.Index,
+					"deadline": entry.Deadline,
+				})
+			}
+			ops = append(ops, op.RebootDequeueOp(entry.Index, entry.Nodes))
+			continue
+		}
+		if len(c.Reboot.Command) == 0 {
+			log.Warn("reboot command is not specified in the cluster configuration", nil)
+			return nil
+		}
+
+		var nodes []*cke.Node
+	OUTER:
+		for _, rebootNode := range entry.Nodes {
+			for _, clusterNode := range c.Nodes {
+				if rebootNode == clusterNode.Address {
+					nodes = append(nodes, clusterNode)
+					continue OUTER
+				}
+			}
+			log.Warn("skipped rebooting a node because it is not found in the cluster", map[string]interface{}{
+				"node": rebootNode,
+			})
+		}
+		if len(nodes) == 0 {
+			ops = append(ops, op.RebootDequeueOp(entry.Index, entry.Nodes))
+			continue
+		}
+		// The entry is dequeued after the nodes are verified by rebootVerifyOps.
+		ops = append(ops, op.RebootOp(nf.HealthyAPIServer(), nodes, entry.Index, &c.Reboot))
 	}
+	return ops
+}
+
+// rebootVerifyOps returns operations to finish the reboot queue entries
+// being verified.  An entry is dequeued when all of its nodes have been
+// rebooted and become ready, or marked as failed if some nodes have not
+// within the verification timeout.  verifying is true if some entries
+// are still being verified.
+func rebootVerifyOps(c *cke.Cluster, entries []*cke.RebootQueueEntry, nf *NodeFilter, now time.Time) (ops []cke.Operator, verifying bool) {
+	timeout := c.Reboot.VerificationTimeout()
+	for _, entry := range entries {
+		if entry.Status != cke.RebootStatusVerifying {
+			continue
+		}
 
-	var nodes []*cke.Node
-OUTER:
-	for _, rebootNode := range entry.Nodes {
-		for _, clusterNode := range c.Nodes {
-			if rebootNode == clusterNode.Address {
-				nodes = append(nodes, clusterNode)
-				continue OUTER
+		var nodes []*cke.Node
+		var failedNodes []string
+		for _, addr := range entry.Nodes {
+			n, ok := nf.nodeMap[addr]
+			if !ok {
+				// removed from the cluster
+				continue
+			}
+			nodes = append(nodes, n)
+			if !nf.RebootVerified(n, entry.BootIDs[addr]) {
+				failedNodes = append(failedNodes, addr)
 			}
 		}
-		log.Warn("skipped rebooting a node because it is not found in the cluster", map[string]interface{}{
-			"node": rebootNode,
-		})
+
+		switch {
+		case len(failedNodes) == 0 && len(nodes) > 0 && len(c.Reboot.Hooks.PostReboot) > 0:
+			ops = append(ops, op.RebootPostHookOp(nf.HealthyAPIServer(), nodes, entry.Index, &c.Reboot))
+		case len(failedNodes) == 0:
+			ops = append(ops, op.RebootDequeueOp(entry.Index, entry.Nodes))
+		case entry.Rebooted != nil && now.Sub(*entry.Rebooted) < timeout:
+			verifying = true
+		default:
+			log.Error("rebooted nodes did not become ready", map[string]interface{}{
+				"index": entry.Index,
+				"nodes": failedNodes,
+			})
+			ops = append(ops, op.RebootFailOp(entry.Index, entry.Nodes, failedNodes))
+		}
 	}
-	if len(nodes) > 0 {
-		return []cke.Operator{
-			op.RebootOp(nf.HealthyAPIServer(), nodes, entry.Index, &c.Reboot),
-			op.RebootDequeueOp(entry.Index),
+	return ops, verifying
+}
+
+// isRebooting returns true if the node at address is in a reboot queue entry
+// that is not cancelled.
+func isRebooting(entries []*cke.RebootQueueEntry, address string) bool {
+	for _, e := range entries {
+		if e.Status == cke.RebootStatusCancelled {
+			continue
+		}
+		for _, r := range e.Nodes {
+			if r == address {
+				return true
+			}
 		}
 	}
-	return []cke.Operator{op.RebootDequeueOp(entry.Index)}
+	return false
 }
 
-func rebootUncordonOp(nf *NodeFilter) cke.Operator {
-	attrNodes := nf.CordonedNodes()
-	if len(attrNodes) == 0 {
-		return nil
+// rebootUncordonOp returns an operator to uncordon nodes cordoned by CKE.
+// Nodes in reboot queue entries being verified or failed verification are
+// kept cordoned.
+func rebootUncordonOp(entries []*cke.RebootQueueEntry, nf *NodeFilter) cke.Operator {
+	keep := make(map[string]bool)
+	for _, e := range entries {
+		if e.Status != cke.RebootStatusVerifying && e.Status != cke.RebootStatusFailed {
+			continue
+		}
+		for _, addr := range e.Nodes {
+			if n, ok := nf.nodeMap[addr]; ok {
+				keep[n.Nodename()] = true
+			}
+		}
+	}
+
+	var nodes []string
+	for _, n := range nf.CordonedNodes() {
+		if keep[n.Name] {
+			continue
+		}
+		nodes = append(nodes, n.Name)
 	}
-	nodes := make([]string, len(attrNodes))
-	for i, n := range attrNodes {
-		nodes[i] = n.Name
+	if len(nodes) == 0 {
+		return nil
 	}
 	return op.RebootUncordonOp(nf.HealthyAPIServer(), nodes)
 }
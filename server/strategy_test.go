@@ -21,6 +21,7 @@ import (
 	proxyv1alpha1 "k8s.io/kube-proxy/config/v1alpha1"
 	schedulerv1beta1 "k8s.io/kube-scheduler/config/v1beta1"
 	kubeletv1beta1 "k8s.io/kubelet/config/v1beta1"
+	"k8s.io/utils/pointer"
 )
 
 const (
@@ -28,6 +29,9 @@ const (
 	testServiceSubnet    = "12.34.56.0/24"
 	testDefaultDNSDomain = "cluster.local"
 	testDefaultDNSAddr   = "10.0.0.53"
+
+	testOldEtcdImage       = cke.Image("quay.io/cybozu/etcd:3.4.16.1")
+	testOldKubernetesImage = cke.Image("quay.io/cybozu/kubernetes:1.21.8.1")
 )
 
 var (
@@ -62,7 +66,8 @@ type testData struct {
 	Status      *cke.ClusterStatus
 	Constraints *cke.Constraints
 	Resources   []cke.ResourceDefinition
-	Reboot      *cke.RebootQueueEntry
+	Reboots     []*cke.RebootQueueEntry
+	Now         time.Time
 }
 
 func (d testData) ControlPlane() (nodes []*cke.Node) {
@@ -253,7 +258,7 @@ func (d testData) withAPIServer(serviceSubnet, domain string) testData {
 		st.Running = true
 		st.IsHealthy = true
 		st.Image = cke.KubernetesImage.Name()
-		st.BuiltInParams = k8s.APIServerParams(n.Address, serviceSubnet, false, "", "", domain)
+		st.BuiltInParams = k8s.APIServerParams(n.Address, serviceSubnet, false, "", "", domain, nil)
 	}
 	return d
 }
@@ -507,6 +512,21 @@ OUTER:
 	return d
 }
 
+func (d testData) withRunningImages(etcdImage, k8sImage cke.Image) testData {
+	for _, n := range d.Cluster.Nodes {
+		st := d.NodeStatus(n)
+		if n.ControlPlane {
+			st.Etcd.Image = etcdImage.Name()
+			st.APIServer.Image = k8sImage.Name()
+			st.ControllerManager.Image = k8sImage.Name()
+			st.Scheduler.Image = k8sImage.Name()
+		}
+		st.Kubelet.Image = k8sImage.Name()
+		st.Proxy.Image = k8sImage.Name()
+	}
+	return d
+}
+
 func (d testData) withSSHNotConnectedCP() testData {
 	n := d.ControlPlane()[0]
 	st := d.NodeStatus(n)
@@ -548,7 +568,14 @@ func (d testData) withRebootConfig() testData {
 }
 
 func (d testData) withRebootEntry(entry *cke.RebootQueueEntry) testData {
-	d.Reboot = entry
+	d.Reboots = append(d.Reboots, entry)
+	return d
+}
+
+func (d testData) withBootIDs(bootID string) testData {
+	for _, n := range d.Cluster.Nodes {
+		d.NodeStatus(n).BootID = bootID
+	}
 	return d
 }
 
@@ -557,6 +584,101 @@ func (d testData) withDisableProxy() testData {
 	return d
 }
 
+func (d testData) withBlackout() testData {
+	d.Now = time.Date(2021, 12, 1, 12, 0, 0, 0, time.UTC)
+	constraints := *d.Constraints
+	constraints.Blackouts = []cke.Blackout{
+		{Start: d.Now.Add(-time.Hour), End: d.Now.Add(time.Hour)},
+	}
+	d.Constraints = &constraints
+	return d
+}
+
+func (d testData) withEtcdRestore() testData {
+	d.Cluster.EtcdBackup.Local = &cke.EtcdBackupLocal{Dir: "/var/lib/cke-backup"}
+	d.Status.EtcdRestore = &cke.EtcdRestoreRequest{
+		Name:  "etcd-20211201-000000.backup",
+		Token: "cke-restore-1638316800",
+	}
+	return d
+}
+
+// withKMS enables KMS.  If running is true, KMS plugin and API servers
+// are running with the current parameters.
+func (d testData) withKMS(running bool) testData {
+	kms := &cke.KMSParams{
+		Image:    "example.com/kms-plugin:1.0.0",
+		Name:     "test-kms",
+		Endpoint: "unix:///run/kms-plugin/kms.sock",
+	}
+	d.Cluster.Options.APIServer.KMS = kms
+	if !running {
+		return d
+	}
+
+	kubeletConfig := k8s.GenerateKubeletConfiguration(d.Cluster.Options.Kubelet, "0.0.0.0", nil)
+	for _, n := range d.ControlPlane() {
+		st := d.NodeStatus(n)
+		st.KMSPlugin.Running = true
+		st.KMSPlugin.Image = kms.Image
+		st.KMSPlugin.BuiltInParams = k8s.KMSPluginParams(*kms)
+		st.APIServer.BuiltInParams = k8s.APIServerParams(n.Address, testServiceSubnet, false, "", "", kubeletConfig.ClusterDomain, kms)
+	}
+	return d
+}
+
+func (d testData) withEncryptionKeyRotation(step cke.EncryptionKeyRotationStep) testData {
+	d.Status.EncryptionKeyRotation = &cke.EncryptionKeyRotation{
+		Provider: cke.EncryptionProviderAESGCM,
+		KeyName:  "2021-12-01T00:00:00Z",
+		Step:     step,
+	}
+	return d
+}
+
+func (d testData) withCARotation(ca string, step cke.CARotationStep) testData {
+	d.Status.CARotation = &cke.CARotation{
+		CA:             ca,
+		OldIssuer:      "old",
+		NewIssuer:      "new",
+		OldCertificate: "old",
+		NewCertificate: "new",
+		Step:           step,
+	}
+	return d
+}
+
+// withCertificateExpiry sets now and the expiration time of certificates
+// of all components as if they were issued at now.
+func (d testData) withCertificateExpiry(now time.Time) testData {
+	d.Now = now
+	for _, n := range d.Cluster.Nodes {
+		components := []string{op.KubeletContainerName, op.KubeProxyContainerName}
+		if n.ControlPlane {
+			components = append(components, op.EtcdContainerName, op.KubeAPIServerContainerName,
+				op.KubeControllerManagerContainerName, op.KubeSchedulerContainerName)
+		}
+		expiry := make(map[string]time.Time)
+		for _, c := range components {
+			expiry[c] = now.Add(cke.DefaultCertificateTTL)
+		}
+		d.NodeStatus(n).CertificateExpiry = expiry
+	}
+	return d
+}
+
+// withMaintenanceWindow sets a window from 02:00 to 04:00 on Wednesdays.
+// 2021-12-01 is Wednesday.
+func (d testData) withMaintenanceWindow(now time.Time) testData {
+	d.Now = now
+	constraints := *d.Constraints
+	constraints.MaintenanceWindows = []cke.MaintenanceWindow{
+		{Days: []string{"Wed"}, Start: "02:00", Duration: "2h"},
+	}
+	d.Constraints = &constraints
+	return d
+}
+
 func TestDecideOps(t *testing.T) {
 	t.Parallel()
 
@@ -565,6 +687,7 @@ func TestDecideOps(t *testing.T) {
 		Input              testData
 		ExpectedOps        []string
 		ExpectedTargetNums map[string]int
+		ExpectedPhase      cke.OperationPhase
 	}{
 		{
 			Name:               "BootRivers",
@@ -660,6 +783,230 @@ func TestDecideOps(t *testing.T) {
 			Input:       newData().withRivers().withEtcdRivers().withSSHNotConnectedNodes(),
 			ExpectedOps: nil,
 		},
+		{
+			Name:               "EtcdRestore",
+			Input:              newData().withK8sResourceReady().withEtcdRestore(),
+			ExpectedOps:        []string{"etcd-restore"},
+			ExpectedTargetNums: map[string]int{"etcd-restore": 3},
+			ExpectedPhase:      cke.PhaseEtcdRestore,
+		},
+		{
+			Name:          "SkipEtcdRestoreUnreachable",
+			Input:         newData().withK8sResourceReady().withEtcdRestore().withSSHNotConnectedCP(),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseEtcdRestoreAborted,
+		},
+		{
+			Name: "SkipEtcdRestoreNoTarget",
+			Input: newData().withK8sResourceReady().withEtcdRestore().with(func(d testData) {
+				d.Cluster.EtcdBackup.Local = nil
+			}),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseEtcdRestoreAborted,
+		},
+		{
+			Name:               "EncryptionKeyRotation",
+			Input:              newData().withK8sResourceReady().withEncryptionKeyRotation(cke.EncryptionKeyRotationAdd),
+			ExpectedOps:        []string{"encryption-key-rotation"},
+			ExpectedTargetNums: map[string]int{"encryption-key-rotation": 3},
+			ExpectedPhase:      cke.PhaseEncryptionKeyRotation,
+		},
+		{
+			Name:          "EncryptionKeyRotationAfterK8sStart",
+			Input:         newData().withK8sResourceReady().withEncryptionKeyRotation(cke.EncryptionKeyRotationRewrite).withRunningImages(cke.EtcdImage, testOldKubernetesImage),
+			ExpectedOps:   []string{"kube-apiserver-restart"},
+			ExpectedPhase: cke.PhaseK8sStart,
+		},
+		{
+			Name:          "SkipEncryptionKeyRotationUnreachable",
+			Input:         newData().withK8sResourceReady().withEncryptionKeyRotation(cke.EncryptionKeyRotationPromote).withSSHNotConnectedCP(),
+			ExpectedOps:   []string{"update-endpoints", "update-endpointslice"},
+			ExpectedPhase: cke.PhaseK8sMaintain,
+		},
+		{
+			Name: "SkipEncryptionKeyRotationUnhealthyAPIServer",
+			Input: newData().withK8sResourceReady().withEncryptionKeyRotation(cke.EncryptionKeyRotationPrune).with(func(d testData) {
+				d.NodeStatus(d.ControlPlane()[0]).APIServer.IsHealthy = false
+			}),
+			ExpectedOps:   []string{"update-endpoints", "update-endpointslice"},
+			ExpectedPhase: cke.PhaseK8sMaintain,
+		},
+		{
+			Name:               "CARotationKubernetes",
+			Input:              newData().withK8sResourceReady().withCARotation(cke.CAKubernetes, cke.CARotationBundle),
+			ExpectedOps:        []string{"ca-rotation"},
+			ExpectedTargetNums: map[string]int{"ca-rotation": 6},
+			ExpectedPhase:      cke.PhaseCARotation,
+		},
+		{
+			Name:               "CARotationEtcdPeer",
+			Input:              newData().withK8sResourceReady().withCARotation(cke.CAEtcdPeer, cke.CARotationReissue),
+			ExpectedOps:        []string{"ca-rotation"},
+			ExpectedTargetNums: map[string]int{"ca-rotation": 3},
+			ExpectedPhase:      cke.PhaseCARotation,
+		},
+		{
+			Name: "CARotationWebhook",
+			Input: newData().withK8sResourceReady().withCARotation(cke.CAWebhook, cke.CARotationPrune).withResources(
+				append(testResources, cke.ResourceDefinition{
+					Key:        "ValidatingWebhookConfiguration/foo",
+					Kind:       cke.KindValidatingWebhookConfiguration,
+					Name:       "foo",
+					Revision:   1,
+					Definition: []byte(`{"apiVersion":"admissionregistration.k8s.io/v1","kind":"ValidatingWebhookConfiguration","metadata":{"name":"foo"}}`),
+				})),
+			ExpectedOps:        []string{"ca-rotation"},
+			ExpectedTargetNums: map[string]int{"ca-rotation": 1},
+			ExpectedPhase:      cke.PhaseCARotation,
+		},
+		{
+			Name:          "SkipCARotationUnreachable",
+			Input:         newData().withK8sResourceReady().withCARotation(cke.CAKubernetes, cke.CARotationBundle).withSSHNotConnectedNonCPWorker(1),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseCompleted,
+		},
+		{
+			Name:               "KMSPluginBoot",
+			Input:              newData().withK8sResourceReady().withKMS(false),
+			ExpectedOps:        []string{"kms-plugin-bootstrap"},
+			ExpectedTargetNums: map[string]int{"kms-plugin-bootstrap": 3},
+			ExpectedPhase:      cke.PhaseK8sStart,
+		},
+		{
+			Name: "KMSPluginRestart",
+			Input: newData().withK8sResourceReady().withKMS(true).with(func(d testData) {
+				d.NodeStatus(d.ControlPlane()[1]).KMSPlugin.Image = "example.com/kms-plugin:0.9.0"
+			}),
+			ExpectedOps:        []string{"kms-plugin-restart"},
+			ExpectedTargetNums: map[string]int{"kms-plugin-restart": 1},
+			ExpectedPhase:      cke.PhaseK8sStart,
+		},
+		{
+			Name: "KMSAPIServerRestart",
+			Input: newData().withK8sResourceReady().withKMS(true).with(func(d testData) {
+				d.NodeStatus(d.ControlPlane()[0]).APIServer.BuiltInParams = k8s.APIServerParams(d.ControlPlane()[0].Address, testServiceSubnet, false, "", "", testDefaultDNSDomain, nil)
+			}),
+			ExpectedOps:        []string{"kube-apiserver-restart"},
+			ExpectedTargetNums: map[string]int{"kube-apiserver-restart": 1},
+			ExpectedPhase:      cke.PhaseK8sStart,
+		},
+		{
+			Name:          "KMSReady",
+			Input:         newData().withK8sResourceReady().withKMS(true),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseCompleted,
+		},
+		{
+			Name:          "CertificateNotExpiring",
+			Input:         newData().withK8sResourceReady().withCertificateExpiry(time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseCompleted,
+		},
+		{
+			Name: "CertificateRenewalControlPlane",
+			Input: newData().withK8sResourceReady().withCertificateExpiry(time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)).with(func(d testData) {
+				expiry := d.NodeStatus(d.ControlPlane()[1]).CertificateExpiry
+				expiry[op.KubeAPIServerContainerName] = d.Now.Add(24 * time.Hour)
+				expiry[op.KubeSchedulerContainerName] = d.Now.Add(24 * time.Hour)
+				d.NodeStatus(d.ControlPlane()[2]).CertificateExpiry[op.KubeControllerManagerContainerName] = d.Now.Add(24 * time.Hour)
+			}),
+			ExpectedOps:        []string{"kube-apiserver-restart", "kube-scheduler-restart"},
+			ExpectedTargetNums: map[string]int{"kube-apiserver-restart": 1, "kube-scheduler-restart": 1},
+			ExpectedPhase:      cke.PhaseCertificateRenewal,
+		},
+		{
+			Name: "CertificateRenewalEtcd",
+			Input: newData().withK8sResourceReady().withCertificateExpiry(time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)).with(func(d testData) {
+				expiry := d.NodeStatus(d.ControlPlane()[0]).CertificateExpiry
+				expiry[op.EtcdContainerName] = d.Now.Add(24 * time.Hour)
+				expiry[op.KubeAPIServerContainerName] = d.Now.Add(24 * time.Hour)
+			}),
+			ExpectedOps:        []string{"etcd-restart"},
+			ExpectedTargetNums: map[string]int{"etcd-restart": 1},
+			ExpectedPhase:      cke.PhaseCertificateRenewal,
+		},
+		{
+			Name: "CertificateRenewalExpired",
+			Input: newData().withK8sResourceReady().withCertificateExpiry(time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)).with(func(d testData) {
+				expiry := d.NodeStatus(d.NonCPWorkers()[0]).CertificateExpiry
+				expiry[op.KubeletContainerName] = d.Now.Add(-time.Hour)
+				expiry[op.KubeProxyContainerName] = d.Now.Add(-time.Hour)
+				d.NodeStatus(d.NonCPWorkers()[1]).CertificateExpiry[op.KubeletContainerName] = d.Now.Add(-time.Hour)
+			}),
+			ExpectedOps:        []string{"kube-proxy-restart", "kubelet-restart"},
+			ExpectedTargetNums: map[string]int{"kube-proxy-restart": 1, "kubelet-restart": 1},
+			ExpectedPhase:      cke.PhaseCertificateRenewal,
+		},
+		{
+			Name: "CertificateRenewalWindow",
+			Input: newData().withK8sResourceReady().withCertificateExpiry(time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)).with(func(d testData) {
+				renewBefore := 30 * 24 * 3600
+				d.Cluster.Certificates.RenewBeforeSeconds = &renewBefore
+				d.NodeStatus(d.NonCPWorkers()[0]).CertificateExpiry[op.KubeletContainerName] = d.Now.Add(60 * 24 * time.Hour)
+			}),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseCompleted,
+		},
+		{
+			Name: "CertificateRenewalInBlackout",
+			Input: newData().withK8sResourceReady().withCertificateExpiry(time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)).with(func(d testData) {
+				expiry := d.NodeStatus(d.ControlPlane()[0]).CertificateExpiry
+				expiry[op.EtcdContainerName] = d.Now.Add(24 * time.Hour)
+				expiry[op.KubeAPIServerContainerName] = d.Now.Add(24 * time.Hour)
+				d.NodeStatus(d.NonCPWorkers()[0]).CertificateExpiry[op.KubeletContainerName] = d.Now.Add(24 * time.Hour)
+			}).withBlackout(),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseWaitingWindow,
+		},
+		{
+			Name: "CertificateRenewalNonDisruptiveInBlackout",
+			Input: newData().withK8sResourceReady().withCertificateExpiry(time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)).with(func(d testData) {
+				expiry := d.NodeStatus(d.ControlPlane()[1]).CertificateExpiry
+				expiry[op.KubeAPIServerContainerName] = d.Now.Add(24 * time.Hour)
+				expiry[op.KubeSchedulerContainerName] = d.Now.Add(24 * time.Hour)
+			}).withBlackout(),
+			ExpectedOps:        []string{"kube-scheduler-restart"},
+			ExpectedTargetNums: map[string]int{"kube-scheduler-restart": 1},
+			ExpectedPhase:      cke.PhaseCertificateRenewal,
+		},
+		{
+			Name: "KMSPluginStop",
+			Input: newData().withK8sResourceReady().with(func(d testData) {
+				d.NodeStatus(d.ControlPlane()[2]).KMSPlugin.Running = true
+			}),
+			ExpectedOps:        []string{"stop-kms-plugin"},
+			ExpectedTargetNums: map[string]int{"stop-kms-plugin": 1},
+			ExpectedPhase:      cke.PhaseK8sStart,
+		},
+		{
+			Name: "KMSDisable",
+			Input: newData().withK8sResourceReady().withKMS(true).with(func(d testData) {
+				d.Cluster.Options.APIServer.KMS = nil
+			}),
+			ExpectedOps:        []string{"kube-apiserver-restart"},
+			ExpectedTargetNums: map[string]int{"kube-apiserver-restart": 3},
+			ExpectedPhase:      cke.PhaseK8sStart,
+		},
+		{
+			Name: "KMSDisableInBlackout",
+			Input: newData().withK8sResourceReady().withKMS(true).with(func(d testData) {
+				d.Cluster.Options.APIServer.KMS = nil
+			}).withBlackout(),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseWaitingWindow,
+		},
+		{
+			Name: "KMSDisableAfterAPIServerRestart",
+			Input: newData().withK8sResourceReady().withKMS(true).with(func(d testData) {
+				d.Cluster.Options.APIServer.KMS = nil
+				kubeletConfig := k8s.GenerateKubeletConfiguration(d.Cluster.Options.Kubelet, "0.0.0.0", nil)
+				n := d.ControlPlane()[0]
+				d.NodeStatus(n).APIServer.BuiltInParams = k8s.APIServerParams(n.Address, testServiceSubnet, false, "", "", kubeletConfig.ClusterDomain, nil)
+			}),
+			ExpectedOps:        []string{"kube-apiserver-restart", "stop-kms-plugin"},
+			ExpectedTargetNums: map[string]int{"kube-apiserver-restart": 2, "stop-kms-plugin": 1},
+			ExpectedPhase:      cke.PhaseK8sStart,
+		},
 		{
 			Name:        "EtcdStart",
 			Input:       newData().withRivers().withEtcdRivers().withStoppedEtcd(),
@@ -1207,7 +1554,7 @@ func TestDecideOps(t *testing.T) {
 				d.Status.Kubernetes.MasterEndpointSlice.Endpoints[2].Conditions.Ready = &endpointReady
 				d.Status.Kubernetes.EtcdEndpointSlice.Endpoints[2].Conditions.Ready = &endpointReady
 			}),
-			ExpectedOps:        []string{"reboot", "reboot-dequeue"},
+			ExpectedOps:        []string{"reboot"},
 			ExpectedTargetNums: nil,
 		},
 		{
@@ -2039,11 +2386,161 @@ func TestDecideOps(t *testing.T) {
 				Nodes:  []string{nodeNames[4], nodeNames[5]},
 				Status: cke.RebootStatusQueued,
 			}),
-			ExpectedOps: []string{"reboot", "reboot-dequeue"},
+			ExpectedOps: []string{"reboot"},
 			ExpectedTargetNums: map[string]int{
-				"reboot":         2,
-				"reboot-dequeue": 0,
+				"reboot": 2,
+			},
+		},
+		{
+			Name: "RebootConcurrently",
+			Input: func() testData {
+				d := newData().withK8sResourceReady().withRebootConfig().with(func(d testData) {
+					d.Cluster.Reboot.MaxConcurrentNodes = pointer.Int(2)
+				}).withRebootEntry(&cke.RebootQueueEntry{
+					Index:  1,
+					Nodes:  []string{nodeNames[4]},
+					Status: cke.RebootStatusQueued,
+				}).withRebootEntry(&cke.RebootQueueEntry{
+					Index:  2,
+					Nodes:  []string{nodeNames[5]},
+					Status: cke.RebootStatusQueued,
+				}).withRebootEntry(&cke.RebootQueueEntry{
+					Index:  3,
+					Nodes:  []string{nodeNames[3]},
+					Status: cke.RebootStatusQueued,
+				})
+				constraints := *d.Constraints
+				constraints.RebootMaximumUnreachable = 3
+				d.Constraints = &constraints
+				return d
+			}(),
+			ExpectedOps:   []string{"reboot", "reboot"},
+			ExpectedPhase: cke.PhaseRebootNodes,
+		},
+		{
+			Name: "RebootConcurrentlyLimitedByUnreachable",
+			Input: newData().withK8sResourceReady().withRebootConfig().with(func(d testData) {
+				d.Cluster.Reboot.MaxConcurrentNodes = pointer.Int(2)
+			}).withRebootEntry(&cke.RebootQueueEntry{
+				Index:  1,
+				Nodes:  []string{nodeNames[4]},
+				Status: cke.RebootStatusQueued,
+			}).withRebootEntry(&cke.RebootQueueEntry{
+				Index:  2,
+				Nodes:  []string{nodeNames[5]},
+				Status: cke.RebootStatusQueued,
+			}),
+			ExpectedOps:   []string{"reboot"},
+			ExpectedPhase: cke.PhaseRebootNodes,
+		},
+		{
+			Name: "RebootVerified",
+			Input: newData().withK8sResourceReady().withRebootConfig().withBootIDs("new").withRebootEntry(&cke.RebootQueueEntry{
+				Index:    1,
+				Nodes:    []string{nodeNames[4], nodeNames[5]},
+				Status:   cke.RebootStatusVerifying,
+				BootIDs:  map[string]string{nodeNames[4]: "old", nodeNames[5]: "old"},
+				Rebooted: &time.Time{},
+			}),
+			ExpectedOps:   []string{"reboot-dequeue"},
+			ExpectedPhase: cke.PhaseRebootNodes,
+		},
+		{
+			Name: "RebootVerifiedWithPostRebootHooks",
+			Input: newData().withK8sResourceReady().withRebootConfig().withBootIDs("new").with(func(d testData) {
+				d.Cluster.Reboot.Hooks.PostReboot = []cke.RebootHook{{Name: "lb", Command: "true"}}
+			}).withRebootEntry(&cke.RebootQueueEntry{
+				Index:    1,
+				Nodes:    []string{nodeNames[4], nodeNames[5]},
+				Status:   cke.RebootStatusVerifying,
+				BootIDs:  map[string]string{nodeNames[4]: "old", nodeNames[5]: "old"},
+				Rebooted: &time.Time{},
+			}),
+			ExpectedOps: []string{"reboot-post-hook"},
+			ExpectedTargetNums: map[string]int{
+				"reboot-post-hook": 2,
+			},
+			ExpectedPhase: cke.PhaseRebootNodes,
+		},
+		{
+			Name: "RebootVerifiedUnknownBootID",
+			Input: newData().withK8sResourceReady().withRebootConfig().withBootIDs("new").withRebootEntry(&cke.RebootQueueEntry{
+				Index:    1,
+				Nodes:    []string{nodeNames[4]},
+				Status:   cke.RebootStatusVerifying,
+				Rebooted: &time.Time{},
+			}),
+			ExpectedOps:   []string{"reboot-dequeue"},
+			ExpectedPhase: cke.PhaseRebootNodes,
+		},
+		{
+			Name: "RebootVerifying",
+			Input: newData().withK8sResourceReady().withRebootConfig().withBootIDs("old").withRebootEntry(&cke.RebootQueueEntry{
+				Index:    1,
+				Nodes:    []string{nodeNames[4], nodeNames[5]},
+				Status:   cke.RebootStatusVerifying,
+				BootIDs:  map[string]string{nodeNames[4]: "old", nodeNames[5]: "old"},
+				Rebooted: &time.Time{},
+			}).withRebootEntry(&cke.RebootQueueEntry{
+				Index:  2,
+				Nodes:  []string{nodeNames[3]},
+				Status: cke.RebootStatusQueued,
+			}),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseRebootNodes,
+		},
+		{
+			Name: "RebootVerifyingNotReady",
+			Input: newData().withK8sResourceReady().withRebootConfig().withBootIDs("new").withRebootEntry(&cke.RebootQueueEntry{
+				Index:    1,
+				Nodes:    []string{nodeNames[4]},
+				Status:   cke.RebootStatusVerifying,
+				BootIDs:  map[string]string{nodeNames[4]: "old"},
+				Rebooted: &time.Time{},
+			}).with(func(d testData) {
+				d.Status.Kubernetes.Nodes[4].Status.Conditions = nil
+			}),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseRebootNodes,
+		},
+		{
+			Name: "RebootVerificationTimeout",
+			Input: func() testData {
+				d := newData().withK8sResourceReady().withRebootConfig().withBootIDs("new").withRebootEntry(&cke.RebootQueueEntry{
+					Index:    1,
+					Nodes:    []string{nodeNames[4], nodeNames[5]},
+					Status:   cke.RebootStatusVerifying,
+					BootIDs:  map[string]string{nodeNames[4]: "old", nodeNames[5]: "new"},
+					Rebooted: &time.Time{},
+				})
+				d.Now = d.Now.Add(time.Hour)
+				return d
+			}(),
+			ExpectedOps:   []string{"reboot-fail"},
+			ExpectedPhase: cke.PhaseRebootNodes,
+		},
+		{
+			Name: "RebootFailed",
+			Input: newData().withK8sResourceReady().withRebootConfig().withRebootEntry(&cke.RebootQueueEntry{
+				Index:       1,
+				Nodes:       []string{nodeNames[4]},
+				Status:      cke.RebootStatusFailed,
+				FailedNodes: []string{nodeNames[4]},
+			}).withRebootEntry(&cke.RebootQueueEntry{
+				Index:  2,
+				Nodes:  []string{nodeNames[5]},
+				Status: cke.RebootStatusQueued,
+			}).with(func(d testData) {
+				d.Status.Kubernetes.Nodes[4].Spec.Unschedulable = true
+				d.Status.Kubernetes.Nodes[4].Annotations = map[string]string{
+					op.CKEAnnotationReboot: "true",
+				}
+			}),
+			ExpectedOps: []string{"reboot"},
+			ExpectedTargetNums: map[string]int{
+				"reboot": 1,
 			},
+			ExpectedPhase: cke.PhaseRebootNodes,
 		},
 		{
 			Name: "RebootInvalidNode",
@@ -2087,11 +2584,221 @@ func TestDecideOps(t *testing.T) {
 			ExpectedOps:        []string{"reboot-dequeue"},
 			ExpectedTargetNums: nil,
 		},
+		{
+			Name: "RebootInBlackout",
+			Input: newData().withK8sResourceReady().withRebootConfig().withRebootEntry(&cke.RebootQueueEntry{
+				Index:  1,
+				Nodes:  []string{nodeNames[4], nodeNames[5]},
+				Status: cke.RebootStatusQueued,
+			}).withBlackout(),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseWaitingWindow,
+		},
+		{
+			Name: "CancelRebootInBlackout",
+			Input: newData().withK8sResourceReady().withRebootConfig().withRebootEntry(&cke.RebootQueueEntry{
+				Index:  1,
+				Nodes:  []string{nodeNames[4], nodeNames[5]},
+				Status: cke.RebootStatusCancelled,
+			}).withBlackout(),
+			ExpectedOps:   []string{"reboot-dequeue"},
+			ExpectedPhase: cke.PhaseRebootNodes,
+		},
+		{
+			Name: "RebootNotBefore",
+			Input: newData().withK8sResourceReady().withRebootConfig().withRebootEntry(&cke.RebootQueueEntry{
+				Index:     1,
+				Nodes:     []string{nodeNames[4], nodeNames[5]},
+				Status:    cke.RebootStatusQueued,
+				NotBefore: func() *time.Time { t := time.Date(2021, 12, 1, 13, 0, 0, 0, time.UTC); return &t }(),
+			}),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseCompleted,
+		},
+		{
+			Name: "RebootPriority",
+			Input: newData().withK8sResourceReady().withRebootConfig().withRebootEntry(&cke.RebootQueueEntry{
+				Index:  1,
+				Nodes:  []string{nodeNames[4], nodeNames[5]},
+				Status: cke.RebootStatusQueued,
+			}).withRebootEntry(&cke.RebootQueueEntry{
+				Index:    2,
+				Nodes:    []string{nodeNames[3]},
+				Status:   cke.RebootStatusQueued,
+				Priority: 1,
+			}),
+			ExpectedOps: []string{"reboot"},
+			ExpectedTargetNums: map[string]int{
+				"reboot": 1,
+			},
+			ExpectedPhase: cke.PhaseRebootNodes,
+		},
+		{
+			Name: "RebootExpiredInBlackout",
+			Input: newData().withK8sResourceReady().withRebootConfig().withRebootEntry(&cke.RebootQueueEntry{
+				Index:    1,
+				Nodes:    []string{nodeNames[4], nodeNames[5]},
+				Status:   cke.RebootStatusQueued,
+				Deadline: func() *time.Time { t := time.Date(2021, 12, 1, 11, 0, 0, 0, time.UTC); return &t }(),
+			}).withBlackout(),
+			ExpectedOps:   []string{"reboot-dequeue"},
+			ExpectedPhase: cke.PhaseRebootNodes,
+		},
+		{
+			Name: "RebootInMaintenanceWindow",
+			Input: newData().withK8sResourceReady().withRebootConfig().withRebootEntry(&cke.RebootQueueEntry{
+				Index:  1,
+				Nodes:  []string{nodeNames[4], nodeNames[5]},
+				Status: cke.RebootStatusQueued,
+			}).withMaintenanceWindow(time.Date(2021, 12, 1, 3, 0, 0, 0, time.UTC)),
+			ExpectedOps:   []string{"reboot"},
+			ExpectedPhase: cke.PhaseRebootNodes,
+		},
+		{
+			Name: "RebootOutsideMaintenanceWindow",
+			Input: newData().withK8sResourceReady().withRebootConfig().withRebootEntry(&cke.RebootQueueEntry{
+				Index:  1,
+				Nodes:  []string{nodeNames[4], nodeNames[5]},
+				Status: cke.RebootStatusQueued,
+			}).withMaintenanceWindow(time.Date(2021, 12, 1, 4, 0, 0, 0, time.UTC)),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseWaitingWindow,
+		},
+		{
+			Name: "EtcdRestartInBlackout",
+			Input: newData().withK8sResourceReady().with(func(d testData) {
+				d.NodeStatus(d.ControlPlane()[0]).Etcd.Image = ""
+			}).withBlackout(),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseWaitingWindow,
+		},
+		{
+			Name:          "RestartAPIServerInBlackout",
+			Input:         newData().withK8sResourceReady().withAPIServer("11.22.33.0/24", testDefaultDNSDomain).withBlackout(),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseWaitingWindow,
+		},
+		{
+			Name:          "EncryptionKeyRotationInBlackout",
+			Input:         newData().withK8sResourceReady().withEncryptionKeyRotation(cke.EncryptionKeyRotationAdd).withBlackout(),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseWaitingWindow,
+		},
+		{
+			Name:          "CARotationInBlackout",
+			Input:         newData().withK8sResourceReady().withCARotation(cke.CAKubernetes, cke.CARotationBundle).withBlackout(),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseWaitingWindow,
+		},
+		{
+			Name: "CARotationWebhookInBlackout",
+			Input: newData().withK8sResourceReady().withCARotation(cke.CAWebhook, cke.CARotationPrune).withResources(
+				append(testResources, cke.ResourceDefinition{
+					Key:        "ValidatingWebhookConfiguration/foo",
+					Kind:       cke.KindValidatingWebhookConfiguration,
+					Name:       "foo",
+					Revision:   1,
+					Definition: []byte(`{"apiVersion":"admissionregistration.k8s.io/v1","kind":"ValidatingWebhookConfiguration","metadata":{"name":"foo"}}`),
+				})).withBlackout(),
+			ExpectedOps:        []string{"ca-rotation"},
+			ExpectedTargetNums: map[string]int{"ca-rotation": 1},
+			ExpectedPhase:      cke.PhaseCARotation,
+		},
+		{
+			Name:          "RestartKubeletInBlackout",
+			Input:         newData().withK8sResourceReady().withKubelet("foo.local", "10.0.0.53", false).withBlackout(),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseWaitingWindow,
+		},
+		{
+			Name:          "UpgradeEtcdFirst",
+			Input:         newData().withK8sResourceReady().withRunningImages(testOldEtcdImage, testOldKubernetesImage),
+			ExpectedOps:   []string{"etcd-restart"},
+			ExpectedPhase: cke.PhaseEtcdMaintain,
+		},
+		{
+			Name:        "UpgradeAPIServerOneByOne",
+			Input:       newData().withK8sResourceReady().withRunningImages(cke.EtcdImage, testOldKubernetesImage),
+			ExpectedOps: []string{"kube-apiserver-restart"},
+			ExpectedTargetNums: map[string]int{
+				"kube-apiserver-restart": 1,
+			},
+			ExpectedPhase: cke.PhaseK8sStart,
+		},
+		{
+			Name: "UpgradeAPIServerWaitHealthy",
+			Input: newData().withK8sResourceReady().withRunningImages(cke.EtcdImage, testOldKubernetesImage).with(func(d testData) {
+				st := &d.NodeStatus(d.ControlPlane()[0]).APIServer
+				st.Image = cke.KubernetesImage.Name()
+				st.IsHealthy = false
+			}),
+			ExpectedOps:   []string{"update-endpoints", "update-endpointslice"},
+			ExpectedPhase: cke.PhaseK8sMaintain,
+		},
+		{
+			Name: "UpgradeControlPlane",
+			Input: newData().withK8sResourceReady().withRunningImages(cke.EtcdImage, testOldKubernetesImage).with(func(d testData) {
+				for _, n := range d.ControlPlane() {
+					d.NodeStatus(n).APIServer.Image = cke.KubernetesImage.Name()
+				}
+			}),
+			ExpectedOps:   []string{"kube-controller-manager-restart", "kube-scheduler-restart"},
+			ExpectedPhase: cke.PhaseK8sStart,
+		},
+		{
+			Name: "UpgradeNodes",
+			Input: newData().withK8sResourceReady().with(func(d testData) {
+				for _, n := range d.Cluster.Nodes {
+					d.NodeStatus(n).Kubelet.Image = testOldKubernetesImage.Name()
+					d.NodeStatus(n).Proxy.Image = testOldKubernetesImage.Name()
+				}
+			}),
+			ExpectedOps:   []string{"kube-proxy-restart", "kubelet-restart"},
+			ExpectedPhase: cke.PhaseK8sStart,
+		},
+		{
+			Name: "UpgradeSkippingMinorVersion",
+			Input: newData().withK8sResourceReady().with(func(d testData) {
+				d.NodeStatus(d.ControlPlane()[0]).APIServer.Image = "quay.io/cybozu/kubernetes:1.20.14.1"
+			}),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseVersionUpgradeAborted,
+		},
+		{
+			Name: "DowngradeEtcd",
+			Input: newData().withK8sResourceReady().with(func(d testData) {
+				d.NodeStatus(d.ControlPlane()[0]).Etcd.Image = "quay.io/cybozu/etcd:3.6.0.1"
+			}),
+			ExpectedOps:   nil,
+			ExpectedPhase: cke.PhaseVersionUpgradeAborted,
+		},
+		{
+			Name: "UpgradeToClusterImages",
+			Input: newData().withK8sResourceReady().with(func(d testData) {
+				d.Cluster.Images.Kubernetes = "quay.io/cybozu/kubernetes:1.23.1.1"
+			}),
+			ExpectedOps: []string{"kube-apiserver-restart"},
+			ExpectedTargetNums: map[string]int{
+				"kube-apiserver-restart": 1,
+			},
+			ExpectedPhase: cke.PhaseK8sStart,
+		},
+		{
+			Name: "RepairInBlackout",
+			Input: newData().withK8sResourceReady().with(func(d testData) {
+				d.NodeStatus(d.ControlPlane()[0]).Scheduler.Running = false
+			}).withBlackout(),
+			ExpectedOps:   []string{"kube-scheduler-bootstrap"},
+			ExpectedPhase: cke.PhaseK8sStart,
+		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.Name, func(t *testing.T) {
-			ops, _ := DecideOps(c.Input.Cluster, c.Input.Status, c.Input.Constraints, c.Input.Resources, c.Input.Reboot)
+			ops, phase := DecideOps(c.Input.Cluster, c.Input.Status, c.Input.Constraints, c.Input.Resources, c.Input.Reboots, c.Input.Now)
+			if c.ExpectedPhase != "" && c.ExpectedPhase != phase {
+				t.Errorf("unexpected phase: expected=%s, actual=%s", c.ExpectedPhase, phase)
+			}
 			if len(ops) == 0 && len(c.ExpectedOps) == 0 {
 				return
 			}
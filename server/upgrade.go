@@ -0,0 +1,212 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/cybozu-go/cke"
+	"github.com/cybozu-go/cke/op"
+)
+
+// upgradeStage is the stage of upgrading etcd and Kubernetes images.
+// Components are upgraded in this order as required by the version skew policy.
+// https://kubernetes.io/releases/version-skew-policy/#supported-component-upgrade-order
+type upgradeStage int
+
+const (
+	upgradeStageEtcd upgradeStage = iota
+	upgradeStageAPIServer
+	upgradeStageControlPlane
+	upgradeStageNodes
+	upgradeStageDone
+)
+
+// Maximum numbers of minor versions that an image can be upgraded at once.
+const (
+	maxEtcdSkew         = 1
+	maxControlPlaneSkew = 1
+	maxNodeSkew         = 2
+)
+
+// upgradeComponent is a component to be upgraded.
+type upgradeComponent struct {
+	name    string
+	stage   upgradeStage
+	maxSkew int
+	target  cke.Image
+	nodes   []*cke.Node
+	status  func(*cke.NodeStatus) cke.ServiceStatus
+}
+
+func (nf *NodeFilter) upgradeComponents() []upgradeComponent {
+	etcdImage := nf.cluster.Images.EtcdImage()
+	k8sImage := nf.cluster.Images.KubernetesImage()
+
+	components := []upgradeComponent{
+		{
+			name:    op.EtcdContainerName,
+			stage:   upgradeStageEtcd,
+			maxSkew: maxEtcdSkew,
+			target:  etcdImage,
+			nodes:   nf.cp,
+			status:  func(st *cke.NodeStatus) cke.ServiceStatus { return st.Etcd.ServiceStatus },
+		},
+		{
+			name:    op.KubeAPIServerContainerName,
+			stage:   upgradeStageAPIServer,
+			maxSkew: maxControlPlaneSkew,
+			target:  k8sImage,
+			nodes:   nf.cp,
+			status:  func(st *cke.NodeStatus) cke.ServiceStatus { return st.APIServer.ServiceStatus },
+		},
+		{
+			name:    op.KubeControllerManagerContainerName,
+			stage:   upgradeStageControlPlane,
+			maxSkew: maxControlPlaneSkew,
+			target:  k8sImage,
+			nodes:   nf.cp,
+			status:  func(st *cke.NodeStatus) cke.ServiceStatus { return st.ControllerManager.ServiceStatus },
+		},
+		{
+			name:    op.KubeSchedulerContainerName,
+			stage:   upgradeStageControlPlane,
+			maxSkew: maxControlPlaneSkew,
+			target:  k8sImage,
+			nodes:   nf.cp,
+			status:  func(st *cke.NodeStatus) cke.ServiceStatus { return st.Scheduler.ServiceStatus },
+		},
+		{
+			name:    op.KubeletContainerName,
+			stage:   upgradeStageNodes,
+			maxSkew: maxNodeSkew,
+			target:  k8sImage,
+			nodes:   nf.cluster.Nodes,
+			status:  func(st *cke.NodeStatus) cke.ServiceStatus { return st.Kubelet.ServiceStatus },
+		},
+	}
+	if !nf.cluster.Options.Proxy.Disable {
+		components = append(components, upgradeComponent{
+			name:    op.KubeProxyContainerName,
+			stage:   upgradeStageNodes,
+			maxSkew: maxNodeSkew,
+			target:  k8sImage,
+			nodes:   nf.cluster.Nodes,
+			status:  func(st *cke.NodeStatus) cke.ServiceStatus { return st.Proxy.ServiceStatus },
+		})
+	}
+	return components
+}
+
+// runningImages returns the images of running containers of the component.
+func (nf *NodeFilter) runningImages(c upgradeComponent) []cke.Image {
+	var images []cke.Image
+	for _, n := range c.nodes {
+		st := c.status(nf.nodeStatus(n))
+		if !st.Running {
+			continue
+		}
+		images = append(images, cke.Image(st.Image))
+	}
+	return images
+}
+
+// upgradeStage returns the current stage of upgrading etcd and Kubernetes.
+// This returns upgradeStageDone if all running components run the target images.
+func (nf *NodeFilter) upgradeStage() upgradeStage {
+	stage := upgradeStageDone
+	for _, c := range nf.upgradeComponents() {
+		if c.stage >= stage {
+			continue
+		}
+		for _, img := range nf.runningImages(c) {
+			if img != c.target {
+				stage = c.stage
+				break
+			}
+		}
+	}
+	return stage
+}
+
+// upgradeFrozen returns true if changes to the components of the stage
+// need to wait until the preceding components are upgraded.
+func (nf *NodeFilter) upgradeFrozen(stage upgradeStage) bool {
+	return nf.upgradeStage() < stage
+}
+
+// UpgradeInProgress returns true if some components are running images
+// other than the images specified in the cluster configuration.
+func (nf *NodeFilter) UpgradeInProgress() bool {
+	return nf.upgradeStage() != upgradeStageDone
+}
+
+// UpgradePreflight checks if etcd and Kubernetes can be upgraded to
+// the images specified in the cluster configuration.
+func (nf *NodeFilter) UpgradePreflight() error {
+	for _, c := range nf.upgradeComponents() {
+		for _, img := range nf.runningImages(c) {
+			if err := checkVersionSkew(c.name, img, c.target, c.maxSkew); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkVersionSkew returns an error if upgrading a component from running to target
+// exceeds maxSkew minor versions or changes its major version.  Downgrading minor
+// versions is not allowed either.
+//
+// If the version of the running image cannot be determined, e.g. because it is
+// referenced by digest or has a custom tag, the skew cannot be checked and this
+// returns nil so that the component can be restarted with the target image.
+func checkVersionSkew(name string, running, target cke.Image, maxSkew int) error {
+	if running == target {
+		return nil
+	}
+
+	rmajor, rminor, err := running.Version()
+	if err != nil {
+		return nil
+	}
+	tmajor, tminor, err := target.Version()
+	if err != nil {
+		return fmt.Errorf("failed to get the version of %s: %w", name, err)
+	}
+
+	switch {
+	case rmajor != tmajor:
+		return fmt.Errorf("%s cannot be upgraded to another major version: %s -> %s", name, running, target)
+	case tminor < rminor:
+		return fmt.Errorf("%s cannot be downgraded: %s -> %s", name, running, target)
+	case tminor-rminor > maxSkew:
+		return fmt.Errorf("%s cannot be upgraded more than %d minor version(s) at once: %s -> %s", name, maxSkew, running, target)
+	}
+	return nil
+}
+
+// UpgradeStatus returns the progress of upgrading etcd and Kubernetes.
+// This returns nil if no upgrade is in progress.
+func (nf *NodeFilter) UpgradeStatus() *cke.UpgradeStatus {
+	if !nf.UpgradeInProgress() {
+		return nil
+	}
+
+	st := &cke.UpgradeStatus{}
+	for _, c := range nf.upgradeComponents() {
+		cs := cke.ComponentUpgradeStatus{
+			Name:  c.name,
+			Image: c.target.Name(),
+		}
+		for _, img := range nf.runningImages(c) {
+			cs.Running++
+			if img == c.target {
+				cs.Upgraded++
+			}
+		}
+		st.Components = append(st.Components, cs)
+	}
+	if err := nf.UpgradePreflight(); err != nil {
+		st.PreflightError = err.Error()
+	}
+	return st
+}
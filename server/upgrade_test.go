@@ -0,0 +1,73 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/cybozu-go/cke"
+)
+
+func TestCheckVersionSkew(t *testing.T) {
+	cases := []struct {
+		name    string
+		running cke.Image
+		target  cke.Image
+		maxSkew int
+		wantErr bool
+	}{
+		{"same", "quay.io/cybozu/kubernetes:1.22.5.1", "quay.io/cybozu/kubernetes:1.22.5.1", 1, false},
+		{"patch", "quay.io/cybozu/kubernetes:1.22.4.1", "quay.io/cybozu/kubernetes:1.22.5.1", 1, false},
+		{"minor", "quay.io/cybozu/kubernetes:1.21.8.1", "quay.io/cybozu/kubernetes:1.22.5.1", 1, false},
+		{"skip minor", "quay.io/cybozu/kubernetes:1.20.14.1", "quay.io/cybozu/kubernetes:1.22.5.1", 1, true},
+		{"skip minor within skew", "quay.io/cybozu/kubernetes:1.20.14.1", "quay.io/cybozu/kubernetes:1.22.5.1", 2, false},
+		{"downgrade", "quay.io/cybozu/kubernetes:1.23.1.1", "quay.io/cybozu/kubernetes:1.22.5.1", 1, true},
+		{"major", "quay.io/cybozu/etcd:3.5.1.1", "quay.io/cybozu/etcd:4.0.0.1", 1, true},
+		{"no tag", "quay.io/cybozu/kubernetes", "quay.io/cybozu/kubernetes:1.22.5.1", 1, false},
+		{"digest", "quay.io/cybozu/kubernetes@sha256:0123456789abcdef", "quay.io/cybozu/kubernetes:1.22.5.1", 1, false},
+		{"custom tag", "quay.io/cybozu/kubernetes:latest", "quay.io/cybozu/kubernetes:1.22.5.1", 1, false},
+		{"empty", "", "quay.io/cybozu/kubernetes:1.22.5.1", 1, false},
+		{"target without tag", "quay.io/cybozu/kubernetes:1.22.5.1", "quay.io/cybozu/kubernetes", 1, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := checkVersionSkew("test", c.running, c.target, c.maxSkew)
+			if c.wantErr && err == nil {
+				t.Error("error is expected")
+			}
+			if !c.wantErr && err != nil {
+				t.Error("unexpected error:", err)
+			}
+		})
+	}
+}
+
+func TestUpgradeStatus(t *testing.T) {
+	d := newData().withK8sResourceReady()
+	nf := NewNodeFilter(d.Cluster, d.Status)
+	if st := nf.UpgradeStatus(); st != nil {
+		t.Error("upgrade status should be nil:", st)
+	}
+
+	d = newData().withK8sResourceReady().with(func(d testData) {
+		d.NodeStatus(d.ControlPlane()[0]).APIServer.Image = testOldKubernetesImage.Name()
+	})
+	nf = NewNodeFilter(d.Cluster, d.Status)
+	st := nf.UpgradeStatus()
+	if st == nil {
+		t.Fatal("upgrade status should not be nil")
+	}
+	if st.PreflightError != "" {
+		t.Error("unexpected preflight error:", st.PreflightError)
+	}
+	for _, c := range st.Components {
+		if c.Name != "kube-apiserver" {
+			if c.Upgraded != c.Running {
+				t.Errorf("%s should be upgraded: %+v", c.Name, c)
+			}
+			continue
+		}
+		if c.Running != 3 || c.Upgraded != 2 {
+			t.Errorf("unexpected kube-apiserver status: %+v", c)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package cke
 
 import (
+	"time"
+
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
@@ -82,9 +84,12 @@ func (s KubernetesClusterStatus) SetResourceStatus(rkey string, ann map[string]s
 // ClusterStatus represents the working cluster status.
 // The structure reflects Cluster, of course.
 type ClusterStatus struct {
-	ConfigVersion string
-	Name          string
-	NodeStatuses  map[string]*NodeStatus // keys are IP address strings.
+	ConfigVersion         string
+	Name                  string
+	NodeStatuses          map[string]*NodeStatus // keys are IP address strings.
+	EtcdRestore           *EtcdRestoreRequest    // non-nil if etcd restore is requested.
+	EncryptionKeyRotation *EncryptionKeyRotation // non-nil if the encryption key is being rotated.
+	CARotation            *CARotation            // non-nil if a CA is being rotated.
 
 	Etcd       EtcdClusterStatus
 	Kubernetes KubernetesClusterStatus
@@ -93,15 +98,22 @@ type ClusterStatus struct {
 // NodeStatus status of a node.
 type NodeStatus struct {
 	SSHConnected      bool
+	SSHError          string // is the reason why the node is not connected, if any.
+	BootID            string // changes whenever the node is rebooted.
 	Etcd              EtcdStatus
 	Rivers            ServiceStatus
 	EtcdRivers        ServiceStatus
 	APIServer         KubeComponentStatus
+	KMSPlugin         ServiceStatus
 	ControllerManager KubeComponentStatus
 	Scheduler         SchedulerStatus
 	Proxy             ProxyStatus
 	Kubelet           KubeletStatus
 	Labels            map[string]string // are labels for k8s Node resource.
+
+	// CertificateExpiry is the earliest expiration time of the certificates
+	// of each running component.  Keys are container names such as "etcd".
+	CertificateExpiry map[string]time.Time
 }
 
 // ServiceStatus represents statuses of a service.
@@ -113,6 +125,12 @@ type ServiceStatus struct {
 	Image         string
 	BuiltInParams ServiceParams
 	ExtraParams   ServiceParams
+
+	// ContainerID changes whenever the container is re-created.
+	ContainerID string
+
+	// ExitCode is the exit status of the container if it is not running.
+	ExitCode int
 }
 
 // EtcdStatus is the status of kubelet.
@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/clientv3util"
@@ -24,26 +25,38 @@ type RecordChan <-chan *Record
 
 // etcd keys and prefixes
 const (
-	KeyCA                    = "ca/"
-	KeyConfigVersion         = "config-version"
-	KeyCluster               = "cluster"
-	KeyClusterRevision       = "cluster-revision"
-	KeyConstraints           = "constraints"
-	KeyLeader                = "leader/"
-	KeyRebootsDisabled       = "reboots/disabled"
-	KeyRebootsPrefix         = "reboots/data/"
-	KeyRebootsWriteIndex     = "reboots/write-index"
-	KeyRecords               = "records/"
-	KeyRecordID              = "records"
-	KeyResourcePrefix        = "resource/"
-	KeySabakanDisabled       = "sabakan/disabled"
-	KeySabakanQueryVariables = "sabakan/query-variables"
-	KeySabakanTemplate       = "sabakan/template"
-	KeySabakanURL            = "sabakan/url"
-	KeyServiceAccountCert    = "service-account/certificate"
-	KeyServiceAccountKey     = "service-account/key"
-	KeyStatus                = "status"
-	KeyVault                 = "vault"
+	KeyBackend                = "backend"
+	KeyBuiltinCAPrefix        = "builtin/ca/"
+	KeyBuiltinSecretsPrefix   = "builtin/secrets/"
+	KeyCA                     = "ca/"
+	KeyCARotation             = "ca-rotation"
+	KeyConfigVersion          = "config-version"
+	KeyCluster                = "cluster"
+	KeyClusterRevision        = "cluster-revision"
+	KeyConstraints            = "constraints"
+	KeyEncryptionKeyRotation  = "encryption-key-rotation"
+	KeyEtcdBackupStatus       = "etcd-backup/status"
+	KeyEtcdRestore            = "etcd-backup/restore"
+	KeyEtcdRestoreStarted     = "etcd-backup/restore-started"
+	KeyLeader                 = "leader/"
+	KeyOperationRetryPrefix   = "operation-retries/"
+	KeyPause                  = "pause"
+	KeyPendingOperationPrefix = "pending-operations/"
+	KeyRebootsDisabled        = "reboots/disabled"
+	KeyRebootsPrefix          = "reboots/data/"
+	KeyRebootsWriteIndex      = "reboots/write-index"
+	KeyRecords                = "records/"
+	KeyRecordID               = "records"
+	KeyResourcePrefix         = "resource/"
+	KeySabakanDisabled        = "sabakan/disabled"
+	KeySabakanQueryVariables  = "sabakan/query-variables"
+	KeySabakanTemplate        = "sabakan/template"
+	KeySabakanURL             = "sabakan/url"
+	KeyServiceAccountCert     = "service-account/certificate"
+	KeyServiceAccountKey      = "service-account/key"
+	KeySSHHostKeysPrefix      = "ssh-host-keys/"
+	KeyStatus                 = "status"
+	KeyVault                  = "vault"
 )
 
 const maxRecords = 1000
@@ -55,6 +68,11 @@ var (
 	ErrNotFound = errors.New("not found")
 	// ErrNoLeader is returned when the session lost leadership.
 	ErrNoLeader = errors.New("lost leadership")
+	// ErrEtcdRestoreRequested is returned when an etcd restore is already requested.
+	ErrEtcdRestoreRequested = errors.New("etcd restore is already requested")
+	// ErrEtcdRestoreStarted is returned when the etcd restore request
+	// cannot be cancelled because CKE has started restoring etcd.
+	ErrEtcdRestoreStarted = errors.New("etcd restore has already started")
 )
 
 func (s Storage) getStringValue(ctx context.Context, key string) (string, error) {
@@ -222,6 +240,37 @@ func (s Storage) GetConstraints(ctx context.Context) (*Constraints, error) {
 	return c, nil
 }
 
+// PutBackendConfig stores *BackendConfig into etcd.
+func (s Storage) PutBackendConfig(ctx context.Context, c *BackendConfig) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Put(ctx, KeyBackend, string(data))
+	return err
+}
+
+// GetBackendConfig loads *BackendConfig from etcd.
+// If the backend is not configured, this returns ErrNotFound.
+func (s Storage) GetBackendConfig(ctx context.Context) (*BackendConfig, error) {
+	resp, err := s.Get(ctx, KeyBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	cfg := new(BackendConfig)
+	err = json.Unmarshal(resp.Kvs[0].Value, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 // PutVaultConfig stores *VaultConfig into etcd.
 func (s Storage) PutVaultConfig(ctx context.Context, c *VaultConfig) error {
 	data, err := json.Marshal(c)
@@ -263,6 +312,88 @@ func (s Storage) PutCACertificate(ctx context.Context, name, pem string) error {
 	return err
 }
 
+// UpdateCACertificate stores CA certificate into etcd if the leaderKey exists.
+func (s Storage) UpdateCACertificate(ctx context.Context, leaderKey, name, pem string) error {
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpPut(KeyCA+name, pem)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	return nil
+}
+
+// PutCARotation stores *CARotation to start the rotation.
+func (s Storage) PutCARotation(ctx context.Context, r *CARotation) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Put(ctx, KeyCARotation, string(data))
+	return err
+}
+
+// UpdateCARotation updates *CARotation if the leaderKey exists.
+func (s Storage) UpdateCARotation(ctx context.Context, leaderKey string, r *CARotation) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpPut(KeyCARotation, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	return nil
+}
+
+// GetCARotation loads *CARotation from etcd.
+// If no rotation is in progress, this returns ErrNotFound.
+func (s Storage) GetCARotation(ctx context.Context) (*CARotation, error) {
+	resp, err := s.Get(ctx, KeyCARotation)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	r := new(CARotation)
+	err = json.Unmarshal(resp.Kvs[0].Value, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// DeleteCARotation deletes *CARotation if the leaderKey exists.
+func (s Storage) DeleteCARotation(ctx context.Context, leaderKey string) error {
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpDelete(KeyCARotation)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	return nil
+}
+
 func recordKey(r *Record) string {
 	return fmt.Sprintf("%s%016x", KeyRecords, r.ID)
 }
@@ -296,6 +427,218 @@ func (s Storage) PutServiceAccountData(ctx context.Context, leaderKey, cert, key
 	return nil
 }
 
+// PutEtcdBackupStatus stores *EtcdBackupStatus if the leaderKey exists.
+func (s Storage) PutEtcdBackupStatus(ctx context.Context, leaderKey string, st *EtcdBackupStatus) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpPut(KeyEtcdBackupStatus, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	return nil
+}
+
+// GetEtcdBackupStatus loads *EtcdBackupStatus from etcd.
+// If no backups have been taken, this returns ErrNotFound.
+func (s Storage) GetEtcdBackupStatus(ctx context.Context) (*EtcdBackupStatus, error) {
+	resp, err := s.Get(ctx, KeyEtcdBackupStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	st := new(EtcdBackupStatus)
+	err = json.Unmarshal(resp.Kvs[0].Value, st)
+	if err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// PutEtcdRestoreRequest stores *EtcdRestoreRequest.
+// If a restore is already requested, this returns ErrEtcdRestoreRequested.
+func (s Storage) PutEtcdRestoreRequest(ctx context.Context, r *EtcdRestoreRequest) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(KeyEtcdRestore), "=", 0)).
+		Then(clientv3.OpPut(KeyEtcdRestore, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrEtcdRestoreRequested
+	}
+	return nil
+}
+
+// GetEtcdRestoreRequest loads *EtcdRestoreRequest from etcd.
+// If no restore is requested, this returns ErrNotFound.
+func (s Storage) GetEtcdRestoreRequest(ctx context.Context) (*EtcdRestoreRequest, error) {
+	resp, err := s.Get(ctx, KeyEtcdRestore)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	r := new(EtcdRestoreRequest)
+	err = json.Unmarshal(resp.Kvs[0].Value, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// CancelEtcdRestoreRequest deletes the restore request.
+// If no restore is requested, this returns ErrNotFound.
+// If CKE has started restoring etcd, this returns ErrEtcdRestoreStarted.
+func (s Storage) CancelEtcdRestoreRequest(ctx context.Context) error {
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyMissing(KeyEtcdRestoreStarted)).
+		Then(clientv3.OpDelete(KeyEtcdRestore)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrEtcdRestoreStarted
+	}
+	if resp.Responses[0].GetResponseDeleteRange().Deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// StartEtcdRestore records that CKE starts restoring etcd if the leaderKey
+// exists.  After this, the restore request cannot be cancelled.
+// If the request has been cancelled, this returns ErrNotFound.
+func (s Storage) StartEtcdRestore(ctx context.Context, leaderKey string) error {
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpTxn(
+			[]clientv3.Cmp{clientv3util.KeyExists(KeyEtcdRestore)},
+			[]clientv3.Op{clientv3.OpPut(KeyEtcdRestoreStarted, time.Now().UTC().Format(time.RFC3339))},
+			nil,
+		)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	if !resp.Responses[0].GetResponseTxn().Succeeded {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteEtcdRestoreRequest deletes the restore request if the leaderKey exists.
+func (s Storage) DeleteEtcdRestoreRequest(ctx context.Context, leaderKey string) error {
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(
+			clientv3.OpDelete(KeyEtcdRestore),
+			clientv3.OpDelete(KeyEtcdRestoreStarted),
+		).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	return nil
+}
+
+// PutEncryptionKeyRotation stores *EncryptionKeyRotation to start the rotation.
+func (s Storage) PutEncryptionKeyRotation(ctx context.Context, r *EncryptionKeyRotation) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Put(ctx, KeyEncryptionKeyRotation, string(data))
+	return err
+}
+
+// UpdateEncryptionKeyRotation updates *EncryptionKeyRotation if the leaderKey exists.
+func (s Storage) UpdateEncryptionKeyRotation(ctx context.Context, leaderKey string, r *EncryptionKeyRotation) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpPut(KeyEncryptionKeyRotation, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	return nil
+}
+
+// GetEncryptionKeyRotation loads *EncryptionKeyRotation from etcd.
+// If no rotation is in progress, this returns ErrNotFound.
+func (s Storage) GetEncryptionKeyRotation(ctx context.Context) (*EncryptionKeyRotation, error) {
+	resp, err := s.Get(ctx, KeyEncryptionKeyRotation)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	r := new(EncryptionKeyRotation)
+	err = json.Unmarshal(resp.Kvs[0].Value, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// DeleteEncryptionKeyRotation deletes *EncryptionKeyRotation if the leaderKey exists.
+func (s Storage) DeleteEncryptionKeyRotation(ctx context.Context, leaderKey string) error {
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpDelete(KeyEncryptionKeyRotation)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	return nil
+}
+
 // GetRecords loads list of *Record from etcd.
 // The returned records are sorted by record ID in decreasing order.
 func (s Storage) GetRecords(ctx context.Context, count int64) ([]*Record, error) {
@@ -589,6 +932,262 @@ func (s Storage) DeleteResource(ctx context.Context, key string) error {
 	return err
 }
 
+// GetSSHHostKey returns the SSH host key pinned for the node address
+// in the authorized_keys format.
+// If not found, this returns ErrNotFound.
+func (s Storage) GetSSHHostKey(ctx context.Context, address string) (string, error) {
+	return s.getStringValue(ctx, KeySSHHostKeysPrefix+address)
+}
+
+// GetSSHHostKeys returns all pinned SSH host keys.  Keys of the map are node addresses.
+func (s Storage) GetSSHHostKeys(ctx context.Context) (map[string]string, error) {
+	resp, err := s.Get(ctx, KeySSHHostKeysPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		keys[string(kv.Key[len(KeySSHHostKeysPrefix):])] = string(kv.Value)
+	}
+	return keys, nil
+}
+
+// PutSSHHostKey pins the SSH host key for the node address.
+// The existing key, if any, is overwritten.
+func (s Storage) PutSSHHostKey(ctx context.Context, address, key string) error {
+	_, err := s.Put(ctx, KeySSHHostKeysPrefix+address, key)
+	return err
+}
+
+// PutSSHHostKeyIfAbsent pins the SSH host key for the node address
+// only when no key has been pinned.  This returns false if a key exists.
+func (s Storage) PutSSHHostKeyIfAbsent(ctx context.Context, address, key string) (bool, error) {
+	k := KeySSHHostKeysPrefix + address
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyMissing(k)).
+		Then(clientv3.OpPut(k, key)).
+		Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
+// DeleteSSHHostKey unpins the SSH host key for the node address.
+func (s Storage) DeleteSSHHostKey(ctx context.Context, address string) error {
+	_, err := s.Delete(ctx, KeySSHHostKeysPrefix+address)
+	return err
+}
+
+// GetOperationRetries returns the retry states of failed operations.
+// Keys of the map are the IDs of the states.
+func (s Storage) GetOperationRetries(ctx context.Context) (map[string]*OperationRetry, error) {
+	resp, err := s.Get(ctx, KeyOperationRetryPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	retries := make(map[string]*OperationRetry, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		r := new(OperationRetry)
+		err = json.Unmarshal(kv.Value, r)
+		if err != nil {
+			return nil, err
+		}
+		retries[string(kv.Key[len(KeyOperationRetryPrefix):])] = r
+	}
+	return retries, nil
+}
+
+// PutOperationRetry stores the retry state if the leaderKey exists.
+func (s Storage) PutOperationRetry(ctx context.Context, leaderKey, id string, r *OperationRetry) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpPut(KeyOperationRetryPrefix+id, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	return nil
+}
+
+// DeleteOperationRetry deletes the retry state if the leaderKey exists.
+// If the state does not exist, this returns ErrNotFound.
+func (s Storage) DeleteOperationRetry(ctx context.Context, leaderKey, id string) error {
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpDelete(KeyOperationRetryPrefix + id)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	if resp.Responses[0].GetResponseDeleteRange().Deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ResetOperationRetry deletes the retry state so that the operation is
+// retried immediately.  This is for administrators.
+// If the state does not exist, this returns ErrNotFound.
+func (s Storage) ResetOperationRetry(ctx context.Context, id string) error {
+	resp, err := s.Delete(ctx, KeyOperationRetryPrefix+id)
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetPendingOperations loads all pending operations sorted by their IDs.
+func (s Storage) GetPendingOperations(ctx context.Context) ([]*PendingOperation, error) {
+	resp, err := s.Get(ctx, KeyPendingOperationPrefix, clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]*PendingOperation, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		p := new(PendingOperation)
+		err = json.Unmarshal(kv.Value, p)
+		if err != nil {
+			return nil, err
+		}
+		pending[i] = p
+	}
+	return pending, nil
+}
+
+// GetPendingOperation loads the pending operation specified by id.
+// If the operation is not found, this returns ErrNotFound.
+func (s Storage) GetPendingOperation(ctx context.Context, id string) (*PendingOperation, error) {
+	resp, err := s.Get(ctx, KeyPendingOperationPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	p := new(PendingOperation)
+	err = json.Unmarshal(resp.Kvs[0].Value, p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// PutPendingOperation stores the pending operation if the leaderKey exists.
+func (s Storage) PutPendingOperation(ctx context.Context, leaderKey string, p *PendingOperation) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpPut(KeyPendingOperationPrefix+p.ID, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	return nil
+}
+
+// UpdatePendingOperation updates the existing pending operation.
+// If the operation is not found, this returns ErrNotFound.
+func (s Storage) UpdatePendingOperation(ctx context.Context, p *PendingOperation) error {
+	key := KeyPendingOperationPrefix + p.ID
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(key)).
+		Then(clientv3.OpPut(key, string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeletePendingOperation deletes the pending operation if the leaderKey exists.
+func (s Storage) DeletePendingOperation(ctx context.Context, leaderKey, id string) error {
+	resp, err := s.Txn(ctx).
+		If(clientv3util.KeyExists(leaderKey)).
+		Then(clientv3.OpDelete(KeyPendingOperationPrefix + id)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrNoLeader
+	}
+	return nil
+}
+
+// PutPause stores *Pause to pause operations.
+func (s Storage) PutPause(ctx context.Context, p *Pause) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Put(ctx, KeyPause, string(data))
+	return err
+}
+
+// GetPause loads *Pause from etcd.
+// If operations are not paused, this returns ErrNotFound.
+func (s Storage) GetPause(ctx context.Context) (*Pause, error) {
+	resp, err := s.Get(ctx, KeyPause)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	p := new(Pause)
+	err = json.Unmarshal(resp.Kvs[0].Value, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// DeletePause deletes *Pause to resume operations.
+func (s Storage) DeletePause(ctx context.Context) error {
+	_, err := s.Delete(ctx, KeyPause)
+	return err
+}
+
 // IsSabakanDisabled returns true if sabakan integration is disabled.
 func (s Storage) IsSabakanDisabled(ctx context.Context) (bool, error) {
 	resp, err := s.Get(ctx, KeySabakanDisabled)
@@ -124,6 +124,452 @@ func testStorageConstraints(t *testing.T) {
 	}
 }
 
+func testStorageEtcdBackupStatus(t *testing.T) {
+	t.Parallel()
+
+	client := newEtcdClient(t)
+	defer client.Close()
+	storage := Storage{client}
+	ctx := context.Background()
+
+	_, err := storage.GetEtcdBackupStatus(ctx)
+	if err != ErrNotFound {
+		t.Fatal("etcd backup status found.")
+	}
+
+	s, err := concurrency.NewSession(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	e := concurrency.NewElection(s, KeyLeader)
+	err = e.Campaign(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := &EtcdBackupStatus{
+		Name:     "etcd-20211001-000000.backup",
+		Time:     time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC),
+		Size:     1024,
+		Checksum: "abcdef",
+	}
+	err = storage.PutEtcdBackupStatus(ctx, e.Key(), st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := storage.GetEtcdBackupStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(st, got) {
+		t.Fatalf("got invalid etcd backup status: %v", got)
+	}
+
+	err = storage.PutEtcdBackupStatus(ctx, "no-such-leader", st)
+	if err != ErrNoLeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func testStorageEtcdRestoreRequest(t *testing.T) {
+	t.Parallel()
+
+	client := newEtcdClient(t)
+	defer client.Close()
+	storage := Storage{client}
+	ctx := context.Background()
+
+	_, err := storage.GetEtcdRestoreRequest(ctx)
+	if err != ErrNotFound {
+		t.Fatal("etcd restore request found.")
+	}
+
+	r := &EtcdRestoreRequest{
+		Name:      "etcd-20211001-000000.backup",
+		Token:     "cke-restore-1633046400",
+		Requested: time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC),
+	}
+	err = storage.PutEtcdRestoreRequest(ctx, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := storage.GetEtcdRestoreRequest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, got) {
+		t.Fatalf("got invalid etcd restore request: %v", got)
+	}
+
+	r2 := *r
+	r2.Name = "etcd-20211002-000000.backup"
+	err = storage.PutEtcdRestoreRequest(ctx, &r2)
+	if err != ErrEtcdRestoreRequested {
+		t.Errorf("existing request should not be replaced: %v", err)
+	}
+
+	err = storage.DeleteEtcdRestoreRequest(ctx, "no-such-leader")
+	if err != ErrNoLeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	s, err := concurrency.NewSession(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	e := concurrency.NewElection(s, KeyLeader)
+	err = e.Campaign(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.StartEtcdRestore(ctx, "no-such-leader")
+	if err != ErrNoLeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = storage.StartEtcdRestore(ctx, e.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.CancelEtcdRestoreRequest(ctx)
+	if err != ErrEtcdRestoreStarted {
+		t.Errorf("started restore should not be cancelled: %v", err)
+	}
+
+	err = storage.DeleteEtcdRestoreRequest(ctx, e.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.GetEtcdRestoreRequest(ctx)
+	if err != ErrNotFound {
+		t.Error("etcd restore request was not deleted")
+	}
+	err = storage.StartEtcdRestore(ctx, e.Key())
+	if err != ErrNotFound {
+		t.Errorf("restore should not start without a request: %v", err)
+	}
+
+	err = storage.CancelEtcdRestoreRequest(ctx)
+	if err != ErrNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = storage.PutEtcdRestoreRequest(ctx, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.CancelEtcdRestoreRequest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.GetEtcdRestoreRequest(ctx)
+	if err != ErrNotFound {
+		t.Error("etcd restore request was not cancelled")
+	}
+}
+
+func testStoragePause(t *testing.T) {
+	t.Parallel()
+
+	client := newEtcdClient(t)
+	defer client.Close()
+	storage := Storage{client}
+	ctx := context.Background()
+
+	_, err := storage.GetPause(ctx)
+	if err != ErrNotFound {
+		t.Fatal("pause found.")
+	}
+
+	p := &Pause{
+		Reason: "investigating",
+		Phases: []OperationPhase{PhaseK8sMaintain},
+		Nodes:  []string{"10.0.0.1"},
+		Since:  time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC),
+	}
+	err = storage.PutPause(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := storage.GetPause(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, got) {
+		t.Fatalf("got invalid pause: %v", got)
+	}
+
+	err = storage.DeletePause(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.GetPause(ctx)
+	if err != ErrNotFound {
+		t.Error("pause was not deleted")
+	}
+
+	err = storage.DeletePause(ctx)
+	if err != nil {
+		t.Error("deleting absent pause should succeed", err)
+	}
+}
+
+func testStoragePendingOperation(t *testing.T) {
+	t.Parallel()
+
+	client := newEtcdClient(t)
+	defer client.Close()
+	storage := Storage{client}
+	ctx := context.Background()
+
+	s, err := concurrency.NewSession(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	e := concurrency.NewElection(s, KeyLeader)
+	err = e.Campaign(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaderKey := e.Key()
+
+	pending, err := storage.GetPendingOperations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Error("pending operations found", pending)
+	}
+
+	now := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	p1 := NewPendingOperation(testOperator{"remove-node", []string{"node1"}}, PhaseK8sMaintain, now)
+	p2 := NewPendingOperation(testOperator{"etcd-remove-member", []string{"10.0.0.1"}}, PhaseEtcdMaintain, now)
+
+	err = storage.PutPendingOperation(ctx, "no-such-leader", p1)
+	if err != ErrNoLeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, p := range []*PendingOperation{p1, p2} {
+		err = storage.PutPendingOperation(ctx, leaderKey, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending, err = storage.GetPendingOperations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pending, []*PendingOperation{p2, p1}) {
+		t.Errorf("unexpected pending operations: %v", pending)
+	}
+
+	p1.Status = PendingOperationApproved
+	p1.Decided = now.Add(time.Minute)
+	err = storage.UpdatePendingOperation(ctx, p1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := storage.GetPendingOperation(ctx, p1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p1) {
+		t.Errorf("unexpected pending operation: %v", got)
+	}
+
+	err = storage.DeletePendingOperation(ctx, "no-such-leader", p1.ID)
+	if err != ErrNoLeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = storage.DeletePendingOperation(ctx, leaderKey, p1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.GetPendingOperation(ctx, p1.ID)
+	if err != ErrNotFound {
+		t.Error("pending operation was not deleted", err)
+	}
+	err = storage.UpdatePendingOperation(ctx, p1)
+	if err != ErrNotFound {
+		t.Error("updating deleted pending operation should fail", err)
+	}
+}
+
+func testStorageEncryptionKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	client := newEtcdClient(t)
+	defer client.Close()
+	storage := Storage{client}
+	ctx := context.Background()
+
+	_, err := storage.GetEncryptionKeyRotation(ctx)
+	if err != ErrNotFound {
+		t.Fatal("encryption key rotation found.")
+	}
+
+	r := &EncryptionKeyRotation{
+		Provider: EncryptionProviderAESGCM,
+		KeyName:  "2021-10-01T00:00:00Z",
+		Step:     EncryptionKeyRotationAdd,
+		Started:  time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC),
+	}
+	err = storage.PutEncryptionKeyRotation(ctx, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := storage.GetEncryptionKeyRotation(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, got) {
+		t.Fatalf("got invalid encryption key rotation: %v", got)
+	}
+
+	r.Step = EncryptionKeyRotationPromote
+	err = storage.UpdateEncryptionKeyRotation(ctx, "no-such-leader", r)
+	if err != ErrNoLeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = storage.DeleteEncryptionKeyRotation(ctx, "no-such-leader")
+	if err != ErrNoLeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	s, err := concurrency.NewSession(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	e := concurrency.NewElection(s, KeyLeader)
+	err = e.Campaign(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.UpdateEncryptionKeyRotation(ctx, e.Key(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = storage.GetEncryptionKeyRotation(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Step != EncryptionKeyRotationPromote {
+		t.Errorf("step was not updated: %s", got.Step)
+	}
+
+	err = storage.DeleteEncryptionKeyRotation(ctx, e.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.GetEncryptionKeyRotation(ctx)
+	if err != ErrNotFound {
+		t.Error("encryption key rotation was not deleted")
+	}
+}
+
+func testStorageCARotation(t *testing.T) {
+	t.Parallel()
+
+	client := newEtcdClient(t)
+	defer client.Close()
+	storage := Storage{client}
+	ctx := context.Background()
+
+	_, err := storage.GetCARotation(ctx)
+	if err != ErrNotFound {
+		t.Fatal("CA rotation found.")
+	}
+
+	r := &CARotation{
+		CA:             CAKubernetes,
+		OldIssuer:      "old",
+		NewIssuer:      "new",
+		OldCertificate: "old cert",
+		NewCertificate: "new cert",
+		Step:           CARotationBundle,
+		Started:        time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC),
+	}
+	err = storage.PutCARotation(ctx, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := storage.GetCARotation(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, got) {
+		t.Fatalf("got invalid CA rotation: %v", got)
+	}
+
+	r.Step = CARotationReissue
+	err = storage.UpdateCARotation(ctx, "no-such-leader", r)
+	if err != ErrNoLeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = storage.UpdateCACertificate(ctx, "no-such-leader", CAKubernetes, r.Bundle())
+	if err != ErrNoLeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = storage.DeleteCARotation(ctx, "no-such-leader")
+	if err != ErrNoLeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	s, err := concurrency.NewSession(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	e := concurrency.NewElection(s, KeyLeader)
+	err = e.Campaign(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.UpdateCARotation(ctx, e.Key(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = storage.GetCARotation(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Step != CARotationReissue {
+		t.Errorf("step was not updated: %s", got.Step)
+	}
+
+	err = storage.UpdateCACertificate(ctx, e.Key(), CAKubernetes, r.Bundle())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := storage.GetCACertificate(ctx, CAKubernetes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert != "old cert\nnew cert" {
+		t.Errorf("unexpected CA certificate: %s", cert)
+	}
+
+	err = storage.DeleteCARotation(ctx, e.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.GetCARotation(ctx)
+	if err != ErrNotFound {
+		t.Error("CA rotation was not deleted")
+	}
+}
+
 func checkLeaderKey(ctx context.Context, s Storage, leaderKey string) (bool, error) {
 	resp, err := s.Get(ctx, leaderKey, clientv3.WithKeysOnly())
 	if err != nil {
@@ -132,6 +578,61 @@ func checkLeaderKey(ctx context.Context, s Storage, leaderKey string) (bool, err
 	return len(resp.Kvs) > 0, nil
 }
 
+func testStorageSSHHostKey(t *testing.T) {
+	t.Parallel()
+
+	client := newEtcdClient(t)
+	defer client.Close()
+	storage := Storage{client}
+	ctx := context.Background()
+
+	_, err := storage.GetSSHHostKey(ctx, "10.0.0.1")
+	if err != ErrNotFound {
+		t.Fatal("ssh host key found.")
+	}
+
+	ok, err := storage.PutSSHHostKeyIfAbsent(ctx, "10.0.0.1", "ssh-ed25519 AAAA1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("ssh host key was not pinned")
+	}
+	ok, err = storage.PutSSHHostKeyIfAbsent(ctx, "10.0.0.1", "ssh-ed25519 AAAA2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("ssh host key was overwritten")
+	}
+
+	err = storage.PutSSHHostKey(ctx, "10.0.0.2", "ssh-rsa BBBB")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := storage.GetSSHHostKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"10.0.0.1": "ssh-ed25519 AAAA1",
+		"10.0.0.2": "ssh-rsa BBBB",
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("unexpected ssh host keys: %v", keys)
+	}
+
+	err = storage.DeleteSSHHostKey(ctx, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.GetSSHHostKey(ctx, "10.0.0.1")
+	if err != ErrNotFound {
+		t.Error("ssh host key was not deleted")
+	}
+}
+
 func testStorageRecord(t *testing.T) {
 	t.Parallel()
 
@@ -812,10 +1313,98 @@ func testStatus(t *testing.T) {
 	}
 }
 
+func testStorageOperationRetry(t *testing.T) {
+	t.Parallel()
+
+	client := newEtcdClient(t)
+	defer client.Close()
+	storage := Storage{client}
+	ctx := context.Background()
+
+	retries, err := storage.GetOperationRetries(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retries) != 0 {
+		t.Fatal("retries found", retries)
+	}
+
+	r := &OperationRetry{
+		Operation: "kubelet-restart",
+		Targets:   []string{"10.0.0.1"},
+		Attempts:  2,
+		LastError: "timeout",
+		Failures:  []time.Time{time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)},
+		NextRetry: time.Date(2021, 10, 1, 0, 1, 0, 0, time.UTC),
+	}
+	err = storage.PutOperationRetry(ctx, "no-such-leader", "kubelet-restart-1", r)
+	if err != ErrNoLeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	s, err := concurrency.NewSession(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	e := concurrency.NewElection(s, KeyLeader)
+	err = e.Campaign(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.PutOperationRetry(ctx, e.Key(), "kubelet-restart-1", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retries, err = storage.GetOperationRetries(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(retries, map[string]*OperationRetry{"kubelet-restart-1": r}) {
+		t.Errorf("unexpected retries: %v", retries)
+	}
+
+	err = storage.DeleteOperationRetry(ctx, "no-such-leader", "kubelet-restart-1")
+	if err != ErrNoLeader {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = storage.DeleteOperationRetry(ctx, e.Key(), "kubelet-restart-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.DeleteOperationRetry(ctx, e.Key(), "kubelet-restart-1")
+	if err != ErrNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = storage.PutOperationRetry(ctx, e.Key(), "kubelet-restart-1", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.ResetOperationRetry(ctx, "kubelet-restart-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.ResetOperationRetry(ctx, "kubelet-restart-1")
+	if err != ErrNotFound {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestStorage(t *testing.T) {
 	t.Run("ConfigVersion", testConfigVersion)
 	t.Run("Cluster", testStorageCluster)
 	t.Run("Constraints", testStorageConstraints)
+	t.Run("EtcdBackupStatus", testStorageEtcdBackupStatus)
+	t.Run("EtcdRestoreRequest", testStorageEtcdRestoreRequest)
+	t.Run("EncryptionKeyRotation", testStorageEncryptionKeyRotation)
+	t.Run("CARotation", testStorageCARotation)
+	t.Run("SSHHostKey", testStorageSSHHostKey)
+	t.Run("OperationRetry", testStorageOperationRetry)
+	t.Run("Pause", testStoragePause)
+	t.Run("PendingOperation", testStoragePendingOperation)
 	t.Run("Record", testStorageRecord)
 	t.Run("Maint", testStorageMaint)
 	t.Run("Resource", testStorageResource)
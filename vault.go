@@ -10,6 +10,10 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cybozu-go/log"
@@ -27,6 +31,21 @@ const K8sSecret = CKESecret + "/k8s"
 
 type anyMap = map[string]interface{}
 
+// Vault auth methods.
+const (
+	VaultAuthAppRole    = "approle"
+	VaultAuthKubernetes = "kubernetes"
+	VaultAuthCert       = "cert"
+	VaultAuthToken      = "token"
+)
+
+// DefaultVaultKubernetesTokenFile is the default path of the service account token
+// to login to Vault with Kubernetes auth method.
+const DefaultVaultKubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// vaultReloginInterval is the interval to retry login to Vault.
+const vaultReloginInterval = 10 * time.Second
+
 // VaultConfig is data to store in etcd
 type VaultConfig struct {
 	// Endpoint is the address of the Vault server.
@@ -35,11 +54,41 @@ type VaultConfig struct {
 	// CACert is x509 certificate in PEM format of the endpoint CA.
 	CACert string `json:"ca-cert"`
 
+	// AuthMethod is the auth method to login to Vault.
+	// One of "approle", "kubernetes", "cert", or "token".  Empty means "approle".
+	AuthMethod string `json:"auth-method,omitempty"`
+
+	// AuthMount is the path where the auth method is enabled.
+	// Empty means the name of the auth method.
+	AuthMount string `json:"auth-mount,omitempty"`
+
 	// RoleID is AppRole ID to login to Vault.
 	RoleID string `json:"role-id"`
 
 	// SecretID is AppRole secret to login to Vault.
 	SecretID string `json:"secret-id"`
+
+	// Role is the role to login with Kubernetes or TLS certificate auth method.
+	Role string `json:"role,omitempty"`
+
+	// KubernetesTokenFile is the path of the service account token for Kubernetes auth method.
+	KubernetesTokenFile string `json:"kubernetes-token-file,omitempty"`
+
+	// ClientCert and ClientKey are x509 certificate and private key in PEM format
+	// for TLS certificate auth method.
+	ClientCert string `json:"client-cert,omitempty"`
+	ClientKey  string `json:"client-key,omitempty"`
+
+	// TokenFile is the path of a file containing a Vault token for token auth method.
+	TokenFile string `json:"token-file,omitempty"`
+}
+
+// Method returns the auth method to login to Vault.
+func (c *VaultConfig) Method() string {
+	if len(c.AuthMethod) == 0 {
+		return VaultAuthAppRole
+	}
+	return c.AuthMethod
 }
 
 // Validate validates the vault configuration
@@ -61,17 +110,39 @@ func (c *VaultConfig) Validate() error {
 			return errors.New("invalid certificate")
 		}
 	}
-	if len(c.RoleID) == 0 {
-		return errors.New("role-id is empty")
-	}
-	if len(c.SecretID) == 0 {
-		return errors.New("secret-id is empty")
+
+	switch c.Method() {
+	case VaultAuthAppRole:
+		if len(c.RoleID) == 0 {
+			return errors.New("role-id is empty")
+		}
+		if len(c.SecretID) == 0 {
+			return errors.New("secret-id is empty")
+		}
+	case VaultAuthKubernetes:
+		if len(c.Role) == 0 {
+			return errors.New("role is empty")
+		}
+	case VaultAuthCert:
+		if len(c.ClientCert) == 0 || len(c.ClientKey) == 0 {
+			return errors.New("client-cert or client-key is empty")
+		}
+		_, err := tls.X509KeyPair([]byte(c.ClientCert), []byte(c.ClientKey))
+		if err != nil {
+			return errors.New("invalid client certificate: " + err.Error())
+		}
+	case VaultAuthToken:
+		if len(c.TokenFile) == 0 {
+			return errors.New("token-file is empty")
+		}
+	default:
+		return errors.New("unknown auth-method: " + c.AuthMethod)
 	}
 	return nil
 }
 
 // VaultClient creates vault client.
-// The client has logged-in to Vault using RoleID and SecretID in cfg.
+// The client has logged-in to Vault using the auth method in cfg.
 func VaultClient(cfg *VaultConfig) (*vault.Client, *vault.Secret, error) {
 	transport := &http.Transport{
 		Proxy:             http.ProxyFromEnvironment,
@@ -98,6 +169,19 @@ func VaultClient(cfg *VaultConfig) (*vault.Client, *vault.Secret, error) {
 		}
 	}
 
+	if cfg.Method() == VaultAuthCert {
+		cert, err := tls.X509KeyPair([]byte(cfg.ClientCert), []byte(cfg.ClientKey))
+		if err != nil {
+			return nil, nil, err
+		}
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{
+				MinVersion: tls.VersionTLS12,
+			}
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+
 	client, err := vault.NewClient(&vault.Config{
 		Address: cfg.Endpoint,
 		HttpClient: &http.Client{
@@ -112,27 +196,179 @@ func VaultClient(cfg *VaultConfig) (*vault.Client, *vault.Secret, error) {
 		return nil, nil, err
 	}
 
-	secret, err := client.Logical().Write("auth/approle/login", anyMap{
-		"role_id":   cfg.RoleID,
-		"secret_id": cfg.SecretID,
-	})
+	secret, err := vaultLogin(client, cfg)
 	if err != nil {
 		log.Error("failed to login to vault", anyMap{
-			log.FnError: err,
-			"endpoint":  cfg.Endpoint,
+			log.FnError:   err,
+			"endpoint":    cfg.Endpoint,
+			"auth_method": cfg.Method(),
 		})
 		return nil, nil, err
 	}
-	// If cke accesses while vault is initializing, then vault returns io.EOF and the secret is nil
-	if secret == nil {
-		log.Error("failed to get secret", anyMap{
-			"endpoint": cfg.Endpoint,
+	return client, secret, nil
+}
+
+// vaultLogin logs in to Vault and sets the token to client.
+// The returned secret always has Auth.
+func vaultLogin(client *vault.Client, cfg *VaultConfig) (*vault.Secret, error) {
+	method := cfg.Method()
+	mount := cfg.AuthMount
+	if len(mount) == 0 {
+		mount = method
+	}
+	loginPath := path.Join("auth", mount, "login")
+
+	var secret *vault.Secret
+	var err error
+	switch method {
+	case VaultAuthAppRole:
+		secret, err = client.Logical().Write(loginPath, anyMap{
+			"role_id":   cfg.RoleID,
+			"secret_id": cfg.SecretID,
 		})
-		return nil, nil, errors.New("failed to get secret")
+	case VaultAuthKubernetes:
+		tokenFile := cfg.KubernetesTokenFile
+		if len(tokenFile) == 0 {
+			tokenFile = DefaultVaultKubernetesTokenFile
+		}
+		var jwt []byte
+		jwt, err = os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		secret, err = client.Logical().Write(loginPath, anyMap{
+			"role": cfg.Role,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		})
+	case VaultAuthCert:
+		data := anyMap{}
+		if len(cfg.Role) > 0 {
+			data["name"] = cfg.Role
+		}
+		secret, err = client.Logical().Write(loginPath, data)
+	case VaultAuthToken:
+		return vaultTokenLogin(client, cfg.TokenFile)
+	default:
+		return nil, errors.New("unknown auth-method: " + method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// If cke accesses while vault is initializing, then vault returns io.EOF and the secret is nil
+	if secret == nil || secret.Auth == nil {
+		return nil, errors.New("failed to get secret")
 	}
 
 	client.SetToken(secret.Auth.ClientToken)
-	return client, secret, nil
+	return secret, nil
+}
+
+// vaultTokenLogin reads a token from a file and looks up its properties.
+func vaultTokenLogin(client *vault.Client, tokenFile string) (*vault.Secret, error) {
+	data, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	token := strings.TrimSpace(string(data))
+	if len(token) == 0 {
+		return nil, errors.New("empty token in " + tokenFile)
+	}
+	client.SetToken(token)
+
+	info, err := client.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, errors.New("failed to lookup token")
+	}
+	ttl, err := info.TokenTTL()
+	if err != nil {
+		return nil, err
+	}
+	renewable, err := info.TokenIsRenewable()
+	if err != nil {
+		return nil, err
+	}
+	return &vault.Secret{
+		Auth: &vault.SecretAuth{
+			ClientToken:   token,
+			LeaseDuration: int(ttl.Seconds()),
+			Renewable:     renewable,
+		},
+	}, nil
+}
+
+// VaultTokenStatus represents the status of the Vault token of this process.
+type VaultTokenStatus struct {
+	// Connected is true if this process has logged in to Vault.
+	Connected bool
+	// AuthMethod is the auth method used to login.
+	AuthMethod string
+	// TTL is the TTL of the token granted at the last login or renewal.
+	// Zero means that the token never expires.
+	TTL time.Duration
+	// LastRenewal is the time of the last login or renewal.
+	LastRenewal time.Time
+	// RenewalFailures is the number of failures to renew the token or to login again.
+	RenewalFailures int
+}
+
+var vaultTokenStatus struct {
+	mu         sync.Mutex
+	generation int
+	status     VaultTokenStatus
+	observer   func(VaultTokenStatus)
+}
+
+// GetVaultTokenStatus returns the status of the Vault token of this process.
+func GetVaultTokenStatus() VaultTokenStatus {
+	vaultTokenStatus.mu.Lock()
+	defer vaultTokenStatus.mu.Unlock()
+	return vaultTokenStatus.status
+}
+
+// SetVaultTokenObserver sets a function called whenever the status of the Vault token changes.
+func SetVaultTokenObserver(f func(VaultTokenStatus)) {
+	vaultTokenStatus.mu.Lock()
+	defer vaultTokenStatus.mu.Unlock()
+	vaultTokenStatus.observer = f
+}
+
+func isCurrentVaultToken(gen int) bool {
+	vaultTokenStatus.mu.Lock()
+	defer vaultTokenStatus.mu.Unlock()
+	return gen == vaultTokenStatus.generation
+}
+
+// updateVaultTokenStatus updates the status by f unless gen is stale.
+func updateVaultTokenStatus(gen int, f func(st *VaultTokenStatus)) bool {
+	vaultTokenStatus.mu.Lock()
+	defer vaultTokenStatus.mu.Unlock()
+	if gen != vaultTokenStatus.generation {
+		return false
+	}
+	f(&vaultTokenStatus.status)
+	if vaultTokenStatus.observer != nil {
+		vaultTokenStatus.observer(vaultTokenStatus.status)
+	}
+	return true
+}
+
+func newVaultTokenGeneration(method string, secret *vault.Secret) int {
+	vaultTokenStatus.mu.Lock()
+	vaultTokenStatus.generation++
+	gen := vaultTokenStatus.generation
+	vaultTokenStatus.status = VaultTokenStatus{}
+	vaultTokenStatus.mu.Unlock()
+
+	updateVaultTokenStatus(gen, func(st *VaultTokenStatus) {
+		st.Connected = true
+		st.AuthMethod = method
+		st.TTL = time.Duration(secret.Auth.LeaseDuration) * time.Second
+		st.LastRenewal = time.Now()
+	})
+	return gen
 }
 
 // ConnectVault unmarshal data to get VaultConfig and call VaultClient
@@ -149,27 +385,110 @@ func ConnectVault(ctx context.Context, data []byte) error {
 		return err
 	}
 
+	gen := newVaultTokenGeneration(c.Method(), secret)
+	go keepVaultToken(ctx, gen, client, c, secret)
+
+	setVaultClient(client)
+	log.Info("connected to vault", anyMap{
+		"endpoint":    c.Endpoint,
+		"auth_method": c.Method(),
+	})
+	return nil
+}
+
+// keepVaultToken keeps the token of client valid by renewing it, or by
+// logging in again when it cannot be renewed any longer.
+// This returns when ctx is canceled or ConnectVault is called again.
+func keepVaultToken(ctx context.Context, gen int, client *vault.Client, cfg *VaultConfig, secret *vault.Secret) {
+	for {
+		err := watchVaultToken(ctx, gen, client, secret)
+		if ctx.Err() != nil || !isCurrentVaultToken(gen) {
+			return
+		}
+		if err != nil {
+			log.Warn("failed to renew vault token", anyMap{
+				log.FnError: err,
+				"endpoint":  cfg.Endpoint,
+			})
+			if !updateVaultTokenStatus(gen, func(st *VaultTokenStatus) { st.RenewalFailures++ }) {
+				return
+			}
+		}
+
+		for {
+			secret, err = vaultLogin(client, cfg)
+			if err == nil {
+				break
+			}
+			log.Error("failed to login to vault again", anyMap{
+				log.FnError:   err,
+				"endpoint":    cfg.Endpoint,
+				"auth_method": cfg.Method(),
+			})
+			if !updateVaultTokenStatus(gen, func(st *VaultTokenStatus) { st.RenewalFailures++ }) {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(vaultReloginInterval):
+			}
+		}
+
+		ok := updateVaultTokenStatus(gen, func(st *VaultTokenStatus) {
+			st.TTL = time.Duration(secret.Auth.LeaseDuration) * time.Second
+			st.LastRenewal = time.Now()
+		})
+		if !ok {
+			return
+		}
+		log.Info("logged in to vault again", anyMap{
+			"endpoint": cfg.Endpoint,
+		})
+	}
+}
+
+// watchVaultToken renews the token while it is renewable.
+// This returns when the token needs to be obtained again.
+func watchVaultToken(ctx context.Context, gen int, client *vault.Client, secret *vault.Secret) error {
+	ttl := time.Duration(secret.Auth.LeaseDuration) * time.Second
+	if !secret.Auth.Renewable {
+		if ttl == 0 {
+			// the token never expires.
+			<-ctx.Done()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(ttl * 2 / 3):
+		}
+		return nil
+	}
+
 	watcher, err := client.NewLifetimeWatcher(&vault.LifetimeWatcherInput{
 		Secret:        secret,
-		RenewBehavior: vault.RenewBehaviorIgnoreErrors,
+		RenewBehavior: vault.RenewBehaviorErrorOnErrors,
 	})
 	if err != nil {
-		log.Error("failed to create vault renewer", anyMap{
-			log.FnError: err,
-			"endpoint":  c.Endpoint,
-		})
 		return err
 	}
-
 	go watcher.Start()
-	go func() {
-		<-ctx.Done()
-		watcher.Stop()
-	}()
+	defer watcher.Stop()
 
-	setVaultClient(client)
-	log.Info("connected to vault", anyMap{
-		"endpoint": c.Endpoint,
-	})
-	return nil
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.DoneCh():
+			return err
+		case out := <-watcher.RenewCh():
+			ok := updateVaultTokenStatus(gen, func(st *VaultTokenStatus) {
+				st.TTL = time.Duration(out.Secret.Auth.LeaseDuration) * time.Second
+				st.LastRenewal = out.RenewedAt
+			})
+			if !ok {
+				return nil
+			}
+		}
+	}
 }
@@ -0,0 +1,160 @@
+package cke
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testClientCertificate(t *testing.T) (cert, key string) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "cke"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	key = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return cert, key
+}
+
+func TestVaultConfig(t *testing.T) {
+	t.Parallel()
+
+	cert, key := testClientCertificate(t)
+	_, otherKey := testClientCertificate(t)
+
+	testCases := []struct {
+		name string
+		cfg  VaultConfig
+		ok   bool
+	}{
+		{
+			name: "approle",
+			cfg:  VaultConfig{Endpoint: "https://vault:8200", RoleID: "role", SecretID: "secret"},
+			ok:   true,
+		},
+		{
+			name: "approle without secret-id",
+			cfg:  VaultConfig{Endpoint: "https://vault:8200", AuthMethod: VaultAuthAppRole, RoleID: "role"},
+		},
+		{
+			name: "no endpoint",
+			cfg:  VaultConfig{RoleID: "role", SecretID: "secret"},
+		},
+		{
+			name: "kubernetes",
+			cfg:  VaultConfig{Endpoint: "https://vault:8200", AuthMethod: VaultAuthKubernetes, Role: "cke"},
+			ok:   true,
+		},
+		{
+			name: "kubernetes without role",
+			cfg:  VaultConfig{Endpoint: "https://vault:8200", AuthMethod: VaultAuthKubernetes},
+		},
+		{
+			name: "cert",
+			cfg:  VaultConfig{Endpoint: "https://vault:8200", AuthMethod: VaultAuthCert, ClientCert: cert, ClientKey: key},
+			ok:   true,
+		},
+		{
+			name: "cert with mismatched key",
+			cfg:  VaultConfig{Endpoint: "https://vault:8200", AuthMethod: VaultAuthCert, ClientCert: cert, ClientKey: otherKey},
+		},
+		{
+			name: "cert without key",
+			cfg:  VaultConfig{Endpoint: "https://vault:8200", AuthMethod: VaultAuthCert, ClientCert: cert},
+		},
+		{
+			name: "token",
+			cfg:  VaultConfig{Endpoint: "https://vault:8200", AuthMethod: VaultAuthToken, TokenFile: "/etc/cke/vault-token"},
+			ok:   true,
+		},
+		{
+			name: "token without file",
+			cfg:  VaultConfig{Endpoint: "https://vault:8200", AuthMethod: VaultAuthToken},
+		},
+		{
+			name: "unknown method",
+			cfg:  VaultConfig{Endpoint: "https://vault:8200", AuthMethod: "userpass"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tc.ok && err == nil {
+				t.Error("validation should fail")
+			}
+		})
+	}
+}
+
+func TestVaultClientToken(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/token/lookup-self" || r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"ttl": 3600, "renewable": true}}`))
+	}))
+	defer ts.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	err := os.WriteFile(tokenFile, []byte("s.token\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &VaultConfig{Endpoint: ts.URL, AuthMethod: VaultAuthToken, TokenFile: tokenFile}
+	client, secret, err := VaultClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Token() != "s.token" {
+		t.Errorf("unexpected token: %s", client.Token())
+	}
+	if secret.Auth.LeaseDuration != 3600 || !secret.Auth.Renewable {
+		t.Errorf("unexpected auth: %#v", secret.Auth)
+	}
+
+	err = os.WriteFile(tokenFile, []byte("s.revoked"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = VaultClient(cfg)
+	if err == nil {
+		t.Error("login with a revoked token should fail")
+	}
+}